@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+// openFile opens a WAV, FLAC, Ogg Vorbis or MP3 file as a source.
+func openFile(path string) (audio.File, error) {
+	return audio.Open(path)
+}
+
+// timelineFlags are the analysis settings of the offline commands.
+type timelineFlags struct {
+	hop          *time.Duration
+	analysisRate *uint
+}
+
+func addTimelineFlags(fs *flag.FlagSet) timelineFlags {
+	return timelineFlags{
+		hop:          fs.Duration("hop", timeline.DEFAULT_HOP, "time between analyses"),
+		analysisRate: fs.Uint("analysis-rate", timeline.DEFAULT_ANALYSIS_RATE, "sample rate the tuner analyzes at, 0 uses the file rate"),
+	}
+}
+
+func (f timelineFlags) config() timeline.Config {
+	c := timeline.DefaultConfig()
+	c.Hop = *f.hop
+	c.AnalysisRate = uint32(*f.analysisRate)
+	return c
+}
+
+// analyzeFile runs the tuner pipeline over a file.
+func analyzeFile(path string, config timeline.Config, fn func(entry timeline.Entry) error) error {
+	src, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	err = timeline.Analyze(src, config, func(entry timeline.Entry) error {
+		entry.File = path
+		return fn(entry)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func runAnalyze(args []string) error {
+	fs := newFlagSet("analyze")
+	settings := addTimelineFlags(fs)
+	formatName := fs.String("format", "csv", "output format: csv, json or jsonl")
+	outPath := fs.String("o", "", "write the timeline to this file instead of standard output")
+	if err := parseArgs(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	format, err := timeline.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := timeline.CreateWriter(out, format)
+	for _, path := range fs.Args() {
+		if err := analyzeFile(path, settings.config(), w.Write); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
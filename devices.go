@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/audio/portaudio"
+	"github.com/metalblueberry/bard/pkg/config"
+)
+
+func runDevices(args []string) error {
+	fs := newFlagSet("devices")
+	shared := addSharedFlags(fs)
+	asJSON := fs.Bool("json", false, "print the devices as JSON instead of a table")
+	test := fs.Bool("test", false, "play a tone on the output device, or meter the input device with -meter")
+	meter := fs.Bool("meter", false, "with -test, meter the input device instead of playing a tone")
+	tone := fs.Float64("tone", 440, "frequency of the test tone in Hz")
+	duration := fs.Duration("duration", 3*time.Second, "length of the test, 0 runs until interrupted")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+
+	return withPortAudio(func() error {
+		if *test {
+			ctx, cancel := interruptContext()
+			defer cancel()
+			if *duration > 0 {
+				ctx, cancel = context.WithTimeout(ctx, *duration)
+				defer cancel()
+			}
+			if *meter {
+				return meterInput(ctx, settings)
+			}
+			return playTone(ctx, settings, *tone)
+		}
+
+		reports, err := portaudio.Inspect()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			return enc.Encode(reports)
+		}
+		return printDevices(os.Stdout, reports)
+	})
+}
+
+// printDevices writes a table of devices for each host API.
+func printDevices(w io.Writer, reports []portaudio.HostApiReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, api := range reports {
+		mark := ""
+		if api.Default {
+			mark = " (default)"
+		}
+		fmt.Fprintf(tw, "host API %d: %s%s\n", api.Index, api.Name, mark)
+		fmt.Fprintln(tw, "\tINDEX\tNAME\tIN\tOUT\tRATE\tIN LATENCY\tOUT LATENCY\tIN RATES\tOUT RATES\t")
+		for _, d := range api.Devices {
+			name := d.Name
+			if d.DefaultInput {
+				name += " [default in]"
+			}
+			if d.DefaultOutput {
+				name += " [default out]"
+			}
+			fmt.Fprintf(tw, "\t%d\t%s\t%d\t%d\t%.0f\t%s\t%s\t%s\t%s\t\n",
+				d.Index, name, d.InputChannels, d.OutputChannels, d.DefaultSampleRate,
+				formatLatency(d.InputChannels, d.LowInputLatency, d.HighInputLatency),
+				formatLatency(d.OutputChannels, d.LowOutputLatency, d.HighOutputLatency),
+				formatRates(d.InputSampleRates), formatRates(d.OutputSampleRates))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// formatLatency shows the low and high latency in milliseconds.
+func formatLatency(channels int, low, high float64) string {
+	if channels < 1 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f-%.1fms", 1000*low, 1000*high)
+}
+
+// formatRates lists sample rates in kHz.
+func formatRates(rates []float64) string {
+	if len(rates) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(rates))
+	for i, rate := range rates {
+		parts[i] = strconv.FormatFloat(rate/1000, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// playTone plays a sine wave on the output device.
+func playTone(ctx context.Context, c *config.Config, frequency float64) error {
+	stream, err := openStream(c, streamOptions{output: true})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	fmt.Printf("playing %.1f Hz on %s\n", frequency, stream.OutputDevice().Name)
+	sine := audio.CreateSine(stream.Format(), 0, frequency, 0.2)
+	err = audio.Pump(ctx, sine, stream, 0, nil)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// meterInput prints the level of the input device until the context ends.
+func meterInput(ctx context.Context, c *config.Config) error {
+	stream, err := openStream(c, streamOptions{input: true})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	fmt.Printf("metering %s, %s\n", stream.InputDevice().Name, stream.Format())
+	const width = 50
+	blockFrames := int(stream.Format().SampleRate / 10)
+	err = audio.Pump(ctx, stream, nil, blockFrames, func(block []float32) {
+		level := audio.Measure(block)
+		rms := audio.Decibels(level.RMS)
+		peak := audio.Decibels(level.Peak)
+		// map -60 to 0 dBFS onto the bar
+		n := int((rms + 60) / 60 * width)
+		if n < 0 {
+			n = 0
+		} else if n > width {
+			n = width
+		}
+		fmt.Printf("\r[%-*s] rms %6.1f dBFS  peak %6.1f dBFS", width, strings.Repeat("#", n), rms, peak)
+	})
+	fmt.Println()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/filter"
+)
+
+func runEcho(args []string) error {
+	fs := newFlagSet("echo")
+	shared := addSharedFlags(fs)
+	hum := fs.Float64("hum", 0, "mains frequency to remove together with its harmonics (50 or 60), 0 disables")
+	noisePath := fs.String("noise", "", "noise profile removed before pitch detection, see visualize -calibrate")
+	analysisRate := fs.Uint("analysis-rate", 0, "sample rate the tuner analyzes at, 0 uses the device rate")
+	duration := fs.Duration("duration", 0, "stop after this long, 0 runs until interrupted")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+	profile, err := loadProfile(*noisePath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	return withPortAudio(func() error {
+		stream, err := openStream(settings, streamOptions{input: true, output: true, lowLatency: true})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		format := stream.Format()
+		var hf filter.Filter
+		if *hum > 0 {
+			hf, err = filter.CreateHumFilter(*hum, 8, 30, format.SampleRate)
+			if err != nil {
+				return err
+			}
+		}
+
+		denoiser, err := createDenoiser(profile, format)
+		if err != nil {
+			return err
+		}
+		live := newLiveTuner(*analysisRate, denoiser)
+		// runs on every block before it is played back
+		tap := func(block []float32) {
+			if hf != nil {
+				hf.Process(block)
+			}
+			live.Process(block, format)
+		}
+		go func() {
+			if err := audio.Pump(ctx, stream, stream, 0, tap); err != nil && ctx.Err() == nil {
+				log.Println(err)
+			}
+		}()
+		if *duration > 0 {
+			go func() {
+				<-time.After(*duration)
+				cancel()
+			}()
+		}
+		return live.Run(ctx, 100*time.Millisecond, printResult)
+	})
+}
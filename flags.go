@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	pa "github.com/gordonklaus/portaudio"
+	"github.com/metalblueberry/bard/pkg/audio/portaudio"
+	"github.com/metalblueberry/bard/pkg/config"
+)
+
+// sharedFlags are the device settings every command accepts. Values given
+// on the command line take precedence over the configuration file.
+type sharedFlags struct {
+	configPath string
+	device     string
+	overrides  config.Config
+}
+
+func addSharedFlags(fs *flag.FlagSet) *sharedFlags {
+	f := &sharedFlags{}
+	fs.StringVar(&f.configPath, "config", "", "configuration file, defaults to bard/config.json in the user config directory")
+	fs.StringVar(&f.overrides.HostAPI, "host-api", "", "host API by index or name, defaults to the system default")
+	fs.StringVar(&f.device, "device", "", "input and output device by index or name")
+	fs.StringVar(&f.overrides.Input, "input", "", "input device by index or name, overrides -device")
+	fs.StringVar(&f.overrides.Output, "output", "", "output device by index or name, overrides -device")
+	fs.Float64Var(&f.overrides.SampleRate, "rate", 0, "sample rate in Hz, 0 uses the device default")
+	fs.IntVar(&f.overrides.BufferFrames, "buffer", 0, "frames per driver buffer, 0 lets the driver choose")
+	return f
+}
+
+// load reads the configuration file and applies the command line.
+func (f *sharedFlags) load() (*config.Config, error) {
+	c, err := config.Load(f.configPath)
+	if err != nil {
+		return nil, err
+	}
+	c.Override(config.Config{Input: f.device, Output: f.device})
+	c.Override(f.overrides)
+	return c, nil
+}
+
+// save changes the configuration file and stores it, settings given on the
+// command line are not stored. It returns the path of the file.
+func (f *sharedFlags) save(change func(c *config.Config)) (string, error) {
+	path := f.configPath
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return "", err
+		}
+	}
+	c, err := config.Load(f.configPath)
+	if err != nil {
+		return "", err
+	}
+	change(c)
+	return path, c.Save(path)
+}
+
+// interruptContext returns a context cancelled on Ctrl+C.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// withPortAudio runs fn while PortAudio is initialized.
+func withPortAudio(fn func() error) error {
+	if err := pa.Initialize(); err != nil {
+		return fmt.Errorf("initializing PortAudio: %w", err)
+	}
+	defer pa.Terminate()
+	return fn()
+}
+
+// streamOptions selects which directions of a stream are opened.
+type streamOptions struct {
+	input      bool
+	output     bool
+	channels   int
+	lowLatency bool
+}
+
+// openStream opens and starts a stream on the configured devices.
+// PortAudio must be initialized.
+func openStream(c *config.Config, o streamOptions) (*portaudio.Stream, error) {
+	api, err := portaudio.SelectHostApi(c.HostAPI)
+	if err != nil {
+		return nil, err
+	}
+	var input, output *pa.DeviceInfo
+	if o.input {
+		input, err = portaudio.FindDevice(api, c.Input, portaudio.INPUT)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("input: %s", input.Name)
+	}
+	if o.output {
+		output, err = portaudio.FindDevice(api, c.Output, portaudio.OUTPUT)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("output: %s", output.Name)
+	}
+	if o.channels == 0 {
+		o.channels = 1
+	}
+
+	stream, err := portaudio.Open(portaudio.Config{
+		Input:           input,
+		Output:          output,
+		Channels:        o.channels,
+		SampleRate:      c.SampleRate,
+		FramesPerBuffer: c.BufferFrames,
+		LowLatency:      o.lowLatency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return stream, nil
+}
@@ -6,16 +6,20 @@ require (
 	github.com/andrepxx/go-dsp-guitar v1.7.2
 	github.com/gordonklaus/portaudio v0.0.0-20221027163845-7c3b689db3cc
 	github.com/hajimehoshi/ebiten/v2 v2.5.0
-	github.com/mjibson/go-dsp v0.0.0-20180508042940-11479a337f12
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/oggvorbis v1.0.5
+	github.com/mewkiz/flac v1.0.8
 	github.com/xthexder/go-jack v0.0.0-20220805234212-bc8604043aba
 	golang.org/x/image v0.6.0
 )
 
 require (
-	github.com/andrepxx/go-jack v0.0.0-20220929171107-71a712d2f786 // indirect
 	github.com/ebitengine/purego v0.3.0 // indirect
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20221017161538-93cebf72946b // indirect
+	github.com/icza/bitio v1.1.0 // indirect
 	github.com/jezek/xgb v1.1.0 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
+	github.com/mewkiz/pkg v0.0.0-20230226050401-4010bf0fec14 // indirect
 	golang.org/x/exp v0.0.0-20190731235908-ec7cb31e5a56 // indirect
 	golang.org/x/mobile v0.0.0-20230301163155-e0f57694e12c // indirect
 	golang.org/x/sync v0.1.0 // indirect
@@ -1,17 +1,133 @@
+// Command bard listens to an instrument and shows what is being played.
+//
+// Usage:
+//
+//	bard <command> [flags] [arguments]
+//
+// Run "bard help" for the list of commands.
 package main
 
 import (
-	"github.com/andrepxx/go-dsp-guitar/controller"
-	"github.com/andrepxx/go-dsp-guitar/hwio"
-	"github.com/andrepxx/go-dsp-guitar/path"
-	"github.com/andrepxx/go-dsp-guitar/tuner"
-	"github.com/andrepxx/go-dsp-guitar/wave"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
 )
 
+// command is a subcommand of bard.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) error
+}
+
+// errUsage reports invalid arguments after the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"devices", "", "list audio host APIs and devices", runDevices},
+		{"tune", "", "show the note played on the input device", runTune},
+		{"visualize", "", "open the live note visualizer", runVisualize},
+		{"echo", "", "monitor the input on the output while tuning", runEcho},
+		{"passthrough", "", "filter audio between JACK ports", runPassthrough},
+		{"analyze", "<file>...", "write a timeline of the pitch detected in audio files", runAnalyze},
+		{"record", "<file>", "record the input device to a wave file", runRecord},
+		{"transcribe", "<file>", "list the notes played in an audio file", runTranscribe},
+		{"practice", "<file>", "score playing along to a MIDI or MusicXML melody", runPractice},
+		{"help", "[command]", "show help for a command", runHelp},
+	}
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "usage: bard <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return nil
+	}
+	c := findCommand(args[0])
+	if c == nil || c.name == "help" {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return c.run([]string{"-h"})
+}
+
+// newFlagSet creates the flags of a command, printing its usage on errors.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		c := findCommand(name)
+		w := fs.Output()
+		fmt.Fprintf(w, "usage: bard %s [flags] %s\n\n%s\n\nflags:\n", name, c.args, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of a command and checks that between min and
+// max arguments remain, a negative max allows any number.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fmt.Fprintf(fs.Output(), "unexpected number of arguments: %q\n", fs.Args())
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
 func main() {
-	tn := tuner.Create()
-	tn.Analyze()
-	// tn.Process()
-	ctrl := controller.CreateController()
-	ctrl.Operate(1)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	switch name {
+	case "-h", "-help", "--help":
+		name = "help"
+	}
+
+	c := findCommand(name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "bard: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	err := c.run(os.Args[2:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "bard %s: %s\n", name, err)
+		os.Exit(1)
+	}
 }
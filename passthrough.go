@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/audio/jack"
+	"github.com/metalblueberry/bard/pkg/filter"
+)
+
+// newEqualizer builds the hum removal and shelving EQ chain for one channel.
+func newEqualizer(sampleRate, hum, bass, treble float64) (filter.Filter, error) {
+	stages := []filter.Filter{}
+	if hum > 0 {
+		notches, err := filter.CreateHumFilter(hum, 8, 30, sampleRate)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, notches)
+	}
+	if bass != 0 {
+		shelf, err := filter.CreateLowShelf(200, 1, bass, sampleRate)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, shelf)
+	}
+	if treble != 0 {
+		shelf, err := filter.CreateHighShelf(4000, 1, treble, sampleRate)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, shelf)
+	}
+	return filter.CreateCascade(stages...), nil
+}
+
+func runPassthrough(args []string) error {
+	fs := newFlagSet("passthrough")
+	name := fs.String("name", "bard", "JACK client name")
+	channels := fs.Int("channels", 2, "number of input and output ports")
+	hum := fs.Float64("hum", 0, "mains frequency to remove together with its harmonics (50 or 60), 0 disables")
+	bass := fs.Float64("bass", 0, "low shelf gain in dB below 200Hz")
+	treble := fs.Float64("treble", 0, "high shelf gain in dB above 4kHz")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := jack.Open(jack.Config{
+		Name:     *name,
+		Channels: *channels,
+		Input:    true,
+		Output:   true,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// one filter per channel, nil for a plain passthrough
+	var filters []filter.Filter
+	if *hum > 0 || *bass != 0 || *treble != 0 {
+		for i := 0; i < *channels; i++ {
+			f, err := newEqualizer(client.Format().SampleRate, *hum, *bass, *treble)
+			if err != nil {
+				return fmt.Errorf("creating filter: %w", err)
+			}
+			filters = append(filters, f)
+		}
+	}
+	process := func(block []float32) {
+		if filters == nil {
+			return
+		}
+		for i := range block {
+			f := filters[i%*channels]
+			block[i] = float32(f.ProcessSample(float64(block[i])))
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	go func() {
+		select {
+		case <-client.Done():
+			log.Println("JACK server shut down")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := client.Start(); err != nil {
+		return err
+	}
+	log.Println(client.Name())
+
+	err = audio.Pump(ctx, client, client, 0, process)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
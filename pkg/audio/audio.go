@@ -0,0 +1,201 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_BLOCK_FRAMES = 512
+)
+
+/*
+ * Data structure describing the layout of an audio stream.
+ *
+ * Samples of multi-channel streams are interleaved.
+ */
+type Format struct {
+	SampleRate float64
+	Channels   int
+}
+
+/*
+ * Interface implemented by everything that produces audio.
+ *
+ * Read fills buf with interleaved samples and returns the number of samples
+ * read, which is always a multiple of the channel count. Finite sources
+ * return io.EOF once all samples were read.
+ */
+type Source interface {
+	Format() Format
+	Read(buf []float32) (int, error)
+	Close() error
+}
+
+/*
+ * Interface implemented by everything that consumes audio.
+ *
+ * Write takes interleaved samples and returns the number of samples
+ * written.
+ */
+type Sink interface {
+	Format() Format
+	Write(buf []float32) (int, error)
+	Close() error
+}
+
+/*
+ * Returns the number of samples in a certain number of frames.
+ */
+func (this Format) Samples(frames int) int {
+	return frames * this.Channels
+}
+
+/*
+ * Returns the duration of a certain number of frames in seconds.
+ */
+func (this Format) Seconds(frames int) float64 {
+	return float64(frames) / this.SampleRate
+}
+
+/*
+ * Returns a textual representation of the format.
+ */
+func (this Format) String() string {
+	return fmt.Sprintf("%.0f Hz, %d channel(s)", this.SampleRate, this.Channels)
+}
+
+/*
+ * Reads exactly len(buf) samples from a source, unless the source ends
+ * early, in which case the number of samples read is returned together with
+ * io.EOF.
+ */
+func ReadFull(src Source, buf []float32) (int, error) {
+	total := 0
+
+	/*
+	 * Keep reading until the buffer is full.
+	 */
+	for total < len(buf) {
+		n, err := src.Read(buf[total:])
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+
+	}
+
+	return total, nil
+}
+
+/*
+ * Mixes interleaved samples down to a single channel and appends the result
+ * to out.
+ */
+func Downmix(buf []float32, channels int, out []float64) []float64 {
+
+	/*
+	 * Mono signals only need to be converted.
+	 */
+	if channels <= 1 {
+
+		for _, x := range buf {
+			out = append(out, float64(x))
+		}
+
+		return out
+	}
+
+	scale := 1.0 / float64(channels)
+	numFrames := len(buf) / channels
+
+	for frame := 0; frame < numFrames; frame++ {
+		offset := frame * channels
+		sum := 0.0
+
+		for _, x := range buf[offset : offset+channels] {
+			sum += float64(x)
+		}
+
+		out = append(out, scale*sum)
+	}
+
+	return out
+}
+
+/*
+ * Copies audio from a source to a sink until the source ends or the context
+ * is cancelled.
+ *
+ * If tap is not nil, it is called with every block before the block is
+ * written, so analyzers can observe the stream. The sink may be nil, in
+ * which case the stream is only tapped. Reaching the end of the source is
+ * not considered an error.
+ */
+func Pump(ctx context.Context, src Source, dst Sink, blockFrames int, tap func(block []float32)) error {
+	format := src.Format()
+
+	/*
+	 * Apply default block size.
+	 */
+	if blockFrames <= 0 {
+		blockFrames = DEFAULT_BLOCK_FRAMES
+	}
+
+	/*
+	 * Sinks must accept the format of the source.
+	 */
+	if dst != nil && dst.Format().Channels != format.Channels {
+		return fmt.Errorf("Sink has %d channels, source has %d.", dst.Format().Channels, format.Channels)
+	}
+
+	buf := make([]float32, format.Samples(blockFrames))
+
+	for {
+
+		/*
+		 * Check for cancellation.
+		 */
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := src.Read(buf)
+		block := buf[0:n]
+
+		/*
+		 * Forward what was read before handling errors.
+		 */
+		if n > 0 {
+
+			if tap != nil {
+				tap(block)
+			}
+
+			if dst != nil {
+				_, errWrite := dst.Write(block)
+
+				if errWrite != nil {
+					msg := errWrite.Error()
+					return fmt.Errorf("Failed to write to sink: %s", msg)
+				}
+
+			}
+
+		}
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to read from source: %s", msg)
+		}
+
+	}
+
+}
@@ -0,0 +1,705 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+/*
+ * Perform a unit test on the ring buffer.
+ */
+func TestRing(t *testing.T) {
+	format := Format{SampleRate: 48000.0, Channels: 2}
+	r := CreateRing(format, 4)
+	n := r.TryWrite([]float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+
+	/*
+	 * Only whole frames that fit are written.
+	 */
+	if n != 8 {
+		t.Errorf("Ring accepted %d samples, expected %d.", n, 8)
+	}
+
+	if r.Overruns() != 2 {
+		t.Errorf("Ring counted %d overruns, expected %d.", r.Overruns(), 2)
+	}
+
+	buf := make([]float32, 5)
+	n = r.TryRead(buf)
+
+	/*
+	 * Reads are aligned to frames.
+	 */
+	if n != 4 {
+		t.Errorf("Ring returned %d samples, expected %d.", n, 4)
+	}
+
+	for i, x := range buf[0:n] {
+
+		if x != float32(i+1) {
+			t.Errorf("Sample %d is %f, expected %f.", i, x, float32(i+1))
+		}
+
+	}
+
+	r.TryWrite([]float32{11, 12})
+	r.Close()
+	total := []float32{}
+
+	for {
+		n, err := r.Read(buf)
+		total = append(total, buf[0:n]...)
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to read from ring: %s", msg)
+		}
+
+	}
+
+	expected := []float32{5, 6, 7, 8, 11, 12}
+
+	/*
+	 * Data written before closing is still delivered.
+	 */
+	if len(total) != len(expected) {
+		t.Fatalf("Drained %d samples, expected %d.", len(total), len(expected))
+	}
+
+	for i, x := range expected {
+
+		if total[i] != x {
+			t.Errorf("Drained sample %d is %f, expected %f.", i, total[i], x)
+		}
+
+	}
+
+}
+
+/*
+ * Check that the ring passes data between goroutines without loss.
+ */
+func TestRingConcurrent(t *testing.T) {
+	format := Format{SampleRate: 48000.0, Channels: 1}
+	src := CreateSine(format, 10000, 440.0, 0.5)
+	r := CreateRing(format, 100)
+
+	go func() {
+		Pump(context.Background(), src, r, 37, nil)
+		r.Close()
+	}()
+
+	expected := CreateSine(format, 10000, 440.0, 0.5)
+	got := make([]float32, 10000)
+	want := make([]float32, 10000)
+	n, _ := ReadFull(r, got)
+	ReadFull(expected, want)
+
+	/*
+	 * Check that every sample arrived in order.
+	 */
+	if n != len(want) {
+		t.Fatalf("Received %d samples, expected %d.", n, len(want))
+	}
+
+	for i := range want {
+
+		if got[i] != want[i] {
+			t.Fatalf("Sample %d is %f, expected %f.", i, got[i], want[i])
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on synthetic sources.
+ */
+func TestGenerator(t *testing.T) {
+	format := Format{SampleRate: 8000.0, Channels: 2}
+	src := CreateSine(format, 1000, 1000.0, 0.5)
+	mono := []float64{}
+	buf := make([]float32, 256)
+	numSamples := 0
+
+	for {
+		n, err := src.Read(buf)
+		numSamples += n
+		mono = Downmix(buf[0:n], format.Channels, mono)
+
+		if err == io.EOF {
+			break
+		}
+
+	}
+
+	/*
+	 * Check the length of the signal.
+	 */
+	if numSamples != 2000 {
+		t.Errorf("Generator produced %d samples, expected %d.", numSamples, 2000)
+	}
+
+	for i, x := range mono {
+		expected := 0.5 * math.Sin(2.0*math.Pi*1000.0*float64(i)/8000.0)
+
+		if math.Abs(x-expected) > 1e-6 {
+			t.Fatalf("Frame %d is %f, expected %f.", i, x, expected)
+		}
+
+	}
+
+}
+
+/*
+ * Check that a wave file can be written and read back.
+ */
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sine.wav")
+	format := Format{SampleRate: 44100.0, Channels: 2}
+	sink, err := CreateFile(path, format)
+
+	/*
+	 * Check if sink could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create file: %s", msg)
+	}
+
+	err = Pump(context.Background(), CreateSine(format, 4410, 440.0, 0.5), sink, 0, nil)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write file: %s", msg)
+	}
+
+	err = sink.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to close file: %s", msg)
+	}
+
+	src, err := OpenFile(path)
+
+	/*
+	 * Check if source could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open file: %s", msg)
+	}
+
+	defer src.Close()
+
+	/*
+	 * Check the format of the file.
+	 */
+	if src.Format() != format {
+		t.Errorf("File has format %s, expected %s.", src.Format(), format)
+	}
+
+	got := make([]float32, 2*4410)
+	want := make([]float32, 2*4410)
+	n, _ := ReadFull(src, got)
+	ReadFull(CreateSine(format, 4410, 440.0, 0.5), want)
+
+	if n != len(want) {
+		t.Fatalf("Read %d samples, expected %d.", n, len(want))
+	}
+
+	/*
+	 * Samples are quantized to 16 bits.
+	 */
+	for i := range want {
+		diff := math.Abs(float64(got[i] - want[i]))
+
+		if diff > 1e-4 {
+			t.Fatalf("Sample %d is %f, expected %f.", i, got[i], want[i])
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on level measurement.
+ */
+func TestMeasure(t *testing.T) {
+	format := Format{SampleRate: 48000.0, Channels: 1}
+	buf := make([]float32, 48000)
+	ReadFull(CreateSine(format, 48000, 1000.0, 0.5), buf)
+	level := Measure(buf)
+	expectedRMS := 0.5 / math.Sqrt(2.0)
+
+	/*
+	 * Check the level of a sine wave.
+	 */
+	if math.Abs(level.RMS-expectedRMS) > 1e-3 {
+		t.Errorf("Sine has RMS level %f, expected %f.", level.RMS, expectedRMS)
+	}
+
+	if math.Abs(level.Peak-0.5) > 1e-3 {
+		t.Errorf("Sine has peak level %f, expected %f.", level.Peak, 0.5)
+	}
+
+	db := Decibels(0.5)
+
+	if math.Abs(db+6.0206) > 1e-3 {
+		t.Errorf("Amplitude 0.5 is %f dBFS, expected %f.", db, -6.0206)
+	}
+
+	/*
+	 * Silence is clamped.
+	 */
+	if Decibels(0.0) != SILENCE_DECIBELS {
+		t.Errorf("Silence is %f dBFS, expected %f.", Decibels(0.0), SILENCE_DECIBELS)
+	}
+
+}
+
+/*
+ * Check that audio file types are detected.
+ */
+func TestDetect(t *testing.T) {
+
+	/*
+	 * Headers, file names and expected types.
+	 */
+	tests := []struct {
+		header   string
+		path     string
+		expected int
+	}{
+		{"RIFF\x00\x00\x00\x00WAVE", "a.mp3", FILE_WAVE},
+		{"fLaC\x00\x00\x00\x22", "a", FILE_FLAC},
+		{"OggS\x00\x02", "a", FILE_VORBIS},
+		{"ID3\x04\x00", "a.mp3", FILE_MP3},
+		{"ID3\x04\x00", "a.FLAC", FILE_FLAC},
+		{"\xff\xfb\x90\xc0", "a", FILE_MP3},
+		{"", "a.ogg", FILE_VORBIS},
+		{"", "a.wav", FILE_WAVE},
+		{"text", "a.txt", FILE_UNKNOWN},
+	}
+
+	for _, test := range tests {
+		result := detect([]byte(test.header), test.path)
+
+		if result != test.expected {
+			t.Errorf("File '%s' with header %q detected as %d, expected %d.", test.path, test.header, result, test.expected)
+		}
+
+	}
+
+}
+
+/*
+ * Check that FLAC files are decoded.
+ */
+func TestFlac(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ramp.flac")
+	file, err := os.Create(path)
+
+	/*
+	 * Check if file could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create file: %s", msg)
+	}
+
+	info := &meta.StreamInfo{
+		BlockSizeMin:  256,
+		BlockSizeMax:  256,
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+	}
+
+	enc, err := flac.NewEncoder(file, info)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create encoder: %s", msg)
+	}
+
+	/*
+	 * Encode a ramp on the left and its inverse on the right channel.
+	 */
+	for f := 0; f < 4; f++ {
+		left := make([]int32, 256)
+		right := make([]int32, 256)
+
+		for i := range left {
+			left[i] = int32(30 * (256*f + i))
+			right[i] = -left[i]
+		}
+
+		fr := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         256,
+				SampleRate:        44100,
+				Channels:          frame.ChannelsLR,
+				BitsPerSample:     16,
+			},
+			Subframes: []*frame.Subframe{
+				&frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: 256},
+				&frame.Subframe{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: 256},
+			},
+		}
+
+		err = enc.WriteFrame(fr)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to encode frame %d: %s", f, msg)
+		}
+
+	}
+
+	enc.Close()
+	src, err := Open(path)
+
+	/*
+	 * Check if source could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open file: %s", msg)
+	}
+
+	defer src.Close()
+	expectedFormat := Format{SampleRate: 44100.0, Channels: 2}
+
+	if src.Format() != expectedFormat {
+		t.Errorf("File has format %s, expected %s.", src.Format(), expectedFormat)
+	}
+
+	if src.Frames() != 1024 {
+		t.Errorf("File has %d frames, expected %d.", src.Frames(), 1024)
+	}
+
+	/*
+	 * Read twice to check rewinding.
+	 */
+	for pass := 0; pass < 2; pass++ {
+		buf := make([]float32, 2*1024+10)
+		n, _ := ReadFull(src, buf)
+
+		if n != 2*1024 {
+			t.Fatalf("Read %d samples, expected %d.", n, 2*1024)
+		}
+
+		for i := 0; i < 1024; i++ {
+			expected := float32(30*i) / 32768.0
+
+			if buf[2*i] != expected || buf[2*i+1] != -expected {
+				t.Fatalf("Frame %d is (%f, %f), expected (%f, %f).", i, buf[2*i], buf[2*i+1], expected, -expected)
+			}
+
+		}
+
+		err = src.Rewind()
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to rewind: %s", msg)
+		}
+
+	}
+
+	err = src.SeekFrame(300)
+
+	/*
+	 * Check seeking into the middle of a FLAC frame.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to seek: %s", msg)
+	}
+
+	buf := make([]float32, 2)
+
+	/*
+	 * Seek forward, backward and into the first FLAC frame.
+	 */
+	for _, position := range []int64{300, 700, 10} {
+		err = src.SeekFrame(position)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to seek to %d: %s", position, msg)
+		}
+
+		ReadFull(src, buf)
+		expected := float32(30*position) / 32768.0
+
+		if buf[0] != expected {
+			t.Errorf("Frame %d is %f after seeking, expected %f.", position, buf[0], expected)
+		}
+
+	}
+
+	err = src.SeekFrame(2000)
+
+	/*
+	 * Seeking beyond the end leaves the stream at its end.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to seek beyond the end: %s", msg)
+	}
+
+	if n, _ := ReadFull(src, buf); n != 0 {
+		t.Errorf("Read %d samples beyond the end, expected %d.", n, 0)
+	}
+
+}
+
+/*
+ * Check that Ogg Vorbis files are decoded.
+ *
+ * The file holds a second of mono tones between silences and comes from
+ * the test data of the decoder.
+ */
+func TestVorbis(t *testing.T) {
+	src, err := Open(filepath.Join("testdata", "tone.ogg"))
+
+	/*
+	 * Check if source could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open file: %s", msg)
+	}
+
+	defer src.Close()
+	expectedFormat := Format{SampleRate: 44100.0, Channels: 1}
+
+	if src.Format() != expectedFormat {
+		t.Errorf("File has format %s, expected %s.", src.Format(), expectedFormat)
+	}
+
+	if src.Frames() != 44100 {
+		t.Errorf("File has %d frames, expected %d.", src.Frames(), 44100)
+	}
+
+	/*
+	 * Decoding the whole file gives the samples expected after seeking.
+	 */
+	decoded := make([]float32, 44100+10)
+	n, _ := ReadFull(src, decoded)
+
+	if n != 44100 {
+		t.Fatalf("Read %d samples, expected %d.", n, 44100)
+	}
+
+	buf := make([]float32, 16)
+
+	/*
+	 * Seek forward, backward, into the first packet and to the last frames.
+	 */
+	for _, position := range []int64{25000, 1000, 10, 44090} {
+		err = src.SeekFrame(position)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to seek to %d: %s", position, msg)
+		}
+
+		expected := decoded[position:44100]
+
+		if len(expected) > len(buf) {
+			expected = expected[0:len(buf)]
+		}
+
+		n, _ := ReadFull(src, buf)
+
+		if n != len(expected) {
+			t.Errorf("Read %d samples after seeking to %d, expected %d.", n, position, len(expected))
+			continue
+		}
+
+		for i, x := range expected {
+
+			if math.Abs(float64(buf[i]-x)) > 1e-6 {
+				t.Errorf("Frame %d is %f after seeking, expected %f.", position+int64(i), buf[i], x)
+				break
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Check that MP3 files are decoded.
+ */
+func TestMp3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silence.mp3")
+
+	/*
+	 * An MPEG-1 layer III frame at 128 kbit/s and 44.1 kHz has 417 bytes.
+	 * Side information and main data of zero decode to silence.
+	 */
+	mp3Frame := make([]byte, 417)
+	copy(mp3Frame, []byte{0xff, 0xfb, 0x90, 0xc0})
+	data := bytes.Repeat(mp3Frame, 10)
+	err := os.WriteFile(path, data, 0644)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write file: %s", msg)
+	}
+
+	src, err := Open(path)
+
+	/*
+	 * Check if source could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open file: %s", msg)
+	}
+
+	defer src.Close()
+	expectedFormat := Format{SampleRate: 44100.0, Channels: 2}
+
+	if src.Format() != expectedFormat {
+		t.Errorf("File has format %s, expected %s.", src.Format(), expectedFormat)
+	}
+
+	if src.Frames() != 10*1152 {
+		t.Errorf("File has %d frames, expected %d.", src.Frames(), 10*1152)
+	}
+
+	buf := make([]float32, 2*src.Frames()+10)
+	n, _ := ReadFull(src, buf)
+
+	if n != 2*10*1152 {
+		t.Errorf("Read %d samples, expected %d.", n, 2*10*1152)
+	}
+
+	for i, x := range buf[0:n] {
+
+		if x != 0.0 {
+			t.Fatalf("Sample %d is %f, expected silence.", i, x)
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on the player.
+ */
+func TestPlayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sine.wav")
+	format := Format{SampleRate: 8000.0, Channels: 1}
+	sink, err := CreateFile(path, format)
+
+	/*
+	 * Check if sink could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create file: %s", msg)
+	}
+
+	Pump(context.Background(), CreateSine(format, 8000, 440.0, 0.5), sink, 0, nil)
+	sink.Close()
+	file, err := Open(path)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open file: %s", msg)
+	}
+
+	player, err := CreatePlayer(file)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create player: %s", msg)
+	}
+
+	defer player.Close()
+
+	if player.Duration() != 1.0 {
+		t.Errorf("Player has duration %f s, expected %f s.", player.Duration(), 1.0)
+	}
+
+	err = player.SetSpeed(2.0)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to set speed: %s", msg)
+	}
+
+	buf := make([]float32, 2000)
+	n, _ := ReadFull(player, buf)
+
+	/*
+	 * Two thousand frames at double speed cover half a second.
+	 */
+	if n != 2000 || math.Abs(player.Position()-0.5) > 1e-9 {
+		t.Errorf("Player is at %f s after %d samples, expected %f s after %d samples.", player.Position(), n, 0.5, 2000)
+	}
+
+	err = player.Seek(0.25)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to seek: %s", msg)
+	}
+
+	if player.Position() != 0.25 {
+		t.Errorf("Player is at %f s after seeking, expected %f s.", player.Position(), 0.25)
+	}
+
+	player.SetPaused(true)
+	n, err = player.Read(buf)
+
+	/*
+	 * Paused players produce nothing.
+	 */
+	if n != 0 || err != nil {
+		t.Errorf("Paused player returned %d samples and error %v, expected none.", n, err)
+	}
+
+	player.SetPaused(false)
+
+	/*
+	 * Play until the end is reached.
+	 */
+	for i := 0; i < 100 && !player.Paused(); i++ {
+		player.Read(buf)
+	}
+
+	if !player.Paused() || player.Position() != 1.0 {
+		t.Errorf("Player is at %f s, paused: %t, expected to pause at %f s.", player.Position(), player.Paused(), 1.0)
+	}
+
+	player.SetPaused(false)
+
+	/*
+	 * Resuming at the end starts over.
+	 */
+	if player.Paused() || player.Position() != 0.0 {
+		t.Errorf("Player is at %f s, paused: %t, expected to play from the start.", player.Position(), player.Paused())
+	}
+
+}
@@ -0,0 +1,217 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/metalblueberry/bard/pkg/wav"
+)
+
+/*
+ * Data structure representing a source reading from a wave file.
+ *
+ * Samples are streamed from disk, so files of any length can be read.
+ */
+type FileSource struct {
+	format Format
+	reader *wav.Reader
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *FileSource) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames in the file.
+ */
+func (this *FileSource) Frames() int64 {
+	return this.reader.Frames()
+}
+
+/*
+ * Returns the cue points of the file.
+ */
+func (this *FileSource) Cues() []wav.Cue {
+	return this.reader.Cues()
+}
+
+/*
+ * Reads interleaved samples from the file.
+ */
+func (this *FileSource) Read(buf []float32) (int, error) {
+	return this.reader.Read(buf)
+}
+
+/*
+ * Rewinds the file to its beginning.
+ */
+func (this *FileSource) Rewind() error {
+	return this.reader.SeekFrame(0)
+}
+
+/*
+ * Moves the read position to a certain frame.
+ */
+func (this *FileSource) SeekFrame(frame int64) error {
+	return this.reader.SeekFrame(frame)
+}
+
+/*
+ * Closes the file.
+ */
+func (this *FileSource) Close() error {
+	return this.reader.Close()
+}
+
+/*
+ * Opens a wave file as a source.
+ */
+func OpenFile(path string) (*FileSource, error) {
+	reader, err := wav.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		format := reader.Format()
+
+		/*
+		 * Create data structure for a file source.
+		 */
+		src := FileSource{
+			format: Format{
+				SampleRate: float64(format.SampleRate),
+				Channels:   int(format.Channels),
+			},
+			reader: reader,
+		}
+
+		return &src, nil
+	}
+
+}
+
+/*
+ * Data structure representing a sink writing to a wave file.
+ *
+ * Samples are written as they arrive, the header is completed when the
+ * sink is closed.
+ */
+type FileSink struct {
+	format Format
+	path   string
+	writer *wav.Writer
+}
+
+/*
+ * Returns the format written to the file.
+ */
+func (this *FileSink) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames written so far.
+ */
+func (this *FileSink) Frames() int64 {
+	return this.writer.Frames()
+}
+
+/*
+ * Writes interleaved samples.
+ */
+func (this *FileSink) Write(buf []float32) (int, error) {
+	numChannels := this.format.Channels
+	n := len(buf) - (len(buf) % numChannels)
+	err := this.writer.Write(buf[0:n])
+
+	/*
+	 * Check if samples could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return 0, fmt.Errorf("Failed to write to wave file '%s': %s", this.path, msg)
+	}
+
+	return n, nil
+}
+
+/*
+ * Adds a labelled cue point at the current position.
+ */
+func (this *FileSink) Mark(label string) {
+	position := uint32(this.writer.Frames())
+	this.writer.AddCue(position, label)
+}
+
+/*
+ * Completes and closes the file.
+ */
+func (this *FileSink) Close() error {
+	err := this.writer.Close()
+
+	/*
+	 * Check if file could be completed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to complete wave file '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Creates a sink writing 16-bit PCM samples to a wave file.
+ */
+func CreateFile(path string, format Format) (*FileSink, error) {
+	return CreateFileEncoded(path, format, wav.PCM_16)
+}
+
+/*
+ * Creates a sink writing samples of a certain encoding to a wave file.
+ */
+func CreateFileEncoded(path string, format Format, encoding wav.Encoding) (*FileSink, error) {
+
+	/*
+	 * Check the format.
+	 */
+	if format.Channels < 1 || format.Channels > math.MaxUint16 {
+		return nil, fmt.Errorf("Wave file must have between 1 and %d channels, requested %d.", math.MaxUint16, format.Channels)
+	} else if format.SampleRate <= 0.0 || format.SampleRate > math.MaxUint32 {
+		return nil, fmt.Errorf("Sample rate must be positive, is %f.", format.SampleRate)
+	} else {
+
+		wavFormat := wav.Format{
+			Encoding:   encoding,
+			Channels:   uint16(format.Channels),
+			SampleRate: uint32(math.Round(format.SampleRate)),
+		}
+
+		writer, err := wav.Create(path, wavFormat)
+
+		/*
+		 * Check if file could be created.
+		 */
+		if err != nil {
+			return nil, err
+		}
+
+		/*
+		 * Create data structure for a file sink.
+		 */
+		sink := FileSink{
+			format: format,
+			path:   path,
+			writer: writer,
+		}
+
+		return &sink, nil
+	}
+
+}
@@ -0,0 +1,299 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mewkiz/flac"
+)
+
+/*
+ * Data structure representing a file read through a buffer, which can
+ * still seek.
+ */
+type bufferedFile struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+/*
+ * Reads from the buffer.
+ */
+func (this *bufferedFile) Read(buf []byte) (int, error) {
+	return this.reader.Read(buf)
+}
+
+/*
+ * Moves the read position, discarding the buffer unless the position is
+ * only queried.
+ */
+func (this *bufferedFile) Seek(offset int64, whence int) (int64, error) {
+	buffered := int64(this.reader.Buffered())
+
+	/*
+	 * The file is ahead of the reader by the buffered bytes.
+	 */
+	if whence == io.SeekCurrent && offset == 0 {
+		position, err := this.file.Seek(0, io.SeekCurrent)
+		return position - buffered, err
+	} else if whence == io.SeekCurrent {
+		offset -= buffered
+	}
+
+	position, err := this.file.Seek(offset, whence)
+	this.reader.Reset(this.file)
+	return position, err
+}
+
+/*
+ * Data structure representing a source decoding a FLAC file.
+ */
+type FlacSource struct {
+	format  Format
+	path    string
+	file    *bufferedFile
+	stream  *flac.Stream
+	scale   float32
+	pending []float32
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *FlacSource) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames in the file, or zero if it is unknown.
+ */
+func (this *FlacSource) Frames() int64 {
+	return int64(this.stream.Info.NSamples)
+}
+
+/*
+ * Decodes the next FLAC frame into the pending samples.
+ */
+func (this *FlacSource) decode() error {
+	frame, err := this.stream.ParseNext()
+
+	/*
+	 * Pass the end of the stream on unchanged.
+	 */
+	if err == io.EOF {
+		return err
+	} else if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to decode FLAC file '%s': %s", this.path, msg)
+	}
+
+	numChannels := len(frame.Subframes)
+	numFrames := int(frame.BlockSize)
+	this.pending = this.pending[:0]
+
+	for i := 0; i < numFrames; i++ {
+
+		for c := 0; c < numChannels; c++ {
+			sample := float32(frame.Subframes[c].Samples[i]) * this.scale
+			this.pending = append(this.pending, sample)
+		}
+
+	}
+
+	return nil
+}
+
+/*
+ * Reads interleaved samples from the file.
+ */
+func (this *FlacSource) Read(buf []float32) (int, error) {
+	numChannels := this.format.Channels
+	numSamples := len(buf) - (len(buf) % numChannels)
+
+	/*
+	 * Decode frames until samples are available.
+	 */
+	for len(this.pending) == 0 {
+		err := this.decode()
+
+		if err != nil {
+			return 0, err
+		}
+
+	}
+
+	n := copy(buf[0:numSamples], this.pending)
+	this.pending = this.pending[n:]
+	return n, nil
+}
+
+/*
+ * Opens a FLAC stream at the beginning of the file.
+ */
+func (this *FlacSource) open() error {
+	_, err := this.file.Seek(0, io.SeekStart)
+
+	/*
+	 * Check if file could be rewound.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to rewind FLAC file '%s': %s", this.path, msg)
+	}
+
+	stream, err := flac.NewSeek(this.file)
+
+	/*
+	 * Check if stream could be parsed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to parse FLAC file '%s': %s", this.path, msg)
+	}
+
+	this.stream = stream
+	this.pending = nil
+	return nil
+}
+
+/*
+ * Rewinds the file to its beginning.
+ */
+func (this *FlacSource) Rewind() error {
+	return this.SeekFrame(0)
+}
+
+/*
+ * Decodes and drops a number of samples following the read position.
+ */
+func (this *FlacSource) skip(samples int64) error {
+
+	for samples > 0 {
+		numPending := int64(len(this.pending))
+
+		/*
+		 * Drop the pending samples, or decode more.
+		 */
+		if samples < numPending {
+			this.pending = this.pending[samples:]
+			samples = 0
+		} else if numPending > 0 {
+			this.pending = this.pending[:0]
+			samples -= numPending
+		} else {
+			err := this.decode()
+
+			/*
+			 * Skipping beyond the end leaves the stream at its end.
+			 */
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+		}
+
+	}
+
+	return nil
+}
+
+/*
+ * Moves the read position to a certain frame.
+ *
+ * The stream seeks to the FLAC frame containing the position using its seek
+ * table, which is built on the first seek if the file has none, and decodes
+ * the rest of the way. Streams which cannot seek are decoded from their
+ * beginning up to the position.
+ */
+func (this *FlacSource) SeekFrame(frame int64) error {
+	numChannels := int64(this.format.Channels)
+	numFrames := this.Frames()
+
+	/*
+	 * Seeking beyond the end leaves the stream at its end.
+	 */
+	if frame < 0 {
+		frame = 0
+	} else if numFrames > 0 && frame > numFrames {
+		frame = numFrames
+	}
+
+	start, err := this.stream.Seek(uint64(frame))
+
+	/*
+	 * Fall back to decoding from the beginning without a seek table, and
+	 * stay at the end of streams of unknown length.
+	 */
+	if err == flac.ErrNoSeektable {
+		err = this.open()
+
+		if err != nil {
+			return err
+		}
+
+		start = 0
+	} else if err == io.EOF {
+		this.pending = nil
+		return nil
+	} else if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek in FLAC file '%s': %s", this.path, msg)
+	}
+
+	this.pending = nil
+	return this.skip((frame - int64(start)) * numChannels)
+}
+
+/*
+ * Closes the file.
+ */
+func (this *FlacSource) Close() error {
+	return this.file.file.Close()
+}
+
+/*
+ * Opens a FLAC file as a source.
+ */
+func OpenFlac(path string) (*FlacSource, error) {
+	file, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open FLAC file '%s': %s", path, msg)
+	}
+
+	/*
+	 * Create data structure for a FLAC source.
+	 */
+	src := &FlacSource{
+		path: path,
+		file: &bufferedFile{
+			file:   file,
+			reader: bufio.NewReader(file),
+		},
+	}
+
+	err = src.open()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	info := src.stream.Info
+	src.scale = 1.0 / float32(int64(1)<<(info.BitsPerSample-1))
+
+	src.format = Format{
+		SampleRate: float64(info.SampleRate),
+		Channels:   int(info.NChannels),
+	}
+
+	return src, nil
+}
@@ -0,0 +1,333 @@
+package jack
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/xthexder/go-jack"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_BUFFER_DURATION = 500 * time.Millisecond
+)
+
+/*
+ * Data structure describing a JACK client.
+ *
+ * One input and one output port is registered per channel, named in_N and
+ * out_N. Buffer is the amount of audio held between the process callback
+ * and the Read and Write methods.
+ */
+type Config struct {
+	Name     string
+	Channels int
+	Input    bool
+	Output   bool
+	Buffer   time.Duration
+}
+
+/*
+ * Data structure representing a JACK client.
+ *
+ * The client is a source reading from its input ports and a sink writing
+ * to its output ports. Samples are interleaved across ports.
+ */
+type Client struct {
+	client   *jack.Client
+	format   audio.Format
+	portsIn  []*jack.Port
+	portsOut []*jack.Port
+	input    *audio.Ring
+	output   *audio.Ring
+	scratch  []float32
+	shutdown chan struct{}
+}
+
+/*
+ * Returns a scratch buffer holding a certain number of frames.
+ */
+func (this *Client) frames(nframes uint32) []float32 {
+	n := this.format.Samples(int(nframes))
+
+	/*
+	 * JACK may change its buffer size at any time.
+	 */
+	if len(this.scratch) < n {
+		this.scratch = make([]float32, n)
+	}
+
+	return this.scratch[0:n]
+}
+
+/*
+ * Exchanges samples between the ports and the rings.
+ */
+func (this *Client) process(nframes uint32) int {
+	numChannels := this.format.Channels
+
+	/*
+	 * Interleave captured samples.
+	 */
+	if this.input != nil {
+		buf := this.frames(nframes)
+
+		for c, port := range this.portsIn {
+			samples := port.GetBuffer(nframes)
+
+			for i, x := range samples {
+				buf[i*numChannels+c] = float32(x)
+			}
+
+		}
+
+		this.input.TryWrite(buf)
+	}
+
+	/*
+	 * Deinterleave pending output, filling the rest with silence.
+	 */
+	if this.output != nil {
+		buf := this.frames(nframes)
+		n := this.output.TryRead(buf)
+
+		for i := n; i < len(buf); i++ {
+			buf[i] = 0.0
+		}
+
+		for c, port := range this.portsOut {
+			samples := port.GetBuffer(nframes)
+
+			for i := range samples {
+				samples[i] = jack.AudioSample(buf[i*numChannels+c])
+			}
+
+		}
+
+	}
+
+	return 0
+}
+
+/*
+ * Returns the format of the client.
+ */
+func (this *Client) Format() audio.Format {
+	return this.format
+}
+
+/*
+ * Returns the name assigned to the client by the server.
+ */
+func (this *Client) Name() string {
+	return this.client.GetName()
+}
+
+/*
+ * Returns a channel which is closed when the server shuts down.
+ */
+func (this *Client) Done() <-chan struct{} {
+	return this.shutdown
+}
+
+/*
+ * Reads captured samples, waiting until some are available.
+ */
+func (this *Client) Read(buf []float32) (int, error) {
+
+	/*
+	 * Check if the client captures audio.
+	 */
+	if this.input == nil {
+		return 0, fmt.Errorf("%s", "Client has no input ports.")
+	}
+
+	return this.input.Read(buf)
+}
+
+/*
+ * Queues samples for playback, waiting until there is space.
+ */
+func (this *Client) Write(buf []float32) (int, error) {
+
+	/*
+	 * Check if the client plays audio.
+	 */
+	if this.output == nil {
+		return 0, fmt.Errorf("%s", "Client has no output ports.")
+	}
+
+	return this.output.Write(buf)
+}
+
+/*
+ * Activates the client, so that the server starts calling it.
+ */
+func (this *Client) Start() error {
+	code := this.client.Activate()
+
+	/*
+	 * Check if client could be activated.
+	 */
+	if code != 0 {
+		msg := jack.StrError(code).Error()
+		return fmt.Errorf("Failed to activate client: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Ends pending reads and writes.
+ */
+func (this *Client) closeRings() {
+
+	if this.input != nil {
+		this.input.Close()
+	}
+
+	if this.output != nil {
+		this.output.Close()
+	}
+
+}
+
+/*
+ * Closes the client.
+ */
+func (this *Client) Close() error {
+	this.closeRings()
+	code := this.client.Close()
+
+	/*
+	 * Check if client could be closed.
+	 */
+	if code != 0 {
+		msg := jack.StrError(code).Error()
+		return fmt.Errorf("Failed to close client: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Opens a client on a running JACK server and registers its ports.
+ *
+ * The client must be started before audio flows.
+ */
+func Open(config Config) (*Client, error) {
+
+	/*
+	 * Check the configuration.
+	 */
+	if !config.Input && !config.Output {
+		return nil, fmt.Errorf("%s", "Client requires input or output ports.")
+	} else if config.Channels < 1 {
+		return nil, fmt.Errorf("Client must have at least one channel, requested %d.", config.Channels)
+	}
+
+	client, status := jack.ClientOpen(config.Name, jack.NoStartServer)
+
+	/*
+	 * Check if client could be opened.
+	 */
+	if status != 0 {
+		msg := jack.StrError(status).Error()
+		return nil, fmt.Errorf("Failed to open client: %s", msg)
+	}
+
+	/*
+	 * Apply default buffer size.
+	 */
+	if config.Buffer <= 0 {
+		config.Buffer = DEFAULT_BUFFER_DURATION
+	}
+
+	sampleRate := float64(client.GetSampleRate())
+
+	format := audio.Format{
+		SampleRate: sampleRate,
+		Channels:   config.Channels,
+	}
+
+	frames := int(sampleRate * config.Buffer.Seconds())
+
+	/*
+	 * Create data structure for a client.
+	 */
+	c := &Client{
+		client:   client,
+		format:   format,
+		scratch:  make([]float32, format.Samples(int(client.GetBufferSize()))),
+		shutdown: make(chan struct{}),
+	}
+
+	/*
+	 * Register the ports of each channel.
+	 */
+	for i := 0; i < config.Channels; i++ {
+
+		if config.Input {
+			name := fmt.Sprintf("in_%d", i)
+			port := client.PortRegister(name, jack.DEFAULT_AUDIO_TYPE, jack.PortIsInput, 0)
+
+			/*
+			 * Check if port could be registered.
+			 */
+			if port == nil {
+				client.Close()
+				return nil, fmt.Errorf("Failed to register JACK port '%s'.", name)
+			}
+
+			c.portsIn = append(c.portsIn, port)
+		}
+
+		if config.Output {
+			name := fmt.Sprintf("out_%d", i)
+			port := client.PortRegister(name, jack.DEFAULT_AUDIO_TYPE, jack.PortIsOutput, 0)
+
+			/*
+			 * Check if port could be registered.
+			 */
+			if port == nil {
+				client.Close()
+				return nil, fmt.Errorf("Failed to register JACK port '%s'.", name)
+			}
+
+			c.portsOut = append(c.portsOut, port)
+		}
+
+	}
+
+	if config.Input {
+		c.input = audio.CreateRing(format, frames)
+	}
+
+	if config.Output {
+		c.output = audio.CreateRing(format, frames)
+	}
+
+	code := client.SetProcessCallback(c.process)
+
+	/*
+	 * Check if callback could be set.
+	 */
+	if code != 0 {
+		client.Close()
+		msg := jack.StrError(code).Error()
+		return nil, fmt.Errorf("Failed to set process callback: %s", msg)
+	}
+
+	/*
+	 * End the stream when the server goes away.
+	 */
+	client.OnShutdown(func() {
+		c.closeRings()
+		close(c.shutdown)
+	})
+
+	return c, nil
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"math"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	SILENCE_DECIBELS = -120.0
+)
+
+/*
+ * Data structure representing the level of a block of samples.
+ */
+type Level struct {
+	RMS  float64
+	Peak float64
+}
+
+/*
+ * Converts an amplitude to decibels relative to full scale.
+ *
+ * Amplitudes too small to be represented are reported as SILENCE_DECIBELS.
+ */
+func Decibels(amplitude float64) float64 {
+	db := SILENCE_DECIBELS
+
+	/*
+	 * Avoid the logarithm of zero.
+	 */
+	if amplitude > 0.0 {
+		db = 20.0 * math.Log10(amplitude)
+
+		if db < SILENCE_DECIBELS {
+			db = SILENCE_DECIBELS
+		}
+
+	}
+
+	return db
+}
+
+/*
+ * Measures the level of a block of samples across all channels.
+ */
+func Measure(buf []float32) Level {
+	level := Level{}
+
+	/*
+	 * Empty blocks are silent.
+	 */
+	if len(buf) == 0 {
+		return level
+	}
+
+	sum := 0.0
+
+	for _, x := range buf {
+		value := math.Abs(float64(x))
+		sum += value * value
+
+		if value > level.Peak {
+			level.Peak = value
+		}
+
+	}
+
+	level.RMS = math.Sqrt(sum / float64(len(buf)))
+	return level
+}
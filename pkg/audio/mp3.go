@@ -0,0 +1,176 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	MP3_CHANNELS        = 2
+	MP3_BYTES_PER_FRAME = 4
+)
+
+/*
+ * Data structure representing a source decoding an MP3 file.
+ *
+ * The decoder always produces stereo output, mono files have both channels
+ * set to the same signal.
+ */
+type Mp3Source struct {
+	format  Format
+	path    string
+	file    *os.File
+	decoder *mp3.Decoder
+	buf     []byte
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *Mp3Source) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames in the file, or zero if it is unknown.
+ */
+func (this *Mp3Source) Frames() int64 {
+	length := this.decoder.Length()
+
+	/*
+	 * The decoder reports a negative length if it is unknown.
+	 */
+	if length < 0 {
+		return 0
+	}
+
+	return length / MP3_BYTES_PER_FRAME
+}
+
+/*
+ * Reads interleaved samples from the file.
+ */
+func (this *Mp3Source) Read(buf []float32) (int, error) {
+	numFrames := len(buf) / MP3_CHANNELS
+	numBytes := numFrames * MP3_BYTES_PER_FRAME
+
+	/*
+	 * Grow the byte buffer as needed.
+	 */
+	if len(this.buf) < numBytes {
+		this.buf = make([]byte, numBytes)
+	}
+
+	data := this.buf[0:numBytes]
+	n, err := io.ReadFull(this.decoder, data)
+
+	/*
+	 * A short read marks the end of the stream.
+	 */
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	} else if err == io.EOF {
+		return 0, err
+	} else if err != nil {
+		msg := err.Error()
+		return 0, fmt.Errorf("Failed to decode MP3 file '%s': %s", this.path, msg)
+	}
+
+	numSamples := (n / MP3_BYTES_PER_FRAME) * MP3_CHANNELS
+
+	for i := 0; i < numSamples; i++ {
+		value := int16(binary.LittleEndian.Uint16(data[2*i:]))
+		buf[i] = float32(value) / 32768.0
+	}
+
+	return numSamples, err
+}
+
+/*
+ * Rewinds the file to its beginning.
+ */
+func (this *Mp3Source) Rewind() error {
+	_, err := this.decoder.Seek(0, io.SeekStart)
+
+	/*
+	 * Check if file could be rewound.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to rewind MP3 file '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Moves the read position to a certain frame.
+ */
+func (this *Mp3Source) SeekFrame(frame int64) error {
+	_, err := this.decoder.Seek(frame*MP3_BYTES_PER_FRAME, io.SeekStart)
+
+	/*
+	 * Check if position could be changed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek in MP3 file '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Closes the file.
+ */
+func (this *Mp3Source) Close() error {
+	return this.file.Close()
+}
+
+/*
+ * Opens an MP3 file as a source.
+ */
+func OpenMp3(path string) (*Mp3Source, error) {
+	file, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open MP3 file '%s': %s", path, msg)
+	}
+
+	decoder, err := mp3.NewDecoder(file)
+
+	/*
+	 * Check if stream could be parsed.
+	 */
+	if err != nil {
+		file.Close()
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to parse MP3 file '%s': %s", path, msg)
+	}
+
+	/*
+	 * Create data structure for an MP3 source.
+	 */
+	src := &Mp3Source{
+		format: Format{
+			SampleRate: float64(decoder.SampleRate()),
+			Channels:   MP3_CHANNELS,
+		},
+		path:    path,
+		file:    file,
+		decoder: decoder,
+	}
+
+	return src, nil
+}
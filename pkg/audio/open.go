@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+ * Audio file types.
+ */
+const (
+	FILE_UNKNOWN = iota
+	FILE_WAVE
+	FILE_FLAC
+	FILE_VORBIS
+	FILE_MP3
+)
+
+/*
+ * Interface for sources reading from a file.
+ *
+ * Frames returns zero if the length of the file is unknown. SeekFrame moves
+ * the read position to a certain frame.
+ */
+type File interface {
+	Source
+	Frames() int64
+	Rewind() error
+	SeekFrame(frame int64) error
+}
+
+/*
+ * Determines the type of an audio file from its first bytes, falling back
+ * to the file name extension.
+ */
+func detect(header []byte, path string) int {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	/*
+	 * Check the signatures first.
+	 */
+	if len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")) {
+		return FILE_WAVE
+	} else if bytes.HasPrefix(header, []byte("fLaC")) {
+		return FILE_FLAC
+	} else if bytes.HasPrefix(header, []byte("OggS")) {
+		return FILE_VORBIS
+	} else if bytes.HasPrefix(header, []byte("ID3")) {
+
+		/*
+		 * ID3 tags precede both MP3 and, rarely, FLAC streams.
+		 */
+		if ext == ".flac" {
+			return FILE_FLAC
+		} else {
+			return FILE_MP3
+		}
+
+	} else if len(header) >= 2 && header[0] == 0xff && (header[1]&0xe0) == 0xe0 {
+		return FILE_MP3
+	}
+
+	switch ext {
+	case ".wav", ".wave":
+		return FILE_WAVE
+	case ".flac":
+		return FILE_FLAC
+	case ".ogg", ".oga":
+		return FILE_VORBIS
+	case ".mp3":
+		return FILE_MP3
+	default:
+		return FILE_UNKNOWN
+	}
+
+}
+
+/*
+ * Opens a WAV, FLAC, Ogg Vorbis or MP3 file as a source.
+ */
+func Open(path string) (File, error) {
+	file, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open audio file '%s': %s", path, msg)
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(file, header)
+	file.Close()
+
+	/*
+	 * Short files are detected by their extension.
+	 */
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read audio file '%s': %s", path, msg)
+	}
+
+	var src File
+
+	switch detect(header[0:n], path) {
+	case FILE_WAVE:
+		src, err = OpenFile(path)
+	case FILE_FLAC:
+		src, err = OpenFlac(path)
+	case FILE_VORBIS:
+		src, err = OpenVorbis(path)
+	case FILE_MP3:
+		src, err = OpenMp3(path)
+	default:
+		err = fmt.Errorf("Audio file '%s' has an unsupported format.", path)
+	}
+
+	/*
+	 * Never return typed nil pointers.
+	 */
+	if err != nil {
+		return nil, err
+	}
+
+	return src, nil
+}
@@ -0,0 +1,235 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/stretch"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	PLAYER_IDLE_INTERVAL = 10 * time.Millisecond
+)
+
+/*
+ * Data structure representing a source playing a file with transport
+ * controls.
+ *
+ * The speed can be changed without changing the pitch. While paused, and
+ * after reaching the end of the file, reads return no samples, so consumers
+ * keep running until the player is closed.
+ */
+type Player struct {
+	lock      sync.Mutex
+	file      File
+	stretcher *stretch.Stretcher
+	input     []float32
+	output    []float32
+	paused    bool
+	flushed   bool
+	ended     bool
+	position  float64
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *Player) Format() Format {
+	return this.file.Format()
+}
+
+/*
+ * Returns the length of the file in seconds, or zero if it is unknown.
+ */
+func (this *Player) Duration() float64 {
+	return float64(this.file.Frames()) / this.file.Format().SampleRate
+}
+
+/*
+ * Returns the playback position in seconds.
+ */
+func (this *Player) Position() float64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.position / this.file.Format().SampleRate
+}
+
+/*
+ * Returns the playback speed.
+ */
+func (this *Player) Speed() float64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.stretcher.Speed()
+}
+
+/*
+ * Changes the playback speed, which must be between stretch.MIN_SPEED and
+ * stretch.MAX_SPEED.
+ */
+func (this *Player) SetSpeed(speed float64) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.stretcher.SetSpeed(speed)
+}
+
+/*
+ * Returns whether playback is paused.
+ */
+func (this *Player) Paused() bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.paused || this.ended
+}
+
+/*
+ * Pauses or resumes playback. Resuming at the end of the file starts over.
+ */
+func (this *Player) SetPaused(paused bool) error {
+	this.lock.Lock()
+	ended := this.ended
+	this.paused = paused
+	this.lock.Unlock()
+
+	/*
+	 * Start over after the end.
+	 */
+	if !paused && ended {
+		return this.Seek(0.0)
+	}
+
+	return nil
+}
+
+/*
+ * Moves the playback position to a certain time in seconds.
+ */
+func (this *Player) Seek(seconds float64) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	format := this.file.Format()
+	frame := int64(math.Round(seconds * format.SampleRate))
+	numFrames := this.file.Frames()
+
+	/*
+	 * Keep the position within the file.
+	 */
+	if frame < 0 {
+		frame = 0
+	} else if numFrames > 0 && frame > numFrames {
+		frame = numFrames
+	}
+
+	err := this.file.SeekFrame(frame)
+
+	/*
+	 * Check if position could be changed.
+	 */
+	if err != nil {
+		return err
+	}
+
+	this.stretcher.Reset()
+	this.output = this.output[0:0]
+	this.position = float64(frame)
+	this.flushed = false
+	this.ended = false
+	return nil
+}
+
+/*
+ * Reads stretched samples from the file.
+ */
+func (this *Player) Read(buf []float32) (int, error) {
+	this.lock.Lock()
+	format := this.file.Format()
+
+	/*
+	 * Idle while paused, so that consumers do not spin.
+	 */
+	if this.paused || this.ended {
+		this.lock.Unlock()
+		time.Sleep(PLAYER_IDLE_INTERVAL)
+		return 0, nil
+	}
+
+	defer this.lock.Unlock()
+
+	for len(this.output) == 0 {
+		n, err := ReadFull(this.file, this.input)
+		this.output = this.stretcher.Process(this.input[0:n], this.output)
+
+		/*
+		 * Drain the stretcher at the end of the file.
+		 */
+		if err == io.EOF {
+
+			if this.flushed {
+				this.ended = true
+				return 0, nil
+			}
+
+			this.output = this.stretcher.Flush(this.output)
+			this.flushed = true
+		} else if err != nil {
+			msg := err.Error()
+			return 0, fmt.Errorf("Failed to read from file: %s", msg)
+		}
+
+	}
+
+	numSamples := len(buf) - (len(buf) % format.Channels)
+	n := copy(buf[0:numSamples], this.output)
+	remaining := copy(this.output, this.output[n:])
+	this.output = this.output[0:remaining]
+	this.position += float64(n/format.Channels) * this.stretcher.Speed()
+	numFrames := this.file.Frames()
+
+	/*
+	 * The flushed tail must not move the position beyond the end.
+	 */
+	if numFrames > 0 && this.position > float64(numFrames) {
+		this.position = float64(numFrames)
+	}
+
+	return n, nil
+}
+
+/*
+ * Closes the file.
+ */
+func (this *Player) Close() error {
+	return this.file.Close()
+}
+
+/*
+ * Creates a player for a file, starting at its beginning.
+ */
+func CreatePlayer(file File) (*Player, error) {
+	format := file.Format()
+	s, err := stretch.Create(format.Channels, format.SampleRate, 1.0)
+
+	/*
+	 * Check if stretcher could be created.
+	 */
+	if err != nil {
+		return nil, err
+	}
+
+	/*
+	 * Create data structure for a player.
+	 */
+	p := &Player{
+		file:      file,
+		stretcher: s,
+		input:     make([]float32, format.Samples(DEFAULT_BLOCK_FRAMES)),
+	}
+
+	return p, nil
+}
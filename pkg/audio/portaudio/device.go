@@ -0,0 +1,238 @@
+package portaudio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pa "github.com/gordonklaus/portaudio"
+)
+
+/*
+ * Direction in which a device transports audio.
+ */
+type Direction int
+
+const (
+	INPUT Direction = iota
+	OUTPUT
+)
+
+/*
+ * Data structure describing which devices to use.
+ *
+ * HostAPI selects the host API by index or name, Input and Output select
+ * devices of this host API by index or name. Names match on any part of the
+ * device name, ignoring case. Empty fields select the defaults.
+ */
+type Selection struct {
+	HostAPI string
+	Input   string
+	Output  string
+}
+
+/*
+ * Returns the number of channels a device offers in a certain direction.
+ */
+func Channels(device *pa.DeviceInfo, direction Direction) int {
+
+	/*
+	 * Choose the channel count of the direction.
+	 */
+	if direction == INPUT {
+		return device.MaxInputChannels
+	} else {
+		return device.MaxOutputChannels
+	}
+
+}
+
+/*
+ * Returns a textual representation of a direction.
+ */
+func (this Direction) String() string {
+
+	/*
+	 * Name the direction.
+	 */
+	if this == INPUT {
+		return "input"
+	} else {
+		return "output"
+	}
+
+}
+
+/*
+ * Checks whether a name matches a pattern, ignoring case.
+ */
+func matches(name string, pattern string) bool {
+	nameLower := strings.ToLower(name)
+	patternLower := strings.ToLower(pattern)
+	return strings.Contains(nameLower, patternLower)
+}
+
+/*
+ * Finds a host API by index or name among a list of host APIs.
+ */
+func FindHostApi(apis []*pa.HostApiInfo, spec string) (*pa.HostApiInfo, error) {
+	idx, err := strconv.Atoi(spec)
+
+	/*
+	 * Numeric specifications select by index.
+	 */
+	if err == nil {
+
+		if idx < 0 || idx >= len(apis) {
+			return nil, fmt.Errorf("Host API index %d out of range, there are %d host APIs.", idx, len(apis))
+		}
+
+		return apis[idx], nil
+	}
+
+	names := []string{}
+
+	/*
+	 * Otherwise match by name.
+	 */
+	for _, api := range apis {
+
+		if matches(api.Name, spec) || matches(api.Type.String(), spec) {
+			return api, nil
+		}
+
+		names = append(names, api.Name)
+	}
+
+	available := strings.Join(names, ", ")
+	return nil, fmt.Errorf("No host API matches '%s', available: %s", spec, available)
+}
+
+/*
+ * Finds a device by index or name among the devices of a host API.
+ *
+ * Only devices offering channels in the requested direction are considered.
+ * An empty specification selects the default device of the host API.
+ */
+func FindDevice(api *pa.HostApiInfo, spec string, direction Direction) (*pa.DeviceInfo, error) {
+
+	/*
+	 * Fall back to the default device.
+	 */
+	if spec == "" {
+		device := api.DefaultInputDevice
+
+		if direction == OUTPUT {
+			device = api.DefaultOutputDevice
+		}
+
+		if device == nil {
+			return nil, fmt.Errorf("Host API '%s' has no default %s device.", api.Name, direction)
+		}
+
+		return device, nil
+	}
+
+	idx, err := strconv.Atoi(spec)
+
+	/*
+	 * Numeric specifications select by index.
+	 */
+	if err == nil {
+
+		if idx < 0 || idx >= len(api.Devices) {
+			return nil, fmt.Errorf("Device index %d out of range, host API '%s' has %d devices.", idx, api.Name, len(api.Devices))
+		}
+
+		device := api.Devices[idx]
+
+		if Channels(device, direction) < 1 {
+			return nil, fmt.Errorf("Device '%s' has no %s channels.", device.Name, direction)
+		}
+
+		return device, nil
+	}
+
+	names := []string{}
+
+	/*
+	 * Otherwise match by name.
+	 */
+	for _, device := range api.Devices {
+
+		if Channels(device, direction) > 0 {
+
+			if matches(device.Name, spec) {
+				return device, nil
+			}
+
+			names = append(names, device.Name)
+		}
+
+	}
+
+	available := strings.Join(names, ", ")
+	return nil, fmt.Errorf("No %s device matches '%s', available: %s", direction, spec, available)
+}
+
+/*
+ * Returns the host API selected by a specification, or the default host API
+ * if the specification is empty. PortAudio must be initialized.
+ */
+func SelectHostApi(spec string) (*pa.HostApiInfo, error) {
+
+	/*
+	 * Fall back to the default host API.
+	 */
+	if spec == "" {
+		api, err := pa.DefaultHostApi()
+
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to obtain default host API: %s", msg)
+		}
+
+		return api, nil
+	}
+
+	apis, err := pa.HostApis()
+
+	/*
+	 * Check if host APIs could be enumerated.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to enumerate host APIs: %s", msg)
+	}
+
+	return FindHostApi(apis, spec)
+}
+
+/*
+ * Returns the input and output devices of a selection. PortAudio must be
+ * initialized.
+ */
+func Select(selection Selection) (*pa.DeviceInfo, *pa.DeviceInfo, error) {
+	api, err := SelectHostApi(selection.HostAPI)
+
+	/*
+	 * Check if host API was found.
+	 */
+	if err != nil {
+		return nil, nil, err
+	}
+
+	input, err := FindDevice(api, selection.Input, INPUT)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := FindDevice(api, selection.Output, OUTPUT)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return input, output, nil
+}
@@ -0,0 +1,133 @@
+package portaudio
+
+import (
+	"testing"
+
+	pa "github.com/gordonklaus/portaudio"
+)
+
+/*
+ * Creates a host API with a webcam microphone and a headset.
+ */
+func testHostApi() *pa.HostApiInfo {
+	camera := &pa.DeviceInfo{Name: "Live camera: USB Audio", MaxInputChannels: 1}
+	headset := &pa.DeviceInfo{Name: "Jabra EVOLVE 20", MaxInputChannels: 1, MaxOutputChannels: 2}
+	speakers := &pa.DeviceInfo{Name: "HDA Intel PCH", MaxOutputChannels: 2}
+
+	/*
+	 * Create data structure for a host API.
+	 */
+	api := &pa.HostApiInfo{
+		Type:                pa.ALSA,
+		Name:                "ALSA",
+		DefaultInputDevice:  camera,
+		DefaultOutputDevice: speakers,
+		Devices:             []*pa.DeviceInfo{camera, headset, speakers},
+	}
+
+	return api
+}
+
+/*
+ * Perform a unit test on device selection.
+ */
+func TestFindDevice(t *testing.T) {
+	api := testHostApi()
+
+	/*
+	 * Specifications and the devices they select.
+	 */
+	cases := []struct {
+		spec      string
+		direction Direction
+		expected  string
+	}{
+		{"", INPUT, "Live camera: USB Audio"},
+		{"", OUTPUT, "HDA Intel PCH"},
+		{"jabra", INPUT, "Jabra EVOLVE 20"},
+		{"JABRA", OUTPUT, "Jabra EVOLVE 20"},
+		{"1", OUTPUT, "Jabra EVOLVE 20"},
+		{"intel", OUTPUT, "HDA Intel PCH"},
+	}
+
+	for _, c := range cases {
+		device, err := FindDevice(api, c.spec, c.direction)
+
+		/*
+		 * Check if device was found.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to find %s device '%s': %s", c.direction, c.spec, msg)
+		} else if device.Name != c.expected {
+			t.Errorf("Specification '%s' selected %s device '%s', expected '%s'.", c.spec, c.direction, device.Name, c.expected)
+		}
+
+	}
+
+	/*
+	 * Specifications which select nothing.
+	 */
+	failures := []struct {
+		spec      string
+		direction Direction
+	}{
+		{"intel", INPUT},
+		{"camera", OUTPUT},
+		{"0", OUTPUT},
+		{"3", INPUT},
+		{"Scarlett", INPUT},
+	}
+
+	for _, c := range failures {
+		device, err := FindDevice(api, c.spec, c.direction)
+
+		if err == nil {
+			t.Errorf("Specification '%s' selected %s device '%s', expected an error.", c.spec, c.direction, device.Name)
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on host API selection.
+ */
+func TestFindHostApi(t *testing.T) {
+	jack := &pa.HostApiInfo{Type: pa.JACK, Name: "JACK Audio Connection Kit"}
+	apis := []*pa.HostApiInfo{testHostApi(), jack}
+
+	/*
+	 * Specifications and the host APIs they select.
+	 */
+	cases := map[string]string{
+		"alsa": "ALSA",
+		"jack": "JACK Audio Connection Kit",
+		"1":    "JACK Audio Connection Kit",
+	}
+
+	for spec, expected := range cases {
+		api, err := FindHostApi(apis, spec)
+
+		/*
+		 * Check if host API was found.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to find host API '%s': %s", spec, msg)
+		} else if api.Name != expected {
+			t.Errorf("Specification '%s' selected host API '%s', expected '%s'.", spec, api.Name, expected)
+		}
+
+	}
+
+	_, err := FindHostApi(apis, "asio")
+
+	/*
+	 * Unknown host APIs are an error.
+	 */
+	if err == nil {
+		t.Errorf("%s", "Specification 'asio' selected a host API, expected an error.")
+	}
+
+}
@@ -0,0 +1,175 @@
+package portaudio
+
+import (
+	"fmt"
+
+	pa "github.com/gordonklaus/portaudio"
+)
+
+/*
+ * Sample rates probed when determining which rates a device supports.
+ */
+var STANDARD_SAMPLE_RATES = []float64{
+	8000.0,
+	11025.0,
+	16000.0,
+	22050.0,
+	32000.0,
+	44100.0,
+	48000.0,
+	88200.0,
+	96000.0,
+	176400.0,
+	192000.0,
+}
+
+/*
+ * Data structure describing the capabilities of a device.
+ *
+ * Latencies are given in seconds. Sample rates list the standard rates the
+ * device accepts in each direction.
+ */
+type DeviceReport struct {
+	Index             int       `json:"index"`
+	Name              string    `json:"name"`
+	DefaultInput      bool      `json:"defaultInput"`
+	DefaultOutput     bool      `json:"defaultOutput"`
+	InputChannels     int       `json:"inputChannels"`
+	OutputChannels    int       `json:"outputChannels"`
+	DefaultSampleRate float64   `json:"defaultSampleRate"`
+	InputSampleRates  []float64 `json:"inputSampleRates"`
+	OutputSampleRates []float64 `json:"outputSampleRates"`
+	LowInputLatency   float64   `json:"lowInputLatency"`
+	HighInputLatency  float64   `json:"highInputLatency"`
+	LowOutputLatency  float64   `json:"lowOutputLatency"`
+	HighOutputLatency float64   `json:"highOutputLatency"`
+}
+
+/*
+ * Data structure describing a host API and its devices.
+ */
+type HostApiReport struct {
+	Index   int            `json:"index"`
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Default bool           `json:"default"`
+	Devices []DeviceReport `json:"devices"`
+}
+
+/*
+ * Returns the standard sample rates a device supports in a certain
+ * direction. PortAudio must be initialized.
+ */
+func SupportedSampleRates(device *pa.DeviceInfo, direction Direction) []float64 {
+	rates := []float64{}
+	channels := Channels(device, direction)
+
+	/*
+	 * Devices without channels in this direction support no rates.
+	 */
+	if channels < 1 {
+		return rates
+	}
+
+	params := pa.StreamDeviceParameters{
+		Device:   device,
+		Channels: 1,
+	}
+
+	callback := func(in []float32, out []float32) {}
+
+	for _, rate := range STANDARD_SAMPLE_RATES {
+		p := pa.StreamParameters{
+			SampleRate:      rate,
+			FramesPerBuffer: pa.FramesPerBufferUnspecified,
+		}
+
+		/*
+		 * Probe the direction in question only.
+		 */
+		if direction == INPUT {
+			p.Input = params
+			p.Input.Latency = device.DefaultLowInputLatency
+		} else {
+			p.Output = params
+			p.Output.Latency = device.DefaultLowOutputLatency
+		}
+
+		err := pa.IsFormatSupported(p, callback)
+
+		if err == nil {
+			rates = append(rates, rate)
+		}
+
+	}
+
+	return rates
+}
+
+/*
+ * Describes the capabilities of a device.
+ */
+func InspectDevice(api *pa.HostApiInfo, index int) DeviceReport {
+	device := api.Devices[index]
+
+	/*
+	 * Create data structure for a device report.
+	 */
+	report := DeviceReport{
+		Index:             index,
+		Name:              device.Name,
+		DefaultInput:      device == api.DefaultInputDevice,
+		DefaultOutput:     device == api.DefaultOutputDevice,
+		InputChannels:     device.MaxInputChannels,
+		OutputChannels:    device.MaxOutputChannels,
+		DefaultSampleRate: device.DefaultSampleRate,
+		InputSampleRates:  SupportedSampleRates(device, INPUT),
+		OutputSampleRates: SupportedSampleRates(device, OUTPUT),
+		LowInputLatency:   device.DefaultLowInputLatency.Seconds(),
+		HighInputLatency:  device.DefaultHighInputLatency.Seconds(),
+		LowOutputLatency:  device.DefaultLowOutputLatency.Seconds(),
+		HighOutputLatency: device.DefaultHighOutputLatency.Seconds(),
+	}
+
+	return report
+}
+
+/*
+ * Describes all host APIs and their devices. PortAudio must be initialized.
+ */
+func Inspect() ([]HostApiReport, error) {
+	apis, err := pa.HostApis()
+
+	/*
+	 * Check if host APIs could be enumerated.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to enumerate host APIs: %s", msg)
+	}
+
+	defaultApi, _ := pa.DefaultHostApi()
+	reports := make([]HostApiReport, len(apis))
+
+	for i, api := range apis {
+		devices := make([]DeviceReport, len(api.Devices))
+
+		for j := range api.Devices {
+			devices[j] = InspectDevice(api, j)
+		}
+
+		/*
+		 * Create data structure for a host API report.
+		 */
+		reports[i] = HostApiReport{
+			Index:   i,
+			Name:    api.Name,
+			Type:    api.Type.String(),
+			Default: api == defaultApi,
+			Devices: devices,
+		}
+
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,287 @@
+package portaudio
+
+import (
+	"fmt"
+	"time"
+
+	pa "github.com/gordonklaus/portaudio"
+	"github.com/metalblueberry/bard/pkg/audio"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_BUFFER_DURATION = 500 * time.Millisecond
+)
+
+/*
+ * Data structure describing a PortAudio stream.
+ *
+ * Either device may be nil for input-only or output-only streams. A sample
+ * rate of zero selects the default rate of the devices and zero frames per
+ * buffer let the driver choose. Buffer is the amount of audio held between
+ * the driver callback and the Read and Write methods.
+ */
+type Config struct {
+	Input           *pa.DeviceInfo
+	Output          *pa.DeviceInfo
+	Channels        int
+	SampleRate      float64
+	FramesPerBuffer int
+	LowLatency      bool
+	Buffer          time.Duration
+}
+
+/*
+ * Data structure representing a PortAudio stream.
+ *
+ * The stream is a source reading from the input device and a sink writing
+ * to the output device. When the output runs dry, silence is played.
+ */
+type Stream struct {
+	stream *pa.Stream
+	config Config
+	format audio.Format
+	input  *audio.Ring
+	output *audio.Ring
+}
+
+/*
+ * Exchanges samples between the driver and the rings.
+ */
+func (this *Stream) process(in []float32, out []float32) {
+
+	/*
+	 * Capture input.
+	 */
+	if this.input != nil {
+		this.input.TryWrite(in)
+	}
+
+	n := 0
+
+	/*
+	 * Play pending output.
+	 */
+	if this.output != nil {
+		n = this.output.TryRead(out)
+	}
+
+	/*
+	 * Fill the rest with silence.
+	 */
+	for i := n; i < len(out); i++ {
+		out[i] = 0.0
+	}
+
+}
+
+/*
+ * Returns the format of the stream.
+ */
+func (this *Stream) Format() audio.Format {
+	return this.format
+}
+
+/*
+ * Returns the input device of the stream.
+ */
+func (this *Stream) InputDevice() *pa.DeviceInfo {
+	return this.config.Input
+}
+
+/*
+ * Returns the output device of the stream.
+ */
+func (this *Stream) OutputDevice() *pa.DeviceInfo {
+	return this.config.Output
+}
+
+/*
+ * Returns the input latency of the stream reported by the driver, or zero
+ * if the stream captures no audio or is not open.
+ */
+func (this *Stream) Latency() time.Duration {
+	info := this.stream.Info()
+
+	/*
+	 * Check if the driver reports the latency.
+	 */
+	if this.input == nil || info == nil {
+		return 0
+	} else {
+		return info.InputLatency
+	}
+
+}
+
+/*
+ * Reads captured samples, waiting until some are available.
+ */
+func (this *Stream) Read(buf []float32) (int, error) {
+
+	/*
+	 * Check if the stream captures audio.
+	 */
+	if this.input == nil {
+		return 0, fmt.Errorf("%s", "Stream has no input device.")
+	}
+
+	return this.input.Read(buf)
+}
+
+/*
+ * Queues samples for playback, waiting until there is space.
+ */
+func (this *Stream) Write(buf []float32) (int, error) {
+
+	/*
+	 * Check if the stream plays audio.
+	 */
+	if this.output == nil {
+		return 0, fmt.Errorf("%s", "Stream has no output device.")
+	}
+
+	return this.output.Write(buf)
+}
+
+/*
+ * Starts the stream.
+ */
+func (this *Stream) Start() error {
+	err := this.stream.Start()
+
+	/*
+	 * Check if stream could be started.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to start stream: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Stops the stream.
+ */
+func (this *Stream) Stop() error {
+	err := this.stream.Stop()
+
+	/*
+	 * Check if stream could be stopped.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to stop stream: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Closes the stream, ending pending reads and writes.
+ */
+func (this *Stream) Close() error {
+
+	if this.input != nil {
+		this.input.Close()
+	}
+
+	if this.output != nil {
+		this.output.Close()
+	}
+
+	return this.stream.Close()
+}
+
+/*
+ * Opens a PortAudio stream. PortAudio must be initialized.
+ */
+func Open(config Config) (*Stream, error) {
+
+	/*
+	 * Check the configuration.
+	 */
+	if config.Input == nil && config.Output == nil {
+		return nil, fmt.Errorf("%s", "Stream requires an input or an output device.")
+	} else if config.Channels < 1 {
+		return nil, fmt.Errorf("Stream must have at least one channel, requested %d.", config.Channels)
+	} else {
+		params := pa.HighLatencyParameters(config.Input, config.Output)
+
+		/*
+		 * Prefer low latency for live monitoring.
+		 */
+		if config.LowLatency {
+			params = pa.LowLatencyParameters(config.Input, config.Output)
+		}
+
+		if config.Input != nil {
+			params.Input.Channels = config.Channels
+		}
+
+		if config.Output != nil {
+			params.Output.Channels = config.Channels
+		}
+
+		/*
+		 * Apply requested sample rate.
+		 */
+		if config.SampleRate > 0.0 {
+			params.SampleRate = config.SampleRate
+		}
+
+		/*
+		 * Apply requested driver buffer size.
+		 */
+		if config.FramesPerBuffer > 0 {
+			params.FramesPerBuffer = config.FramesPerBuffer
+		}
+
+		/*
+		 * Apply default buffer size.
+		 */
+		if config.Buffer <= 0 {
+			config.Buffer = DEFAULT_BUFFER_DURATION
+		}
+
+		format := audio.Format{
+			SampleRate: params.SampleRate,
+			Channels:   config.Channels,
+		}
+
+		frames := int(params.SampleRate * config.Buffer.Seconds())
+
+		/*
+		 * Create data structure for a stream.
+		 */
+		s := &Stream{
+			config: config,
+			format: format,
+		}
+
+		if config.Input != nil {
+			s.input = audio.CreateRing(format, frames)
+		}
+
+		if config.Output != nil {
+			s.output = audio.CreateRing(format, frames)
+		}
+
+		stream, err := pa.OpenStream(params, s.process)
+
+		/*
+		 * Check if stream could be opened.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to open stream: %s", msg)
+		}
+
+		s.stream = stream
+		return s, nil
+	}
+
+}
@@ -0,0 +1,280 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+ * Data structure representing a thread-safe FIFO of interleaved samples.
+ *
+ * Rings connect the real-time callbacks of sound card drivers with the
+ * blocking Source and Sink interfaces. Callbacks use TryRead and TryWrite,
+ * which never block, while consumers use Read and Write, which wait for
+ * data or space.
+ */
+type Ring struct {
+	format   Format
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	buffer   []float32
+	start    int
+	length   int
+	closed   bool
+	overruns int
+}
+
+/*
+ * Returns the format of the samples stored in the ring.
+ */
+func (this *Ring) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of samples that can be stored in the ring.
+ */
+func (this *Ring) Capacity() int {
+	return len(this.buffer)
+}
+
+/*
+ * Returns the number of samples currently stored in the ring.
+ */
+func (this *Ring) Length() int {
+	this.mutex.Lock()
+	n := this.length
+	this.mutex.Unlock()
+	return n
+}
+
+/*
+ * Returns the number of samples dropped because the ring was full.
+ */
+func (this *Ring) Overruns() int {
+	this.mutex.Lock()
+	n := this.overruns
+	this.mutex.Unlock()
+	return n
+}
+
+/*
+ * Rounds a number of samples down to whole frames.
+ */
+func (this *Ring) align(n int) int {
+	channels := this.format.Channels
+
+	/*
+	 * Only multi-channel streams need alignment.
+	 */
+	if channels > 1 {
+		n -= n % channels
+	}
+
+	return n
+}
+
+/*
+ * Moves samples from the ring into buf. The lock must be held.
+ */
+func (this *Ring) get(buf []float32) int {
+	n := this.align(len(buf))
+
+	/*
+	 * Never read more than is stored.
+	 */
+	if n > this.length {
+		n = this.length
+	}
+
+	size := len(this.buffer)
+
+	for i := 0; i < n; i++ {
+		buf[i] = this.buffer[(this.start+i)%size]
+	}
+
+	this.start = (this.start + n) % size
+	this.length -= n
+	return n
+}
+
+/*
+ * Moves samples from buf into the ring. The lock must be held.
+ */
+func (this *Ring) put(buf []float32) int {
+	size := len(this.buffer)
+	n := this.align(len(buf))
+	free := this.align(size - this.length)
+
+	/*
+	 * Never write more than fits.
+	 */
+	if n > free {
+		n = free
+	}
+
+	end := this.start + this.length
+
+	for i := 0; i < n; i++ {
+		this.buffer[(end+i)%size] = buf[i]
+	}
+
+	this.length += n
+	return n
+}
+
+/*
+ * Reads samples without blocking and returns the number of samples read.
+ */
+func (this *Ring) TryRead(buf []float32) int {
+	this.mutex.Lock()
+	n := this.get(buf)
+	this.mutex.Unlock()
+
+	/*
+	 * Wake up writers waiting for space.
+	 */
+	if n > 0 {
+		this.cond.Broadcast()
+	}
+
+	return n
+}
+
+/*
+ * Writes samples without blocking and returns the number of samples
+ * written. Samples that do not fit are dropped and counted as overruns.
+ */
+func (this *Ring) TryWrite(buf []float32) int {
+	this.mutex.Lock()
+	n := 0
+
+	/*
+	 * Closed rings accept no more data.
+	 */
+	if !this.closed {
+		n = this.put(buf)
+		this.overruns += this.align(len(buf)) - n
+	}
+
+	this.mutex.Unlock()
+
+	/*
+	 * Wake up readers waiting for data.
+	 */
+	if n > 0 {
+		this.cond.Broadcast()
+	}
+
+	return n
+}
+
+/*
+ * Reads samples, waiting until at least one frame is available.
+ *
+ * Returns io.EOF once the ring was closed and all samples were read.
+ */
+func (this *Ring) Read(buf []float32) (int, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	/*
+	 * Wait for data.
+	 */
+	for this.length == 0 && !this.closed {
+		this.cond.Wait()
+	}
+
+	n := this.get(buf)
+
+	/*
+	 * Signal the end of the stream once the ring is drained.
+	 */
+	if n == 0 && this.closed {
+		return 0, io.EOF
+	}
+
+	this.cond.Broadcast()
+	return n, nil
+}
+
+/*
+ * Writes samples, waiting until there is space for all of them.
+ */
+func (this *Ring) Write(buf []float32) (int, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	buf = buf[0:this.align(len(buf))]
+	total := 0
+
+	/*
+	 * Write in portions as space becomes available.
+	 */
+	for total < len(buf) {
+
+		/*
+		 * Writing to a closed ring is an error.
+		 */
+		if this.closed {
+			return total, fmt.Errorf("%s", "Ring is closed.")
+		}
+
+		n := this.put(buf[total:])
+		total += n
+
+		if n > 0 {
+			this.cond.Broadcast()
+		} else {
+			this.cond.Wait()
+		}
+
+	}
+
+	return total, nil
+}
+
+/*
+ * Closes the ring, waking up all waiting readers and writers.
+ *
+ * Samples that are still stored can be read until the ring is drained.
+ */
+func (this *Ring) Close() error {
+	this.mutex.Lock()
+	this.closed = true
+	this.mutex.Unlock()
+	this.cond.Broadcast()
+	return nil
+}
+
+/*
+ * Creates a ring holding a certain number of frames.
+ */
+func CreateRing(format Format, frames int) *Ring {
+	channels := format.Channels
+
+	/*
+	 * Always store at least one channel.
+	 */
+	if channels < 1 {
+		channels = 1
+	}
+
+	/*
+	 * Always store at least one frame.
+	 */
+	if frames < 1 {
+		frames = 1
+	}
+
+	/*
+	 * Create data structure for a ring.
+	 */
+	r := &Ring{
+		format: format,
+		buffer: make([]float32, frames*channels),
+	}
+
+	r.cond = sync.NewCond(&r.mutex)
+	return r
+}
@@ -0,0 +1,262 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"time"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	THROTTLE_MAX_LAG = 100 * time.Millisecond
+)
+
+/*
+ * Data structure representing a synthetic source.
+ *
+ * The same signal is written to every channel. Generators with a positive
+ * length end after this number of frames, all others never end.
+ */
+type Generator struct {
+	format Format
+	next   func(frame int64) float64
+	frame  int64
+	length int64
+}
+
+/*
+ * Returns the format of the generated signal.
+ */
+func (this *Generator) Format() Format {
+	return this.format
+}
+
+/*
+ * Generates interleaved samples.
+ */
+func (this *Generator) Read(buf []float32) (int, error) {
+	channels := this.format.Channels
+	numFrames := int64(len(buf) / channels)
+
+	/*
+	 * Finite generators stop at their length.
+	 */
+	if this.length > 0 {
+		remaining := this.length - this.frame
+
+		if remaining <= 0 {
+			return 0, io.EOF
+		} else if numFrames > remaining {
+			numFrames = remaining
+		}
+
+	}
+
+	for i := int64(0); i < numFrames; i++ {
+		value := float32(this.next(this.frame))
+		offset := int(i) * channels
+
+		for c := 0; c < channels; c++ {
+			buf[offset+c] = value
+		}
+
+		this.frame++
+	}
+
+	return int(numFrames) * channels, nil
+}
+
+/*
+ * Generators hold no resources.
+ */
+func (this *Generator) Close() error {
+	return nil
+}
+
+/*
+ * Creates a source from a function returning the value of each frame.
+ */
+func CreateGenerator(format Format, frames int64, next func(frame int64) float64) *Generator {
+
+	/*
+	 * Always produce at least one channel.
+	 */
+	if format.Channels < 1 {
+		format.Channels = 1
+	}
+
+	/*
+	 * Create data structure for a generator.
+	 */
+	g := Generator{
+		format: format,
+		next:   next,
+		length: frames,
+	}
+
+	return &g
+}
+
+/*
+ * Creates a source producing a sine wave.
+ */
+func CreateSine(format Format, frames int64, frequency float64, amplitude float64) *Generator {
+	omega := 2.0 * math.Pi * frequency / format.SampleRate
+
+	/*
+	 * Calculate the phase from the frame index, so that no error accumulates.
+	 */
+	next := func(frame int64) float64 {
+		return amplitude * math.Sin(omega*float64(frame))
+	}
+
+	return CreateGenerator(format, frames, next)
+}
+
+/*
+ * Creates a source producing uniformly distributed white noise.
+ */
+func CreateNoise(format Format, frames int64, amplitude float64, seed int64) *Generator {
+	random := rand.New(rand.NewSource(seed))
+
+	/*
+	 * Draw a new random value for each frame.
+	 */
+	next := func(frame int64) float64 {
+		return amplitude * (2.0*random.Float64() - 1.0)
+	}
+
+	return CreateGenerator(format, frames, next)
+}
+
+/*
+ * Creates a source producing silence.
+ */
+func CreateSilence(format Format, frames int64) *Generator {
+
+	next := func(frame int64) float64 {
+		return 0.0
+	}
+
+	return CreateGenerator(format, frames, next)
+}
+
+/*
+ * Data structure representing a source which delivers samples no faster
+ * than in real time.
+ *
+ * Files and generators produce samples as fast as they are read, throttling
+ * them lets them stand in for a sound card.
+ */
+type Throttle struct {
+	source Source
+	start  time.Time
+	frames int64
+}
+
+/*
+ * Returns the format of the throttled source.
+ */
+func (this *Throttle) Format() Format {
+	return this.source.Format()
+}
+
+/*
+ * Reads samples, waiting until they would have been played.
+ */
+func (this *Throttle) Read(buf []float32) (int, error) {
+	format := this.source.Format()
+
+	/*
+	 * The clock starts with the first read.
+	 */
+	if this.start.IsZero() {
+		this.start = time.Now()
+	}
+
+	n, err := this.source.Read(buf)
+	this.frames += int64(n / format.Channels)
+	seconds := float64(this.frames) / format.SampleRate
+	due := this.start.Add(time.Duration(seconds * float64(time.Second)))
+	wait := time.Until(due)
+
+	/*
+	 * Wait until the samples are due. After the source stalled, for example
+	 * because it was paused, the clock restarts instead of catching up.
+	 */
+	if wait > 0 {
+		time.Sleep(wait)
+	} else if wait < -THROTTLE_MAX_LAG {
+		this.start = this.start.Add(-wait)
+	}
+
+	return n, err
+}
+
+/*
+ * Closes the throttled source.
+ */
+func (this *Throttle) Close() error {
+	return this.source.Close()
+}
+
+/*
+ * Creates a source delivering the samples of another source in real time.
+ */
+func CreateThrottle(source Source) *Throttle {
+
+	/*
+	 * Create data structure for a throttle.
+	 */
+	t := Throttle{
+		source: source,
+	}
+
+	return &t
+}
+
+/*
+ * Data structure representing a sink which drops all samples.
+ */
+type Discard struct {
+	format Format
+}
+
+/*
+ * Returns the format accepted by the sink.
+ */
+func (this *Discard) Format() Format {
+	return this.format
+}
+
+/*
+ * Drops samples.
+ */
+func (this *Discard) Write(buf []float32) (int, error) {
+	return len(buf), nil
+}
+
+/*
+ * Discarding sinks hold no resources.
+ */
+func (this *Discard) Close() error {
+	return nil
+}
+
+/*
+ * Creates a sink dropping all samples.
+ */
+func CreateDiscard(format Format) *Discard {
+
+	/*
+	 * Create data structure for a discarding sink.
+	 */
+	d := Discard{
+		format: format,
+	}
+
+	return &d
+}
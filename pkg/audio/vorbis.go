@@ -0,0 +1,134 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+/*
+ * Data structure representing a source decoding an Ogg Vorbis file.
+ */
+type VorbisSource struct {
+	format Format
+	path   string
+	file   *os.File
+	reader *oggvorbis.Reader
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *VorbisSource) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames in the file, or zero if it is unknown.
+ */
+func (this *VorbisSource) Frames() int64 {
+	return this.reader.Length()
+}
+
+/*
+ * Reads interleaved samples from the file.
+ */
+func (this *VorbisSource) Read(buf []float32) (int, error) {
+	numChannels := this.format.Channels
+	numSamples := len(buf) - (len(buf) % numChannels)
+	n, err := this.reader.Read(buf[0:numSamples])
+
+	/*
+	 * Pass the end of the stream on unchanged.
+	 */
+	if err != nil && err != io.EOF {
+		msg := err.Error()
+		return n, fmt.Errorf("Failed to decode Ogg Vorbis file '%s': %s", this.path, msg)
+	}
+
+	return n, err
+}
+
+/*
+ * Rewinds the file to its beginning.
+ */
+func (this *VorbisSource) Rewind() error {
+	err := this.reader.SetPosition(0)
+
+	/*
+	 * Check if file could be rewound.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to rewind Ogg Vorbis file '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Moves the read position to a certain frame.
+ */
+func (this *VorbisSource) SeekFrame(frame int64) error {
+	err := this.reader.SetPosition(frame)
+
+	/*
+	 * Check if position could be changed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek in Ogg Vorbis file '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Closes the file.
+ */
+func (this *VorbisSource) Close() error {
+	return this.file.Close()
+}
+
+/*
+ * Opens an Ogg Vorbis file as a source.
+ */
+func OpenVorbis(path string) (*VorbisSource, error) {
+	file, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open Ogg Vorbis file '%s': %s", path, msg)
+	}
+
+	reader, err := oggvorbis.NewReader(file)
+
+	/*
+	 * Check if stream could be parsed.
+	 */
+	if err != nil {
+		file.Close()
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to parse Ogg Vorbis file '%s': %s", path, msg)
+	}
+
+	/*
+	 * Create data structure for an Ogg Vorbis source.
+	 */
+	src := &VorbisSource{
+		format: Format{
+			SampleRate: float64(reader.SampleRate()),
+			Channels:   reader.Channels(),
+		},
+		path:   path,
+		file:   file,
+		reader: reader,
+	}
+
+	return src, nil
+}
@@ -0,0 +1,198 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+/*
+ * Interface implemented by everything the visualizer can draw on.
+ *
+ * Coordinates are absolute, so a section returned by Sub keeps the
+ * coordinates of its parent. Text is drawn with its baseline at y.
+ */
+type Canvas interface {
+	Bounds() image.Rectangle
+	Sub(r image.Rectangle) Canvas
+	Fill(c color.Color)
+	FillRect(x float32, y float32, width float32, height float32, c color.Color)
+	Polyline(points []float32, width float32, c color.Color)
+	Text(s string, x int, y int, c color.Color)
+	DrawImage(img *image.RGBA, dst image.Rectangle)
+}
+
+/*
+ * Data structure representing a canvas drawing into an image in memory.
+ */
+type Image struct {
+	img        *image.RGBA
+	face       font.Face
+	rasterizer *vector.Rasterizer
+}
+
+/*
+ * Returns the image drawn into.
+ */
+func (this *Image) RGBA() *image.RGBA {
+	return this.img
+}
+
+/*
+ * Returns the area of the canvas.
+ */
+func (this *Image) Bounds() image.Rectangle {
+	return this.img.Bounds()
+}
+
+/*
+ * Returns a canvas drawing into a section of this canvas.
+ */
+func (this *Image) Sub(r image.Rectangle) Canvas {
+	sub := this.img.SubImage(r).(*image.RGBA)
+
+	/*
+	 * Create data structure for a section.
+	 */
+	section := &Image{
+		img:  sub,
+		face: this.face,
+	}
+
+	return section
+}
+
+/*
+ * Fills the whole canvas with a color.
+ */
+func (this *Image) Fill(c color.Color) {
+	draw.Draw(this.img, this.img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+/*
+ * Fills a rectangle, blending it over what was drawn before.
+ */
+func (this *Image) FillRect(x float32, y float32, width float32, height float32, c color.Color) {
+	x0 := int(math.Round(float64(x)))
+	y0 := int(math.Round(float64(y)))
+	x1 := int(math.Round(float64(x + width)))
+	y1 := int(math.Round(float64(y + height)))
+	r := image.Rect(x0, y0, x1, y1).Intersect(this.img.Bounds())
+	draw.Draw(this.img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+/*
+ * Draws connected line segments through points given as pairs of x and y
+ * coordinates.
+ */
+func (this *Image) Polyline(points []float32, width float32, c color.Color) {
+	bounds := this.img.Bounds()
+
+	/*
+	 * At least one segment is needed.
+	 */
+	if len(points) < 4 || bounds.Empty() {
+		return
+	}
+
+	/*
+	 * Reuse the rasterizer while the size of the canvas stays the same.
+	 */
+	if this.rasterizer == nil {
+		this.rasterizer = vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	} else {
+		this.rasterizer.Reset(bounds.Dx(), bounds.Dy())
+	}
+
+	r := this.rasterizer
+	minX := float32(bounds.Min.X)
+	minY := float32(bounds.Min.Y)
+	half := width / 2
+
+	/*
+	 * Every segment is drawn as a rectangle around it.
+	 */
+	for i := 0; i+3 < len(points); i += 2 {
+		x0 := points[i] - minX
+		y0 := points[i+1] - minY
+		x1 := points[i+2] - minX
+		y1 := points[i+3] - minY
+		dx := x1 - x0
+		dy := y1 - y0
+		length := float32(math.Hypot(float64(dx), float64(dy)))
+
+		/*
+		 * Degenerate segments are drawn as a square.
+		 */
+		if length == 0 {
+			dx = 1
+			dy = 0
+			length = 1
+		}
+
+		nx := -dy / length * half
+		ny := dx / length * half
+		ex := dx / length * half
+		ey := dy / length * half
+		r.MoveTo(x0+nx-ex, y0+ny-ey)
+		r.LineTo(x1+nx+ex, y1+ny+ey)
+		r.LineTo(x1-nx+ex, y1-ny+ey)
+		r.LineTo(x0-nx-ex, y0-ny-ey)
+		r.ClosePath()
+	}
+
+	r.Draw(this.img, bounds, image.NewUniform(c), image.Point{})
+}
+
+/*
+ * Draws text with its baseline starting at a point.
+ */
+func (this *Image) Text(s string, x int, y int, c color.Color) {
+
+	/*
+	 * Text requires a font.
+	 */
+	if this.face == nil {
+		return
+	}
+
+	d := font.Drawer{
+		Dst:  this.img,
+		Src:  image.NewUniform(c),
+		Face: this.face,
+		Dot:  fixed.P(x, y),
+	}
+
+	d.DrawString(s)
+}
+
+/*
+ * Draws an image scaled to a rectangle without smoothing, so every source
+ * pixel stays a sharp block.
+ */
+func (this *Image) DrawImage(img *image.RGBA, dst image.Rectangle) {
+	xdraw.NearestNeighbor.Scale(this.img, dst, img, img.Bounds(), xdraw.Over, nil)
+}
+
+/*
+ * Creates a canvas drawing into a new image of a certain size, using a font
+ * face for text. The face may be nil if no text is drawn.
+ */
+func CreateImage(width int, height int, face font.Face) *Image {
+
+	/*
+	 * Create data structure for a canvas.
+	 */
+	canvas := &Image{
+		img:  image.NewRGBA(image.Rect(0, 0, width, height)),
+		face: face,
+	}
+
+	return canvas
+}
@@ -0,0 +1,296 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+/*
+ * Returns whether a pixel of an image is brighter than half intensity.
+ */
+func lit(img *image.RGBA, x int, y int) bool {
+	c := img.RGBAAt(x, y)
+	return c.R > 128 || c.G > 128 || c.B > 128
+}
+
+/*
+ * Counts the lit pixels within an area.
+ */
+func countLit(img *image.RGBA, r image.Rectangle) int {
+	count := 0
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+
+		for x := r.Min.X; x < r.Max.X; x++ {
+
+			if lit(img, x, y) {
+				count++
+			}
+
+		}
+
+	}
+
+	return count
+}
+
+/*
+ * Check drawing of the primitives.
+ */
+func TestImage(t *testing.T) {
+	c := CreateImage(100, 60, basicfont.Face7x13)
+	c.Fill(color.Black)
+	img := c.RGBA()
+
+	if n := countLit(img, img.Bounds()); n != 0 {
+		t.Fatalf("Filled canvas has %d lit pixels, expected %d.", n, 0)
+	}
+
+	c.FillRect(10, 10, 5, 4, color.White)
+
+	if n := countLit(img, img.Bounds()); n != 20 {
+		t.Errorf("Rectangle has %d lit pixels, expected %d.", n, 20)
+	}
+
+	if !lit(img, 10, 10) || !lit(img, 14, 13) || lit(img, 15, 13) {
+		t.Errorf("%s", "Rectangle covers the wrong pixels.")
+	}
+
+	c.Fill(color.Black)
+	c.Polyline([]float32{0, 30.5, 50.5, 30.5, 50.5, 59}, 1, color.White)
+
+	/*
+	 * The line must cover the row it passes through.
+	 */
+	for x := 1; x < 49; x++ {
+
+		if !lit(img, x, 30) {
+			t.Fatalf("Pixel %d of horizontal segment is not lit.", x)
+		}
+
+		if lit(img, x, 28) || lit(img, x, 32) {
+			t.Fatalf("Horizontal segment is wider than expected at pixel %d.", x)
+		}
+
+	}
+
+	if !lit(img, 50, 45) {
+		t.Errorf("%s", "Vertical segment is not drawn.")
+	}
+
+	c.Fill(color.Black)
+	c.Text("bard", 5, 20, color.White)
+
+	/*
+	 * Text is drawn above its baseline.
+	 */
+	if countLit(img, image.Rect(5, 7, 40, 20)) == 0 {
+		t.Errorf("%s", "Text was not drawn.")
+	}
+
+	if n := countLit(img, image.Rect(0, 30, 100, 60)); n != 0 {
+		t.Errorf("Text drew %d pixels far below its baseline.", n)
+	}
+
+}
+
+/*
+ * Check that sections keep absolute coordinates and clip drawing.
+ */
+func TestSub(t *testing.T) {
+	c := CreateImage(100, 100, nil)
+	c.Fill(color.Black)
+	sub := c.Sub(image.Rect(0, 50, 100, 100))
+
+	if sub.Bounds() != image.Rect(0, 50, 100, 100) {
+		t.Fatalf("Section has bounds %v, expected %v.", sub.Bounds(), image.Rect(0, 50, 100, 100))
+	}
+
+	sub.FillRect(0, 0, 100, 100, color.White)
+	sub.Polyline([]float32{0, 75.5, 100, 75.5}, 1, color.Black)
+	img := c.RGBA()
+
+	if n := countLit(img, image.Rect(0, 0, 100, 50)); n != 0 {
+		t.Errorf("Drawing leaked %d pixels out of the section.", n)
+	}
+
+	if !lit(img, 10, 60) || lit(img, 10, 75) {
+		t.Errorf("%s", "Section was not drawn at absolute coordinates.")
+	}
+
+	/*
+	 * Drawing text without a font has no effect.
+	 */
+	sub.Text("bard", 10, 90, color.Black)
+
+	if !lit(img, 12, 85) {
+		t.Errorf("%s", "Text was drawn without a font.")
+	}
+
+}
+
+/*
+ * Check that images are scaled into place.
+ */
+func TestDrawImage(t *testing.T) {
+	c := CreateImage(40, 40, nil)
+	c.Fill(color.Black)
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(1, 0, color.RGBA{255, 255, 255, 255})
+	src.SetRGBA(0, 0, color.RGBA{0, 0, 0, 255})
+	c.DrawImage(src, image.Rect(10, 10, 30, 20))
+	img := c.RGBA()
+
+	if n := countLit(img, img.Bounds()); n != 100 {
+		t.Errorf("Scaled image has %d lit pixels, expected %d.", n, 100)
+	}
+
+	if !lit(img, 20, 10) || !lit(img, 29, 19) || lit(img, 19, 15) {
+		t.Errorf("%s", "Scaled image covers the wrong pixels.")
+	}
+
+}
+
+/*
+ * Check that frames are written as PNG sequences and GIFs.
+ */
+func TestFrameWriters(t *testing.T) {
+	dir := t.TempDir()
+	c := CreateImage(16, 8, nil)
+	framesDir := filepath.Join(dir, "frames")
+	gifPath := filepath.Join(dir, "frames.gif")
+
+	for _, path := range []string{framesDir, gifPath} {
+		w, err := CreateFrameWriter(path, 25)
+
+		/*
+		 * Check if writer could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to create frame writer for '%s': %s", path, msg)
+		}
+
+		for i := 0; i < 3; i++ {
+			c.Fill(color.Gray{Y: uint8(100 * i)})
+			err = w.WriteFrame(c.RGBA())
+
+			if err != nil {
+				msg := err.Error()
+				t.Fatalf("Failed to write frame: %s", msg)
+			}
+
+		}
+
+		err = w.Close()
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to close frame writer: %s", msg)
+		}
+
+	}
+
+	file, err := os.Open(filepath.Join(framesDir, "frame-00002.png"))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open last frame: %s", msg)
+	}
+
+	img, err := png.Decode(file)
+	file.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to decode last frame: %s", msg)
+	}
+
+	if r, _, _, _ := img.At(3, 3).RGBA(); r>>8 != 200 {
+		t.Errorf("Last frame has intensity %d, expected %d.", r>>8, 200)
+	}
+
+	file, err = os.Open(gifPath)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open GIF: %s", msg)
+	}
+
+	anim, err := gif.DecodeAll(file)
+	file.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to decode GIF: %s", msg)
+	}
+
+	if len(anim.Image) != 3 || anim.Delay[0] != 4 {
+		t.Errorf("GIF has %d frames with delay %d, expected %d frames with delay %d.", len(anim.Image), anim.Delay[0], 3, 4)
+	}
+
+	_, err = CreateGIF(gifPath, 0)
+
+	if err == nil {
+		t.Errorf("%s", "Frame rate of zero was accepted.")
+	}
+
+}
+
+/*
+ * Check that scaled canvases draw logical coordinates at their scale.
+ */
+func TestScaled(t *testing.T) {
+	c := CreateImage(100, 60, basicfont.Face7x13)
+	c.Fill(color.Black)
+	img := c.RGBA()
+	scaled := CreateScaled(c, 2.0)
+
+	if scaled.Bounds() != image.Rect(0, 0, 50, 30) {
+		t.Errorf("Scaled canvas has bounds %v, expected %v.", scaled.Bounds(), image.Rect(0, 0, 50, 30))
+	}
+
+	scaled.FillRect(10, 10, 5, 4, color.White)
+
+	if n := countLit(img, img.Bounds()); n != 80 {
+		t.Errorf("Rectangle has %d lit pixels, expected %d.", n, 80)
+	}
+
+	if !lit(img, 20, 20) || !lit(img, 29, 27) || lit(img, 30, 27) || lit(img, 19, 20) {
+		t.Errorf("%s", "Rectangle covers the wrong pixels.")
+	}
+
+	/*
+	 * Sections are logical as well.
+	 */
+	sub := scaled.Sub(image.Rect(25, 15, 50, 30))
+
+	if sub.Bounds() != image.Rect(25, 15, 50, 30) {
+		t.Errorf("Section has bounds %v, expected %v.", sub.Bounds(), image.Rect(25, 15, 50, 30))
+	}
+
+	c.Fill(color.Black)
+	sub.Fill(color.White)
+
+	if n := countLit(img, img.Bounds()); n != 50*30 || !lit(img, 50, 30) {
+		t.Errorf("Section filled %d pixels, expected %d from (50, 30).", n, 50*30)
+	}
+
+	c.Fill(color.Black)
+	scaled.Polyline([]float32{0, 15, 50, 15}, 1, color.White)
+
+	/*
+	 * The line is twice as wide.
+	 */
+	if !lit(img, 40, 29) || !lit(img, 40, 30) || lit(img, 40, 28) || lit(img, 40, 31) {
+		t.Errorf("%s", "Line covers the wrong pixels.")
+	}
+
+}
@@ -0,0 +1,244 @@
+package canvas
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	FRAME_PATTERN = "frame-%05d.png"
+)
+
+/*
+ * Interface implemented by everything that stores rendered frames.
+ */
+type FrameWriter interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+/*
+ * Data structure representing a sequence of numbered PNG files in a
+ * directory, suitable for assembling a video.
+ */
+type PNGSequence struct {
+	dir   string
+	count int
+}
+
+/*
+ * Writes the next frame into its own file.
+ */
+func (this *PNGSequence) WriteFrame(img image.Image) error {
+	path := filepath.Join(this.dir, fmt.Sprintf(FRAME_PATTERN, this.count))
+	file, err := os.Create(path)
+
+	/*
+	 * Check if file could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to create frame '%s': %s", path, msg)
+	}
+
+	err = png.Encode(file, img)
+	errClose := file.Close()
+
+	/*
+	 * Check if frame could be stored.
+	 */
+	if err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write frame '%s': %s", path, msg)
+	}
+
+	this.count++
+	return nil
+}
+
+/*
+ * Returns the number of frames written.
+ */
+func (this *PNGSequence) Frames() int {
+	return this.count
+}
+
+/*
+ * Finishes the sequence. The files are complete as soon as they are written.
+ */
+func (this *PNGSequence) Close() error {
+	return nil
+}
+
+/*
+ * Creates a sequence of PNG files in a directory, which is created if it
+ * does not exist.
+ */
+func CreatePNGSequence(dir string) (*PNGSequence, error) {
+	err := os.MkdirAll(dir, 0755)
+
+	/*
+	 * Check if directory could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create frame directory '%s': %s", dir, msg)
+	}
+
+	/*
+	 * Create data structure for a sequence.
+	 */
+	sequence := &PNGSequence{
+		dir: dir,
+	}
+
+	return sequence, nil
+}
+
+/*
+ * Data structure representing an animated GIF which is written when it is
+ * closed.
+ */
+type GIF struct {
+	path  string
+	delay int
+	anim  gif.GIF
+}
+
+/*
+ * Adds a frame to the animation, reducing it to the web-safe palette.
+ */
+func (this *GIF) WriteFrame(img image.Image) error {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, palette.WebSafe)
+	draw.Draw(paletted, bounds, img, bounds.Min, draw.Src)
+	this.anim.Image = append(this.anim.Image, paletted)
+	this.anim.Delay = append(this.anim.Delay, this.delay)
+	return nil
+}
+
+/*
+ * Returns the number of frames written.
+ */
+func (this *GIF) Frames() int {
+	return len(this.anim.Image)
+}
+
+/*
+ * Encodes the animation into its file.
+ */
+func (this *GIF) Close() error {
+
+	/*
+	 * An animation needs at least one frame.
+	 */
+	if len(this.anim.Image) == 0 {
+		return fmt.Errorf("GIF '%s' has no frames.", this.path)
+	}
+
+	file, err := os.Create(this.path)
+
+	/*
+	 * Check if file could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to create GIF '%s': %s", this.path, msg)
+	}
+
+	err = gif.EncodeAll(file, &this.anim)
+	errClose := file.Close()
+
+	/*
+	 * Check if animation could be stored.
+	 */
+	if err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write GIF '%s': %s", this.path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Creates an animated GIF showing a certain number of frames per second.
+ *
+ * GIF delays are stored in hundredths of a second, so the frame rate is
+ * rounded to the closest delay.
+ */
+func CreateGIF(path string, frameRate float64) (*GIF, error) {
+
+	/*
+	 * Check the frame rate.
+	 */
+	if !(frameRate > 0.0) {
+		return nil, fmt.Errorf("Frame rate must be positive, got %f.", frameRate)
+	}
+
+	delay := int(math.Round(100.0 / frameRate))
+
+	/*
+	 * Browsers do not honour delays below two hundredths of a second.
+	 */
+	if delay < 2 {
+		delay = 2
+	}
+
+	/*
+	 * Create data structure for an animation.
+	 */
+	animation := &GIF{
+		path:  path,
+		delay: delay,
+	}
+
+	return animation, nil
+}
+
+/*
+ * Creates a frame writer for a path. Paths ending in .gif produce an
+ * animated GIF, any other path is a directory of PNG files.
+ */
+func CreateFrameWriter(path string, frameRate float64) (FrameWriter, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+
+	/*
+	 * Choose the writer by extension.
+	 */
+	if extension == ".gif" {
+		animation, err := CreateGIF(path, frameRate)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return animation, nil
+	}
+
+	sequence, err := CreatePNGSequence(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sequence, nil
+}
@@ -0,0 +1,128 @@
+package canvas
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+/*
+ * Data structure representing a canvas drawing into another one at a
+ * larger scale, like the pixels of a high resolution display.
+ *
+ * Coordinates are logical and multiplied by the factor before they are
+ * drawn. Text is only moved, so the canvas drawn into must draw it with a
+ * face scaled by the same factor.
+ */
+type Scaled struct {
+	canvas Canvas
+	factor float64
+	points *[]float32
+}
+
+/*
+ * Converts a logical coordinate into one of the canvas drawn into.
+ */
+func (this *Scaled) up(value int) int {
+	return int(math.Round(float64(value) * this.factor))
+}
+
+/*
+ * Converts a coordinate of the canvas drawn into into a logical one.
+ */
+func (this *Scaled) down(value int) int {
+	return int(math.Floor(float64(value)/this.factor + 1e-9))
+}
+
+/*
+ * Converts a logical rectangle into one of the canvas drawn into.
+ */
+func (this *Scaled) upRect(r image.Rectangle) image.Rectangle {
+	return image.Rect(this.up(r.Min.X), this.up(r.Min.Y), this.up(r.Max.X), this.up(r.Max.Y))
+}
+
+/*
+ * Returns the logical area of the canvas.
+ */
+func (this *Scaled) Bounds() image.Rectangle {
+	b := this.canvas.Bounds()
+	return image.Rect(this.down(b.Min.X), this.down(b.Min.Y), this.down(b.Max.X), this.down(b.Max.Y))
+}
+
+/*
+ * Returns a canvas drawing into a logical section of this one.
+ */
+func (this *Scaled) Sub(r image.Rectangle) Canvas {
+
+	/*
+	 * Create data structure for a scaled section.
+	 */
+	sub := &Scaled{
+		canvas: this.canvas.Sub(this.upRect(r)),
+		factor: this.factor,
+		points: this.points,
+	}
+
+	return sub
+}
+
+/*
+ * Fills the whole canvas with a color.
+ */
+func (this *Scaled) Fill(c color.Color) {
+	this.canvas.Fill(c)
+}
+
+/*
+ * Fills a rectangle with a color.
+ */
+func (this *Scaled) FillRect(x float32, y float32, width float32, height float32, c color.Color) {
+	f := float32(this.factor)
+	this.canvas.FillRect(f*x, f*y, f*width, f*height, c)
+}
+
+/*
+ * Draws connected line segments through pairs of coordinates.
+ */
+func (this *Scaled) Polyline(points []float32, width float32, c color.Color) {
+	f := float32(this.factor)
+	scaled := (*this.points)[:0]
+
+	for _, value := range points {
+		scaled = append(scaled, f*value)
+	}
+
+	this.canvas.Polyline(scaled, f*width, c)
+	*this.points = scaled
+}
+
+/*
+ * Draws text with its baseline starting at a point.
+ */
+func (this *Scaled) Text(s string, x int, y int, c color.Color) {
+	this.canvas.Text(s, this.up(x), this.up(y), c)
+}
+
+/*
+ * Draws an image scaled to a logical rectangle.
+ */
+func (this *Scaled) DrawImage(img *image.RGBA, dst image.Rectangle) {
+	this.canvas.DrawImage(img, this.upRect(dst))
+}
+
+/*
+ * Creates a canvas drawing into another one scaled by a factor.
+ */
+func CreateScaled(c Canvas, factor float64) *Scaled {
+
+	/*
+	 * Create data structure for a scaled canvas.
+	 */
+	scaled := &Scaled{
+		canvas: c,
+		factor: factor,
+		points: &[]float32{},
+	}
+
+	return scaled
+}
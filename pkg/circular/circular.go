@@ -114,13 +114,11 @@ func (b *Buffer[T]) At(n int) *T {
  */
 func CreateBuffer[T any](size int) *Buffer[T] {
 	values := make([]T, size)
-	m := sync.RWMutex{}
 
 	/*
 	 * Create circular buffer.
 	 */
 	buf := Buffer[T]{
-		mutex:   m,
 		values:  values,
 		pointer: 0,
 	}
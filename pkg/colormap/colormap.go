@@ -0,0 +1,158 @@
+package colormap
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	GRAY    = "gray"
+	HOT     = "hot"
+	INFERNO = "inferno"
+	MAGMA   = "magma"
+	VIRIDIS = "viridis"
+	DEFAULT = VIRIDIS
+)
+
+/*
+ * Data structure representing a colour map, which assigns colours to
+ * intensities between zero and one by interpolating between evenly spaced
+ * stops.
+ */
+type Map struct {
+	name  string
+	stops []color.RGBA
+}
+
+/*
+ * Stops of the built-in colour maps. The perceptual maps are sampled from
+ * the matplotlib maps of the same name.
+ */
+var maps = map[string][]uint32{
+	GRAY: {0x000000, 0xffffff},
+	HOT:  {0x000000, 0xe60000, 0xffd200, 0xffffff},
+	INFERNO: {
+		0x000004, 0x1b0c41, 0x4a0c6b, 0x781c6d, 0xa52c60,
+		0xcf4446, 0xed6925, 0xfb9b06, 0xf7d13d, 0xfcffa4,
+	},
+	MAGMA: {
+		0x000004, 0x180f3d, 0x440f76, 0x721f81, 0x9e2f7f,
+		0xcd4071, 0xf1605d, 0xfd9668, 0xfeca8d, 0xfcfdbf,
+	},
+	VIRIDIS: {
+		0x440154, 0x482878, 0x3e4989, 0x31688e, 0x26828e,
+		0x1f9e89, 0x35b779, 0x6ece58, 0xb5de2b, 0xfde725,
+	},
+}
+
+/*
+ * Returns the name of the colour map.
+ */
+func (this *Map) Name() string {
+	return this.name
+}
+
+/*
+ * Returns the colour of an intensity. Intensities outside of the range from
+ * zero to one are clamped, NaN maps to the lowest colour.
+ */
+func (this *Map) At(x float64) color.RGBA {
+
+	/*
+	 * Clamp the intensity.
+	 */
+	if !(x > 0.0) {
+		x = 0.0
+	} else if x > 1.0 {
+		x = 1.0
+	}
+
+	last := len(this.stops) - 1
+	position := x * float64(last)
+	idx := int(math.Floor(position))
+
+	/*
+	 * The highest intensity is the last stop.
+	 */
+	if idx >= last {
+		return this.stops[last]
+	}
+
+	frac := position - float64(idx)
+	a := this.stops[idx]
+	b := this.stops[idx+1]
+
+	/*
+	 * Interpolate between two stops.
+	 */
+	mix := func(u uint8, v uint8) uint8 {
+		return uint8(math.Round(float64(u) + frac*(float64(v)-float64(u))))
+	}
+
+	c := color.RGBA{
+		R: mix(a.R, b.R),
+		G: mix(a.G, b.G),
+		B: mix(a.B, b.B),
+		A: 255,
+	}
+
+	return c
+}
+
+/*
+ * Returns the names of all colour maps in alphabetical order.
+ */
+func Names() []string {
+	names := make([]string, 0, len(maps))
+
+	for name := range maps {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+/*
+ * Returns the colour map of a certain name.
+ */
+func Get(name string) (*Map, error) {
+	values, ok := maps[strings.ToLower(name)]
+
+	/*
+	 * Check if colour map exists.
+	 */
+	if !ok {
+		list := strings.Join(Names(), ", ")
+		return nil, fmt.Errorf("Unknown colour map '%s', expected one of %s.", name, list)
+	}
+
+	stops := make([]color.RGBA, len(values))
+
+	for i, value := range values {
+
+		stops[i] = color.RGBA{
+			R: uint8(value >> 16),
+			G: uint8(value >> 8),
+			B: uint8(value),
+			A: 255,
+		}
+
+	}
+
+	/*
+	 * Create data structure for a colour map.
+	 */
+	m := &Map{
+		name:  strings.ToLower(name),
+		stops: stops,
+	}
+
+	return m, nil
+}
@@ -0,0 +1,84 @@
+package colormap
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+/*
+ * Returns the perceived brightness of a colour.
+ */
+func luma(c color.RGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+/*
+ * Perform a unit test on the colour maps.
+ */
+func TestMaps(t *testing.T) {
+	names := Names()
+
+	if len(names) != 5 || names[0] != GRAY {
+		t.Fatalf("Colour maps are %v, expected five starting with '%s'.", names, GRAY)
+	}
+
+	for _, name := range names {
+		m, err := Get(name)
+
+		/*
+		 * Check if colour map could be found.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to get colour map '%s': %s", name, msg)
+		}
+
+		if m.Name() != name {
+			t.Errorf("Colour map '%s' is named '%s'.", name, m.Name())
+		}
+
+		/*
+		 * Clamped values equal the ends of the map.
+		 */
+		if m.At(-1.0) != m.At(0.0) || m.At(2.0) != m.At(1.0) || m.At(math.NaN()) != m.At(0.0) {
+			t.Errorf("Colour map '%s' does not clamp intensities.", name)
+		}
+
+		previous := -1.0
+
+		/*
+		 * All maps get brighter with intensity.
+		 */
+		for i := 0; i <= 100; i++ {
+			c := m.At(float64(i) / 100.0)
+
+			if c.A != 255 {
+				t.Fatalf("Colour map '%s' is not opaque at %d%%.", name, i)
+			}
+
+			l := luma(c)
+
+			if l < previous-1.0 {
+				t.Errorf("Colour map '%s' gets darker at %d%%: %.1f after %.1f.", name, i, l, previous)
+			}
+
+			previous = l
+		}
+
+	}
+
+	gray, _ := Get("GRAY")
+	mid := gray.At(0.5)
+
+	if mid.R != 128 || mid.G != 128 || mid.B != 128 {
+		t.Errorf("Middle of gray map is %v, expected %v.", mid, color.RGBA{128, 128, 128, 255})
+	}
+
+	_, err := Get("rainbow")
+
+	if err == nil {
+		t.Errorf("%s", "Unknown colour map was accepted.")
+	}
+
+}
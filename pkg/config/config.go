@@ -0,0 +1,207 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	APPLICATION_NAME = "bard"
+	FILE_NAME        = "config.json"
+)
+
+/*
+ * Data structure representing the settings shared by all commands.
+ *
+ * Devices are selected by index or by any part of their name, empty fields
+ * select the default devices. A sample rate or buffer size of zero selects
+ * the default of the device.
+ *
+ * The notes shown by the visualizer are selected by a scale built on a root,
+ * or by a comma-separated list of custom notes, between a low and a high
+ * note. Empty fields keep the defaults of the visualizer.
+ *
+ * The layout arranges the views of the visualizer window, it is stored when
+ * the window is closed after it was changed.
+ */
+type Config struct {
+	HostAPI      string  `json:"hostApi"`
+	Input        string  `json:"input"`
+	Output       string  `json:"output"`
+	SampleRate   float64 `json:"sampleRate"`
+	BufferFrames int     `json:"bufferFrames"`
+	Scale        string  `json:"scale"`
+	Root         string  `json:"root"`
+	LowNote      string  `json:"lowNote"`
+	HighNote     string  `json:"highNote"`
+	Notes        string  `json:"notes"`
+	Layout       string  `json:"layout"`
+}
+
+/*
+ * Returns the path of the configuration file in the user's configuration
+ * directory.
+ */
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+
+	/*
+	 * Check if configuration directory is known.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return "", fmt.Errorf("Failed to locate configuration directory: %s", msg)
+	}
+
+	path := filepath.Join(dir, APPLICATION_NAME, FILE_NAME)
+	return path, nil
+}
+
+/*
+ * Loads a configuration file.
+ *
+ * If path is empty, the file at the default path is loaded if it exists,
+ * otherwise an empty configuration is returned.
+ */
+func Load(path string) (*Config, error) {
+	optional := false
+
+	/*
+	 * Fall back to the default path.
+	 */
+	if path == "" {
+		defaultPath, err := DefaultPath()
+
+		if err != nil {
+			return &Config{}, nil
+		}
+
+		path = defaultPath
+		optional = true
+	}
+
+	buf, err := os.ReadFile(path)
+
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read configuration from '%s': %s", path, msg)
+	}
+
+	c := &Config{}
+	err = json.Unmarshal(buf, c)
+
+	/*
+	 * Check if configuration could be parsed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to parse configuration from '%s': %s", path, msg)
+	}
+
+	return c, nil
+}
+
+/*
+ * Stores the configuration in a file, creating its directory if needed.
+ */
+func (this *Config) Save(path string) error {
+	buf, err := json.MarshalIndent(this, "", "\t")
+
+	/*
+	 * Check if configuration could be serialized.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to serialize configuration: %s", msg)
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, 0755)
+
+	/*
+	 * Check if directory could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to create directory '%s': %s", dir, msg)
+	}
+
+	err = os.WriteFile(path, buf, 0644)
+
+	/*
+	 * Check if file could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write configuration to '%s': %s", path, msg)
+	}
+
+	return nil
+}
+
+/*
+ * Replaces settings with those of another configuration which are not
+ * empty, for example with values given on the command line.
+ */
+func (this *Config) Override(other Config) {
+
+	if other.HostAPI != "" {
+		this.HostAPI = other.HostAPI
+	}
+
+	if other.Input != "" {
+		this.Input = other.Input
+	}
+
+	if other.Output != "" {
+		this.Output = other.Output
+	}
+
+	if other.SampleRate != 0.0 {
+		this.SampleRate = other.SampleRate
+	}
+
+	if other.BufferFrames != 0 {
+		this.BufferFrames = other.BufferFrames
+	}
+
+	if other.Scale != "" {
+		this.Scale = other.Scale
+	}
+
+	if other.Root != "" {
+		this.Root = other.Root
+	}
+
+	if other.LowNote != "" {
+		this.LowNote = other.LowNote
+	}
+
+	if other.HighNote != "" {
+		this.HighNote = other.HighNote
+	}
+
+	if other.Notes != "" {
+		this.Notes = other.Notes
+	}
+
+	if other.Layout != "" {
+		this.Layout = other.Layout
+	}
+
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+/*
+ * Check that a configuration survives saving and loading, and that
+ * overrides only replace given settings.
+ */
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), APPLICATION_NAME, FILE_NAME)
+
+	original := Config{
+		HostAPI:    "alsa",
+		Input:      "Live camera",
+		Output:     "Jabra",
+		SampleRate: 48000.0,
+		Scale:      "dorian",
+		Root:       "D",
+		Layout:     "roll;2*panel,tuner:0.5",
+	}
+
+	err := original.Save(path)
+
+	/*
+	 * Check if configuration could be saved.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to save configuration: %s", msg)
+	}
+
+	loaded, err := Load(path)
+
+	/*
+	 * Check if configuration could be loaded.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to load configuration: %s", msg)
+	}
+
+	if *loaded != original {
+		t.Errorf("Loaded configuration %+v, expected %+v.", *loaded, original)
+	}
+
+	loaded.Override(Config{Input: "2", BufferFrames: 256, Root: "E"})
+	expected := Config{HostAPI: "alsa", Input: "2", Output: "Jabra", SampleRate: 48000.0, BufferFrames: 256, Scale: "dorian", Root: "E", Layout: "roll;2*panel,tuner:0.5"}
+
+	/*
+	 * Check if only the given setting was replaced.
+	 */
+	if *loaded != expected {
+		t.Errorf("Overridden configuration is %+v, expected %+v.", *loaded, expected)
+	}
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+
+	/*
+	 * Explicitly requested files must exist.
+	 */
+	if err == nil {
+		t.Errorf("%s", "Loading a missing file succeeded, expected an error.")
+	}
+
+}
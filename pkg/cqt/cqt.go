@@ -0,0 +1,300 @@
+package cqt
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	REFERENCE_FREQUENCY  = 440.0
+	SEMITONES_PER_OCTAVE = 12
+)
+
+/*
+ * Data structure describing the layout of a constant-Q transform.
+ *
+ * Bins are placed on a grid of BinsPerSemitone subdivisions of the
+ * equal-tempered scale, relative to the Reference frequency (A4).
+ *
+ * A Gamma greater than zero widens the bandwidth of every bin by a constant
+ * amount in Hz, which turns the transform into a variable-Q transform with
+ * shorter kernels (and better time resolution) at low frequencies.
+ *
+ * If MaxLength is greater than zero, kernels are limited to this number of
+ * samples, trading frequency resolution at low notes for latency.
+ */
+type Config struct {
+	SampleRate      float64
+	MinFrequency    float64
+	MaxFrequency    float64
+	BinsPerSemitone int
+	Reference       float64
+	Gamma           float64
+	MaxLength       int
+}
+
+/*
+ * Data structure representing a single frequency bin.
+ *
+ * Semitone is the distance to the reference note in (fractional) semitones.
+ */
+type Bin struct {
+	Frequency float64
+	Semitone  float64
+	Bandwidth float64
+	Length    int
+}
+
+/*
+ * Data structure representing a constant-Q transform.
+ *
+ * The kernels are precomputed on creation, so processing does not allocate.
+ */
+type Transform struct {
+	bins      []Bin
+	kernelRe  [][]float64
+	kernelIm  [][]float64
+	maxLength int
+}
+
+/*
+ * Returns the quality factor for the given number of bins per semitone.
+ */
+func quality(binsPerSemitone int) float64 {
+	binsPerOctave := float64(SEMITONES_PER_OCTAVE * binsPerSemitone)
+	ratio := math.Pow(2.0, 1.0/binsPerOctave)
+	return 1.0 / (ratio - 1.0)
+}
+
+/*
+ * Calculates a Hann window of a certain length and returns it together with
+ * the sum of its coefficients.
+ */
+func hann(length int) ([]float64, float64) {
+	window := make([]float64, length)
+	sum := 0.0
+
+	/*
+	 * A window of a single sample degenerates to a rectangle.
+	 */
+	if length == 1 {
+		window[0] = 1.0
+		return window, 1.0
+	}
+
+	lengthFloat := float64(length - 1)
+
+	for i := range window {
+		arg := 2.0 * math.Pi * float64(i) / lengthFloat
+		value := 0.5 - 0.5*math.Cos(arg)
+		window[i] = value
+		sum += value
+	}
+
+	return window, sum
+}
+
+/*
+ * Returns the frequency bins of the transform, ordered from low to high.
+ */
+func (this *Transform) Bins() []Bin {
+	return this.bins
+}
+
+/*
+ * Returns the number of samples required to calculate all bins.
+ */
+func (this *Transform) Length() int {
+	return this.maxLength
+}
+
+/*
+ * Returns the index of the bin closest to a certain frequency.
+ *
+ * A transform always has bins, so the index is valid.
+ */
+func (this *Transform) Closest(frequency float64) int {
+	bins := this.bins
+	bestIdx := -1
+	bestDist := math.Inf(1)
+
+	/*
+	 * Compare frequencies on a logarithmic scale.
+	 */
+	for i, bin := range bins {
+		dist := math.Abs(math.Log2(frequency / bin.Frequency))
+
+		if dist < bestDist {
+			bestIdx = i
+			bestDist = dist
+		}
+
+	}
+
+	return bestIdx
+}
+
+/*
+ * Calculates the magnitude of each bin and stores it into out.
+ *
+ * All kernels are aligned to the end of the sample buffer, so that each bin
+ * reflects the most recent samples. A sinusoid of amplitude A centered on a
+ * bin results in a magnitude of A.
+ */
+func (this *Transform) Process(samples []float64, out []float64) error {
+	bins := this.bins
+	numBins := len(bins)
+	numSamples := len(samples)
+
+	/*
+	 * Ensure the buffers are large enough.
+	 */
+	if len(out) != numBins {
+		return fmt.Errorf("Output buffer must have %d elements, has %d.", numBins, len(out))
+	} else if numSamples < this.maxLength {
+		return fmt.Errorf("Transform requires %d samples, got %d.", this.maxLength, numSamples)
+	} else {
+
+		/*
+		 * Correlate the tail of the signal with each kernel.
+		 */
+		for k := range bins {
+			kernelRe := this.kernelRe[k]
+			kernelIm := this.kernelIm[k]
+			offset := numSamples - len(kernelRe)
+			tail := samples[offset:]
+			sumRe := 0.0
+			sumIm := 0.0
+
+			for i, sample := range tail {
+				sumRe += sample * kernelRe[i]
+				sumIm += sample * kernelIm[i]
+			}
+
+			out[k] = math.Hypot(sumRe, sumIm)
+		}
+
+		return nil
+	}
+
+}
+
+/*
+ * Creates a constant-Q transform.
+ */
+func Create(config Config) (*Transform, error) {
+	sampleRate := config.SampleRate
+	minFreq := config.MinFrequency
+	maxFreq := config.MaxFrequency
+	binsPerSemitone := config.BinsPerSemitone
+	reference := config.Reference
+
+	/*
+	 * Apply defaults.
+	 */
+	if binsPerSemitone <= 0 {
+		binsPerSemitone = 1
+	}
+
+	if reference <= 0.0 {
+		reference = REFERENCE_FREQUENCY
+	}
+
+	nyquist := 0.5 * sampleRate
+
+	/*
+	 * Validate the frequency range.
+	 */
+	if sampleRate <= 0.0 {
+		return nil, fmt.Errorf("Sample rate must be positive, is %f.", sampleRate)
+	} else if minFreq <= 0.0 || maxFreq < minFreq {
+		return nil, fmt.Errorf("Invalid frequency range [%f, %f].", minFreq, maxFreq)
+	} else if maxFreq >= nyquist {
+		return nil, fmt.Errorf("Maximum frequency %f must be below the Nyquist frequency %f.", maxFreq, nyquist)
+	} else {
+		q := quality(binsPerSemitone)
+		step := 1.0 / float64(binsPerSemitone)
+		bpsFloat := float64(binsPerSemitone)
+
+		/*
+		 * Find the grid positions enclosing the frequency range.
+		 *
+		 * The small epsilon keeps exact note frequencies inside the range.
+		 */
+		firstIdx := int(math.Ceil(SEMITONES_PER_OCTAVE*bpsFloat*math.Log2(minFreq/reference) - 1e-6))
+		lastIdx := int(math.Floor(SEMITONES_PER_OCTAVE*bpsFloat*math.Log2(maxFreq/reference) + 1e-6))
+
+		/*
+		 * Make sure the range contains at least one bin.
+		 */
+		if lastIdx < firstIdx {
+			return nil, fmt.Errorf("Frequency range [%f, %f] contains no bins.", minFreq, maxFreq)
+		}
+
+		bins := []Bin{}
+		kernelsRe := [][]float64{}
+		kernelsIm := [][]float64{}
+		maxLength := 0
+
+		for idx := firstIdx; idx <= lastIdx; idx++ {
+			semitone := float64(idx) * step
+			freq := reference * math.Pow(2.0, semitone/SEMITONES_PER_OCTAVE)
+			bandwidth := (freq / q) + config.Gamma
+			length := int(math.Ceil(sampleRate / bandwidth))
+
+			/*
+			 * Limit the kernel length if requested.
+			 */
+			if config.MaxLength > 0 && length > config.MaxLength {
+				length = config.MaxLength
+			}
+
+			window, windowSum := hann(length)
+			scale := 2.0 / windowSum
+			kernelRe := make([]float64, length)
+			kernelIm := make([]float64, length)
+			omega := 2.0 * math.Pi * freq / sampleRate
+
+			/*
+			 * Modulate the window with a complex exponential.
+			 */
+			for i, w := range window {
+				arg := omega * float64(i)
+				kernelRe[i] = scale * w * math.Cos(arg)
+				kernelIm[i] = -scale * w * math.Sin(arg)
+			}
+
+			bin := Bin{
+				Frequency: freq,
+				Semitone:  semitone,
+				Bandwidth: bandwidth,
+				Length:    length,
+			}
+
+			bins = append(bins, bin)
+			kernelsRe = append(kernelsRe, kernelRe)
+			kernelsIm = append(kernelsIm, kernelIm)
+
+			if length > maxLength {
+				maxLength = length
+			}
+
+		}
+
+		/*
+		 * Create data structure for the transform.
+		 */
+		t := Transform{
+			bins:      bins,
+			kernelRe:  kernelsRe,
+			kernelIm:  kernelsIm,
+			maxLength: maxLength,
+		}
+
+		return &t, nil
+	}
+
+}
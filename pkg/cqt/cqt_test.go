@@ -0,0 +1,169 @@
+package cqt
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Generates a sine wave of a certain frequency and amplitude.
+ */
+func sine(frequency float64, amplitude float64, sampleRate float64, length int) []float64 {
+	buf := make([]float64, length)
+
+	for i := range buf {
+		arg := 2.0 * math.Pi * frequency * float64(i) / sampleRate
+		buf[i] = amplitude * math.Sin(arg)
+	}
+
+	return buf
+}
+
+/*
+ * Perform a unit test on the constant-Q transform.
+ */
+func TestTransform(t *testing.T) {
+	sampleRate := 44100.0
+
+	/*
+	 * Frequencies to test, from low notes where a linear FFT of the same
+	 * length cannot tell neighbouring semitones apart, to high notes.
+	 */
+	frequencies := []float64{
+		73.4162,
+		110.0000,
+		116.5409,
+		261.6256,
+		440.0000,
+		1318.5102,
+	}
+
+	tr, err := Create(Config{
+		SampleRate:      sampleRate,
+		MinFrequency:    60.0,
+		MaxFrequency:    2000.0,
+		BinsPerSemitone: 1,
+	})
+
+	/*
+	 * Check if transform could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create transform: %s", msg)
+	}
+
+	bins := tr.Bins()
+	out := make([]float64, len(bins))
+
+	for _, freq := range frequencies {
+		samples := sine(freq, 0.5, sampleRate, tr.Length())
+		err := tr.Process(samples, out)
+
+		/*
+		 * Check if transform could be calculated.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to process %.1f Hz: %s", freq, msg)
+		} else {
+			expected := tr.Closest(freq)
+			maxIdx := 0
+
+			for i := range out {
+
+				if out[i] > out[maxIdx] {
+					maxIdx = i
+				}
+
+			}
+
+			/*
+			 * Check if the sine ends up in the right bin.
+			 */
+			if maxIdx != expected {
+				t.Errorf("Expected %.1f Hz in bin %d (%.1f Hz), got bin %d (%.1f Hz).", freq, expected, bins[expected].Frequency, maxIdx, bins[maxIdx].Frequency)
+			}
+
+			magnitude := out[expected]
+
+			/*
+			 * Check if the magnitude reflects the amplitude.
+			 */
+			if math.Abs(magnitude-0.5) > 0.01 {
+				t.Errorf("Expected magnitude %.2f for %.1f Hz, got %.4f.", 0.5, freq, magnitude)
+			}
+
+			/*
+			 * Check if the energy does not leak beyond the main lobe,
+			 * which spans one semitone to each side.
+			 */
+			if expected > 1 && out[expected-2] > 0.05*magnitude {
+				t.Errorf("Leakage of %.1f Hz two semitones down is %.4f.", freq, out[expected-2])
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Check that bins are aligned to semitone subdivisions.
+ */
+func TestBinsPerSemitone(t *testing.T) {
+	tr, err := Create(Config{
+		SampleRate:      48000.0,
+		MinFrequency:    440.0,
+		MaxFrequency:    880.0,
+		BinsPerSemitone: 3,
+	})
+
+	/*
+	 * Check if transform could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create transform: %s", msg)
+	}
+
+	bins := tr.Bins()
+	numBins := len(bins)
+
+	/*
+	 * One octave with three bins per semitone, including both ends.
+	 */
+	if numBins != 37 {
+		t.Errorf("Expected %d bins, got %d.", 37, numBins)
+	}
+
+	for i, bin := range bins {
+		expected := float64(i) / 3.0
+
+		/*
+		 * Check the position of each bin.
+		 */
+		if math.Abs(bin.Semitone-expected) > 1e-9 {
+			t.Errorf("Bin %d is at semitone %f, expected %f.", i, bin.Semitone, expected)
+		}
+
+	}
+
+}
+
+/*
+ * Check that a frequency range without bins is rejected.
+ */
+func TestEmptyRange(t *testing.T) {
+	_, err := Create(Config{
+		SampleRate:      44100.0,
+		MinFrequency:    441.0,
+		MaxFrequency:    460.0,
+		BinsPerSemitone: 1,
+	})
+
+	if err == nil {
+		t.Errorf("%s", "Range between two semitones was accepted.")
+	}
+
+}
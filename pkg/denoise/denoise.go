@@ -0,0 +1,291 @@
+package denoise
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andrepxx/go-dsp-guitar/fft"
+)
+
+/*
+ * Methods for removing noise from a spectrum.
+ */
+type Method int
+
+const (
+	SPECTRAL_SUBTRACTION Method = iota
+	WIENER
+)
+
+/*
+ * Data structure describing how noise is removed.
+ *
+ * OverSubtraction scales the noise profile before it is removed, so that
+ * fluctuations above the mean noise level are suppressed as well.
+ *
+ * SpectralFloor is the minimum gain applied to any bin. Keeping some of the
+ * residual noise avoids "musical noise" artifacts.
+ */
+type Config struct {
+	Method          Method
+	OverSubtraction float64
+	SpectralFloor   float64
+}
+
+/*
+ * Data structure representing a streaming noise reduction.
+ *
+ * Samples are processed in frames with 50 percent overlap and resynthesized
+ * by overlap-add, which delays the signal by one frame.
+ */
+type Denoiser struct {
+	config           Config
+	profile          *Profile
+	noise            []float64
+	window           []float64
+	fourierTransform fft.FourierTransform
+	frame            []float64
+	fill             int
+	accumulator      []float64
+	output           []float64
+	bufFrame         []float64
+	bufFFT           []complex128
+}
+
+/*
+ * Returns a noise reduction configuration suitable for a rehearsal room.
+ */
+func DefaultConfig() Config {
+	return Config{
+		Method:          WIENER,
+		OverSubtraction: 2.0,
+		SpectralFloor:   0.05,
+	}
+}
+
+/*
+ * Calculates the gain applied to a bin of a certain magnitude given the
+ * magnitude of the noise in this bin.
+ */
+func Gain(method Method, magnitude float64, noise float64, overSubtraction float64, floor float64) float64 {
+	gain := 1.0
+
+	/*
+	 * Silence has nothing left to remove.
+	 */
+	if magnitude <= 0.0 {
+		return floor
+	}
+
+	switch method {
+	case WIENER:
+		power := magnitude * magnitude
+		noisePower := overSubtraction * noise * noise
+
+		/*
+		 * Estimate the a-priori signal-to-noise ratio.
+		 */
+		if noisePower > 0.0 {
+			snr := math.Max((power/noisePower)-1.0, 0.0)
+			gain = snr / (1.0 + snr)
+		}
+
+	default:
+		gain = 1.0 - (overSubtraction * noise / magnitude)
+	}
+
+	/*
+	 * Never fall below the spectral floor.
+	 */
+	if gain < floor {
+		gain = floor
+	}
+
+	return gain
+}
+
+/*
+ * Removes noise from a magnitude of any spectral representation.
+ *
+ * This allows cleaning magnitudes obtained by other transforms, as long as
+ * they are scaled to amplitudes like the noise profile.
+ */
+func Subtract(magnitude float64, noise float64, config Config) float64 {
+	gain := Gain(config.Method, magnitude, noise, config.OverSubtraction, config.SpectralFloor)
+	return gain * magnitude
+}
+
+/*
+ * Returns the noise profile used by the denoiser.
+ */
+func (this *Denoiser) Profile() *Profile {
+	return this.profile
+}
+
+/*
+ * Returns the delay introduced by the denoiser in samples.
+ */
+func (this *Denoiser) Latency() int {
+	return this.profile.FrameSize
+}
+
+/*
+ * Cleans a full frame and adds it to the overlap-add accumulator.
+ */
+func (this *Denoiser) synthesize() {
+	config := this.config
+	frameSize := len(this.frame)
+	hop := frameSize / 2
+	window := this.window
+	bufFrame := this.bufFrame
+	bufFFT := this.bufFFT
+	noise := this.noise
+
+	for i, x := range this.frame {
+		bufFrame[i] = window[i] * x
+	}
+
+	err := this.fourierTransform.RealFourier(bufFrame, bufFFT, fft.SCALING_DEFAULT)
+
+	/*
+	 * The buffers have matching power of two sizes, so this cannot fail.
+	 */
+	if err == nil {
+
+		/*
+		 * Apply the same gain to positive and negative frequencies.
+		 */
+		for k := 0; k <= hop; k++ {
+			elem := bufFFT[k]
+			magnitude := math.Hypot(real(elem), imag(elem))
+			gain := Gain(config.Method, magnitude, noise[k], config.OverSubtraction, config.SpectralFloor)
+			gainComplex := complex(gain, 0.0)
+			bufFFT[k] = gainComplex * elem
+
+			if k > 0 && k < hop {
+				bufFFT[frameSize-k] *= gainComplex
+			}
+
+		}
+
+		err = this.fourierTransform.RealInverseFourier(bufFFT, bufFrame, fft.SCALING_DEFAULT)
+
+		if err == nil {
+			accumulator := this.accumulator
+
+			for i, x := range bufFrame {
+				accumulator[i] += window[i] * x
+			}
+
+		}
+
+	}
+
+	/*
+	 * Emit the completed first half and shift the accumulator.
+	 */
+	accumulator := this.accumulator
+	copy(this.output, accumulator[0:hop])
+	copy(accumulator[0:hop], accumulator[hop:])
+	fft.ZeroFloat(accumulator[hop:])
+}
+
+/*
+ * Cleans a single sample and returns the delayed output.
+ */
+func (this *Denoiser) push(x float64) float64 {
+	frame := this.frame
+	hop := len(frame) / 2
+	y := this.output[this.fill-hop]
+	frame[this.fill] = x
+	this.fill++
+
+	/*
+	 * Process each full frame, then keep its second half.
+	 */
+	if this.fill == len(frame) {
+		this.synthesize()
+		copy(frame[0:hop], frame[hop:])
+		this.fill = hop
+	}
+
+	return y
+}
+
+/*
+ * Removes noise from samples in place.
+ */
+func (this *Denoiser) Process(samples []float64) {
+
+	for i, x := range samples {
+		samples[i] = this.push(x)
+	}
+
+}
+
+/*
+ * Removes noise from samples in place.
+ */
+func (this *Denoiser) ProcessFloat32(samples []float32) {
+
+	for i, x := range samples {
+		samples[i] = float32(this.push(float64(x)))
+	}
+
+}
+
+/*
+ * Creates a streaming noise reduction based on a noise profile for a stream
+ * of a certain sample rate.
+ *
+ * The bins of the profile are only valid at the sample rate it was learned
+ * at, so the stream must have the same rate.
+ */
+func CreateDenoiser(profile *Profile, sampleRate float64, config Config) (*Denoiser, error) {
+
+	/*
+	 * Check if the profile is valid.
+	 */
+	if profile == nil {
+		return nil, fmt.Errorf("%s", "Denoiser requires a noise profile.")
+	} else if profile.SampleRate != sampleRate {
+		return nil, fmt.Errorf("Noise profile was learned at %.0f Hz, stream has %.0f Hz.", profile.SampleRate, sampleRate)
+	} else if err := checkFrameSize(profile.FrameSize); err != nil {
+		return nil, err
+	} else if len(profile.Spectrum) != profile.FrameSize/2+1 {
+		return nil, fmt.Errorf("Noise profile has %d bins, expected %d.", len(profile.Spectrum), profile.FrameSize/2+1)
+	} else {
+		frameSize := profile.FrameSize
+		hop := frameSize / 2
+		window := sqrtHann(frameSize)
+		scale := amplitudeScale(window)
+		noise := make([]float64, len(profile.Spectrum))
+
+		/*
+		 * Convert the profile back into raw FFT magnitudes.
+		 */
+		for k, value := range profile.Spectrum {
+			noise[k] = value / scale
+		}
+
+		/*
+		 * Create data structure for a denoiser.
+		 */
+		d := Denoiser{
+			config:           config,
+			profile:          profile,
+			noise:            noise,
+			window:           window,
+			fourierTransform: fft.CreateFourierTransform(),
+			frame:            make([]float64, frameSize),
+			fill:             hop,
+			accumulator:      make([]float64, frameSize),
+			output:           make([]float64, hop),
+			bufFrame:         make([]float64, frameSize),
+			bufFFT:           make([]complex128, frameSize),
+		}
+
+		return &d, nil
+	}
+
+}
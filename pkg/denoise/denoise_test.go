@@ -0,0 +1,188 @@
+package denoise
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Generates uniformly distributed white noise.
+ */
+func whiteNoise(random *rand.Rand, amplitude float64, length int) []float64 {
+	buf := make([]float64, length)
+
+	for i := range buf {
+		buf[i] = amplitude * (2.0*random.Float64() - 1.0)
+	}
+
+	return buf
+}
+
+/*
+ * Generates a sine wave.
+ */
+func sine(frequency float64, amplitude float64, sampleRate float64, length int) []float64 {
+	buf := make([]float64, length)
+
+	for i := range buf {
+		arg := 2.0 * math.Pi * frequency * float64(i) / sampleRate
+		buf[i] = amplitude * math.Sin(arg)
+	}
+
+	return buf
+}
+
+/*
+ * Calculates the RMS level of a buffer.
+ */
+func rms(buf []float64) float64 {
+	sum := 0.0
+
+	for _, x := range buf {
+		sum += x * x
+	}
+
+	return math.Sqrt(sum / float64(len(buf)))
+}
+
+/*
+ * Learns a profile of white noise.
+ */
+func learnWhiteNoise(t *testing.T, random *rand.Rand, sampleRate float64) *Profile {
+	learner, err := CreateLearner(DEFAULT_FRAME_SIZE, sampleRate)
+
+	/*
+	 * Check if learner could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create learner: %s", msg)
+	}
+
+	learner.Process(whiteNoise(random, 0.05, 3*int(sampleRate)))
+	profile, err := learner.Profile()
+
+	/*
+	 * Check if profile could be learned.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to learn profile: %s", msg)
+	}
+
+	return profile
+}
+
+/*
+ * Check that a denoiser with unity gain reconstructs its input.
+ */
+func TestReconstruction(t *testing.T) {
+	sampleRate := 48000.0
+	random := rand.New(rand.NewSource(1))
+	profile := learnWhiteNoise(t, random, sampleRate)
+	d, err := CreateDenoiser(profile, sampleRate, Config{
+		Method:        SPECTRAL_SUBTRACTION,
+		SpectralFloor: 1.0,
+	})
+
+	/*
+	 * Check if denoiser could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create denoiser: %s", msg)
+	}
+
+	input := sine(440.0, 0.5, sampleRate, 48000)
+	output := make([]float64, len(input))
+	copy(output, input)
+	d.Process(output)
+	latency := d.Latency()
+
+	/*
+	 * Output is the input delayed by the latency.
+	 */
+	for i := latency; i < len(output); i++ {
+		diff := math.Abs(output[i] - input[i-latency])
+
+		if diff > 1e-9 {
+			t.Fatalf("Output sample %d differs from delayed input by %e.", i, diff)
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on noise removal.
+ */
+func TestDenoise(t *testing.T) {
+	sampleRate := 48000.0
+	random := rand.New(rand.NewSource(1))
+	profile := learnWhiteNoise(t, random, sampleRate)
+
+	/*
+	 * The profile of white noise is flat.
+	 */
+	expected := profile.Magnitude(1000.0)
+	high := profile.Magnitude(10000.0)
+
+	if math.Abs(high-expected) > 0.2*expected {
+		t.Errorf("Profile of white noise not flat: %f at 1 kHz, %f at 10 kHz.", expected, high)
+	}
+
+	for _, method := range []Method{SPECTRAL_SUBTRACTION, WIENER} {
+		config := DefaultConfig()
+		config.Method = method
+		d, err := CreateDenoiser(profile, sampleRate, config)
+
+		/*
+		 * Check if denoiser could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to create denoiser: %s", msg)
+		}
+
+		noise := whiteNoise(random, 0.05, 48000)
+		noiseLevel := rms(noise)
+		d.Process(noise)
+		residual := rms(noise[24000:])
+
+		/*
+		 * Check if noise was attenuated by at least 10 dB.
+		 */
+		if residual > noiseLevel*math.Pow(10.0, -10.0/20.0) {
+			t.Errorf("Method %d leaves noise at %f, input was %f.", method, residual, noiseLevel)
+		}
+
+		tone := sine(440.0, 0.5, sampleRate, 48000)
+		toneLevel := rms(tone)
+		d.Process(tone)
+		kept := rms(tone[24000:])
+
+		/*
+		 * Check if the tone was preserved within 1 dB.
+		 */
+		if kept < toneLevel*math.Pow(10.0, -1.0/20.0) {
+			t.Errorf("Method %d attenuates tone to %f, input was %f.", method, kept, toneLevel)
+		}
+
+	}
+
+}
+
+/*
+ * Check that a profile learned at another sample rate is rejected.
+ */
+func TestSampleRateMismatch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	profile := learnWhiteNoise(t, random, 44100.0)
+	_, err := CreateDenoiser(profile, 48000.0, DefaultConfig())
+
+	if err == nil {
+		t.Errorf("%s", "Profile learned at 44100 Hz was accepted for a stream at 48000 Hz.")
+	}
+
+}
@@ -0,0 +1,248 @@
+package denoise
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+ * Data structure describing how the noise floor of a spectrum is tracked.
+ *
+ * A bin peaks when its magnitude exceeds the floor by Threshold decibels.
+ *
+ * The floor follows falling magnitudes with the time constant FallTime in
+ * seconds and rises by at most Rise decibels per second, or PeakRise while
+ * the bin peaks. Steady noise is thereby absorbed into the floor, while held
+ * notes stay above it for a long time.
+ *
+ * Minimum is the lowest magnitude the floor falls to, so that digital
+ * silence does not make every bit of dither a peak.
+ */
+type FloorConfig struct {
+	Threshold float64
+	Rise      float64
+	PeakRise  float64
+	FallTime  float64
+	Minimum   float64
+}
+
+/*
+ * Data structure tracking the noise floor of each bin of a spectrum.
+ */
+type Floor struct {
+	config  FloorConfig
+	levels  []float64
+	started bool
+	sum     []float64
+	learned int
+}
+
+/*
+ * Returns a noise floor configuration suitable for an instrument played in
+ * a room.
+ */
+func DefaultFloorConfig() FloorConfig {
+	return FloorConfig{
+		Threshold: 12.0,
+		Rise:      3.0,
+		PeakRise:  0.3,
+		FallTime:  0.25,
+		Minimum:   1e-6,
+	}
+}
+
+/*
+ * Converts a level in decibels into a magnitude ratio.
+ */
+func ratio(level float64) float64 {
+	return math.Pow(10.0, level/20.0)
+}
+
+/*
+ * Returns the configuration of the noise floor.
+ */
+func (this *Floor) Config() FloorConfig {
+	return this.config
+}
+
+/*
+ * Changes the level in decibels a bin must exceed the floor by to peak.
+ */
+func (this *Floor) SetThreshold(threshold float64) {
+	this.config.Threshold = threshold
+}
+
+/*
+ * Returns the noise floor of a bin.
+ */
+func (this *Floor) Level(bin int) float64 {
+	return this.levels[bin]
+}
+
+/*
+ * Returns the magnitude a bin must exceed to peak.
+ */
+func (this *Floor) Threshold(bin int) float64 {
+	return this.levels[bin] * ratio(this.config.Threshold)
+}
+
+/*
+ * Returns the level of a magnitude above the floor of a bin in decibels.
+ */
+func (this *Floor) Ratio(bin int, magnitude float64) float64 {
+	magnitude = math.Max(magnitude, this.config.Minimum)
+	return 20.0 * math.Log10(magnitude/this.levels[bin])
+}
+
+/*
+ * Returns whether a magnitude peaks over the floor of a bin.
+ */
+func (this *Floor) Peaks(bin int, magnitude float64) bool {
+	return magnitude > this.Threshold(bin)
+}
+
+/*
+ * Returns the median of a spectrum.
+ */
+func median(spectrum []float64) float64 {
+	sorted := make([]float64, len(spectrum))
+	copy(sorted, spectrum)
+	sort.Float64s(sorted)
+	n := len(sorted)
+
+	/*
+	 * Average the middle values of an even number of bins.
+	 */
+	if n%2 == 0 {
+		return 0.5 * (sorted[n/2-1] + sorted[n/2])
+	} else {
+		return sorted[n/2]
+	}
+
+}
+
+/*
+ * Tracks the floor over a spectrum measured a number of seconds after the
+ * previous one.
+ *
+ * The first spectrum initializes the floor of all bins to its median, as
+ * noise spreads over the spectrum while notes already sounding only occupy
+ * a few bins.
+ */
+func (this *Floor) Update(spectrum []float64, seconds float64) {
+	fall := 1.0 - math.Exp(-seconds/this.config.FallTime)
+	rise := ratio(this.config.Rise * seconds)
+	peakRise := ratio(this.config.PeakRise * seconds)
+
+	/*
+	 * Initialize the floor from the first spectrum.
+	 */
+	if !this.started && len(spectrum) > 0 {
+		level := math.Max(median(spectrum), this.config.Minimum)
+
+		for bin := range this.levels {
+			this.levels[bin] = level
+		}
+
+		this.started = true
+		return
+	}
+
+	for bin, magnitude := range spectrum {
+		magnitude = math.Max(magnitude, this.config.Minimum)
+		level := this.levels[bin]
+
+		/*
+		 * Falling magnitudes are followed smoothly, rising ones slowly.
+		 */
+		if magnitude < level {
+			level += fall * (magnitude - level)
+		} else if this.Peaks(bin, magnitude) {
+			level = math.Min(magnitude, level*peakRise)
+		} else {
+			level = math.Min(magnitude, level*rise)
+		}
+
+		this.levels[bin] = math.Max(level, this.config.Minimum)
+	}
+
+}
+
+/*
+ * Accumulates a spectrum measured while the instrument is silent.
+ */
+func (this *Floor) Learn(spectrum []float64) {
+
+	/*
+	 * Start a new measurement after the previous one was applied.
+	 */
+	if this.learned == 0 {
+
+		for bin := range this.sum {
+			this.sum[bin] = 0.0
+		}
+
+	}
+
+	for bin, magnitude := range spectrum {
+		this.sum[bin] += magnitude
+	}
+
+	this.learned++
+}
+
+/*
+ * Replaces the floor with the mean of the spectra accumulated by Learn.
+ */
+func (this *Floor) Calibrate() error {
+
+	/*
+	 * At least one spectrum must have been learned.
+	 */
+	if this.learned == 0 {
+		return fmt.Errorf("Failed to calibrate noise floor: No spectra were learned.")
+	}
+
+	n := float64(this.learned)
+
+	for bin, sum := range this.sum {
+		this.levels[bin] = math.Max(sum/n, this.config.Minimum)
+	}
+
+	this.learned = 0
+	this.started = true
+	return nil
+}
+
+/*
+ * Creates a tracker for the noise floor of spectra with a number of bins.
+ */
+func CreateFloor(bins int, config FloorConfig) (*Floor, error) {
+
+	/*
+	 * Check if the configuration is valid.
+	 */
+	if bins <= 0 {
+		return nil, fmt.Errorf("Failed to create noise floor: Number of bins must be positive, is %d.", bins)
+	} else if config.FallTime <= 0.0 {
+		return nil, fmt.Errorf("Failed to create noise floor: Fall time must be positive, is %f.", config.FallTime)
+	} else if config.Minimum <= 0.0 {
+		return nil, fmt.Errorf("Failed to create noise floor: Minimum must be positive, is %f.", config.Minimum)
+	} else if config.Rise < 0.0 || config.PeakRise < 0.0 {
+		return nil, fmt.Errorf("Failed to create noise floor: Rise must not be negative, is %f and %f.", config.Rise, config.PeakRise)
+	} else {
+
+		/*
+		 * Create data structure for a noise floor.
+		 */
+		f := Floor{
+			config: config,
+			levels: make([]float64, bins),
+			sum:    make([]float64, bins),
+		}
+
+		return &f, nil
+	}
+
+}
@@ -0,0 +1,117 @@
+package denoise
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Check that the floor absorbs steady noise, lets tones peak over it and
+ * can be calibrated from silent spectra.
+ */
+func TestFloor(t *testing.T) {
+	config := DefaultFloorConfig()
+	floor, err := CreateFloor(3, config)
+
+	/*
+	 * Check if the floor could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create noise floor: %s", msg)
+	}
+
+	const step = 1.0 / 60.0
+	noise := []float64{0.01, 0.01, 0.01}
+	floor.Update([]float64{0.01, 0.01, 1.0}, step)
+
+	/*
+	 * The first spectrum initializes all bins to its median, so a tone
+	 * sounding from the start peaks.
+	 */
+	if floor.Level(2) != 0.01 || !floor.Peaks(2, 1.0) {
+		t.Errorf("Initial floor of a sounding bin is %f, expected %f.", floor.Level(2), 0.01)
+	}
+
+	for i := 0; i < 60; i++ {
+		floor.Update(noise, step)
+	}
+
+	/*
+	 * Steady noise must become the floor and must not peak.
+	 */
+	for bin := range noise {
+		level := floor.Level(bin)
+
+		if math.Abs(level-0.01) > 1e-9 {
+			t.Errorf("Floor of bin %d is %f, expected %f.", bin, level, 0.01)
+		}
+
+		if floor.Peaks(bin, 0.015) {
+			t.Errorf("Noise fluctuation peaks in bin %d.", bin)
+		}
+
+	}
+
+	tone := []float64{0.01, 1.0, 0.01}
+
+	for i := 0; i < 120; i++ {
+		floor.Update(tone, step)
+	}
+
+	/*
+	 * A tone held for two seconds must still peak, the floor of the other
+	 * bin must be unchanged.
+	 */
+	if !floor.Peaks(1, 1.0) {
+		t.Errorf("Held tone at %.1f dB over the floor does not peak.", floor.Ratio(1, 1.0))
+	}
+
+	if floor.Level(0) != 0.01 {
+		t.Errorf("Floor of silent bin changed to %f.", floor.Level(0))
+	}
+
+	for i := 0; i < 60; i++ {
+		floor.Update(noise, step)
+	}
+
+	/*
+	 * The floor must fall back to the noise once the tone stops.
+	 */
+	if level := floor.Level(1); level > 0.011 {
+		t.Errorf("Floor is %f one second after the tone stopped, expected %f.", level, 0.01)
+	}
+
+	err = floor.Calibrate()
+
+	/*
+	 * Calibration needs learned spectra.
+	 */
+	if err == nil {
+		t.Errorf("Calibration without learned spectra succeeded.")
+	}
+
+	floor.Learn([]float64{0.1, 0.2, 0.1})
+	floor.Learn([]float64{0.3, 0.4, 0.1})
+	err = floor.Calibrate()
+
+	/*
+	 * Check if the floor is the mean of the learned spectra.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Errorf("Failed to calibrate noise floor: %s", msg)
+	} else if math.Abs(floor.Level(0)-0.2) > 1e-9 || math.Abs(floor.Level(1)-0.3) > 1e-9 {
+		t.Errorf("Calibrated floor is %f and %f, expected %f and %f.", floor.Level(0), floor.Level(1), 0.2, 0.3)
+	}
+
+	_, err = CreateFloor(0, config)
+
+	/*
+	 * A floor needs bins.
+	 */
+	if err == nil {
+		t.Errorf("Noise floor without bins was created.")
+	}
+
+}
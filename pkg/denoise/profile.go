@@ -0,0 +1,338 @@
+package denoise
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/andrepxx/go-dsp-guitar/fft"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_FRAME_SIZE = 2048
+)
+
+/*
+ * Data structure representing the spectrum of stationary background noise.
+ *
+ * Spectrum holds the mean magnitude of each FFT bin from DC up to the
+ * Nyquist frequency, scaled so that a sinusoid of amplitude A centered on a
+ * bin has a magnitude of A.
+ */
+type Profile struct {
+	SampleRate float64   `json:"sampleRate"`
+	FrameSize  int       `json:"frameSize"`
+	Frames     int       `json:"frames"`
+	Spectrum   []float64 `json:"spectrum"`
+}
+
+/*
+ * Data structure accumulating frames of background noise into a profile.
+ */
+type Learner struct {
+	sampleRate       float64
+	frameSize        int
+	window           []float64
+	scale            float64
+	fourierTransform fft.FourierTransform
+	frame            []float64
+	fill             int
+	bufFrame         []float64
+	bufFFT           []complex128
+	sum              []float64
+	frames           int
+}
+
+/*
+ * Calculates a periodic square-root Hann window.
+ *
+ * Applied both before analysis and after synthesis, the squared windows sum
+ * to one at an overlap of 50 percent.
+ */
+func sqrtHann(length int) []float64 {
+	window := make([]float64, length)
+	lengthFloat := float64(length)
+
+	for i := range window {
+		arg := 2.0 * math.Pi * float64(i) / lengthFloat
+		window[i] = math.Sqrt(0.5 - 0.5*math.Cos(arg))
+	}
+
+	return window
+}
+
+/*
+ * Returns the factor which converts raw FFT magnitudes of a windowed frame
+ * into amplitudes.
+ */
+func amplitudeScale(window []float64) float64 {
+	sum := 0.0
+
+	for _, w := range window {
+		sum += w
+	}
+
+	return 2.0 / sum
+}
+
+/*
+ * Verifies that a frame size is a power of two.
+ */
+func checkFrameSize(frameSize int) error {
+
+	/*
+	 * The FFT requires a power of two of at least four samples.
+	 */
+	if frameSize < 4 || frameSize&(frameSize-1) != 0 {
+		return fmt.Errorf("Frame size must be a power of two, is %d.", frameSize)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Returns the noise magnitude at a certain frequency, interpolating between
+ * the bins of the profile.
+ */
+func (this *Profile) Magnitude(frequency float64) float64 {
+	spectrum := this.Spectrum
+	n := len(spectrum)
+
+	/*
+	 * An empty profile has no noise.
+	 */
+	if n == 0 || this.SampleRate <= 0.0 {
+		return 0.0
+	}
+
+	position := frequency * float64(this.FrameSize) / this.SampleRate
+
+	/*
+	 * Clamp to the available bins.
+	 */
+	if position <= 0.0 {
+		return spectrum[0]
+	} else if position >= float64(n-1) {
+		return spectrum[n-1]
+	} else {
+		idx := int(position)
+		frac := position - float64(idx)
+		return (1.0-frac)*spectrum[idx] + frac*spectrum[idx+1]
+	}
+
+}
+
+/*
+ * Stores the profile as JSON.
+ */
+func (this *Profile) Save(path string) error {
+	buf, err := json.MarshalIndent(this, "", "\t")
+
+	/*
+	 * Check if the profile could be serialized.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to serialize noise profile: %s", msg)
+	} else {
+		err = os.WriteFile(path, buf, 0644)
+
+		/*
+		 * Check if the file could be written.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to write noise profile to '%s': %s", path, msg)
+		} else {
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Loads a profile stored as JSON.
+ */
+func LoadProfile(path string) (*Profile, error) {
+	buf, err := os.ReadFile(path)
+
+	/*
+	 * Check if the file could be read.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read noise profile from '%s': %s", path, msg)
+	} else {
+		profile := Profile{}
+		err = json.Unmarshal(buf, &profile)
+
+		/*
+		 * Check if the profile could be parsed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to parse noise profile from '%s': %s", path, msg)
+		} else if len(profile.Spectrum) != profile.FrameSize/2+1 {
+			return nil, fmt.Errorf("Noise profile '%s' has %d bins, expected %d.", path, len(profile.Spectrum), profile.FrameSize/2+1)
+		} else {
+			return &profile, nil
+		}
+
+	}
+
+}
+
+/*
+ * Adds a full frame to the accumulated spectrum.
+ */
+func (this *Learner) analyze() {
+	frame := this.frame
+	window := this.window
+	bufFrame := this.bufFrame
+	bufFFT := this.bufFFT
+
+	for i, x := range frame {
+		bufFrame[i] = window[i] * x
+	}
+
+	err := this.fourierTransform.RealFourier(bufFrame, bufFFT, fft.SCALING_DEFAULT)
+
+	/*
+	 * The buffers have matching power of two sizes, so this cannot fail.
+	 */
+	if err == nil {
+		sum := this.sum
+
+		for k := range sum {
+			sum[k] += this.scale * math.Hypot(real(bufFFT[k]), imag(bufFFT[k]))
+		}
+
+		this.frames++
+	}
+
+}
+
+/*
+ * Adds a single sample to the current frame.
+ *
+ * Frames overlap by 50 percent.
+ */
+func (this *Learner) push(x float64) {
+	frame := this.frame
+	hop := this.frameSize / 2
+	frame[this.fill] = x
+	this.fill++
+
+	/*
+	 * Analyze each full frame, then keep its second half.
+	 */
+	if this.fill == this.frameSize {
+		this.analyze()
+		copy(frame[0:hop], frame[hop:])
+		this.fill = hop
+	}
+
+}
+
+/*
+ * Feeds samples of background noise into the learner.
+ */
+func (this *Learner) Process(samples []float64) {
+
+	for _, x := range samples {
+		this.push(x)
+	}
+
+}
+
+/*
+ * Feeds samples of background noise into the learner.
+ */
+func (this *Learner) ProcessFloat32(samples []float32) {
+
+	for _, x := range samples {
+		this.push(float64(x))
+	}
+
+}
+
+/*
+ * Returns the number of frames analyzed so far.
+ */
+func (this *Learner) Frames() int {
+	return this.frames
+}
+
+/*
+ * Returns the mean spectrum of all frames analyzed so far.
+ */
+func (this *Learner) Profile() (*Profile, error) {
+	frames := this.frames
+
+	/*
+	 * At least one full frame is required.
+	 */
+	if frames == 0 {
+		return nil, fmt.Errorf("Not enough samples to learn a noise profile, need at least %d.", this.frameSize)
+	} else {
+		spectrum := make([]float64, len(this.sum))
+		framesFloat := float64(frames)
+
+		for k, value := range this.sum {
+			spectrum[k] = value / framesFloat
+		}
+
+		/*
+		 * Create noise profile.
+		 */
+		profile := Profile{
+			SampleRate: this.sampleRate,
+			FrameSize:  this.frameSize,
+			Frames:     frames,
+			Spectrum:   spectrum,
+		}
+
+		return &profile, nil
+	}
+
+}
+
+/*
+ * Creates a learner for noise profiles.
+ */
+func CreateLearner(frameSize int, sampleRate float64) (*Learner, error) {
+	err := checkFrameSize(frameSize)
+
+	/*
+	 * Check if the frame size is valid.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		window := sqrtHann(frameSize)
+
+		/*
+		 * Create data structure for a learner.
+		 */
+		l := Learner{
+			sampleRate:       sampleRate,
+			frameSize:        frameSize,
+			window:           window,
+			scale:            amplitudeScale(window),
+			fourierTransform: fft.CreateFourierTransform(),
+			frame:            make([]float64, frameSize),
+			bufFrame:         make([]float64, frameSize),
+			bufFFT:           make([]complex128, frameSize),
+			sum:              make([]float64, frameSize/2+1),
+		}
+
+		return &l, nil
+	}
+
+}
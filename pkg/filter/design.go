@@ -0,0 +1,347 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+ * Types of second-order sections from the RBJ audio EQ cookbook.
+ */
+type Kind int
+
+const (
+	LOW_PASS Kind = iota
+	HIGH_PASS
+	BAND_PASS
+	NOTCH
+	PEAKING
+	LOW_SHELF
+	HIGH_SHELF
+	ALL_PASS
+)
+
+/*
+ * Quality factor of a second-order Butterworth section.
+ */
+const (
+	BUTTERWORTH_Q = 0.7071067811865476
+)
+
+/*
+ * Returns the name of a filter type.
+ */
+func (this Kind) String() string {
+
+	switch this {
+	case LOW_PASS:
+		return "lowpass"
+	case HIGH_PASS:
+		return "highpass"
+	case BAND_PASS:
+		return "bandpass"
+	case NOTCH:
+		return "notch"
+	case PEAKING:
+		return "peaking"
+	case LOW_SHELF:
+		return "lowshelf"
+	case HIGH_SHELF:
+		return "highshelf"
+	case ALL_PASS:
+		return "allpass"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(this))
+	}
+
+}
+
+/*
+ * Calculates the coefficients of a second-order section according to the
+ * RBJ audio EQ cookbook.
+ *
+ * The gain (in dB) is only used by peaking and shelving filters. For
+ * shelving filters, q is the shelf slope, where 1 is the steepest slope
+ * without overshoot.
+ */
+func Design(kind Kind, frequency float64, q float64, gain float64, sampleRate float64) (Coefficients, error) {
+	err := checkFrequency(frequency, sampleRate)
+
+	/*
+	 * Check parameters.
+	 */
+	if err != nil {
+		return Coefficients{}, err
+	} else if q <= 0.0 {
+		return Coefficients{}, fmt.Errorf("Quality factor must be positive, is %f.", q)
+	} else {
+		a := math.Pow(10.0, gain/40.0)
+		omega := 2.0 * math.Pi * frequency / sampleRate
+		cosOmega := math.Cos(omega)
+		sinOmega := math.Sin(omega)
+		alpha := sinOmega / (2.0 * q)
+		var b0, b1, b2, a0, a1, a2 float64
+
+		switch kind {
+		case LOW_PASS:
+			b0 = 0.5 * (1.0 - cosOmega)
+			b1 = 1.0 - cosOmega
+			b2 = 0.5 * (1.0 - cosOmega)
+			a0 = 1.0 + alpha
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha
+		case HIGH_PASS:
+			b0 = 0.5 * (1.0 + cosOmega)
+			b1 = -(1.0 + cosOmega)
+			b2 = 0.5 * (1.0 + cosOmega)
+			a0 = 1.0 + alpha
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha
+		case BAND_PASS:
+			b0 = alpha
+			b1 = 0.0
+			b2 = -alpha
+			a0 = 1.0 + alpha
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha
+		case NOTCH:
+			b0 = 1.0
+			b1 = -2.0 * cosOmega
+			b2 = 1.0
+			a0 = 1.0 + alpha
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha
+		case PEAKING:
+			b0 = 1.0 + alpha*a
+			b1 = -2.0 * cosOmega
+			b2 = 1.0 - alpha*a
+			a0 = 1.0 + alpha/a
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha/a
+		case LOW_SHELF, HIGH_SHELF:
+			shelfAlpha := 0.5 * sinOmega * math.Sqrt((a+1.0/a)*(1.0/q-1.0)+2.0)
+			twoSqrtAAlpha := 2.0 * math.Sqrt(a) * shelfAlpha
+			sign := 1.0
+
+			/*
+			 * High shelves mirror the low shelf around the center.
+			 */
+			if kind == HIGH_SHELF {
+				sign = -1.0
+			}
+
+			b0 = a * ((a + 1.0) - sign*(a-1.0)*cosOmega + twoSqrtAAlpha)
+			b1 = sign * 2.0 * a * ((a - 1.0) - sign*(a+1.0)*cosOmega)
+			b2 = a * ((a + 1.0) - sign*(a-1.0)*cosOmega - twoSqrtAAlpha)
+			a0 = (a + 1.0) + sign*(a-1.0)*cosOmega + twoSqrtAAlpha
+			a1 = -sign * 2.0 * ((a - 1.0) + sign*(a+1.0)*cosOmega)
+			a2 = (a + 1.0) + sign*(a-1.0)*cosOmega - twoSqrtAAlpha
+		case ALL_PASS:
+			b0 = 1.0 - alpha
+			b1 = -2.0 * cosOmega
+			b2 = 1.0 + alpha
+			a0 = 1.0 + alpha
+			a1 = -2.0 * cosOmega
+			a2 = 1.0 - alpha
+		default:
+			return Coefficients{}, fmt.Errorf("Unknown filter type: %s", kind)
+		}
+
+		/*
+		 * Normalize coefficients to a0 = 1.
+		 */
+		c := Coefficients{
+			B0: b0 / a0,
+			B1: b1 / a0,
+			B2: b2 / a0,
+			A1: a1 / a0,
+			A2: a2 / a0,
+		}
+
+		return c, nil
+	}
+
+}
+
+/*
+ * Creates a biquad of a certain type.
+ */
+func Create(kind Kind, frequency float64, q float64, gain float64, sampleRate float64) (*Biquad, error) {
+	c, err := Design(kind, frequency, q, gain, sampleRate)
+
+	/*
+	 * Check if the filter could be designed.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		b := CreateBiquad(c, sampleRate)
+		return b, nil
+	}
+
+}
+
+/*
+ * Creates a second-order low-pass filter.
+ */
+func CreateLowPass(frequency float64, q float64, sampleRate float64) (*Biquad, error) {
+	return Create(LOW_PASS, frequency, q, 0.0, sampleRate)
+}
+
+/*
+ * Creates a second-order high-pass filter.
+ */
+func CreateHighPass(frequency float64, q float64, sampleRate float64) (*Biquad, error) {
+	return Create(HIGH_PASS, frequency, q, 0.0, sampleRate)
+}
+
+/*
+ * Creates a band-pass filter with a peak gain of 0 dB.
+ */
+func CreateBandPass(frequency float64, q float64, sampleRate float64) (*Biquad, error) {
+	return Create(BAND_PASS, frequency, q, 0.0, sampleRate)
+}
+
+/*
+ * Creates a notch filter.
+ */
+func CreateNotch(frequency float64, q float64, sampleRate float64) (*Biquad, error) {
+	return Create(NOTCH, frequency, q, 0.0, sampleRate)
+}
+
+/*
+ * Creates a peaking equalizer with a certain gain in dB.
+ */
+func CreatePeaking(frequency float64, q float64, gain float64, sampleRate float64) (*Biquad, error) {
+	return Create(PEAKING, frequency, q, gain, sampleRate)
+}
+
+/*
+ * Creates a low-shelf equalizer with a certain gain in dB.
+ */
+func CreateLowShelf(frequency float64, slope float64, gain float64, sampleRate float64) (*Biquad, error) {
+	return Create(LOW_SHELF, frequency, slope, gain, sampleRate)
+}
+
+/*
+ * Creates a high-shelf equalizer with a certain gain in dB.
+ */
+func CreateHighShelf(frequency float64, slope float64, gain float64, sampleRate float64) (*Biquad, error) {
+	return Create(HIGH_SHELF, frequency, slope, gain, sampleRate)
+}
+
+/*
+ * Creates a second-order all-pass filter.
+ */
+func CreateAllPass(frequency float64, q float64, sampleRate float64) (*Biquad, error) {
+	return Create(ALL_PASS, frequency, q, 0.0, sampleRate)
+}
+
+/*
+ * Calculates the coefficients of a first-order low- or high-pass section
+ * using the bilinear transform.
+ */
+func designFirstOrder(kind Kind, frequency float64, sampleRate float64) Coefficients {
+	k := math.Tan(math.Pi * frequency / sampleRate)
+	norm := 1.0 / (1.0 + k)
+	c := Coefficients{
+		A1: (k - 1.0) * norm,
+	}
+
+	/*
+	 * High-pass filters differentiate instead of integrate.
+	 */
+	if kind == HIGH_PASS {
+		c.B0 = norm
+		c.B1 = -norm
+	} else {
+		c.B0 = k * norm
+		c.B1 = k * norm
+	}
+
+	return c
+}
+
+/*
+ * Creates a Butterworth low- or high-pass filter of a certain order as a
+ * cascade of second-order sections, plus a first-order section for odd
+ * orders.
+ */
+func CreateButterworth(kind Kind, order int, frequency float64, sampleRate float64) (*Cascade, error) {
+	err := checkFrequency(frequency, sampleRate)
+
+	/*
+	 * Check parameters.
+	 */
+	if err != nil {
+		return nil, err
+	} else if kind != LOW_PASS && kind != HIGH_PASS {
+		return nil, fmt.Errorf("Butterworth filters must be lowpass or highpass, not %s.", kind)
+	} else if order < 1 {
+		return nil, fmt.Errorf("Filter order must be at least 1, is %d.", order)
+	} else {
+		stages := []Filter{}
+		orderFloat := float64(order)
+		numPairs := order / 2
+
+		/*
+		 * Each pair of complex conjugate poles forms a second-order section.
+		 *
+		 * theta = pi * (2k + N + 1) / (2N), Q = -1 / (2 cos(theta))
+		 */
+		for k := 0; k < numPairs; k++ {
+			theta := math.Pi * float64(2*k+order+1) / (2.0 * orderFloat)
+			q := -1.0 / (2.0 * math.Cos(theta))
+			c, err := Design(kind, frequency, q, 0.0, sampleRate)
+
+			if err != nil {
+				return nil, err
+			}
+
+			stages = append(stages, CreateBiquad(c, sampleRate))
+		}
+
+		/*
+		 * Odd orders have an additional real pole.
+		 */
+		if order%2 == 1 {
+			c := designFirstOrder(kind, frequency, sampleRate)
+			stages = append(stages, CreateBiquad(c, sampleRate))
+		}
+
+		cascade := CreateCascade(stages...)
+		return cascade, nil
+	}
+
+}
+
+/*
+ * Creates a series of notch filters removing mains hum at a certain
+ * fundamental frequency and its harmonics.
+ */
+func CreateHumFilter(fundamental float64, harmonics int, q float64, sampleRate float64) (*Cascade, error) {
+	stages := []Filter{}
+	nyquist := 0.5 * sampleRate
+
+	/*
+	 * Add a notch for each harmonic below the Nyquist frequency.
+	 */
+	for i := 1; i <= harmonics; i++ {
+		frequency := float64(i) * fundamental
+
+		if frequency >= nyquist {
+			break
+		}
+
+		notch, err := CreateNotch(frequency, q, sampleRate)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, notch)
+	}
+
+	cascade := CreateCascade(stages...)
+	return cascade, nil
+}
@@ -0,0 +1,232 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+/*
+ * Interface implemented by all streaming filters.
+ *
+ * Filters keep their state between calls, so consecutive buffers of a stream
+ * are filtered seamlessly. None of the methods allocate, which makes them
+ * safe to call from real-time audio callbacks.
+ */
+type Filter interface {
+	ProcessSample(x float64) float64
+	Process(samples []float32)
+	ProcessFloat64(samples []float64)
+	Response(frequency float64) float64
+	Reset()
+}
+
+/*
+ * Coefficients of a second-order section, normalized to a0 = 1.
+ */
+type Coefficients struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+/*
+ * Data structure representing a second-order IIR filter in transposed
+ * direct form II.
+ */
+type Biquad struct {
+	coefficients Coefficients
+	sampleRate   float64
+	z1, z2       float64
+}
+
+/*
+ * Data structure representing a series of filters.
+ */
+type Cascade struct {
+	stages []Filter
+}
+
+/*
+ * Returns the coefficients of the filter.
+ */
+func (this *Biquad) Coefficients() Coefficients {
+	return this.coefficients
+}
+
+/*
+ * Replaces the coefficients of the filter, keeping its state.
+ *
+ * This allows smooth parameter changes while a stream is running.
+ */
+func (this *Biquad) SetCoefficients(c Coefficients) {
+	this.coefficients = c
+}
+
+/*
+ * Filters a single sample.
+ */
+func (this *Biquad) ProcessSample(x float64) float64 {
+	c := &this.coefficients
+	y := c.B0*x + this.z1
+	this.z1 = c.B1*x - c.A1*y + this.z2
+	this.z2 = c.B2*x - c.A2*y
+	return y
+}
+
+/*
+ * Filters a buffer of samples in place.
+ */
+func (this *Biquad) Process(samples []float32) {
+
+	for i, x := range samples {
+		samples[i] = float32(this.ProcessSample(float64(x)))
+	}
+
+}
+
+/*
+ * Filters a buffer of samples in place.
+ */
+func (this *Biquad) ProcessFloat64(samples []float64) {
+
+	for i, x := range samples {
+		samples[i] = this.ProcessSample(x)
+	}
+
+}
+
+/*
+ * Returns the magnitude response of the filter at a certain frequency.
+ */
+func (this *Biquad) Response(frequency float64) float64 {
+	c := this.coefficients
+	omega := 2.0 * math.Pi * frequency / this.sampleRate
+	z1 := cmplx.Exp(complex(0.0, -omega))
+	z2 := z1 * z1
+	num := complex(c.B0, 0.0) + complex(c.B1, 0.0)*z1 + complex(c.B2, 0.0)*z2
+	den := complex(1.0, 0.0) + complex(c.A1, 0.0)*z1 + complex(c.A2, 0.0)*z2
+	return cmplx.Abs(num / den)
+}
+
+/*
+ * Clears the state of the filter.
+ */
+func (this *Biquad) Reset() {
+	this.z1 = 0.0
+	this.z2 = 0.0
+}
+
+/*
+ * Returns the filters in the cascade.
+ */
+func (this *Cascade) Stages() []Filter {
+	return this.stages
+}
+
+/*
+ * Filters a single sample through all stages.
+ */
+func (this *Cascade) ProcessSample(x float64) float64 {
+
+	for _, stage := range this.stages {
+		x = stage.ProcessSample(x)
+	}
+
+	return x
+}
+
+/*
+ * Filters a buffer of samples in place through all stages.
+ */
+func (this *Cascade) Process(samples []float32) {
+
+	for _, stage := range this.stages {
+		stage.Process(samples)
+	}
+
+}
+
+/*
+ * Filters a buffer of samples in place through all stages.
+ */
+func (this *Cascade) ProcessFloat64(samples []float64) {
+
+	for _, stage := range this.stages {
+		stage.ProcessFloat64(samples)
+	}
+
+}
+
+/*
+ * Returns the magnitude response of the cascade at a certain frequency.
+ */
+func (this *Cascade) Response(frequency float64) float64 {
+	response := 1.0
+
+	for _, stage := range this.stages {
+		response *= stage.Response(frequency)
+	}
+
+	return response
+}
+
+/*
+ * Clears the state of all stages.
+ */
+func (this *Cascade) Reset() {
+
+	for _, stage := range this.stages {
+		stage.Reset()
+	}
+
+}
+
+/*
+ * Creates a biquad from normalized coefficients.
+ */
+func CreateBiquad(c Coefficients, sampleRate float64) *Biquad {
+
+	/*
+	 * Create data structure for a biquad.
+	 */
+	b := Biquad{
+		coefficients: c,
+		sampleRate:   sampleRate,
+	}
+
+	return &b
+}
+
+/*
+ * Creates a series of filters.
+ */
+func CreateCascade(stages ...Filter) *Cascade {
+
+	/*
+	 * Create data structure for a cascade.
+	 */
+	c := Cascade{
+		stages: stages,
+	}
+
+	return &c
+}
+
+/*
+ * Verifies that a frequency lies between zero and the Nyquist frequency.
+ */
+func checkFrequency(frequency float64, sampleRate float64) error {
+	nyquist := 0.5 * sampleRate
+
+	/*
+	 * The bilinear transform is only defined below the Nyquist frequency.
+	 */
+	if sampleRate <= 0.0 {
+		return fmt.Errorf("Sample rate must be positive, is %f.", sampleRate)
+	} else if frequency <= 0.0 || frequency >= nyquist {
+		return fmt.Errorf("Frequency %f must be between 0 and the Nyquist frequency %f.", frequency, nyquist)
+	} else {
+		return nil
+	}
+
+}
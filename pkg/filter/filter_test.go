@@ -0,0 +1,211 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Converts a gain in dB to a linear factor.
+ */
+func fromDecibels(gain float64) float64 {
+	return math.Pow(10.0, gain/20.0)
+}
+
+/*
+ * Data structure describing an expected point of a magnitude response.
+ */
+type responsePoint struct {
+	frequency float64
+	magnitude float64
+}
+
+/*
+ * Perform a unit test on the magnitude responses of the cookbook filters.
+ */
+func TestDesign(t *testing.T) {
+	sampleRate := 48000.0
+
+	/*
+	 * Filters under test.
+	 */
+	kinds := []Kind{
+		LOW_PASS,
+		HIGH_PASS,
+		BAND_PASS,
+		NOTCH,
+		PEAKING,
+		LOW_SHELF,
+		HIGH_SHELF,
+		ALL_PASS,
+	}
+
+	/*
+	 * Expected responses for a 1 kHz filter with 6 dB gain.
+	 */
+	expected := [][]responsePoint{
+		{{1.0, 1.0}, {1000.0, BUTTERWORTH_Q}},
+		{{23999.0, 1.0}, {1000.0, BUTTERWORTH_Q}},
+		{{1000.0, 1.0}, {1.0, 0.0}},
+		{{1000.0, 0.0}, {1.0, 1.0}, {23999.0, 1.0}},
+		{{1000.0, fromDecibels(6.0)}, {1.0, 1.0}, {23999.0, 1.0}},
+		{{1.0, fromDecibels(6.0)}, {1000.0, fromDecibels(3.0)}, {23999.0, 1.0}},
+		{{1.0, 1.0}, {1000.0, fromDecibels(3.0)}, {23999.0, fromDecibels(6.0)}},
+		{{1.0, 1.0}, {1000.0, 1.0}, {23999.0, 1.0}},
+	}
+
+	for i, kind := range kinds {
+		q := BUTTERWORTH_Q
+
+		/*
+		 * Use the steepest slope for shelving filters.
+		 */
+		if kind == LOW_SHELF || kind == HIGH_SHELF {
+			q = 1.0
+		}
+
+		b, err := Create(kind, 1000.0, q, 6.0, sampleRate)
+
+		/*
+		 * Check if the filter could be designed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to design %s filter: %s", kind, msg)
+		} else {
+
+			for _, point := range expected[i] {
+				magnitude := b.Response(point.frequency)
+
+				/*
+				 * Check the magnitude response.
+				 */
+				if math.Abs(magnitude-point.magnitude) > 0.01 {
+					t.Errorf("Response of %s filter at %.0f Hz is %f, expected %f.", kind, point.frequency, magnitude, point.magnitude)
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on the Butterworth design.
+ */
+func TestButterworth(t *testing.T) {
+	sampleRate := 48000.0
+	cutoff := 1000.0
+
+	for order := 1; order <= 8; order++ {
+		c, err := CreateButterworth(LOW_PASS, order, cutoff, sampleRate)
+
+		/*
+		 * Check if the filter could be designed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to design Butterworth filter of order %d: %s", order, msg)
+		} else {
+			numStages := len(c.Stages())
+			expectedStages := (order + 1) / 2
+
+			/*
+			 * Check the number of sections.
+			 */
+			if numStages != expectedStages {
+				t.Errorf("Butterworth filter of order %d has %d stages, expected %d.", order, numStages, expectedStages)
+			}
+
+			/*
+			 * The response is -3 dB at the cutoff frequency for any order.
+			 */
+			magnitude := c.Response(cutoff)
+
+			if math.Abs(magnitude-BUTTERWORTH_Q) > 0.01 {
+				t.Errorf("Response of order %d at cutoff is %f, expected %f.", order, magnitude, BUTTERWORTH_Q)
+			}
+
+			/*
+			 * One octave above the cutoff, the attenuation grows with the order.
+			 */
+			magnitude = c.Response(2.0 * cutoff)
+			analog := 1.0 / math.Sqrt(1.0+math.Pow(2.0, float64(2*order)))
+
+			if math.Abs(magnitude-analog) > 0.02 {
+				t.Errorf("Response of order %d at twice the cutoff is %f, expected about %f.", order, magnitude, analog)
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Check that streaming a sinusoid matches the magnitude response, and that
+ * processing does not allocate.
+ */
+func TestProcess(t *testing.T) {
+	sampleRate := 48000.0
+	frequency := 100.0
+	hum, err := CreateHumFilter(50.0, 4, 10.0, sampleRate)
+
+	/*
+	 * Check if the filter could be designed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to design hum filter: %s", msg)
+	}
+
+	buf := make([]float32, 4800)
+	peak := 0.0
+
+	/*
+	 * Stream one second of hum in chunks.
+	 */
+	for chunk := 0; chunk < 10; chunk++ {
+
+		for i := range buf {
+			n := chunk*len(buf) + i
+			arg := 2.0 * math.Pi * frequency * float64(n) / sampleRate
+			buf[i] = float32(math.Sin(arg))
+		}
+
+		hum.Process(buf)
+
+		/*
+		 * Measure the residual after the filter settled.
+		 */
+		if chunk >= 5 {
+
+			for _, x := range buf {
+				peak = math.Max(peak, math.Abs(float64(x)))
+			}
+
+		}
+
+	}
+
+	/*
+	 * Check if the hum was removed.
+	 */
+	if peak > 0.01 {
+		t.Errorf("Residual hum at %.0f Hz has peak %f.", frequency, peak)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		hum.Process(buf)
+	})
+
+	/*
+	 * Check if processing is allocation-free.
+	 */
+	if allocs != 0 {
+		t.Errorf("Processing allocates %f times per run.", allocs)
+	}
+
+}
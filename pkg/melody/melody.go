@@ -0,0 +1,283 @@
+package melody
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_TEMPO = 120.0
+)
+
+/*
+ * Data structure representing a note of a melody.
+ *
+ * Pitch is the MIDI number of the note, Start the time from the beginning
+ * of the melody at which it is played.
+ */
+type Note struct {
+	Pitch    int
+	Start    time.Duration
+	Duration time.Duration
+}
+
+/*
+ * Returns the time at which the note ends.
+ */
+func (this *Note) End() time.Duration {
+	return this.Start + this.Duration
+}
+
+/*
+ * Data structure representing a melody, a sequence of notes of which at
+ * most one sounds at any time, sorted by their start.
+ */
+type Melody struct {
+	Title string
+	Notes []Note
+}
+
+/*
+ * Returns the time at which the last note of the melody ends.
+ */
+func (this *Melody) Duration() time.Duration {
+	end := time.Duration(0)
+
+	for _, note := range this.Notes {
+
+		if note.End() > end {
+			end = note.End()
+		}
+
+	}
+
+	return end
+}
+
+/*
+ * Returns the range of the notes of the melody as the lowest and highest
+ * MIDI number, both zero if it has no notes.
+ */
+func (this *Melody) Range() (int, int) {
+	low := 0
+	high := 0
+
+	for i, note := range this.Notes {
+
+		if i == 0 || note.Pitch < low {
+			low = note.Pitch
+		}
+
+		if i == 0 || note.Pitch > high {
+			high = note.Pitch
+		}
+
+	}
+
+	return low, high
+}
+
+/*
+ * Data structure representing a change of tempo at a beat.
+ */
+type tempoChange struct {
+	beat           float64
+	beatsPerMinute float64
+}
+
+/*
+ * Data structure converting beats into time.
+ *
+ * Changes are sorted by beat, before the first change the default tempo
+ * applies.
+ */
+type tempoMap struct {
+	changes []tempoChange
+}
+
+/*
+ * Adds a change of tempo at a beat.
+ */
+func (this *tempoMap) add(beat float64, beatsPerMinute float64) {
+	change := tempoChange{
+		beat:           beat,
+		beatsPerMinute: beatsPerMinute,
+	}
+
+	this.changes = append(this.changes, change)
+}
+
+/*
+ * Sorts the changes by beat once all are added. Of changes at the same beat
+ * the one added last takes precedence.
+ */
+func (this *tempoMap) sort() {
+	changes := this.changes
+
+	sort.SliceStable(changes, func(i int, j int) bool {
+		return changes[i].beat < changes[j].beat
+	})
+
+}
+
+/*
+ * Returns the time of a beat from the beginning. The changes must be
+ * sorted.
+ */
+func (this *tempoMap) time(beat float64) time.Duration {
+	seconds := 0.0
+	position := 0.0
+	tempo := DEFAULT_TEMPO
+
+	for _, change := range this.changes {
+
+		if change.beat >= beat {
+			break
+		}
+
+		seconds += 60.0 * (change.beat - position) / tempo
+		position = change.beat
+		tempo = change.beatsPerMinute
+	}
+
+	seconds += 60.0 * (beat - position) / tempo
+	return time.Duration(seconds * float64(time.Second))
+}
+
+/*
+ * Reduces notes to a melody which only plays one note at a time.
+ *
+ * Of notes starting together the highest is kept, notes still sounding
+ * when the next one starts are cut short.
+ */
+func monophonic(notes []Note) []Note {
+
+	sort.SliceStable(notes, func(i int, j int) bool {
+
+		if notes[i].Start != notes[j].Start {
+			return notes[i].Start < notes[j].Start
+		} else {
+			return notes[i].Pitch > notes[j].Pitch
+		}
+
+	})
+
+	result := []Note{}
+
+	for _, note := range notes {
+		n := len(result)
+
+		/*
+		 * Skip lower notes of chords and notes without length.
+		 */
+		if note.Duration <= 0 || (n > 0 && result[n-1].Start == note.Start) {
+			continue
+		}
+
+		if n > 0 && result[n-1].End() > note.Start {
+			result[n-1].Duration = note.Start - result[n-1].Start
+		}
+
+		result = append(result, note)
+	}
+
+	return result
+}
+
+/*
+ * Selects one of the parts of a file by its index among the parts which
+ * have notes.
+ */
+func selectPart(parts [][]Note, part int) ([]Note, error) {
+	withNotes := [][]Note{}
+
+	for _, notes := range parts {
+
+		if len(notes) > 0 {
+			withNotes = append(withNotes, notes)
+		}
+
+	}
+
+	/*
+	 * Check if the part exists.
+	 */
+	if len(withNotes) == 0 {
+		return nil, fmt.Errorf("%s", "File contains no notes.")
+	} else if part < 0 || part >= len(withNotes) {
+		return nil, fmt.Errorf("Part %d does not exist, file has %d parts with notes.", part, len(withNotes))
+	} else {
+		return monophonic(withNotes[part]), nil
+	}
+
+}
+
+/*
+ * Loads a melody from a MIDI file (.mid, .midi) or a MusicXML file (.xml,
+ * .musicxml or compressed .mxl).
+ *
+ * Part selects the track or part holding the melody, counting only those
+ * which have notes, starting at zero. Files without a title are named
+ * after the file.
+ */
+func Load(path string, part int) (*Melody, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	f, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open melody file '%s': %s", path, msg)
+	}
+
+	defer f.Close()
+	m := (*Melody)(nil)
+
+	/*
+	 * Decode the file by its extension.
+	 */
+	switch ext {
+	case ".mid", ".midi":
+		m, err = ReadMidi(f, part)
+	case ".xml", ".musicxml":
+		m, err = ReadMusicXML(f, part)
+	case ".mxl":
+		info, errStat := f.Stat()
+
+		if errStat != nil {
+			err = errStat
+		} else {
+			m, err = ReadCompressedMusicXML(f, info.Size(), part)
+		}
+
+	default:
+		return nil, fmt.Errorf("Failed to load melody file '%s': Unknown extension '%s', expected .mid, .midi, .xml, .musicxml or .mxl.", path, ext)
+	}
+
+	/*
+	 * Check if file could be decoded.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to load melody file '%s': %s", path, msg)
+	}
+
+	/*
+	 * Name melody after the file.
+	 */
+	if m.Title == "" {
+		base := filepath.Base(path)
+		m.Title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return m, nil
+}
@@ -0,0 +1,243 @@
+package melody
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+ * Builds a chunk of a MIDI file.
+ */
+func midiChunk(id string, data []byte) []byte {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+/*
+ * Builds a MIDI file of format 1 with 480 ticks per beat.
+ */
+func midiFile(tracks ...[]byte) []byte {
+	header := []byte{0, 1, 0, byte(len(tracks)), 0x01, 0xe0}
+	file := midiChunk("MThd", header)
+
+	for _, track := range tracks {
+		file = append(file, midiChunk("MTrk", track)...)
+	}
+
+	return file
+}
+
+/*
+ * Compares the notes of a melody with the expected ones, allowing for
+ * rounding of their times.
+ */
+func compareNotes(t *testing.T, got []Note, expected []Note) {
+	const tolerance = time.Microsecond
+
+	if len(got) != len(expected) {
+		t.Fatalf("Melody has %d notes, expected %d: %v", len(got), len(expected), got)
+	}
+
+	for i, note := range got {
+		want := expected[i]
+		dStart := note.Start - want.Start
+		dDuration := note.Duration - want.Duration
+
+		if note.Pitch != want.Pitch || dStart < -tolerance || dStart > tolerance || dDuration < -tolerance || dDuration > tolerance {
+			t.Errorf("Note %d is %v, expected %v.", i, note, want)
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on reading MIDI files.
+ */
+func TestReadMidi(t *testing.T) {
+	conductor := []byte{
+		0x00, 0xff, 0x03, 0x04, 'S', 'o', 'n', 'g',
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20,
+		0x83, 0x60, 0xff, 0x51, 0x03, 0x0f, 0x42, 0x40,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	/*
+	 * A chord on the first beat with running status, a note stopped by a
+	 * note on without velocity across the change to half the tempo, and
+	 * a drum hit which is ignored.
+	 */
+	melody := []byte{
+		0x00, 0x90, 60, 100,
+		0x00, 64, 100,
+		0x00, 0x99, 36, 100,
+		0x83, 0x60, 0x80, 60, 0,
+		0x00, 64, 0,
+		0x00, 0x90, 67, 90,
+		0x87, 0x40, 67, 0,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	bass := []byte{
+		0x00, 0x90, 36, 100,
+		0x8f, 0x00, 0x80, 36, 0,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	file := midiFile(conductor, melody, bass)
+	m, err := ReadMidi(bytes.NewReader(file), 0)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read MIDI file: %s", msg)
+	}
+
+	if m.Title != "Song" {
+		t.Errorf("Title is '%s', expected '%s'.", m.Title, "Song")
+	}
+
+	/*
+	 * The first beat is played at 120 bpm, the following at 60 bpm.
+	 */
+	compareNotes(t, m.Notes, []Note{
+		{Pitch: 64, Start: 0, Duration: 500 * time.Millisecond},
+		{Pitch: 67, Start: 500 * time.Millisecond, Duration: 2 * time.Second},
+	})
+
+	if m.Duration() != 2500*time.Millisecond {
+		t.Errorf("Melody lasts %s, expected %s.", m.Duration(), 2500*time.Millisecond)
+	}
+
+	if low, high := m.Range(); low != 64 || high != 67 {
+		t.Errorf("Melody ranges from %d to %d, expected %d to %d.", low, high, 64, 67)
+	}
+
+	bassLine, err := ReadMidi(bytes.NewReader(file), 1)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read second part: %s", msg)
+	}
+
+	compareNotes(t, bassLine.Notes, []Note{
+		{Pitch: 36, Start: 0, Duration: 3500 * time.Millisecond},
+	})
+
+	/*
+	 * Check the errors of invalid files.
+	 */
+	if _, err := ReadMidi(bytes.NewReader(file), 2); err == nil {
+		t.Errorf("%s", "Part without notes was selected.")
+	}
+
+	if _, err := ReadMidi(bytes.NewReader(file[:len(file)-3]), 0); err == nil {
+		t.Errorf("%s", "Truncated file was read.")
+	}
+
+	if _, err := ReadMidi(strings.NewReader("RIFF"), 0); err == nil {
+		t.Errorf("%s", "File without header was read.")
+	}
+
+}
+
+/*
+ * A score with a title, a chord, a rest, a grace note, a tie across a bar
+ * line, a second voice between a backup and a forward and a change of
+ * tempo.
+ */
+const testScore = `<?xml version="1.0" encoding="UTF-8"?>
+<score-partwise version="3.1">
+  <work><work-title>Exercise</work-title></work>
+  <part-list><score-part id="P1"><part-name>Flute</part-name></score-part></part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes><divisions>2</divisions></attributes>
+      <direction><sound tempo="60"/></direction>
+      <note><pitch><step>C</step><octave>4</octave></pitch><duration>2</duration></note>
+      <note><chord/><pitch><step>E</step><octave>4</octave></pitch><duration>2</duration></note>
+      <note><rest/><duration>2</duration></note>
+      <note><grace/><pitch><step>A</step><octave>4</octave></pitch></note>
+      <note><pitch><step>F</step><alter>1</alter><octave>4</octave></pitch><duration>4</duration><tie type="start"/></note>
+      <backup><duration>6</duration></backup>
+      <note><pitch><step>C</step><octave>3</octave></pitch><duration>4</duration><voice>2</voice></note>
+      <forward><duration>2</duration></forward>
+    </measure>
+    <measure number="2">
+      <note><pitch><step>F</step><alter>1</alter><octave>4</octave></pitch><duration>2</duration><tie type="stop"/></note>
+      <sound tempo="120"/>
+      <note><pitch><step>B</step><alter>-1</alter><octave>4</octave></pitch><duration>2</duration></note>
+    </measure>
+  </part>
+</score-partwise>`
+
+/*
+ * Perform a unit test on reading MusicXML scores.
+ */
+func TestReadMusicXML(t *testing.T) {
+	m, err := ReadMusicXML(strings.NewReader(testScore), 0)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read MusicXML score: %s", msg)
+	}
+
+	if m.Title != "Exercise" {
+		t.Errorf("Title is '%s', expected '%s'.", m.Title, "Exercise")
+	}
+
+	/*
+	 * The bass voice is cut short by the notes above it.
+	 */
+	compareNotes(t, m.Notes, []Note{
+		{Pitch: 64, Start: 0, Duration: time.Second},
+		{Pitch: 48, Start: time.Second, Duration: time.Second},
+		{Pitch: 66, Start: 2 * time.Second, Duration: 3 * time.Second},
+		{Pitch: 70, Start: 5 * time.Second, Duration: 500 * time.Millisecond},
+	})
+
+	/*
+	 * Check compressed scores.
+	 */
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	container, _ := archive.Create(MUSICXML_CONTAINER)
+	container.Write([]byte(`<container><rootfiles><rootfile full-path="score/exercise.xml"/></rootfiles></container>`))
+	score, _ := archive.Create("score/exercise.xml")
+	score.Write([]byte(testScore))
+	err = archive.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create archive: %s", msg)
+	}
+
+	path := filepath.Join(t.TempDir(), "exercise.mxl")
+	err = os.WriteFile(path, buf.Bytes(), 0644)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write archive: %s", msg)
+	}
+
+	compressed, err := Load(path, 0)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to load compressed score: %s", msg)
+	}
+
+	compareNotes(t, compressed.Notes, m.Notes)
+
+	if _, err := Load(filepath.Join(t.TempDir(), "exercise.txt"), 0); err == nil {
+		t.Errorf("%s", "File of unknown type was loaded.")
+	}
+
+}
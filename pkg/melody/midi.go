@@ -0,0 +1,388 @@
+package melody
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	MIDI_DRUM_CHANNEL = 9
+)
+
+/*
+ * Data structure representing the bytes of a chunk being parsed.
+ */
+type midiReader struct {
+	data []byte
+	pos  int
+}
+
+/*
+ * Returns whether all bytes were read.
+ */
+func (this *midiReader) done() bool {
+	return this.pos >= len(this.data)
+}
+
+/*
+ * Reads a number of bytes.
+ */
+func (this *midiReader) read(n int) ([]byte, error) {
+
+	/*
+	 * Check if enough bytes remain.
+	 */
+	if n < 0 || this.pos+n > len(this.data) {
+		return nil, fmt.Errorf("Unexpected end of data at byte %d.", len(this.data))
+	} else {
+		b := this.data[this.pos : this.pos+n]
+		this.pos += n
+		return b, nil
+	}
+
+}
+
+/*
+ * Reads a byte.
+ */
+func (this *midiReader) byte() (byte, error) {
+	b, err := this.read(1)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+/*
+ * Reads a variable-length quantity of at most four bytes.
+ */
+func (this *midiReader) quantity() (int, error) {
+	value := 0
+
+	for i := 0; i < 4; i++ {
+		b, err := this.byte()
+
+		if err != nil {
+			return 0, err
+		}
+
+		value = (value << 7) | int(b&0x7f)
+
+		if b&0x80 == 0 {
+			return value, nil
+		}
+
+	}
+
+	return 0, fmt.Errorf("Variable-length quantity at byte %d is too long.", this.pos)
+}
+
+/*
+ * Data structure representing a note which was started but not stopped.
+ */
+type midiNote struct {
+	tick    int
+	channel byte
+	key     byte
+}
+
+/*
+ * Data structure representing a note in ticks.
+ */
+type midiSpan struct {
+	key   int
+	start int
+	end   int
+}
+
+/*
+ * Parses the events of a track into the notes it plays, adding its tempo
+ * changes to a map unless it is nil. Notes on the drum channel are ignored,
+ * notes not stopped end with the track.
+ */
+func parseTrack(data []byte, tempo *tempoMap, division float64) (string, []midiSpan, error) {
+	r := &midiReader{data: data}
+	name := ""
+	spans := []midiSpan{}
+	open := []midiNote{}
+	tick := 0
+	status := byte(0)
+
+	/*
+	 * Stops the oldest sounding note of a key.
+	 */
+	stop := func(channel byte, key byte) {
+
+		for i, n := range open {
+
+			if n.channel == channel && n.key == key {
+				spans = append(spans, midiSpan{key: int(key), start: n.tick, end: tick})
+				open = append(open[:i], open[i+1:]...)
+				return
+			}
+
+		}
+
+	}
+
+	for !r.done() {
+		delta, err := r.quantity()
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		tick += delta
+		b, err := r.byte()
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		/*
+		 * Data bytes repeat the previous status.
+		 */
+		if b < 0x80 {
+
+			if status == 0 {
+				return "", nil, fmt.Errorf("Data byte without status at byte %d.", r.pos)
+			}
+
+			r.pos--
+			b = status
+		}
+
+		switch {
+		case b == 0xff:
+			kind, err := r.byte()
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			length, err := r.quantity()
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			payload, err := r.read(length)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			/*
+			 * Keep the track name and the tempo, stop at the end of
+			 * the track.
+			 */
+			if kind == 0x03 && name == "" {
+				name = string(payload)
+			} else if kind == 0x51 && length == 3 && tempo != nil {
+				microseconds := int(payload[0])<<16 | int(payload[1])<<8 | int(payload[2])
+
+				if microseconds > 0 {
+					tempo.add(float64(tick)/division, 60e6/float64(microseconds))
+				}
+
+			} else if kind == 0x2f {
+				r.pos = len(r.data)
+			}
+
+			status = 0
+		case b == 0xf0 || b == 0xf7:
+			length, err := r.quantity()
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			_, err = r.read(length)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			status = 0
+		case b >= 0xf0:
+			return "", nil, fmt.Errorf("Unexpected system message 0x%02x at byte %d.", b, r.pos)
+		default:
+			status = b
+			kind := b & 0xf0
+			channel := b & 0x0f
+			length := 2
+
+			if kind == 0xc0 || kind == 0xd0 {
+				length = 1
+			}
+
+			params, err := r.read(length)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			/*
+			 * A note on with zero velocity stops the note.
+			 */
+			if channel == MIDI_DRUM_CHANNEL {
+				continue
+			} else if kind == 0x90 && params[1] > 0 {
+				open = append(open, midiNote{tick: tick, channel: channel, key: params[0]})
+			} else if kind == 0x80 || kind == 0x90 {
+				stop(channel, params[0])
+			}
+
+		}
+
+	}
+
+	for _, n := range open {
+		spans = append(spans, midiSpan{key: int(n.key), start: n.tick, end: tick})
+	}
+
+	return name, spans, nil
+}
+
+/*
+ * Reads a melody from a standard MIDI file.
+ *
+ * Part selects the track holding the melody, counting only tracks which
+ * have notes. The tempo is taken from all tracks, the title from the name
+ * of the first track.
+ */
+func ReadMidi(r io.Reader, part int) (*Melody, error) {
+	data, err := io.ReadAll(r)
+
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read MIDI file: %s", msg)
+	}
+
+	file := &midiReader{data: data}
+	tempo := &tempoMap{}
+	division := 0.0
+	smpte := false
+	names := []string{}
+	tracks := [][]midiSpan{}
+
+	for !file.done() {
+		header, err := file.read(8)
+
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to read MIDI chunk: %s", msg)
+		}
+
+		length := int(binary.BigEndian.Uint32(header[4:8]))
+		chunk, err := file.read(length)
+
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to read MIDI chunk: %s", msg)
+		}
+
+		id := string(header[0:4])
+
+		/*
+		 * The header must come first, unknown chunks are skipped.
+		 */
+		if division == 0.0 && id != "MThd" {
+			return nil, fmt.Errorf("%s", "Failed to read MIDI file: File does not start with a MIDI header.")
+		} else if id == "MThd" {
+
+			if length < 6 {
+				return nil, fmt.Errorf("Failed to read MIDI file: Header has %d bytes, expected 6.", length)
+			}
+
+			ticks := binary.BigEndian.Uint16(chunk[4:6])
+
+			/*
+			 * SMPTE divisions count ticks per second instead of per
+			 * beat, so a beat is a second and tempo changes are
+			 * ignored.
+			 */
+			if ticks&0x8000 != 0 {
+				framesPerSecond := -int(int8(ticks >> 8))
+				division = float64(framesPerSecond * int(ticks&0xff))
+				smpte = true
+				tempo.add(0.0, 60.0)
+			} else {
+				division = float64(ticks)
+			}
+
+			if division <= 0.0 {
+				return nil, fmt.Errorf("%s", "Failed to read MIDI file: Header has no time division.")
+			}
+
+		} else if id == "MTrk" {
+			trackTempo := tempo
+
+			if smpte {
+				trackTempo = nil
+			}
+
+			name, spans, err := parseTrack(chunk, trackTempo, division)
+
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to parse MIDI track %d: %s", len(tracks), msg)
+			}
+
+			names = append(names, name)
+			tracks = append(tracks, spans)
+		}
+
+	}
+
+	tempo.sort()
+	parts := make([][]Note, len(tracks))
+
+	for i, spans := range tracks {
+
+		for _, span := range spans {
+			start := tempo.time(float64(span.start) / division)
+
+			/*
+			 * Create data structure for a note.
+			 */
+			note := Note{
+				Pitch:    span.key,
+				Start:    start,
+				Duration: tempo.time(float64(span.end)/division) - start,
+			}
+
+			parts[i] = append(parts[i], note)
+		}
+
+	}
+
+	notes, err := selectPart(parts, part)
+
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read MIDI file: %s", msg)
+	}
+
+	/*
+	 * Create data structure for a melody.
+	 */
+	m := &Melody{
+		Notes: notes,
+	}
+
+	if len(names) > 0 {
+		m.Title = names[0]
+	}
+
+	return m, nil
+}
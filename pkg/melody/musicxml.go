@@ -0,0 +1,432 @@
+package melody
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strings"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	MUSICXML_CONTAINER = "META-INF/container.xml"
+)
+
+/*
+ * Semitones of the steps of MusicXML pitches above C.
+ */
+var musicXMLSteps = map[string]int{
+	"C": 0,
+	"D": 2,
+	"E": 4,
+	"F": 5,
+	"G": 7,
+	"A": 9,
+	"B": 11,
+}
+
+/*
+ * Data structure representing a partwise MusicXML score.
+ */
+type xmlScore struct {
+	XMLName       xml.Name  `xml:"score-partwise"`
+	WorkTitle     string    `xml:"work>work-title"`
+	MovementTitle string    `xml:"movement-title"`
+	Parts         []xmlPart `xml:"part"`
+}
+
+/*
+ * Data structure representing a part of a score.
+ */
+type xmlPart struct {
+	Measures []xmlMeasure `xml:"measure"`
+}
+
+/*
+ * Data structure representing a note of a part.
+ */
+type xmlNote struct {
+	Chord    *struct{} `xml:"chord"`
+	Grace    *struct{} `xml:"grace"`
+	Rest     *struct{} `xml:"rest"`
+	Step     string    `xml:"pitch>step"`
+	Alter    float64   `xml:"pitch>alter"`
+	Octave   int       `xml:"pitch>octave"`
+	Duration int       `xml:"duration"`
+	Ties     []struct {
+		Type string `xml:"type,attr"`
+	} `xml:"tie"`
+}
+
+/*
+ * Returns whether the note has a tie of a type.
+ */
+func (this *xmlNote) tied(kind string) bool {
+
+	for _, tie := range this.Ties {
+
+		if tie.Type == kind {
+			return true
+		}
+
+	}
+
+	return false
+}
+
+/*
+ * Data structure representing an element of a measure which moves in time,
+ * sets the divisions of a beat or changes the tempo.
+ *
+ * Exactly one of the fields is set for each element.
+ */
+type xmlEvent struct {
+	note      *xmlNote
+	move      int
+	divisions int
+	tempo     float64
+}
+
+/*
+ * Data structure representing the elements of a measure in their order.
+ */
+type xmlMeasure struct {
+	events []xmlEvent
+}
+
+/*
+ * Decodes the elements of a measure which matter for the melody, keeping
+ * their order.
+ */
+func (this *xmlMeasure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+
+	/*
+	 * Data structure representing a backup or a forward.
+	 */
+	type move struct {
+		Duration int `xml:"duration"`
+	}
+
+	/*
+	 * Data structure representing a sound, on its own or in a direction.
+	 */
+	type sound struct {
+		Tempo float64 `xml:"tempo,attr"`
+	}
+
+	for {
+		token, err := d.Token()
+
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			event := xmlEvent{}
+
+			switch t.Name.Local {
+			case "note":
+				note := &xmlNote{}
+				err = d.DecodeElement(note, &t)
+				event.note = note
+			case "backup", "forward":
+				m := move{}
+				err = d.DecodeElement(&m, &t)
+				event.move = m.Duration
+
+				if t.Name.Local == "backup" {
+					event.move = -m.Duration
+				}
+
+			case "attributes":
+				attributes := struct {
+					Divisions int `xml:"divisions"`
+				}{}
+
+				err = d.DecodeElement(&attributes, &t)
+				event.divisions = attributes.Divisions
+			case "direction":
+				direction := struct {
+					Sound sound `xml:"sound"`
+				}{}
+
+				err = d.DecodeElement(&direction, &t)
+				event.tempo = direction.Sound.Tempo
+			case "sound":
+				s := sound{}
+				err = d.DecodeElement(&s, &t)
+				event.tempo = s.Tempo
+			default:
+				err = d.Skip()
+			}
+
+			if err != nil {
+				return err
+			}
+
+			this.events = append(this.events, event)
+		}
+
+	}
+
+}
+
+/*
+ * Data structure representing a note in beats.
+ */
+type xmlSpan struct {
+	pitch int
+	start float64
+	end   float64
+}
+
+/*
+ * Collects the notes of a part in beats, adding its tempo changes to a map.
+ * Tied notes are joined, grace notes are ignored.
+ */
+func parsePart(part *xmlPart, tempo *tempoMap) []xmlSpan {
+	spans := []xmlSpan{}
+	divisions := 1.0
+	position := 0.0
+	previous := 0.0
+	tied := map[int]int{}
+
+	for _, measure := range part.Measures {
+
+		for _, event := range measure.events {
+
+			/*
+			 * Durations count divisions of a beat.
+			 */
+			switch {
+			case event.divisions > 0:
+				divisions = float64(event.divisions)
+			case event.tempo > 0.0:
+				tempo.add(position, event.tempo)
+			case event.move != 0:
+				position += float64(event.move) / divisions
+
+				if position < 0.0 {
+					position = 0.0
+				}
+
+			case event.note != nil:
+				note := event.note
+				start := position
+
+				/*
+				 * Chord notes start with the previous note.
+				 */
+				if note.Chord != nil {
+					start = previous
+				} else if note.Grace == nil {
+					position += float64(note.Duration) / divisions
+				}
+
+				previous = start
+
+				if note.Grace != nil || note.Rest != nil || note.Step == "" {
+					continue
+				}
+
+				semitone, ok := musicXMLSteps[strings.ToUpper(strings.TrimSpace(note.Step))]
+
+				if !ok {
+					continue
+				}
+
+				pitch := (note.Octave+1)*12 + semitone + int(math.Round(note.Alter))
+				end := start + float64(note.Duration)/divisions
+				idx, continued := tied[pitch]
+
+				/*
+				 * A tied note lengthens the note it continues.
+				 */
+				if continued && note.tied("stop") {
+					spans[idx].end = end
+				} else {
+					idx = len(spans)
+					spans = append(spans, xmlSpan{pitch: pitch, start: start, end: end})
+				}
+
+				if note.tied("start") {
+					tied[pitch] = idx
+				} else {
+					delete(tied, pitch)
+				}
+
+			}
+
+		}
+
+	}
+
+	return spans
+}
+
+/*
+ * Reads a melody from a partwise MusicXML score.
+ *
+ * Part selects the part holding the melody, counting only parts which have
+ * notes. The tempo is taken from all parts.
+ */
+func ReadMusicXML(r io.Reader, part int) (*Melody, error) {
+	score := xmlScore{}
+	err := xml.NewDecoder(r).Decode(&score)
+
+	/*
+	 * Check if score could be decoded.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to decode MusicXML score: %s", msg)
+	}
+
+	tempo := &tempoMap{}
+	spans := make([][]xmlSpan, len(score.Parts))
+
+	for i := range score.Parts {
+		spans[i] = parsePart(&score.Parts[i], tempo)
+	}
+
+	tempo.sort()
+	parts := make([][]Note, len(spans))
+
+	for i, partSpans := range spans {
+
+		for _, span := range partSpans {
+			start := tempo.time(span.start)
+
+			/*
+			 * Create data structure for a note.
+			 */
+			note := Note{
+				Pitch:    span.pitch,
+				Start:    start,
+				Duration: tempo.time(span.end) - start,
+			}
+
+			parts[i] = append(parts[i], note)
+		}
+
+	}
+
+	notes, err := selectPart(parts, part)
+
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read MusicXML score: %s", msg)
+	}
+
+	title := score.WorkTitle
+
+	if title == "" {
+		title = score.MovementTitle
+	}
+
+	/*
+	 * Create data structure for a melody.
+	 */
+	m := &Melody{
+		Title: strings.TrimSpace(title),
+		Notes: notes,
+	}
+
+	return m, nil
+}
+
+/*
+ * Reads a melody from a compressed MusicXML file, a ZIP archive holding the
+ * score named by its container.
+ */
+func ReadCompressedMusicXML(r io.ReaderAt, size int64, part int) (*Melody, error) {
+	archive, err := zip.NewReader(r, size)
+
+	/*
+	 * Check if archive could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open compressed MusicXML file: %s", msg)
+	}
+
+	files := map[string]*zip.File{}
+
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	name := ""
+	container, ok := files[MUSICXML_CONTAINER]
+
+	/*
+	 * The container names the score, without one the first XML file
+	 * outside of the metadata is taken.
+	 */
+	if ok {
+		content, err := container.Open()
+
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to open MusicXML container: %s", msg)
+		}
+
+		rootfiles := struct {
+			Files []struct {
+				Path string `xml:"full-path,attr"`
+			} `xml:"rootfiles>rootfile"`
+		}{}
+
+		err = xml.NewDecoder(content).Decode(&rootfiles)
+		content.Close()
+
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to decode MusicXML container: %s", msg)
+		}
+
+		if len(rootfiles.Files) > 0 {
+			name = rootfiles.Files[0].Path
+		}
+
+	} else {
+
+		for _, f := range archive.File {
+			ext := strings.ToLower(path.Ext(f.Name))
+
+			if !strings.HasPrefix(f.Name, "META-INF/") && (ext == ".xml" || ext == ".musicxml") {
+				name = f.Name
+				break
+			}
+
+		}
+
+	}
+
+	score, ok := files[name]
+
+	/*
+	 * Check if the score exists.
+	 */
+	if !ok {
+		return nil, fmt.Errorf("%s", "Failed to open compressed MusicXML file: Archive contains no score.")
+	}
+
+	content, err := score.Open()
+
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open score '%s': %s", name, msg)
+	}
+
+	defer content.Close()
+	return ReadMusicXML(content, part)
+}
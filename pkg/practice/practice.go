@@ -0,0 +1,669 @@
+package practice
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/melody"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_TIMING_WINDOW = 200 * time.Millisecond
+	DEFAULT_TOLERANCE     = 50.0
+	DEFAULT_LEAD_IN       = 3 * time.Second
+	MAX_TOLERANCE         = 600.0
+	MIN_SPEED             = 0.25
+	MAX_SPEED             = 2.0
+	POINTS_PER_NOTE       = 100
+	ATTACK_LEVEL          = 6.0
+)
+
+/*
+ * States of the notes of a pass.
+ */
+const (
+	STATE_PENDING = iota
+	STATE_HIT
+	STATE_MISSED
+)
+
+/*
+ * Data structure describing how a melody is practiced.
+ *
+ * A note is hit when a pitch within Tolerance cents of it is detected no
+ * more than TimingWindow before or after it starts. Latency is the delay
+ * of the pitch detection, which is subtracted from the time notes are
+ * detected at. A note held on does not hit the next note of the same
+ * pitch, it must be played again after a silence or with an attack
+ * ATTACK_LEVEL dB louder than the previous result.
+ *
+ * The melody is practiced at Speed times its tempo, each pass starting
+ * LeadIn before the section, so the first notes can be seen coming. In
+ * Wait mode the melody stops at every note until it is played, timing is
+ * not judged then.
+ *
+ * The section practiced starts at From and ends at To, zero plays to the
+ * end of the melody. Loop repeats it until the session is stopped.
+ */
+type Config struct {
+	TimingWindow time.Duration
+	Tolerance    float64
+	Latency      time.Duration
+	LeadIn       time.Duration
+	Speed        float64
+	Wait         bool
+	From         time.Duration
+	To           time.Duration
+	Loop         bool
+}
+
+/*
+ * Returns the configuration used by the command line tools.
+ */
+func DefaultConfig() Config {
+	return Config{
+		TimingWindow: DEFAULT_TIMING_WINDOW,
+		Tolerance:    DEFAULT_TOLERANCE,
+		LeadIn:       DEFAULT_LEAD_IN,
+		Speed:        1.0,
+	}
+}
+
+/*
+ * Data structure representing how a note of a pass was played.
+ *
+ * Offset is the time the note was hit at relative to its start, negative
+ * when early. Cents is the mean deviation of the pitch while the note was
+ * held, negative when flat. Accuracy rates timing and intonation from zero
+ * for a missed note to one for a note hit on time and in tune.
+ */
+type Result struct {
+	Note     melody.Note
+	State    int
+	Offset   time.Duration
+	Cents    float64
+	Accuracy float64
+	count    int
+	sum      float64
+	sumAbs   float64
+}
+
+/*
+ * Data structure summarizing the notes of a pass judged so far.
+ */
+type Summary struct {
+	Pass     int
+	Notes    int
+	Judged   int
+	Hits     int
+	Accuracy float64
+	Score    int
+}
+
+/*
+ * Data structure representing a practice session of a melody.
+ *
+ * The session is driven by the results of the tuner. Their times advance
+ * the melody, so it follows the audio even if results arrive late or in
+ * bursts.
+ *
+ * The note whose hit is still sounding is kept as sounding, or -1 if the
+ * pitch stopped or changed since, with the level of the last result to
+ * detect new attacks.
+ */
+type Session struct {
+	melody   *melody.Melody
+	config   Config
+	results  []Result
+	end      time.Duration
+	position time.Duration
+	clock    float64
+	started  bool
+	waiting  bool
+	paused   bool
+	finished bool
+	pass     int
+	last     []Result
+	summary  Summary
+	best     Summary
+	sounding int
+	level    float64
+}
+
+/*
+ * Checks a configuration.
+ */
+func (this *Config) validate() error {
+
+	if this.TimingWindow <= 0 {
+		return fmt.Errorf("Timing window must be positive, got %s.", this.TimingWindow)
+	} else if !(this.Tolerance > 0.0 && this.Tolerance <= MAX_TOLERANCE) {
+		return fmt.Errorf("Tolerance must be between 0 and %.0f cents, got %v.", MAX_TOLERANCE, this.Tolerance)
+	} else if this.Latency < 0 || this.LeadIn < 0 {
+		return fmt.Errorf("Latency and lead-in must not be negative, got %s and %s.", this.Latency, this.LeadIn)
+	} else if !(this.Speed >= MIN_SPEED && this.Speed <= MAX_SPEED) {
+		return fmt.Errorf("Speed must be between %.2f and %.2f, got %v.", MIN_SPEED, MAX_SPEED, this.Speed)
+	} else if this.From < 0 || (this.To != 0 && this.To <= this.From) {
+		return fmt.Errorf("Section from %s to %s is empty.", this.From, this.To)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Returns the frequency of a MIDI note.
+ */
+func frequency(pitch int) float64 {
+	return 440.0 * math.Pow(2.0, float64(pitch-69)/12.0)
+}
+
+/*
+ * Returns the deviation of a frequency from a MIDI note in cents.
+ */
+func Cents(hz float64, pitch int) float64 {
+	return 1200.0 * math.Log2(hz/frequency(pitch))
+}
+
+/*
+ * Creates a practice session of a melody.
+ */
+func Create(m *melody.Melody, config Config) (*Session, error) {
+	err := config.validate()
+
+	/*
+	 * Check if configuration is valid.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create practice session: %s", msg)
+	}
+
+	/*
+	 * Create data structure for a session.
+	 */
+	s := &Session{
+		melody:   m,
+		config:   config,
+		sounding: -1,
+		level:    math.Inf(-1),
+	}
+
+	err = s.prepare()
+
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create practice session: %s", msg)
+	}
+
+	return s, nil
+}
+
+/*
+ * Collects the notes of the section and starts the first pass.
+ */
+func (this *Session) prepare() error {
+	to := this.config.To
+
+	if to == 0 {
+		to = this.melody.Duration()
+	}
+
+	results := []Result{}
+
+	for _, note := range this.melody.Notes {
+
+		/*
+		 * Notes are cut at the end of the section.
+		 */
+		if note.Start >= this.config.From && note.Start < to {
+
+			if note.End() > to {
+				note.Duration = to - note.Start
+			}
+
+			results = append(results, Result{Note: note})
+		}
+
+	}
+
+	/*
+	 * Check if the section has notes.
+	 */
+	if len(results) == 0 {
+		return fmt.Errorf("Section from %s to %s has no notes.", this.config.From, to)
+	}
+
+	/*
+	 * The pass lasts until the last note can no longer be hit.
+	 */
+	this.end = to
+	lastChance := results[len(results)-1].Note.Start + this.config.TimingWindow
+
+	if lastChance > this.end {
+		this.end = lastChance
+	}
+
+	this.results = results
+	this.pass = 0
+	this.last = nil
+	this.summary = Summary{}
+	this.best = Summary{}
+	this.finished = false
+	this.startPass()
+	return nil
+}
+
+/*
+ * Starts a pass over the section.
+ */
+func (this *Session) startPass() {
+
+	for i := range this.results {
+		this.results[i] = Result{Note: this.results[i].Note}
+	}
+
+	this.pass++
+	this.position = this.config.From - this.config.LeadIn
+	this.waiting = false
+	this.sounding = -1
+}
+
+/*
+ * Completes the pass, the notes which were not hit are missed.
+ */
+func (this *Session) completePass() {
+
+	for i := range this.results {
+
+		if this.results[i].State == STATE_PENDING {
+			this.results[i].State = STATE_MISSED
+		}
+
+	}
+
+	this.last = make([]Result, len(this.results))
+	copy(this.last, this.results)
+	this.summary = this.Summary()
+
+	if this.best.Pass == 0 || this.summary.Score > this.best.Score {
+		this.best = this.summary
+	}
+
+	/*
+	 * Start over if the section is looped.
+	 */
+	if this.config.Loop {
+		this.startPass()
+	} else {
+		this.finished = true
+	}
+
+}
+
+/*
+ * Returns the melody practiced.
+ */
+func (this *Session) Melody() *melody.Melody {
+	return this.melody
+}
+
+/*
+ * Returns the configuration of the session.
+ */
+func (this *Session) Config() Config {
+	return this.config
+}
+
+/*
+ * Returns the notes of the section with how they were played in the
+ * current pass. The results must not be changed.
+ */
+func (this *Session) Results() []Result {
+	return this.results
+}
+
+/*
+ * Returns the position in the melody.
+ */
+func (this *Session) Position() time.Duration {
+	return this.position
+}
+
+/*
+ * Returns the section practiced. The end is zero if the section reaches
+ * the end of the melody.
+ */
+func (this *Session) Section() (time.Duration, time.Duration) {
+	return this.config.From, this.config.To
+}
+
+/*
+ * Returns whether the melody waits for a note to be played.
+ */
+func (this *Session) Waiting() bool {
+	return this.waiting
+}
+
+/*
+ * Returns whether the pass is over and the section is not looped.
+ */
+func (this *Session) Finished() bool {
+	return this.finished
+}
+
+/*
+ * Returns whether the melody is paused.
+ */
+func (this *Session) Paused() bool {
+	return this.paused
+}
+
+/*
+ * Pauses or resumes the melody.
+ */
+func (this *Session) SetPaused(paused bool) {
+	this.paused = paused
+}
+
+/*
+ * Returns the summary of the current pass.
+ */
+func (this *Session) Summary() Summary {
+
+	/*
+	 * Create data structure for a summary.
+	 */
+	summary := Summary{
+		Pass:  this.pass,
+		Notes: len(this.results),
+	}
+
+	sum := 0.0
+
+	for _, result := range this.results {
+
+		if result.State != STATE_PENDING {
+			summary.Judged++
+			sum += result.Accuracy
+		}
+
+		if result.State == STATE_HIT {
+			summary.Hits++
+		}
+
+	}
+
+	if summary.Judged > 0 {
+		summary.Accuracy = sum / float64(summary.Judged)
+	}
+
+	summary.Score = int(math.Round(sum * POINTS_PER_NOTE))
+	return summary
+}
+
+/*
+ * Returns the results and the summary of the last completed pass, nil if
+ * no pass was completed yet.
+ */
+func (this *Session) Last() ([]Result, Summary) {
+	return this.last, this.summary
+}
+
+/*
+ * Returns the summary of the completed pass with the highest score, with
+ * zero as pass if no pass was completed yet.
+ */
+func (this *Session) Best() Summary {
+	return this.best
+}
+
+/*
+ * Starts practicing the section from the first pass.
+ */
+func (this *Session) Restart() {
+
+	/*
+	 * The section was checked when it was set, so it has notes.
+	 */
+	this.prepare()
+}
+
+/*
+ * Sets the section practiced and restarts, to zero reaches the end of the
+ * melody. On error the section is kept.
+ */
+func (this *Session) SetSection(from time.Duration, to time.Duration) error {
+	previous := this.config
+	this.config.From = from
+	this.config.To = to
+	err := this.config.validate()
+
+	if err == nil {
+		err = this.prepare()
+	}
+
+	/*
+	 * Keep the section if the new one is invalid, nothing was changed.
+	 */
+	if err != nil {
+		this.config = previous
+		msg := err.Error()
+		return fmt.Errorf("Failed to set section: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Repeats the section or stops after the current pass.
+ */
+func (this *Session) SetLoop(loop bool) {
+	this.config.Loop = loop
+
+	/*
+	 * Start over after a finished pass.
+	 */
+	if loop && this.finished {
+		this.finished = false
+		this.startPass()
+	}
+
+}
+
+/*
+ * Waits for every note to be played, or keeps time.
+ */
+func (this *Session) SetWait(wait bool) {
+	this.config.Wait = wait
+	this.waiting = false
+}
+
+/*
+ * Changes the speed the melody is practiced at within the supported range.
+ */
+func (this *Session) SetSpeed(speed float64) {
+
+	if speed < MIN_SPEED {
+		speed = MIN_SPEED
+	} else if speed > MAX_SPEED {
+		speed = MAX_SPEED
+	}
+
+	this.config.Speed = speed
+}
+
+/*
+ * Advances the melody to the time of a tuner result and judges the pitch
+ * it detected. Results older than the last one processed are ignored.
+ */
+func (this *Session) Process(entry timeline.Entry) {
+
+	/*
+	 * The first result only starts the clock.
+	 */
+	if !this.started {
+		this.clock = entry.Time
+		this.started = true
+		return
+	} else if entry.Time <= this.clock {
+		return
+	}
+
+	elapsed := time.Duration((entry.Time - this.clock) * float64(time.Second))
+	this.clock = entry.Time
+
+	if this.paused || this.finished {
+		return
+	}
+
+	speed := this.config.Speed
+	this.position += time.Duration(float64(elapsed) * speed)
+	played := this.position - time.Duration(float64(this.config.Latency)*speed)
+
+	held := -1
+
+	/*
+	 * Hold the melody at the next note which was not played.
+	 */
+	if this.config.Wait {
+		played = this.position
+		this.waiting = false
+
+		for i, result := range this.results {
+
+			if result.State == STATE_PENDING {
+
+				if this.position >= result.Note.Start {
+					this.position = result.Note.Start
+					played = this.position
+					this.waiting = true
+					held = i
+				}
+
+				break
+			}
+
+		}
+
+	}
+
+	this.judge(entry, played)
+
+	/*
+	 * Notes are missed once they can no longer be hit.
+	 */
+	if !this.config.Wait {
+
+		for i := range this.results {
+			result := &this.results[i]
+
+			if result.State == STATE_PENDING && result.Note.Start+this.config.TimingWindow < played {
+				result.State = STATE_MISSED
+			}
+
+		}
+
+	}
+
+	/*
+	 * A hit releases the melody held by the wait mode.
+	 */
+	if held >= 0 && this.results[held].State == STATE_HIT {
+		this.waiting = false
+	}
+
+	if played >= this.end {
+		this.completePass()
+	}
+
+}
+
+/*
+ * Credits a detected pitch to the note it plays at a position, if any.
+ */
+func (this *Session) judge(entry timeline.Entry, played time.Duration) {
+	attack := entry.Level-this.level >= ATTACK_LEVEL
+	this.level = entry.Level
+
+	/*
+	 * A silence or a new attack ends the note sounding, and so does a
+	 * change of pitch.
+	 */
+	if !entry.Voiced || !(entry.Frequency > 0.0) {
+		this.sounding = -1
+		return
+	} else if attack {
+		this.sounding = -1
+	} else if this.sounding >= 0 {
+		pitch := this.results[this.sounding].Note.Pitch
+
+		if math.Abs(Cents(entry.Frequency, pitch)) > this.config.Tolerance {
+			this.sounding = -1
+		}
+
+	}
+
+	window := this.config.TimingWindow
+
+	for i := range this.results {
+		result := &this.results[i]
+		note := result.Note
+
+		/*
+		 * Notes are sorted, so the following ones cannot be played yet.
+		 */
+		if played < note.Start-window {
+			return
+		}
+
+		cents := Cents(entry.Frequency, note.Pitch)
+
+		if math.Abs(cents) > this.config.Tolerance {
+			continue
+		}
+
+		/*
+		 * Pending notes are hit within the window around their start,
+		 * hit notes are held until they end.
+		 */
+		if result.State == STATE_PENDING {
+			offset := played - note.Start
+
+			/*
+			 * A note held on from the previous one of the same pitch
+			 * does not play this one.
+			 */
+			if this.sounding >= 0 && this.sounding != i && this.results[this.sounding].Note.Pitch == note.Pitch {
+				continue
+			}
+
+			if this.config.Wait {
+				offset = 0
+			} else if offset > window {
+				continue
+			}
+
+			result.State = STATE_HIT
+			result.Offset = offset
+			this.sounding = i
+		} else if result.State != STATE_HIT || played < note.Start || played >= note.End() {
+			continue
+		}
+
+		result.count++
+		result.sum += cents
+		result.sumAbs += math.Abs(cents)
+		result.Cents = result.sum / float64(result.count)
+		timing := 1.0 - math.Abs(float64(result.Offset))/float64(window)
+		intonation := 1.0 - result.sumAbs/float64(result.count)/this.config.Tolerance
+		result.Accuracy = math.Max(0.0, (timing+intonation)/2.0)
+		return
+	}
+
+}
@@ -0,0 +1,322 @@
+package practice
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/melody"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	TEST_HOP = 50 * time.Millisecond
+)
+
+/*
+ * Returns a melody of A4, C5 and E5, each lasting a second.
+ */
+func testMelody() *melody.Melody {
+	return &melody.Melody{
+		Title: "Arpeggio",
+		Notes: []melody.Note{
+			{Pitch: 69, Start: 0, Duration: time.Second},
+			{Pitch: 72, Start: time.Second, Duration: time.Second},
+			{Pitch: 76, Start: 2 * time.Second, Duration: time.Second},
+		},
+	}
+}
+
+/*
+ * Data structure representing a player feeding tuner results into a
+ * session, one per hop.
+ */
+type player struct {
+	session *Session
+	clock   float64
+	level   float64
+}
+
+/*
+ * Creates a player and starts the clock of the session.
+ */
+func createPlayer(s *Session) *player {
+	p := &player{
+		session: s,
+		clock:   10.0,
+	}
+
+	s.Process(timeline.Entry{Time: p.clock})
+	return p
+}
+
+/*
+ * Plays a MIDI note detuned by some cents for a number of hops, a negative
+ * note plays nothing.
+ */
+func (this *player) play(pitch int, cents float64, hops int) {
+
+	for i := 0; i < hops; i++ {
+		this.clock += TEST_HOP.Seconds()
+		entry := timeline.Entry{Time: this.clock, Level: this.level}
+
+		if pitch >= 0 {
+			entry.Voiced = true
+			entry.Frequency = frequency(pitch) * math.Pow(2.0, cents/1200.0)
+		}
+
+		this.session.Process(entry)
+	}
+
+}
+
+/*
+ * Creates a session or fails the test.
+ */
+func createSession(t *testing.T, config Config) *Session {
+	s, err := Create(testMelody(), config)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create session: %s", msg)
+	}
+
+	return s
+}
+
+/*
+ * Perform a unit test on scoring a pass played in time.
+ */
+func TestScoring(t *testing.T) {
+	config := DefaultConfig()
+	config.LeadIn = 0
+	s := createSession(t, config)
+	p := createPlayer(s)
+
+	/*
+	 * A4 is hit one hop late and in tune, C5 three hops late and sharp,
+	 * E5 is not played.
+	 */
+	p.play(69, 0.0, 20)
+	p.play(-1, 0.0, 2)
+	p.play(72, 20.0, 18)
+
+	if s.Position() != 2*time.Second {
+		t.Errorf("Position is %s, expected %s.", s.Position(), 2*time.Second)
+	}
+
+	results := s.Results()
+
+	if results[0].State != STATE_HIT || results[0].Offset != TEST_HOP || math.Abs(results[0].Cents) > 1e-6 {
+		t.Errorf("A4 was judged %+v, expected a hit %s late in tune.", results[0], TEST_HOP)
+	}
+
+	if math.Abs(results[0].Accuracy-0.875) > 1e-6 {
+		t.Errorf("Accuracy of A4 is %f, expected %f.", results[0].Accuracy, 0.875)
+	}
+
+	if results[1].State != STATE_HIT || results[1].Offset != 3*TEST_HOP || math.Abs(results[1].Cents-20.0) > 1e-6 {
+		t.Errorf("C5 was judged %+v, expected a hit %s late and 20 cents sharp.", results[1], 3*TEST_HOP)
+	}
+
+	if s.Finished() {
+		t.Errorf("%s", "Session finished before the last note.")
+	}
+
+	p.play(-1, 0.0, 25)
+
+	if !s.Finished() || results[2].State != STATE_MISSED {
+		t.Errorf("E5 was judged %+v and finished is %v, expected a miss at the end.", results[2], s.Finished())
+	}
+
+	last, summary := s.Last()
+
+	if len(last) != 3 || summary.Hits != 2 || summary.Judged != 3 || summary.Pass != 1 {
+		t.Errorf("Summary is %+v, expected 2 of 3 notes hit in the first pass.", summary)
+	}
+
+	if summary.Score != int(math.Round(100*(last[0].Accuracy+last[1].Accuracy))) {
+		t.Errorf("Score is %d, expected the sum of the accuracies.", summary.Score)
+	}
+
+	/*
+	 * A finished session does not move.
+	 */
+	p.play(76, 0.0, 5)
+
+	if s.Position() != 3*time.Second {
+		t.Errorf("Finished session moved to %s.", s.Position())
+	}
+
+}
+
+/*
+ * Perform a unit test on repeated notes, which are hit again only after a
+ * silence or a new attack.
+ */
+func TestRepeatedNotes(t *testing.T) {
+	config := DefaultConfig()
+	config.LeadIn = 0
+	repeated := &melody.Melody{
+		Title: "Repeated",
+		Notes: []melody.Note{
+			{Pitch: 69, Start: 0, Duration: time.Second},
+			{Pitch: 69, Start: time.Second, Duration: time.Second},
+			{Pitch: 69, Start: 2 * time.Second, Duration: time.Second},
+			{Pitch: 69, Start: 3 * time.Second, Duration: time.Second},
+		},
+	}
+	s, err := Create(repeated, config)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create session: %s", msg)
+	}
+
+	p := createPlayer(s)
+
+	/*
+	 * A4 held on through the first two notes only hits the first, it hits
+	 * the third after a silence and the fourth with a louder attack.
+	 */
+	p.level = -30.0
+	p.play(69, 0.0, 40)
+	p.play(-1, 0.0, 1)
+	p.play(69, 0.0, 19)
+	p.level = -10.0
+	p.play(69, 0.0, 20)
+
+	results := s.Results()
+	expected := []int{STATE_HIT, STATE_MISSED, STATE_HIT, STATE_HIT}
+
+	for i, state := range expected {
+
+		if results[i].State != state {
+			t.Errorf("Note %d was judged %+v, expected state %d.", i, results[i], state)
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on the wait mode, pausing and latency.
+ */
+func TestWait(t *testing.T) {
+	config := DefaultConfig()
+	config.LeadIn = time.Second
+	config.Wait = true
+	s := createSession(t, config)
+	p := createPlayer(s)
+
+	/*
+	 * The melody stops at A4 until it is played.
+	 */
+	p.play(-1, 0.0, 40)
+
+	if !s.Waiting() || s.Position() != 0 {
+		t.Errorf("Session is at %s waiting %v, expected it to wait at the start.", s.Position(), s.Waiting())
+	}
+
+	p.play(72, 0.0, 5)
+
+	if !s.Waiting() {
+		t.Errorf("%s", "Wrong note released the melody.")
+	}
+
+	p.play(69, 30.0, 1)
+
+	if s.Waiting() || s.Results()[0].State != STATE_HIT || s.Results()[0].Offset != 0 {
+		t.Errorf("A4 was judged %+v, expected a hit without timing.", s.Results()[0])
+	}
+
+	p.play(69, 30.0, 5)
+	s.SetPaused(true)
+	p.play(-1, 0.0, 10)
+
+	if s.Position() != 5*TEST_HOP {
+		t.Errorf("Paused session is at %s, expected %s.", s.Position(), 5*TEST_HOP)
+	}
+
+	/*
+	 * Detection is late by the latency.
+	 */
+	config.Wait = false
+	config.Latency = 2 * TEST_HOP
+	config.LeadIn = 0
+	s = createSession(t, config)
+	p = createPlayer(s)
+	p.play(-1, 0.0, 2)
+	p.play(69, 0.0, 1)
+
+	if s.Results()[0].Offset != TEST_HOP {
+		t.Errorf("A4 was hit %s late, expected %s.", s.Results()[0].Offset, TEST_HOP)
+	}
+
+}
+
+/*
+ * Perform a unit test on looping a section.
+ */
+func TestSection(t *testing.T) {
+	config := DefaultConfig()
+	config.LeadIn = 0
+	config.From = time.Second
+	config.To = 2 * time.Second
+	config.Loop = true
+	s := createSession(t, config)
+
+	if len(s.Results()) != 1 || s.Results()[0].Note.Pitch != 72 {
+		t.Fatalf("Section has notes %+v, expected C5.", s.Results())
+	}
+
+	p := createPlayer(s)
+	p.play(72, 0.0, 20)
+	p.play(-1, 0.0, 5)
+
+	/*
+	 * The second pass has started, the first was hit.
+	 */
+	if s.Finished() || s.Summary().Pass != 2 {
+		t.Errorf("Session is in pass %d finished %v, expected the second pass.", s.Summary().Pass, s.Finished())
+	}
+
+	p.play(-1, 0.0, 25)
+	_, last := s.Last()
+
+	if last.Pass != 2 || last.Hits != 0 || s.Best().Pass != 1 || s.Best().Hits != 1 {
+		t.Errorf("Last pass is %+v, best %+v, expected the first pass to be best.", last, s.Best())
+	}
+
+	/*
+	 * Sections without notes are refused and the section is kept.
+	 */
+	err := s.SetSection(5*time.Second, 6*time.Second)
+
+	if err == nil {
+		t.Errorf("%s", "Section without notes was accepted.")
+	}
+
+	from, to := s.Section()
+
+	if from != time.Second || to != 2*time.Second {
+		t.Errorf("Section changed to %s-%s.", from, to)
+	}
+
+	err = s.SetSection(0, 0)
+
+	if err != nil || len(s.Results()) != 3 || s.Summary().Pass != 1 {
+		t.Errorf("Section of the whole melody has %d notes: %v", len(s.Results()), err)
+	}
+
+	config.Speed = 3.0
+	_, err = Create(testMelody(), config)
+
+	if err == nil {
+		t.Errorf("%s", "Speed out of range was accepted.")
+	}
+
+}
@@ -0,0 +1,389 @@
+package resample
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+ * Trade-off between quality and CPU usage.
+ */
+type Quality int
+
+const (
+	QUALITY_LOW Quality = iota
+	QUALITY_MEDIUM
+	QUALITY_HIGH
+)
+
+/*
+ * Fraction of the target Nyquist frequency which is passed unattenuated.
+ */
+const (
+	ROLLOFF = 0.9
+)
+
+/*
+ * Data structure describing the interpolation kernel for a quality level.
+ *
+ * Taps is the number of zero crossings on each side of the kernel, Phases
+ * the number of precomputed fractional offsets and Beta the shape parameter
+ * of the Kaiser window.
+ */
+type kernelParameters struct {
+	taps   int
+	phases int
+	beta   float64
+}
+
+/*
+ * Data structure representing a streaming sample-rate converter.
+ *
+ * The converter interpolates with a windowed-sinc kernel stored as a
+ * polyphase table, so any ratio between input and output rate is supported.
+ *
+ * The read position is derived from the number of samples produced and
+ * discarded, so that the output does not depend on how the input is split
+ * into blocks.
+ */
+type Resampler struct {
+	inputRate  float64
+	outputRate float64
+	step       float64
+	width      int
+	phases     int
+	table      [][]float64
+	buffer     []float64
+	produced   int64
+	discarded  int64
+}
+
+/*
+ * Returns the kernel parameters for a certain quality level.
+ */
+func parameters(quality Quality) kernelParameters {
+
+	switch quality {
+	case QUALITY_LOW:
+		return kernelParameters{taps: 8, phases: 64, beta: 6.0}
+	case QUALITY_HIGH:
+		return kernelParameters{taps: 32, phases: 512, beta: 10.0}
+	default:
+		return kernelParameters{taps: 16, phases: 256, beta: 8.0}
+	}
+
+}
+
+/*
+ * Zeroth-order modified Bessel function of the first kind.
+ */
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	halfX := 0.5 * x
+
+	/*
+	 * Sum the power series until the terms become negligible.
+	 */
+	for k := 1; k < 64; k++ {
+		factor := halfX / float64(k)
+		term *= factor * factor
+		sum += term
+
+		if term < 1e-12*sum {
+			break
+		}
+
+	}
+
+	return sum
+}
+
+/*
+ * Evaluates a Kaiser window of a certain half-width at position x.
+ */
+func kaiser(x float64, halfWidth float64, beta float64) float64 {
+	ratio := x / halfWidth
+
+	/*
+	 * The window is zero outside of its support.
+	 */
+	if ratio <= -1.0 || ratio >= 1.0 {
+		return 0.0
+	}
+
+	return besselI0(beta*math.Sqrt(1.0-ratio*ratio)) / besselI0(beta)
+}
+
+/*
+ * Normalized sinc function.
+ */
+func sinc(x float64) float64 {
+
+	/*
+	 * Avoid division by zero.
+	 */
+	if x == 0.0 {
+		return 1.0
+	}
+
+	arg := math.Pi * x
+	return math.Sin(arg) / arg
+}
+
+/*
+ * Returns the number of input samples on each side of the kernel, so that
+ * it spans the requested number of zero crossings at a certain cutoff.
+ */
+func kernelWidth(params kernelParameters, cutoff float64) int {
+	return int(math.Ceil(float64(params.taps) / (2.0 * cutoff)))
+}
+
+/*
+ * Calculates the polyphase table of the interpolation kernel.
+ *
+ * Row p holds the coefficients for a fractional offset of p / phases, the
+ * additional last row allows interpolating between phases without wrapping.
+ */
+func createTable(params kernelParameters, cutoff float64) [][]float64 {
+	width := kernelWidth(params, cutoff)
+	phases := params.phases
+	halfWidth := float64(width)
+	table := make([][]float64, phases+1)
+
+	for p := range table {
+		frac := float64(p) / float64(phases)
+		row := make([]float64, 2*width)
+		sum := 0.0
+
+		for i := range row {
+			x := float64(i-width+1) - frac
+			value := 2.0 * cutoff * sinc(2.0*cutoff*x) * kaiser(x, halfWidth, params.beta)
+			row[i] = value
+			sum += value
+		}
+
+		/*
+		 * Normalize each phase to unity gain at DC.
+		 */
+		for i := range row {
+			row[i] /= sum
+		}
+
+		table[p] = row
+	}
+
+	return table
+}
+
+/*
+ * Returns the rate of the input signal.
+ */
+func (this *Resampler) InputRate() float64 {
+	return this.inputRate
+}
+
+/*
+ * Returns the rate of the output signal.
+ */
+func (this *Resampler) OutputRate() float64 {
+	return this.outputRate
+}
+
+/*
+ * Returns the number of input samples which are held back until more input
+ * arrives or the converter is flushed.
+ */
+func (this *Resampler) Latency() int {
+	return this.width
+}
+
+/*
+ * Returns the buffer index and fractional offset of the next output sample.
+ */
+func (this *Resampler) position() (int, float64) {
+	total := float64(this.produced) * this.step
+	whole := math.Floor(total)
+	frac := total - whole
+	idx := int(int64(whole)-this.discarded) + this.width
+	return idx, frac
+}
+
+/*
+ * Interpolates a single output sample at a certain position.
+ */
+func (this *Resampler) interpolate(idx int, frac float64) float64 {
+	phase := frac * float64(this.phases)
+	phaseIdx := int(phase)
+	weight := phase - float64(phaseIdx)
+	rowA := this.table[phaseIdx]
+	rowB := this.table[phaseIdx+1]
+	samples := this.buffer[idx-this.width+1 : idx+this.width+1]
+	sum := 0.0
+
+	/*
+	 * Interpolate linearly between neighbouring phases.
+	 */
+	for i, x := range samples {
+		coefficient := rowA[i] + weight*(rowB[i]-rowA[i])
+		sum += coefficient * x
+	}
+
+	return sum
+}
+
+/*
+ * Converts a block of input samples and appends the result to out.
+ *
+ * Blocks of any size may be passed, the output is continuous across calls.
+ * The extended output slice is returned, so passing a slice with sufficient
+ * capacity avoids allocations.
+ */
+func (this *Resampler) Process(in []float64, out []float64) []float64 {
+	this.buffer = append(this.buffer, in...)
+	width := this.width
+	numBuffered := len(this.buffer)
+	idx, frac := this.position()
+
+	/*
+	 * Produce output as long as the kernel is covered by buffered input.
+	 */
+	for idx+width < numBuffered {
+		out = append(out, this.interpolate(idx, frac))
+		this.produced++
+		idx, frac = this.position()
+	}
+
+	consumed := idx - width + 1
+
+	/*
+	 * Discard input which is no longer needed.
+	 */
+	if consumed > 0 {
+		remaining := copy(this.buffer, this.buffer[consumed:])
+		this.buffer = this.buffer[0:remaining]
+		this.discarded += int64(consumed)
+	}
+
+	return out
+}
+
+/*
+ * Converts a block of input samples and appends the result to out.
+ */
+func (this *Resampler) ProcessFloat32(in []float32, out []float64) []float64 {
+
+	for _, x := range in {
+		this.buffer = append(this.buffer, float64(x))
+	}
+
+	return this.Process(nil, out)
+}
+
+/*
+ * Emits the output held back by the converter, as if the input was followed
+ * by silence.
+ */
+func (this *Resampler) Flush(out []float64) []float64 {
+	silence := make([]float64, this.width)
+	return this.Process(silence, out)
+}
+
+/*
+ * Clears the state of the converter.
+ */
+func (this *Resampler) Reset() {
+	this.buffer = this.buffer[0:0]
+
+	for i := 0; i < this.width; i++ {
+		this.buffer = append(this.buffer, 0.0)
+	}
+
+	this.produced = 0
+	this.discarded = 0
+}
+
+/*
+ * Returns the number of output samples produced from a certain number of
+ * input samples, not counting samples held back by the converter.
+ */
+func (this *Resampler) OutputLength(inputLength int) int {
+	return int(math.Ceil(float64(inputLength) * this.outputRate / this.inputRate))
+}
+
+/*
+ * Creates a sample-rate converter.
+ */
+func Create(inputRate float64, outputRate float64, quality Quality) (*Resampler, error) {
+
+	/*
+	 * Check sample rates.
+	 */
+	if inputRate <= 0.0 {
+		return nil, fmt.Errorf("Input rate must be positive, is %f.", inputRate)
+	} else if outputRate <= 0.0 {
+		return nil, fmt.Errorf("Output rate must be positive, is %f.", outputRate)
+	} else {
+		params := parameters(quality)
+		cutoff := 0.5
+
+		/*
+		 * When downsampling, remove content above the new Nyquist frequency.
+		 */
+		if outputRate < inputRate {
+			cutoff *= ROLLOFF * outputRate / inputRate
+		}
+
+		/*
+		 * The buffer starts with silence, so that the first output sample
+		 * is aligned with the first input sample.
+		 */
+		width := kernelWidth(params, cutoff)
+		buffer := make([]float64, width, 4*width)
+
+		/*
+		 * Create data structure for a resampler.
+		 */
+		r := Resampler{
+			inputRate:  inputRate,
+			outputRate: outputRate,
+			step:       inputRate / outputRate,
+			width:      width,
+			phases:     params.phases,
+			table:      createTable(params, cutoff),
+			buffer:     buffer,
+		}
+
+		return &r, nil
+	}
+
+}
+
+/*
+ * Converts a complete signal from one sample rate to another.
+ */
+func Convert(samples []float64, inputRate float64, outputRate float64, quality Quality) ([]float64, error) {
+	r, err := Create(inputRate, outputRate, quality)
+
+	/*
+	 * Check if the converter could be created.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		n := r.OutputLength(len(samples))
+		out := make([]float64, 0, n+r.width)
+		out = r.Process(samples, out)
+		out = r.Flush(out)
+
+		/*
+		 * Trim the trailing silence.
+		 */
+		if len(out) > n {
+			out = out[0:n]
+		}
+
+		return out, nil
+	}
+
+}
@@ -0,0 +1,181 @@
+package resample
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Generates a sine wave.
+ */
+func sine(frequency float64, sampleRate float64, length int) []float64 {
+	buf := make([]float64, length)
+
+	for i := range buf {
+		arg := 2.0 * math.Pi * frequency * float64(i) / sampleRate
+		buf[i] = math.Sin(arg)
+	}
+
+	return buf
+}
+
+/*
+ * Returns the largest absolute difference between two buffers.
+ */
+func maxDifference(a []float64, b []float64) float64 {
+	diff := 0.0
+
+	for i := range a {
+		diff = math.Max(diff, math.Abs(a[i]-b[i]))
+	}
+
+	return diff
+}
+
+/*
+ * Calculates the RMS level of a buffer.
+ */
+func rms(buf []float64) float64 {
+	sum := 0.0
+
+	for _, x := range buf {
+		sum += x * x
+	}
+
+	return math.Sqrt(sum / float64(len(buf)))
+}
+
+/*
+ * Perform a unit test on conversion between common sample rates.
+ */
+func TestConvert(t *testing.T) {
+
+	/*
+	 * Pairs of input and output rates.
+	 */
+	rates := [][]float64{
+		{44100.0, 48000.0},
+		{48000.0, 44100.0},
+		{96000.0, 48000.0},
+		{44100.0, 96000.0},
+		{48000.0, 16000.0},
+	}
+
+	for _, pair := range rates {
+		inputRate := pair[0]
+		outputRate := pair[1]
+		input := sine(1000.0, inputRate, int(inputRate/10.0))
+		output, err := Convert(input, inputRate, outputRate, QUALITY_HIGH)
+
+		/*
+		 * Check if the signal could be converted.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Errorf("Failed to convert from %.0f Hz to %.0f Hz: %s", inputRate, outputRate, msg)
+		} else {
+			expected := sine(1000.0, outputRate, int(outputRate/10.0))
+
+			/*
+			 * Check the length of the output.
+			 */
+			if len(output) != len(expected) {
+				t.Errorf("Conversion from %.0f Hz to %.0f Hz produced %d samples, expected %d.", inputRate, outputRate, len(output), len(expected))
+			} else {
+				margin := len(expected) / 10
+				diff := maxDifference(output[margin:len(output)-margin], expected[margin:len(expected)-margin])
+
+				/*
+				 * Check if the waveform is preserved away from the edges.
+				 */
+				if diff > 1e-3 {
+					t.Errorf("Conversion from %.0f Hz to %.0f Hz deviates by %e.", inputRate, outputRate, diff)
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Check that content above the target Nyquist frequency is removed.
+ */
+func TestAntiAliasing(t *testing.T) {
+	input := sine(12000.0, 96000.0, 96000)
+	output, err := Convert(input, 96000.0, 16000.0, QUALITY_MEDIUM)
+
+	/*
+	 * Check if the signal could be converted.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to convert: %s", msg)
+	}
+
+	level := rms(output[1000 : len(output)-1000])
+	limit := math.Pow(10.0, -60.0/20.0)
+
+	/*
+	 * Check if the alias is attenuated by at least 60 dB.
+	 */
+	if level > limit {
+		t.Errorf("Alias of 12 kHz tone has level %e, expected below %e.", level, limit)
+	}
+
+}
+
+/*
+ * Check that streaming in blocks of varying size yields the same output as
+ * converting the whole signal at once.
+ */
+func TestStreaming(t *testing.T) {
+	input := sine(440.0, 44100.0, 44100)
+	expected, err := Convert(input, 44100.0, 48000.0, QUALITY_MEDIUM)
+
+	/*
+	 * Check if the signal could be converted.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to convert: %s", msg)
+	}
+
+	r, err := Create(44100.0, 48000.0, QUALITY_MEDIUM)
+
+	/*
+	 * Check if the converter could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create converter: %s", msg)
+	}
+
+	output := []float64{}
+	offset := 0
+
+	for blockSize := 1; offset < len(input); blockSize = (blockSize * 7) % 1031 {
+		end := offset + blockSize
+
+		if end > len(input) {
+			end = len(input)
+		}
+
+		output = r.Process(input[offset:end], output)
+		offset = end
+	}
+
+	output = r.Flush(output)
+	output = output[0:len(expected)]
+	diff := maxDifference(output, expected)
+
+	/*
+	 * Check if block boundaries are seamless.
+	 */
+	if diff > 1e-12 {
+		t.Errorf("Streaming output deviates from block output by %e.", diff)
+	}
+
+}
@@ -0,0 +1,328 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	AUDIO_EXTENSION  = ".wav"
+	FRAMES_EXTENSION = ".jsonl"
+	NAME_LAYOUT      = "session-20060102-150405"
+)
+
+/*
+ * Data structure representing the analysis of a hop of a session.
+ *
+ * Notes holds the magnitude of each displayed note at the time the hop was
+ * recorded, if the recording was made from the visualizer.
+ */
+type Frame struct {
+	timeline.Entry
+	Notes map[string]float64 `json:"notes,omitempty"`
+}
+
+/*
+ * Data structure representing a session being recorded.
+ *
+ * The audio is written to a wave file, while the results of the tuner
+ * analyzing it are written as frames to a JSON lines file next to it. The
+ * recorder does not analyze the audio itself, so writing the audio stays
+ * cheap. Frame times must be positions in the wave file.
+ */
+type Recorder struct {
+	lock    sync.Mutex
+	base    string
+	sink    *audio.FileSink
+	file    *os.File
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+	notes   map[string]float64
+	closed  bool
+}
+
+/*
+ * Returns the base path of a session started at a certain time.
+ */
+func Path(dir string, start time.Time) string {
+	name := start.Format(NAME_LAYOUT)
+	return filepath.Join(dir, name)
+}
+
+/*
+ * Returns the path of the frames file belonging to an audio file.
+ */
+func FramesPath(audioPath string) string {
+	ext := filepath.Ext(audioPath)
+	base := strings.TrimSuffix(audioPath, ext)
+	return base + FRAMES_EXTENSION
+}
+
+/*
+ * Reads the frames of a session.
+ *
+ * Both JSON lines, as written by the recorder, and JSON arrays, as written
+ * by the analyze command, are accepted.
+ */
+func ReadFrames(path string) ([]Frame, error) {
+	content, err := os.ReadFile(path)
+
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to read frames file '%s': %s", path, msg)
+	}
+
+	frames := []Frame{}
+	trimmed := bytes.TrimSpace(content)
+
+	/*
+	 * Decode either an array or one frame per line.
+	 */
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		err = json.Unmarshal(trimmed, &frames)
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+
+		for err == nil && decoder.More() {
+			frame := Frame{}
+			err = decoder.Decode(&frame)
+
+			if err == nil {
+				frames = append(frames, frame)
+			}
+
+		}
+
+	}
+
+	/*
+	 * Check if frames could be decoded.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to decode frames file '%s': %s", path, msg)
+	}
+
+	return frames, nil
+}
+
+/*
+ * Returns the frame covering a certain time in seconds, or nil if the time
+ * lies beyond the last frame. Frames must be sorted by time.
+ */
+func FrameAt(frames []Frame, seconds float64) *Frame {
+
+	/*
+	 * Frames carry the time at the end of their hop.
+	 */
+	idx := sort.Search(len(frames), func(i int) bool {
+		return frames[i].Time >= seconds
+	})
+
+	if idx >= len(frames) {
+		return nil
+	}
+
+	return &frames[idx]
+}
+
+/*
+ * Returns the path of the wave file.
+ */
+func (this *Recorder) AudioPath() string {
+	return this.base + AUDIO_EXTENSION
+}
+
+/*
+ * Returns the path of the frames file.
+ */
+func (this *Recorder) FramesPath() string {
+	return this.base + FRAMES_EXTENSION
+}
+
+/*
+ * Returns the length of the recording in seconds.
+ */
+func (this *Recorder) Seconds() float64 {
+	this.lock.Lock()
+	frames := this.sink.Frames()
+	this.lock.Unlock()
+	return float64(frames) / this.sink.Format().SampleRate
+}
+
+/*
+ * Sets the note magnitudes attached to the following frames.
+ */
+func (this *Recorder) SetNotes(notes map[string]float64) {
+	copied := make(map[string]float64, len(notes))
+
+	for name, value := range notes {
+		copied[name] = value
+	}
+
+	this.lock.Lock()
+	this.notes = copied
+	this.lock.Unlock()
+}
+
+/*
+ * Adds a labelled marker at the current position of the wave file.
+ */
+func (this *Recorder) Mark(label string) {
+	this.lock.Lock()
+
+	/*
+	 * Markers after closing are dropped.
+	 */
+	if !this.closed {
+		this.sink.Mark(label)
+	}
+
+	this.lock.Unlock()
+}
+
+/*
+ * Writes the result of the tuner for a hop ending at a position of the wave
+ * file, along with the note magnitudes set last.
+ */
+func (this *Recorder) WriteFrame(entry timeline.Entry) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	/*
+	 * Closed recorders accept no more data.
+	 */
+	if this.closed {
+		return fmt.Errorf("Session '%s' is already closed.", this.base)
+	}
+
+	/*
+	 * Create data structure for a frame.
+	 */
+	frame := Frame{
+		Entry: entry,
+		Notes: this.notes,
+	}
+
+	err := this.encoder.Encode(frame)
+
+	/*
+	 * Check if frame could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write frame to '%s': %s", this.FramesPath(), msg)
+	}
+
+	return nil
+}
+
+/*
+ * Records interleaved samples.
+ */
+func (this *Recorder) Write(block []float32) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	/*
+	 * Closed recorders accept no more data.
+	 */
+	if this.closed {
+		return fmt.Errorf("Session '%s' is already closed.", this.base)
+	}
+
+	_, err := this.sink.Write(block)
+	return err
+}
+
+/*
+ * Completes and closes both files.
+ */
+func (this *Recorder) Close() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	/*
+	 * Only close once.
+	 */
+	if this.closed {
+		return nil
+	}
+
+	this.closed = true
+	errSink := this.sink.Close()
+	errBuffer := this.buffer.Flush()
+	errFile := this.file.Close()
+
+	/*
+	 * Report the first error.
+	 */
+	for _, err := range []error{errSink, errBuffer, errFile} {
+
+		if err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+/*
+ * Creates a recorder writing a session of audio in a certain format to
+ * files starting with the base path.
+ */
+func CreateRecorder(base string, format audio.Format) (*Recorder, error) {
+	sink, err := audio.CreateFile(base+AUDIO_EXTENSION, format)
+
+	/*
+	 * Check if wave file could be created.
+	 */
+	if err != nil {
+		return nil, err
+	}
+
+	framesPath := base + FRAMES_EXTENSION
+	file, err := os.Create(framesPath)
+
+	/*
+	 * Check if frames file could be created.
+	 */
+	if err != nil {
+		sink.Close()
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create frames file '%s': %s", framesPath, msg)
+	}
+
+	buffer := bufio.NewWriter(file)
+
+	/*
+	 * Create data structure for a recorder.
+	 */
+	recorder := &Recorder{
+		base:    base,
+		sink:    sink,
+		file:    file,
+		buffer:  buffer,
+		encoder: json.NewEncoder(buffer),
+	}
+
+	return recorder, nil
+}
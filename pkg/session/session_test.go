@@ -0,0 +1,215 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+/*
+ * Perform a unit test on session recording.
+ */
+func TestRecorder(t *testing.T) {
+	format := audio.Format{SampleRate: 44100.0, Channels: 1}
+	start := time.Date(2023, 4, 1, 18, 30, 0, 0, time.UTC)
+	base := Path(t.TempDir(), start)
+
+	/*
+	 * Check the naming of sessions.
+	 */
+	if filepath.Base(base) != "session-20230401-183000" {
+		t.Errorf("Session is named '%s', expected '%s'.", filepath.Base(base), "session-20230401-183000")
+	}
+
+	recorder, err := CreateRecorder(base, format)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create recorder: %s", msg)
+	}
+
+	/*
+	 * The frames come from a tuner analyzing the recorded audio.
+	 */
+	analyzer, err := timeline.CreateAnalyzer(format, timeline.DefaultConfig())
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create analyzer: %s", msg)
+	}
+
+	sine := audio.CreateSine(format, 44100, 220.0, 0.3)
+	buf := make([]float32, 1000)
+	recorder.SetNotes(map[string]float64{"A3": 0.5})
+
+	/*
+	 * Record a second in blocks which do not align with the hops.
+	 */
+	for {
+		n, _ := audio.ReadFull(sine, buf)
+
+		if n == 0 {
+			break
+		}
+
+		err = recorder.Write(buf[0:n])
+
+		if err == nil {
+			err = analyzer.Process(buf[0:n], recorder.WriteFrame)
+		}
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to record: %s", msg)
+		}
+
+	}
+
+	recorder.Mark("end")
+
+	if math.Abs(recorder.Seconds()-1.0) > 1e-9 {
+		t.Errorf("Recording is %f s long, expected %f s.", recorder.Seconds(), 1.0)
+	}
+
+	err = recorder.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to close recorder: %s", msg)
+	}
+
+	src, err := audio.OpenFile(recorder.AudioPath())
+
+	/*
+	 * Check the recorded audio.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open recording: %s", msg)
+	}
+
+	defer src.Close()
+
+	if src.Frames() != 44100 {
+		t.Errorf("Recording has %d frames, expected %d.", src.Frames(), 44100)
+	}
+
+	cues := src.Cues()
+
+	if len(cues) != 1 || cues[0].Label != "end" || cues[0].Position != 44100 {
+		t.Errorf("Recording has cue points %+v, expected one named '%s' at %d.", cues, "end", 44100)
+	}
+
+	file, err := os.Open(recorder.FramesPath())
+
+	/*
+	 * Check the recorded frames.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to open frames: %s", msg)
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	frames := []Frame{}
+
+	for scanner.Scan() {
+		frame := Frame{}
+		err = json.Unmarshal(scanner.Bytes(), &frame)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to parse frame: %s", msg)
+		}
+
+		frames = append(frames, frame)
+	}
+
+	/*
+	 * One second at a hop of 50 ms.
+	 */
+	if len(frames) != 20 {
+		t.Fatalf("Session has %d frames, expected %d.", len(frames), 20)
+	}
+
+	last := frames[len(frames)-1]
+
+	if math.Abs(last.Time-1.0) > 1e-9 {
+		t.Errorf("Last frame is at %f s, expected %f s.", last.Time, 1.0)
+	}
+
+	if !last.Voiced || last.Note != "A3" {
+		t.Errorf("Last frame has note '%s', expected '%s'.", last.Note, "A3")
+	}
+
+	if last.Notes["A3"] != 0.5 {
+		t.Errorf("Last frame has magnitude %f for A3, expected %f.", last.Notes["A3"], 0.5)
+	}
+
+}
+
+/*
+ * Check that frames files can be read back.
+ */
+func TestReadFrames(t *testing.T) {
+	dir := t.TempDir()
+	audioPath := filepath.Join(dir, "lesson.flac")
+	path := FramesPath(audioPath)
+
+	/*
+	 * The frames file replaces the extension of the audio file.
+	 */
+	if path != filepath.Join(dir, "lesson.jsonl") {
+		t.Errorf("Frames of '%s' are stored in '%s', expected '%s'.", audioPath, path, filepath.Join(dir, "lesson.jsonl"))
+	}
+
+	contents := []string{
+		"{\"time\":0.05,\"voiced\":false}\n{\"time\":0.1,\"voiced\":true,\"note\":\"A3\"}\n",
+		"[{\"time\":0.05,\"voiced\":false},{\"time\":0.1,\"voiced\":true,\"note\":\"A3\"}]",
+	}
+
+	for _, content := range contents {
+		err := os.WriteFile(path, []byte(content), 0644)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to write frames: %s", msg)
+		}
+
+		frames, err := ReadFrames(path)
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to read frames: %s", msg)
+		}
+
+		if len(frames) != 2 {
+			t.Fatalf("Read %d frames, expected %d.", len(frames), 2)
+		}
+
+		/*
+		 * Look up frames by time.
+		 */
+		if frame := FrameAt(frames, 0.07); frame == nil || frame.Note != "A3" {
+			t.Errorf("Frame at %f s is %+v, expected note '%s'.", 0.07, frame, "A3")
+		}
+
+		if frame := FrameAt(frames, 0.0); frame == nil || frame.Voiced {
+			t.Errorf("Frame at %f s is %+v, expected silence.", 0.0, frame)
+		}
+
+		if frame := FrameAt(frames, 0.2); frame != nil {
+			t.Errorf("Frame at %f s is %+v, expected none.", 0.2, frame)
+		}
+
+	}
+
+}
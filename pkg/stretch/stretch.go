@@ -0,0 +1,336 @@
+package stretch
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	MIN_SPEED       = 0.5
+	MAX_SPEED       = 2.0
+	WINDOW_DURATION = 0.046
+	SEARCH_STEP     = 4
+)
+
+/*
+ * Data structure representing a streaming time stretcher.
+ *
+ * The stretcher implements WSOLA (waveform similarity overlap-add). Windowed
+ * segments of the input are added to the output at a fixed hop, while the
+ * input is advanced by the hop times the speed. Each segment is moved within
+ * a small tolerance so that it continues the previous segment as smoothly
+ * as possible, which keeps the pitch unchanged.
+ *
+ * Positions are counted in frames from the start of the input, the buffer
+ * holds interleaved input samples starting at frame start.
+ */
+type Stretcher struct {
+	channels  int
+	window    int
+	hop       int
+	tolerance int
+	speed     float64
+	shape     []float32
+	buffer    []float32
+	start     int64
+	analysis  float64
+	previous  int64
+	overlap   []float32
+}
+
+/*
+ * Returns the number of interleaved channels.
+ */
+func (this *Stretcher) Channels() int {
+	return this.channels
+}
+
+/*
+ * Returns the playback speed.
+ */
+func (this *Stretcher) Speed() float64 {
+	return this.speed
+}
+
+/*
+ * Changes the playback speed, which must be between MIN_SPEED and
+ * MAX_SPEED.
+ */
+func (this *Stretcher) SetSpeed(speed float64) error {
+
+	/*
+	 * Check the range of the speed.
+	 */
+	if speed < MIN_SPEED || speed > MAX_SPEED || math.IsNaN(speed) {
+		return fmt.Errorf("Speed must be between %.2f and %.2f, is %f.", MIN_SPEED, MAX_SPEED, speed)
+	}
+
+	this.speed = speed
+	return nil
+}
+
+/*
+ * Returns the number of frames held back until more input arrives or the
+ * stretcher is flushed.
+ */
+func (this *Stretcher) Latency() int {
+	return this.window + this.tolerance
+}
+
+/*
+ * Returns the frame of the input at which the next output frame starts.
+ */
+func (this *Stretcher) Position() float64 {
+	return this.analysis
+}
+
+/*
+ * Returns the interleaved samples of a frame range of the buffer.
+ */
+func (this *Stretcher) frames(from int64, length int) []float32 {
+	offset := int(from-this.start) * this.channels
+	return this.buffer[offset : offset+length*this.channels]
+}
+
+/*
+ * Measures how well two ranges of frames match, using every second frame.
+ */
+func (this *Stretcher) similarity(a []float32, b []float32) float64 {
+	stride := 2 * this.channels
+	sum := 0.0
+
+	for i := 0; i < len(a); i += stride {
+
+		for c := 0; c < this.channels; c++ {
+			sum += float64(a[i+c]) * float64(b[i+c])
+		}
+
+	}
+
+	return sum
+}
+
+/*
+ * Finds the start of the segment which best continues the previous one,
+ * searching around a certain frame.
+ */
+func (this *Stretcher) search(center int64) int64 {
+	lowest := center - int64(this.tolerance)
+
+	/*
+	 * Frames before the buffer are gone.
+	 */
+	if lowest < this.start {
+		lowest = this.start
+	}
+
+	/*
+	 * The first segment has nothing to continue.
+	 */
+	if this.previous < 0 {
+		return lowest
+	}
+
+	highest := center + int64(this.tolerance)
+	natural := this.frames(this.previous+int64(this.hop), this.hop)
+	best := lowest
+	bestValue := math.Inf(-1)
+
+	/*
+	 * Search coarsely first.
+	 */
+	for candidate := lowest; candidate <= highest; candidate += SEARCH_STEP {
+		value := this.similarity(this.frames(candidate, this.hop), natural)
+
+		if value > bestValue {
+			best = candidate
+			bestValue = value
+		}
+
+	}
+
+	coarse := best
+
+	/*
+	 * Refine around the best coarse match.
+	 */
+	for candidate := coarse - SEARCH_STEP + 1; candidate < coarse+SEARCH_STEP; candidate++ {
+
+		if candidate >= lowest && candidate <= highest && candidate != coarse {
+			value := this.similarity(this.frames(candidate, this.hop), natural)
+
+			if value > bestValue {
+				best = candidate
+				bestValue = value
+			}
+
+		}
+
+	}
+
+	return best
+}
+
+/*
+ * Stretches a block of interleaved input samples and appends the output
+ * to out.
+ *
+ * Blocks of any size may be passed, the output is continuous across calls.
+ * The extended output slice is returned.
+ */
+func (this *Stretcher) Process(in []float32, out []float32) []float32 {
+	this.buffer = append(this.buffer, in...)
+	numChannels := this.channels
+	end := this.start + int64(len(this.buffer)/numChannels)
+
+	for {
+		center := int64(math.Round(this.analysis))
+		required := center + int64(this.tolerance+this.window)
+
+		/*
+		 * The continuation of the previous segment must be available too.
+		 */
+		if natural := this.previous + int64(this.hop+this.window); natural > required {
+			required = natural
+		}
+
+		if required > end {
+			break
+		}
+
+		position := this.search(center)
+		segment := this.frames(position, this.window)
+
+		/*
+		 * Add the windowed segment to the output.
+		 */
+		for i, x := range segment {
+			this.overlap[i] += x * this.shape[i/numChannels]
+		}
+
+		/*
+		 * The first hop of the output is complete.
+		 */
+		hopSamples := this.hop * numChannels
+		out = append(out, this.overlap[0:hopSamples]...)
+		copy(this.overlap, this.overlap[hopSamples:])
+
+		for i := len(this.overlap) - hopSamples; i < len(this.overlap); i++ {
+			this.overlap[i] = 0.0
+		}
+
+		this.previous = position
+		this.analysis += float64(this.hop) * this.speed
+	}
+
+	keep := int64(math.Round(this.analysis)) - int64(this.tolerance)
+
+	/*
+	 * The continuation of the previous segment is still needed.
+	 */
+	if this.previous >= 0 && this.previous+int64(this.hop) < keep {
+		keep = this.previous + int64(this.hop)
+	}
+
+	/*
+	 * Discard input which is no longer needed.
+	 */
+	if keep > this.start {
+		consumed := int(keep-this.start) * numChannels
+
+		if consumed > len(this.buffer) {
+			consumed = len(this.buffer)
+		}
+
+		remaining := copy(this.buffer, this.buffer[consumed:])
+		this.buffer = this.buffer[0:remaining]
+		this.start += int64(consumed / numChannels)
+	}
+
+	return out
+}
+
+/*
+ * Emits the output held back by the stretcher, as if the input was followed
+ * by silence.
+ */
+func (this *Stretcher) Flush(out []float32) []float32 {
+	frames := int(math.Ceil(float64(this.Latency()+this.window) * this.speed))
+	silence := make([]float32, (frames+this.window)*this.channels)
+	return this.Process(silence, out)
+}
+
+/*
+ * Clears the state of the stretcher, so that the next input is treated as
+ * the start of a new signal.
+ */
+func (this *Stretcher) Reset() {
+	this.buffer = this.buffer[0:0]
+	this.start = 0
+	this.analysis = 0.0
+	this.previous = -1
+
+	for i := range this.overlap {
+		this.overlap[i] = 0.0
+	}
+
+}
+
+/*
+ * Creates a time stretcher for interleaved signals.
+ */
+func Create(channels int, sampleRate float64, speed float64) (*Stretcher, error) {
+
+	/*
+	 * Check the signal parameters.
+	 */
+	if channels < 1 {
+		return nil, fmt.Errorf("Signal must have at least one channel, has %d.", channels)
+	} else if sampleRate <= 0.0 {
+		return nil, fmt.Errorf("Sample rate must be positive, is %f.", sampleRate)
+	} else {
+		hop := int(math.Round(0.5 * WINDOW_DURATION * sampleRate))
+
+		/*
+		 * Keep at least a few frames per hop.
+		 */
+		if hop < SEARCH_STEP {
+			hop = SEARCH_STEP
+		}
+
+		window := 2 * hop
+		shape := make([]float32, window)
+
+		/*
+		 * A periodic Hann window sums to one at half overlap.
+		 */
+		for i := range shape {
+			shape[i] = float32(0.5 - 0.5*math.Cos(2.0*math.Pi*float64(i)/float64(window)))
+		}
+
+		/*
+		 * Create data structure for a stretcher.
+		 */
+		s := &Stretcher{
+			channels:  channels,
+			window:    window,
+			hop:       hop,
+			tolerance: hop / 2,
+			shape:     shape,
+			overlap:   make([]float32, window*channels),
+		}
+
+		err := s.SetSpeed(speed)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s.Reset()
+		return s, nil
+	}
+
+}
@@ -0,0 +1,163 @@
+package stretch
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Generates a stereo sine wave with a different amplitude on each channel.
+ */
+func sine(frequency float64, sampleRate float64, numFrames int) []float32 {
+	samples := make([]float32, 2*numFrames)
+
+	for i := 0; i < numFrames; i++ {
+		value := math.Sin(2.0 * math.Pi * frequency * float64(i) / sampleRate)
+		samples[2*i] = float32(0.5 * value)
+		samples[2*i+1] = float32(0.25 * value)
+	}
+
+	return samples
+}
+
+/*
+ * Estimates the frequency of a channel of an interleaved signal from its
+ * rising zero crossings.
+ */
+func estimateFrequency(samples []float32, channel int, sampleRate float64) float64 {
+	first := -1
+	last := -1
+	crossings := 0
+	numFrames := len(samples) / 2
+
+	for i := 1; i < numFrames; i++ {
+		previous := samples[2*(i-1)+channel]
+		current := samples[2*i+channel]
+
+		if previous < 0.0 && current >= 0.0 {
+
+			if first < 0 {
+				first = i
+			} else {
+				crossings++
+			}
+
+			last = i
+		}
+
+	}
+
+	/*
+	 * At least two crossings are required.
+	 */
+	if crossings == 0 {
+		return 0.0
+	}
+
+	return float64(crossings) * sampleRate / float64(last-first)
+}
+
+/*
+ * Perform a unit test on time stretching.
+ */
+func TestStretcher(t *testing.T) {
+	sampleRate := 44100.0
+	input := sine(440.0, sampleRate, 2*44100)
+
+	for _, speed := range []float64{0.5, 0.75, 1.0, 1.5, 2.0} {
+		s, err := Create(2, sampleRate, speed)
+
+		/*
+		 * Check if stretcher could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to create stretcher: %s", msg)
+		}
+
+		out := []float32{}
+
+		/*
+		 * Feed the input in odd block sizes.
+		 */
+		for offset := 0; offset < len(input); offset += 2 * 777 {
+			end := offset + 2*777
+
+			if end > len(input) {
+				end = len(input)
+			}
+
+			out = s.Process(input[offset:end], out)
+		}
+
+		out = s.Flush(out)
+		expectedFrames := float64(len(input)/2) / speed
+		numFrames := float64(len(out) / 2)
+
+		/*
+		 * The output may only be longer by the flushed latency.
+		 */
+		if numFrames < expectedFrames-float64(s.window) || numFrames > expectedFrames+float64(4*s.Latency()) {
+			t.Errorf("Speed %.2f produced %.0f frames, expected about %.0f.", speed, numFrames, expectedFrames)
+		}
+
+		middle := out[len(out)/4 : len(out)/2]
+
+		/*
+		 * The pitch must not change.
+		 */
+		for c := 0; c < 2; c++ {
+			frequency := estimateFrequency(middle, c, sampleRate)
+
+			if math.Abs(frequency-440.0) > 2.0 {
+				t.Errorf("Speed %.2f changed frequency of channel %d to %.1f Hz, expected %.1f Hz.", speed, c, frequency, 440.0)
+			}
+
+		}
+
+		peak := float32(0.0)
+
+		for _, x := range middle {
+
+			if x > peak {
+				peak = x
+			}
+
+		}
+
+		/*
+		 * The amplitude must be preserved.
+		 */
+		if math.Abs(float64(peak)-0.5) > 0.05 {
+			t.Errorf("Speed %.2f has peak %f, expected %f.", speed, peak, 0.5)
+		}
+
+	}
+
+}
+
+/*
+ * Check that invalid speeds are rejected.
+ */
+func TestSpeed(t *testing.T) {
+	s, err := Create(1, 8000.0, 1.0)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create stretcher: %s", msg)
+	}
+
+	for _, speed := range []float64{0.25, 2.5, math.NaN()} {
+		err = s.SetSpeed(speed)
+
+		if err == nil {
+			t.Errorf("Speed %f was accepted.", speed)
+		}
+
+	}
+
+	if s.Speed() != 1.0 {
+		t.Errorf("Speed is %f after invalid changes, expected %f.", s.Speed(), 1.0)
+	}
+
+}
@@ -0,0 +1,249 @@
+package timeline
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/tuner"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	DEFAULT_HOP           = 50 * time.Millisecond
+	DEFAULT_ANALYSIS_RATE = 16000
+)
+
+/*
+ * Data structure representing the pitch of a signal at a point in time.
+ *
+ * Time is the end of the analyzed hop in seconds. Level is the RMS level
+ * of the hop in dBFS. Entries for which the signal was below the noise gate
+ * are not voiced and carry no pitch.
+ */
+type Entry struct {
+	File       string  `json:"file,omitempty"`
+	Time       float64 `json:"time"`
+	Voiced     bool    `json:"voiced"`
+	Frequency  float64 `json:"frequency"`
+	Note       string  `json:"note"`
+	Cents      int     `json:"cents"`
+	Confidence float64 `json:"confidence"`
+	Level      float64 `json:"level"`
+}
+
+/*
+ * Data structure describing how a signal is analyzed.
+ *
+ * An analysis rate of zero analyzes the signal at its own rate.
+ */
+type Config struct {
+	Hop          time.Duration
+	AnalysisRate uint32
+	Preprocess   tuner.PreprocessConfig
+}
+
+/*
+ * Returns the configuration used by the command line tools.
+ */
+func DefaultConfig() Config {
+	return Config{
+		Hop:          DEFAULT_HOP,
+		AnalysisRate: DEFAULT_ANALYSIS_RATE,
+		Preprocess:   tuner.DefaultPreprocessConfig(),
+	}
+}
+
+/*
+ * Data structure representing a streaming pitch analysis.
+ *
+ * Blocks of any length are collected into hops, an entry is produced
+ * after every complete hop.
+ */
+type Analyzer struct {
+	format    audio.Format
+	hopFrames int
+	tuner     *tuner.Tuner
+	hop       []float32
+	mono      []float64
+	frames    int64
+}
+
+/*
+ * Returns the number of frames analyzed so far.
+ */
+func (this *Analyzer) Frames() int64 {
+	return this.frames
+}
+
+/*
+ * Analyzes a hop and calls fn with the resulting entry.
+ */
+func (this *Analyzer) analyze(block []float32, fn func(entry Entry) error) error {
+	numChannels := this.format.Channels
+	sampleRate := this.format.SampleRate
+	this.mono = audio.Downmix(block, numChannels, this.mono[0:0])
+	this.tuner.Process(this.mono, uint32(sampleRate))
+	this.frames += int64(len(block) / numChannels)
+	level := audio.Measure(block)
+
+	/*
+	 * Create data structure for a timeline entry.
+	 */
+	entry := Entry{
+		Time:  float64(this.frames) / sampleRate,
+		Level: audio.Decibels(level.RMS),
+	}
+
+	/*
+	 * Only voiced hops carry a pitch.
+	 */
+	if this.tuner.Voiced() {
+		result, err := this.tuner.Analyze()
+
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to analyze hop ending at %.3f s: %s", entry.Time, msg)
+		}
+
+		entry.Voiced = true
+		entry.Frequency = result.Frequency()
+		entry.Note = result.Note()
+		entry.Cents = int(result.Cents())
+		entry.Confidence = result.Confidence()
+	}
+
+	return fn(entry)
+}
+
+/*
+ * Adds interleaved samples and calls fn for every hop completed by them.
+ */
+func (this *Analyzer) Process(block []float32, fn func(entry Entry) error) error {
+	hopSamples := this.format.Samples(this.hopFrames)
+
+	for len(block) > 0 {
+		missing := hopSamples - len(this.hop)
+
+		/*
+		 * Take as much as fits into the current hop.
+		 */
+		if missing > len(block) {
+			missing = len(block)
+		}
+
+		this.hop = append(this.hop, block[0:missing]...)
+		block = block[missing:]
+
+		/*
+		 * Analyze complete hops.
+		 */
+		if len(this.hop) == hopSamples {
+			err := this.analyze(this.hop, fn)
+			this.hop = this.hop[0:0]
+
+			if err != nil {
+				return err
+			}
+
+		}
+
+	}
+
+	return nil
+}
+
+/*
+ * Analyzes an incomplete last hop, if any, at the end of the signal.
+ */
+func (this *Analyzer) Flush(fn func(entry Entry) error) error {
+	numChannels := this.format.Channels
+	numSamples := len(this.hop) - (len(this.hop) % numChannels)
+
+	/*
+	 * Nothing is left to analyze.
+	 */
+	if numSamples == 0 {
+		return nil
+	}
+
+	err := this.analyze(this.hop[0:numSamples], fn)
+	this.hop = this.hop[0:0]
+	return err
+}
+
+/*
+ * Creates a streaming analysis of a signal in a certain format.
+ */
+func CreateAnalyzer(format audio.Format, config Config) (*Analyzer, error) {
+	hopFrames := int(format.SampleRate * config.Hop.Seconds())
+
+	/*
+	 * Hops must contain at least one frame.
+	 */
+	if hopFrames < 1 {
+		return nil, fmt.Errorf("Hop of %s is shorter than a frame.", config.Hop)
+	}
+
+	t := tuner.Create()
+	t.SetPreprocessing(config.Preprocess)
+	t.SetAnalysisRate(config.AnalysisRate)
+
+	/*
+	 * Create data structure for an analyzer.
+	 */
+	analyzer := &Analyzer{
+		format:    format,
+		hopFrames: hopFrames,
+		tuner:     t,
+		hop:       make([]float32, 0, format.Samples(hopFrames)),
+	}
+
+	return analyzer, nil
+}
+
+/*
+ * Runs the tuner over a source and calls fn with an entry after every hop,
+ * until the source ends or fn returns an error.
+ */
+func Analyze(src audio.Source, config Config, fn func(entry Entry) error) error {
+	format := src.Format()
+	analyzer, err := CreateAnalyzer(format, config)
+
+	/*
+	 * Check if analysis could be set up.
+	 */
+	if err != nil {
+		return err
+	}
+
+	buf := make([]float32, format.Samples(analyzer.hopFrames))
+
+	for {
+		n, err := audio.ReadFull(src, buf)
+
+		/*
+		 * Analyze what was read before handling errors.
+		 */
+		if n > 0 {
+			errProcess := analyzer.Process(buf[0:n], fn)
+
+			if errProcess != nil {
+				return errProcess
+			}
+
+		}
+
+		if err == io.EOF {
+			return analyzer.Flush(fn)
+		} else if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to read from source: %s", msg)
+		}
+
+	}
+
+}
@@ -0,0 +1,184 @@
+package timeline
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+)
+
+/*
+ * Generates silence, followed by two seconds of A3 and silence again.
+ */
+func testSource() audio.Source {
+	format := audio.Format{SampleRate: 44100.0, Channels: 2}
+
+	next := func(frame int64) float64 {
+		seconds := float64(frame) / format.SampleRate
+
+		/*
+		 * Only the middle of the signal is voiced.
+		 */
+		if seconds < 0.5 || seconds >= 2.5 {
+			return 0.0
+		}
+
+		return 0.3 * math.Sin(2.0*math.Pi*220.0*seconds)
+	}
+
+	return audio.CreateGenerator(format, 3*44100, next)
+}
+
+/*
+ * Perform a unit test on timeline analysis.
+ */
+func TestAnalyze(t *testing.T) {
+	entries := []Entry{}
+
+	err := Analyze(testSource(), DefaultConfig(), func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+
+	/*
+	 * Check if signal could be analyzed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to analyze signal: %s", msg)
+	}
+
+	/*
+	 * Three seconds at a hop of 50 ms.
+	 */
+	if len(entries) != 60 {
+		t.Fatalf("Timeline has %d entries, expected %d.", len(entries), 60)
+	}
+
+	for _, entry := range entries {
+
+		/*
+		 * Check the silent parts.
+		 */
+		if entry.Time < 0.5 && entry.Voiced {
+			t.Errorf("Entry at %.3f s is voiced, expected silence.", entry.Time)
+		}
+
+		/*
+		 * Check the stable part of the tone.
+		 */
+		if entry.Time > 0.7 && entry.Time < 2.4 {
+
+			if !entry.Voiced {
+				t.Errorf("Entry at %.3f s is not voiced.", entry.Time)
+			} else if entry.Note != "A3" {
+				t.Errorf("Entry at %.3f s has note %s, expected %s.", entry.Time, entry.Note, "A3")
+			} else if math.Abs(entry.Frequency-220.0) > 1.0 {
+				t.Errorf("Entry at %.3f s has frequency %f, expected %f.", entry.Time, entry.Frequency, 220.0)
+			} else if entry.Confidence < 0.9 {
+				t.Errorf("Entry at %.3f s has confidence %f, expected above %f.", entry.Time, entry.Confidence, 0.9)
+			}
+
+			expectedLevel := audio.Decibels(0.3 / math.Sqrt(2.0))
+
+			if math.Abs(entry.Level-expectedLevel) > 0.5 {
+				t.Errorf("Entry at %.3f s has level %f dBFS, expected %f dBFS.", entry.Time, entry.Level, expectedLevel)
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on the serialization formats.
+ */
+func TestWriter(t *testing.T) {
+
+	entries := []Entry{
+		{File: "a.wav", Time: 0.05, Level: -120.0},
+		{File: "a.wav", Time: 0.1, Voiced: true, Frequency: 220.5, Note: "A3", Cents: 4, Confidence: 0.98, Level: -13.5},
+	}
+
+	for _, format := range []Format{FORMAT_CSV, FORMAT_JSON, FORMAT_JSONL} {
+		buf := &bytes.Buffer{}
+		w := CreateWriter(buf, format)
+
+		for _, entry := range entries {
+			err := w.Write(entry)
+
+			if err != nil {
+				msg := err.Error()
+				t.Fatalf("Failed to write %s entry: %s", format, msg)
+			}
+
+		}
+
+		err := w.Close()
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to close %s writer: %s", format, msg)
+		}
+
+		decoded := []Entry{}
+
+		/*
+		 * Decode the output again.
+		 */
+		switch format {
+		case FORMAT_CSV:
+			records, err := csv.NewReader(buf).ReadAll()
+
+			if err != nil {
+				msg := err.Error()
+				t.Fatalf("Failed to parse CSV: %s", msg)
+			}
+
+			if len(records) != 3 {
+				t.Fatalf("CSV has %d rows, expected %d.", len(records), 3)
+			}
+
+			if records[2][4] != "A3" || records[2][3] != "220.50" {
+				t.Errorf("CSV row is %v, expected note A3 at 220.50 Hz.", records[2])
+			}
+
+			continue
+		case FORMAT_JSON:
+			err = json.Unmarshal(buf.Bytes(), &decoded)
+		case FORMAT_JSONL:
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+			for _, line := range lines {
+				entry := Entry{}
+				err = json.Unmarshal([]byte(line), &entry)
+				decoded = append(decoded, entry)
+			}
+
+		}
+
+		if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to parse %s: %s", format, msg)
+		}
+
+		if len(decoded) != len(entries) {
+			t.Fatalf("Decoded %d %s entries, expected %d.", len(decoded), format, len(entries))
+		}
+
+		for i := range entries {
+
+			if decoded[i] != entries[i] {
+				t.Errorf("Decoded %s entry %+v, expected %+v.", format, decoded[i], entries[i])
+			}
+
+		}
+
+	}
+
+}
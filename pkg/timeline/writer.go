@@ -0,0 +1,225 @@
+package timeline
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Serialization formats of a timeline.
+ */
+type Format int
+
+const (
+	FORMAT_CSV Format = iota
+	FORMAT_JSON
+	FORMAT_JSONL
+)
+
+/*
+ * Interface implemented by everything that serializes timeline entries.
+ *
+ * Close completes the output, it does not close the underlying stream.
+ */
+type Writer interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+/*
+ * Returns the name of a format.
+ */
+func (this Format) String() string {
+
+	switch this {
+	case FORMAT_CSV:
+		return "csv"
+	case FORMAT_JSON:
+		return "json"
+	case FORMAT_JSONL:
+		return "jsonl"
+	default:
+		return fmt.Sprintf("Format(%d)", int(this))
+	}
+
+}
+
+/*
+ * Parses the name of a format.
+ */
+func ParseFormat(name string) (Format, error) {
+	nameLower := strings.ToLower(name)
+
+	switch nameLower {
+	case "csv":
+		return FORMAT_CSV, nil
+	case "json":
+		return FORMAT_JSON, nil
+	case "jsonl", "ndjson":
+		return FORMAT_JSONL, nil
+	default:
+		return FORMAT_CSV, fmt.Errorf("Unknown timeline format '%s', expected csv, json or jsonl.", name)
+	}
+
+}
+
+/*
+ * Data structure representing a writer of comma-separated values.
+ */
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+	record []string
+}
+
+/*
+ * Column names of the CSV format.
+ */
+var CSV_HEADER = []string{
+	"file",
+	"time",
+	"voiced",
+	"frequency",
+	"note",
+	"cents",
+	"confidence",
+	"level",
+}
+
+/*
+ * Writes an entry as a row, preceded by the header for the first entry.
+ */
+func (this *csvWriter) Write(entry Entry) error {
+
+	/*
+	 * Start with the header.
+	 */
+	if !this.header {
+		this.header = true
+		err := this.writer.Write(CSV_HEADER)
+
+		if err != nil {
+			return err
+		}
+
+	}
+
+	record := this.record
+	record[0] = entry.File
+	record[1] = strconv.FormatFloat(entry.Time, 'f', 3, 64)
+	record[2] = strconv.FormatBool(entry.Voiced)
+	record[3] = strconv.FormatFloat(entry.Frequency, 'f', 2, 64)
+	record[4] = entry.Note
+	record[5] = strconv.Itoa(entry.Cents)
+	record[6] = strconv.FormatFloat(entry.Confidence, 'f', 3, 64)
+	record[7] = strconv.FormatFloat(entry.Level, 'f', 1, 64)
+	return this.writer.Write(record)
+}
+
+/*
+ * Flushes buffered rows.
+ */
+func (this *csvWriter) Close() error {
+	this.writer.Flush()
+	return this.writer.Error()
+}
+
+/*
+ * Data structure representing a writer of JSON arrays.
+ */
+type jsonWriter struct {
+	writer io.Writer
+	count  int
+}
+
+/*
+ * Writes an entry as an element of the array.
+ */
+func (this *jsonWriter) Write(entry Entry) error {
+	buf, err := json.Marshal(entry)
+
+	/*
+	 * Check if entry could be serialized.
+	 */
+	if err != nil {
+		return err
+	}
+
+	prefix := ",\n\t"
+
+	/*
+	 * The first element opens the array.
+	 */
+	if this.count == 0 {
+		prefix = "[\n\t"
+	}
+
+	this.count++
+	_, err = fmt.Fprintf(this.writer, "%s%s", prefix, buf)
+	return err
+}
+
+/*
+ * Closes the array.
+ */
+func (this *jsonWriter) Close() error {
+	suffix := "\n]\n"
+
+	/*
+	 * Empty timelines are empty arrays.
+	 */
+	if this.count == 0 {
+		suffix = "[]\n"
+	}
+
+	_, err := io.WriteString(this.writer, suffix)
+	return err
+}
+
+/*
+ * Data structure representing a writer of JSON lines.
+ */
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+/*
+ * Writes an entry as a line.
+ */
+func (this *jsonlWriter) Write(entry Entry) error {
+	return this.encoder.Encode(entry)
+}
+
+/*
+ * Lines are complete once written.
+ */
+func (this *jsonlWriter) Close() error {
+	return nil
+}
+
+/*
+ * Creates a writer serializing entries in a certain format.
+ */
+func CreateWriter(w io.Writer, format Format) Writer {
+
+	switch format {
+	case FORMAT_JSON:
+		return &jsonWriter{
+			writer: w,
+		}
+	case FORMAT_JSONL:
+		return &jsonlWriter{
+			encoder: json.NewEncoder(w),
+		}
+	default:
+		return &csvWriter{
+			writer: csv.NewWriter(w),
+			record: make([]string, len(CSV_HEADER)),
+		}
+	}
+
+}
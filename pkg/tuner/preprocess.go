@@ -0,0 +1,332 @@
+package tuner
+
+import (
+	"math"
+
+	"github.com/metalblueberry/bard/pkg/filter"
+)
+
+/*
+ * Data structure describing the preprocessing applied to samples before
+ * they are buffered for analysis.
+ *
+ * Stages are applied in the following order and are disabled when their
+ * parameter is zero.
+ *
+ *  1. DC blocker (DCBlock)
+ *  2. High-pass filter (HighPass, cutoff in Hz)
+ *  3. Low-pass filter (LowPass, cutoff in Hz)
+ *  4. Noise gate (GateOpen / GateClose, levels in dBFS)
+ *  5. Automatic gain control (AGCTarget, level in dBFS)
+ *
+ * The gate opens when the signal envelope rises above GateOpen and closes
+ * when it falls below GateClose, which should be lower to avoid chatter.
+ */
+type PreprocessConfig struct {
+	DCBlock    bool
+	HighPass   float64
+	LowPass    float64
+	GateOpen   float64
+	GateClose  float64
+	AGCTarget  float64
+	AGCMaxGain float64
+}
+
+/*
+ * Returns a preprocessing configuration suitable for instrument tuning.
+ */
+func DefaultPreprocessConfig() PreprocessConfig {
+	return PreprocessConfig{
+		DCBlock:    true,
+		HighPass:   40.0,
+		LowPass:    5000.0,
+		GateOpen:   -50.0,
+		GateClose:  -56.0,
+		AGCTarget:  -20.0,
+		AGCMaxGain: 30.0,
+	}
+}
+
+/*
+ * Time constants of the envelope followers.
+ */
+const (
+	DC_BLOCK_POLE   = 0.995
+	GATE_ATTACK     = 0.001
+	GATE_RELEASE    = 0.050
+	GATE_RAMP       = 0.005
+	AGC_WINDOW      = 0.300
+	AGC_GAIN_SMOOTH = 0.100
+)
+
+/*
+ * Data structure representing a noise gate with hysteresis.
+ */
+type noiseGate struct {
+	envelope float64
+	gain     float64
+	open     bool
+}
+
+/*
+ * Data structure representing an automatic gain control.
+ */
+type gainControl struct {
+	meanSquare float64
+	gain       float64
+}
+
+/*
+ * Data structure representing a preprocessing chain.
+ */
+type Preprocessor struct {
+	config     PreprocessConfig
+	sampleRate uint32
+	dcX1       float64
+	dcY1       float64
+	highPass   *filter.Biquad
+	lowPass    *filter.Biquad
+	gate       noiseGate
+	agc        gainControl
+	buffer     []float64
+}
+
+/*
+ * Converts a level in dBFS to a linear amplitude.
+ */
+func fromDecibels(level float64) float64 {
+	return math.Pow(10.0, level/20.0)
+}
+
+/*
+ * Returns the coefficient of a one-pole smoothing filter with a certain time
+ * constant in seconds.
+ */
+func smoothing(timeConstant float64, sampleRate float64) float64 {
+	return 1.0 - math.Exp(-1.0/(timeConstant*sampleRate))
+}
+
+/*
+ * Applies the noise gate to a single sample.
+ */
+func (this *noiseGate) process(x float64, openLevel float64, closeLevel float64, attack float64, release float64, ramp float64) float64 {
+	level := math.Abs(x)
+
+	/*
+	 * Follow the envelope quickly when rising and slowly when falling.
+	 */
+	if level > this.envelope {
+		this.envelope += attack * (level - this.envelope)
+	} else {
+		this.envelope += release * (level - this.envelope)
+	}
+
+	/*
+	 * Switch state with hysteresis.
+	 */
+	if this.open && this.envelope < closeLevel {
+		this.open = false
+	} else if !this.open && this.envelope > openLevel {
+		this.open = true
+	}
+
+	target := 0.0
+
+	if this.open {
+		target = 1.0
+	}
+
+	this.gain += ramp * (target - this.gain)
+	return this.gain * x
+}
+
+/*
+ * Applies the automatic gain control to a single sample.
+ *
+ * The level estimate is frozen while hold is set, so that the gain does not
+ * creep up while the noise gate is closed.
+ */
+func (this *gainControl) process(x float64, target float64, maxGain float64, window float64, smooth float64, hold bool) float64 {
+
+	/*
+	 * Track the mean square of the signal.
+	 */
+	if !hold {
+		this.meanSquare += window * (x*x - this.meanSquare)
+	}
+
+	rms := math.Sqrt(this.meanSquare)
+	desired := maxGain
+
+	/*
+	 * Avoid division by zero for silent input.
+	 */
+	if rms > 0.0 {
+		desired = target / rms
+	}
+
+	if desired > maxGain {
+		desired = maxGain
+	}
+
+	this.gain += smooth * (desired - this.gain)
+	return this.gain * x
+}
+
+/*
+ * Returns the configuration of the preprocessing chain.
+ */
+func (this *Preprocessor) Config() PreprocessConfig {
+	return this.config
+}
+
+/*
+ * Returns whether the noise gate is currently open.
+ *
+ * If the gate is disabled, it is always considered open.
+ */
+func (this *Preprocessor) GateOpen() bool {
+	return this.config.GateOpen == 0.0 || this.gate.open
+}
+
+/*
+ * Returns the gain currently applied by the automatic gain control.
+ */
+func (this *Preprocessor) Gain() float64 {
+	return this.agc.gain
+}
+
+/*
+ * Redesigns filters for a new sample rate.
+ */
+func (this *Preprocessor) setSampleRate(sampleRate uint32) {
+	config := this.config
+	rate := float64(sampleRate)
+	this.sampleRate = sampleRate
+	this.highPass = nil
+	this.lowPass = nil
+
+	/*
+	 * Filters whose cutoff is not below the Nyquist frequency cannot be
+	 * designed and stay disabled.
+	 */
+	if config.HighPass > 0.0 {
+		this.highPass, _ = filter.CreateHighPass(config.HighPass, filter.BUTTERWORTH_Q, rate)
+	}
+
+	if config.LowPass > 0.0 {
+		this.lowPass, _ = filter.CreateLowPass(config.LowPass, filter.BUTTERWORTH_Q, rate)
+	}
+
+}
+
+/*
+ * Runs samples through the preprocessing chain.
+ *
+ * The result is stored in an internal buffer, which is only valid until the
+ * next call. The input samples are left untouched.
+ */
+func (this *Preprocessor) Process(samples []float64, sampleRate uint32) []float64 {
+	config := this.config
+	n := len(samples)
+
+	/*
+	 * Redesign filters if the sample rate changed.
+	 */
+	if sampleRate != this.sampleRate {
+		this.setSampleRate(sampleRate)
+	}
+
+	/*
+	 * Ensure that the output buffer is of correct length.
+	 */
+	if cap(this.buffer) < n {
+		this.buffer = make([]float64, n)
+	}
+
+	buf := this.buffer[0:n]
+	rate := float64(sampleRate)
+	highPass := this.highPass
+	lowPass := this.lowPass
+	useGate := config.GateOpen != 0.0
+	useAGC := config.AGCTarget != 0.0
+	gateOpen := fromDecibels(config.GateOpen)
+	gateClose := fromDecibels(config.GateClose)
+
+	/*
+	 * Never close above the opening threshold.
+	 */
+	if config.GateClose == 0.0 || gateClose > gateOpen {
+		gateClose = gateOpen
+	}
+
+	gateAttack := smoothing(GATE_ATTACK, rate)
+	gateRelease := smoothing(GATE_RELEASE, rate)
+	gateRamp := smoothing(GATE_RAMP, rate)
+	agcTarget := fromDecibels(config.AGCTarget)
+	agcMaxGain := fromDecibels(config.AGCMaxGain)
+	agcWindow := smoothing(AGC_WINDOW, rate)
+	agcSmooth := smoothing(AGC_GAIN_SMOOTH, rate)
+
+	for i, x := range samples {
+
+		/*
+		 * y[n] = x[n] - x[n - 1] + p * y[n - 1]
+		 */
+		if config.DCBlock {
+			y := x - this.dcX1 + DC_BLOCK_POLE*this.dcY1
+			this.dcX1 = x
+			this.dcY1 = y
+			x = y
+		}
+
+		if highPass != nil {
+			x = highPass.ProcessSample(x)
+		}
+
+		if lowPass != nil {
+			x = lowPass.ProcessSample(x)
+		}
+
+		if useGate {
+			x = this.gate.process(x, gateOpen, gateClose, gateAttack, gateRelease, gateRamp)
+		}
+
+		if useAGC {
+			hold := useGate && !this.gate.open
+			x = this.agc.process(x, agcTarget, agcMaxGain, agcWindow, agcSmooth, hold)
+		}
+
+		buf[i] = x
+	}
+
+	return buf
+}
+
+/*
+ * Creates a preprocessing chain.
+ */
+func CreatePreprocessor(config PreprocessConfig) *Preprocessor {
+
+	/*
+	 * Create data structure for a preprocessing chain.
+	 */
+	p := Preprocessor{
+		config: config,
+		gate: noiseGate{
+			gain: 1.0,
+		},
+		agc: gainControl{
+			gain: 1.0,
+		},
+	}
+
+	/*
+	 * Without a gate, start fully open.
+	 */
+	if config.GateOpen != 0.0 {
+		p.gate.gain = 0.0
+	}
+
+	return &p
+}
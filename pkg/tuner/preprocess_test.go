@@ -0,0 +1,140 @@
+package tuner
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Generates a sine wave with a DC offset.
+ */
+func sineWithOffset(frequency float64, amplitude float64, offset float64, sampleRate uint32, length int) []float64 {
+	buf := make([]float64, length)
+	rate := float64(sampleRate)
+
+	for i := range buf {
+		arg := 2.0 * math.Pi * frequency * float64(i) / rate
+		buf[i] = offset + amplitude*math.Sin(arg)
+	}
+
+	return buf
+}
+
+/*
+ * Calculates the mean and RMS level of a buffer.
+ */
+func meanAndRMS(buf []float64) (float64, float64) {
+	sum := 0.0
+	sumSquares := 0.0
+
+	for _, x := range buf {
+		sum += x
+		sumSquares += x * x
+	}
+
+	n := float64(len(buf))
+	return sum / n, math.Sqrt(sumSquares / n)
+}
+
+/*
+ * Perform a unit test on the filter stages of the preprocessing chain.
+ */
+func TestPreprocessFilters(t *testing.T) {
+	sampleRate := uint32(48000)
+	p := CreatePreprocessor(PreprocessConfig{
+		DCBlock:  true,
+		HighPass: 200.0,
+	})
+
+	/*
+	 * Mains hum with a DC offset should be removed.
+	 */
+	hum := sineWithOffset(50.0, 0.5, 0.3, sampleRate, 48000)
+	out := p.Process(hum, sampleRate)
+	mean, rms := meanAndRMS(out[24000:])
+
+	/*
+	 * Check if DC offset was removed.
+	 */
+	if math.Abs(mean) > 0.001 {
+		t.Errorf("DC offset not removed, mean is %f.", mean)
+	}
+
+	/*
+	 * Check if hum was attenuated.
+	 */
+	if rms > 0.02 {
+		t.Errorf("Hum not attenuated, RMS is %f.", rms)
+	}
+
+	/*
+	 * Check if input was left untouched.
+	 */
+	if hum[0] != 0.3 {
+		t.Errorf("Preprocessing modified its input.")
+	}
+
+	tone := sineWithOffset(440.0, 0.5, 0.3, sampleRate, 48000)
+	out = p.Process(tone, sampleRate)
+	_, rms = meanAndRMS(out[24000:])
+	expected := 0.5 / math.Sqrt2
+
+	/*
+	 * Check if tones above the cutoff pass.
+	 */
+	if math.Abs(rms-expected) > 0.05 {
+		t.Errorf("Tone above cutoff attenuated, RMS is %f, expected %f.", rms, expected)
+	}
+
+}
+
+/*
+ * Perform a unit test on the noise gate and automatic gain control.
+ */
+func TestPreprocessDynamics(t *testing.T) {
+	sampleRate := uint32(48000)
+	p := CreatePreprocessor(PreprocessConfig{
+		GateOpen:   -40.0,
+		GateClose:  -46.0,
+		AGCTarget:  -20.0,
+		AGCMaxGain: 40.0,
+	})
+
+	/*
+	 * Quiet noise must not open the gate.
+	 */
+	quiet := sineWithOffset(440.0, 0.001, 0.0, sampleRate, 24000)
+	out := p.Process(quiet, sampleRate)
+	_, rms := meanAndRMS(out)
+
+	/*
+	 * Check if the gate stayed closed.
+	 */
+	if p.GateOpen() {
+		t.Errorf("Gate opened on quiet input.")
+	}
+
+	if rms > 0.0001 {
+		t.Errorf("Gate leaks quiet input, RMS is %f.", rms)
+	}
+
+	loud := sineWithOffset(440.0, 0.05, 0.0, sampleRate, 96000)
+	out = p.Process(loud, sampleRate)
+	_, rms = meanAndRMS(out[72000:])
+	expected := fromDecibels(-20.0)
+
+	/*
+	 * Check if the gate opened.
+	 */
+	if !p.GateOpen() {
+		t.Errorf("Gate did not open on loud input.")
+	}
+
+	/*
+	 * Check if the gain control reached the target level.
+	 */
+	if math.Abs(rms-expected) > 0.1*expected {
+		t.Errorf("AGC did not reach target level, RMS is %f, expected %f.", rms, expected)
+	}
+
+}
@@ -8,13 +8,18 @@ import (
 
 	"github.com/andrepxx/go-dsp-guitar/circular"
 	"github.com/andrepxx/go-dsp-guitar/fft"
+	"github.com/metalblueberry/bard/pkg/resample"
 )
 
 /*
  * Global constants.
+ *
+ * NUM_SAMPLES holds one second of audio at NUM_SAMPLES_RATE.
  */
 const (
-	NUM_SAMPLES = 96000
+	NUM_SAMPLES      = 96000
+	NUM_SAMPLES_RATE = 96000
+	PEAK_THRESHOLD   = 0.9
 )
 
 /*
@@ -32,6 +37,7 @@ type Result struct {
 	cents          int8
 	frequency      float64
 	note           string
+	confidence     float64
 	SubCorrelation []float64
 	NoteValues     []NoteValue
 }
@@ -52,6 +58,10 @@ type Tuner struct {
 	fourierTransform fft.FourierTransform
 	bufCorrelation   []float64
 	bufFFT           []complex128
+	preprocessor     *Preprocessor
+	analysisRate     uint32
+	resampler        *resample.Resampler
+	bufResample      []float64
 }
 
 /*
@@ -346,6 +356,42 @@ func findMaximum(buf []float64) (float64, int) {
 	return maxVal, maxIdx
 }
 
+/*
+ * Find the first local maximum in a buffer which reaches a certain fraction
+ * of the global maximum.
+ *
+ * The autocorrelation of a periodic signal peaks at every multiple of the
+ * period. When the period is not an integer number of samples, a multiple
+ * may be sampled closer to its peak and win over the period itself, which
+ * results in octave errors. Preferring the first strong peak avoids this.
+ */
+func findFirstPeak(buf []float64, threshold float64) (float64, int) {
+	maxVal, maxIdx := findMaximum(buf)
+	limit := threshold * maxVal
+	lastIdx := len(buf) - 1
+
+	/*
+	 * Only positive maxima can be peaks of a periodic signal.
+	 */
+	if maxVal <= 0.0 {
+		return maxVal, maxIdx
+	}
+
+	for idx := 1; idx < lastIdx; idx++ {
+		value := buf[idx]
+
+		/*
+		 * Return the first local maximum above the limit.
+		 */
+		if value >= limit && value >= buf[idx-1] && value >= buf[idx+1] {
+			return value, idx
+		}
+
+	}
+
+	return maxVal, maxIdx
+}
+
 /*
  * Returns the deviation from the reference note in cents.
  */
@@ -353,6 +399,14 @@ func (this *Result) Cents() int8 {
 	return this.cents
 }
 
+/*
+ * Returns how periodic the signal is, from zero for noise to one for a
+ * perfectly periodic signal.
+ */
+func (this *Result) Confidence() float64 {
+	return this.confidence
+}
+
 /*
  * Returns the fundamental frequency of the signal.
  */
@@ -475,7 +529,7 @@ func (this *Tuner) Analyze() (*Result, error) {
 				}
 
 				subCorrelation := bufCorrelation[lowIdx:highIdx]
-				maxVal, maxIdx := findMaximum(subCorrelation)
+				maxVal, maxIdx := findFirstPeak(subCorrelation, PEAK_THRESHOLD)
 				idx := lowIdx + maxIdx
 				idxUp := idx + 1
 
@@ -516,6 +570,16 @@ func (this *Tuner) Analyze() (*Result, error) {
 
 				idxFloat += shiftEstimation
 				actualFrequency := sampleRateFloat / idxFloat
+				energy := bufCorrelation[0]
+				confidence := 0.0
+
+				/*
+				 * Normalize the peak by the energy of the signal.
+				 */
+				if energy > 0.0 {
+					confidence = math.Max(0.0, math.Min(1.0, maxVal/energy))
+				}
+
 				actualNote := "Unknown"
 				actualCents := math.Inf(1)
 				actualCentsAbs := math.Abs(actualCents)
@@ -566,6 +630,7 @@ func (this *Tuner) Analyze() (*Result, error) {
 					cents:          actualCentsInt,
 					frequency:      actualFrequency,
 					note:           actualNote,
+					confidence:     confidence,
 					SubCorrelation: copySubCorrelation,
 					NoteValues:     noteValues,
 				}
@@ -580,11 +645,129 @@ func (this *Tuner) Analyze() (*Result, error) {
 
 }
 
+/*
+ * Enables preprocessing of streamed samples before they are buffered.
+ */
+func (this *Tuner) SetPreprocessing(config PreprocessConfig) {
+	preprocessor := CreatePreprocessor(config)
+	this.mutexBuffer.Lock()
+	this.preprocessor = preprocessor
+	this.mutexBuffer.Unlock()
+}
+
+/*
+ * Disables preprocessing of streamed samples.
+ */
+func (this *Tuner) DisablePreprocessing() {
+	this.mutexBuffer.Lock()
+	this.preprocessor = nil
+	this.mutexBuffer.Unlock()
+}
+
+/*
+ * Returns the active preprocessing chain, or nil if disabled.
+ */
+func (this *Tuner) Preprocessor() *Preprocessor {
+	this.mutexBuffer.RLock()
+	preprocessor := this.preprocessor
+	this.mutexBuffer.RUnlock()
+	return preprocessor
+}
+
+/*
+ * Returns whether the streamed signal currently passes the noise gate.
+ *
+ * Without preprocessing, the signal is always considered present.
+ */
+func (this *Tuner) Voiced() bool {
+	voiced := true
+	this.mutexBuffer.RLock()
+
+	/*
+	 * Query the noise gate, if enabled.
+	 */
+	if this.preprocessor != nil {
+		voiced = this.preprocessor.GateOpen()
+	}
+
+	this.mutexBuffer.RUnlock()
+	return voiced
+}
+
+/*
+ * Sets the rate at which the signal is analyzed.
+ *
+ * Streamed samples are converted to this rate before they are buffered, so
+ * sources of different rates are analyzed uniformly, and a lower rate cuts
+ * the cost of the autocorrelation. The buffer is resized to hold the same
+ * duration of audio as at NUM_SAMPLES_RATE.
+ *
+ * A rate of zero analyzes samples at the rate they are streamed at.
+ */
+func (this *Tuner) SetAnalysisRate(rate uint32) {
+	size := NUM_SAMPLES
+
+	/*
+	 * Scale the buffer to the analysis rate.
+	 */
+	if rate != 0 {
+		size = int((uint64(NUM_SAMPLES) * uint64(rate)) / NUM_SAMPLES_RATE)
+	}
+
+	buffer := circular.CreateBuffer(size)
+	this.mutexAnalyze.Lock()
+	this.mutexBuffer.Lock()
+	this.buffer = buffer
+	this.analysisRate = rate
+	this.resampler = nil
+	this.mutexBuffer.Unlock()
+	this.mutexAnalyze.Unlock()
+}
+
+/*
+ * Returns the rate at which the signal is analyzed, or zero if samples are
+ * analyzed at the rate they are streamed at.
+ */
+func (this *Tuner) AnalysisRate() uint32 {
+	this.mutexBuffer.RLock()
+	rate := this.analysisRate
+	this.mutexBuffer.RUnlock()
+	return rate
+}
+
 /*
  * Stream samples for later analysis.
  */
 func (this *Tuner) Process(samples []float64, sampleRate uint32) {
 	this.mutexBuffer.Lock()
+
+	/*
+	 * Run samples through the preprocessing chain, if enabled.
+	 */
+	if this.preprocessor != nil {
+		samples = this.preprocessor.Process(samples, sampleRate)
+	}
+
+	analysisRate := this.analysisRate
+
+	/*
+	 * Convert samples to the analysis rate, if set.
+	 */
+	if analysisRate != 0 && sampleRate != 0 && analysisRate != sampleRate {
+		inputRate := float64(sampleRate)
+
+		/*
+		 * Create a new converter whenever the input rate changes.
+		 */
+		if this.resampler == nil || this.resampler.InputRate() != inputRate {
+			this.resampler, _ = resample.Create(inputRate, float64(analysisRate), resample.QUALITY_MEDIUM)
+		}
+
+		this.bufResample = this.resampler.Process(samples, this.bufResample[0:0])
+		samples = this.bufResample
+		sampleRate = analysisRate
+	}
+
 	this.buffer.Enqueue(samples...)
 	this.sampleRate = sampleRate
 	this.mutexBuffer.Unlock()
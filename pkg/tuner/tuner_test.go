@@ -1,11 +1,11 @@
 package tuner
 
 import (
+	"io"
 	"math"
-	"os"
 	"testing"
 
-	"github.com/andrepxx/go-dsp-guitar/wave"
+	"github.com/metalblueberry/bard/pkg/wav"
 )
 
 /*
@@ -43,80 +43,87 @@ func TestTuner(t *testing.T) {
 	 */
 	for i, path := range wavePaths {
 		currentNote := notes[i]
-		buf, err := os.ReadFile(path)
+		file, err := wav.Open(path)
 
 		/*
-		 * Check if file was successfully read.
+		 * Check if file was successfully opened.
 		 */
 		if err != nil {
-			t.Errorf("Failed to read wave file from '%s'.", path)
+			t.Errorf("Failed to open wave file from '%s'.", path)
 		} else {
-			file, err := wave.FromBuffer(buf)
+			defer file.Close()
+			format := file.Format()
+			sampleRate := format.SampleRate
+			numChannels := format.Channels
 
 			/*
-			 * Check if file was successfully parsed.
+			 * Check if file has a single channel.
 			 */
-			if err != nil {
-				t.Errorf("Failed to parse wave file from '%s'.", path)
+			if numChannels != 1 {
+				t.Errorf("Wave file '%s' has %d channels, expected %d.", path, numChannels, 1)
 			} else {
-				sampleRate := file.SampleRate()
-				numChannels := file.ChannelCount()
+				buf := make([]float32, file.Frames())
+				n := 0
 
 				/*
-				 * Check if file has a single channel.
+				 * Read all samples from the file.
 				 */
-				if numChannels != 1 {
-					t.Errorf("Wave file '%s' has %d channels, expected %d.", path, numChannels, 1)
+				for err == nil && n < len(buf) {
+					var m int
+					m, err = file.Read(buf[n:])
+					n += m
+				}
+
+				/*
+				 * Check if samples could be read.
+				 */
+				if err != nil && err != io.EOF {
+					msg := err.Error()
+					t.Errorf("Failed to read samples from wave file '%s': %s", path, msg)
 				} else {
-					c, err := file.Channel(0)
+					samples := make([]float64, n)
+
+					for j, x := range buf[0:n] {
+						samples[j] = float64(x)
+					}
+
+					tn.Process(samples, sampleRate)
+					res, err := tn.Analyze()
 
 					/*
-					 * Check if channel could be obtained.
+					 * Check if analysis could be performed.
 					 */
 					if err != nil {
 						msg := err.Error()
-						t.Errorf("Failed to obtain channel %d from wave file '%s': %s", 1, path, msg)
+						t.Errorf("Failed to analyze wave file '%s': %s", path, msg)
 					} else {
-						samples := c.Floats()
-						tn.Process(samples, sampleRate)
-						res, err := tn.Analyze()
+						note := res.Note()
 
 						/*
-						 * Check if analysis could be performed.
+						 * Check if note was determined correctly.
 						 */
-						if err != nil {
-							msg := err.Error()
-							t.Errorf("Failed to analyze wave file '%s': %s", path, msg)
-						} else {
-							note := res.Note()
-
-							/*
-							 * Check if note was determined correctly.
-							 */
-							if note != currentNote {
-								t.Errorf("Tuner failed to determine correct note. Expected '%s', got '%s'.", currentNote, note)
-							}
-
-							cents := res.Cents()
-
-							/*
-							 * Check if deviation is large.
-							 */
-							if cents < -5 || cents > 5 {
-								t.Errorf("Tuner exhibits large deviation for note '%s'.", currentNote)
-							}
-
-							freq := res.Frequency()
-							freqInfinite := math.IsInf(freq, 0)
-							freqNaN := math.IsNaN(freq)
-
-							/*
-							 * Check if frequency is infinite or not a number.
-							 */
-							if freqInfinite || freqNaN {
-								t.Errorf("Tuner reported invalid frequency ('%e') for note '%s'.", freq, currentNote)
-							}
+						if note != currentNote {
+							t.Errorf("Tuner failed to determine correct note. Expected '%s', got '%s'.", currentNote, note)
+						}
+
+						cents := res.Cents()
+
+						/*
+						 * Check if deviation is large.
+						 */
+						if cents < -5 || cents > 5 {
+							t.Errorf("Tuner exhibits large deviation for note '%s'.", currentNote)
+						}
 
+						freq := res.Frequency()
+						freqInfinite := math.IsInf(freq, 0)
+						freqNaN := math.IsNaN(freq)
+
+						/*
+						 * Check if frequency is infinite or not a number.
+						 */
+						if freqInfinite || freqNaN {
+							t.Errorf("Tuner reported invalid frequency ('%e') for note '%s'.", freq, currentNote)
 						}
 
 					}
@@ -130,3 +137,177 @@ func TestTuner(t *testing.T) {
 	}
 
 }
+
+/*
+ * Generates a tone with a certain fundamental frequency and decaying
+ * harmonics, similar to a plucked string.
+ */
+func harmonicTone(frequency float64, sampleRate uint32, length int) []float64 {
+	buf := make([]float64, length)
+	rate := float64(sampleRate)
+
+	for i := range buf {
+		t := float64(i) / rate
+		sum := 0.0
+
+		for h := 1; h <= 6; h++ {
+			arg := 2.0 * math.Pi * float64(h) * frequency * t
+			sum += math.Sin(arg) / float64(h)
+		}
+
+		buf[i] = 0.3 * sum
+	}
+
+	return buf
+}
+
+/*
+ * Perform a unit test on the tuner analyzing at a reduced sample rate.
+ */
+func TestAnalysisRate(t *testing.T) {
+
+	/*
+	 * Sample rates of the sources.
+	 */
+	sampleRates := []uint32{
+		44100,
+		48000,
+		96000,
+	}
+
+	/*
+	 * Notes to detect and their frequencies.
+	 */
+	notes := []string{
+		"A2",
+		"E4",
+	}
+
+	frequencies := []float64{
+		110.0000,
+		329.6276,
+	}
+
+	for _, sampleRate := range sampleRates {
+
+		for i, currentNote := range notes {
+			tn := Create()
+			tn.SetAnalysisRate(16000)
+			samples := harmonicTone(frequencies[i], sampleRate, int(sampleRate))
+			tn.Process(samples, sampleRate)
+			res, err := tn.Analyze()
+
+			/*
+			 * Check if analysis could be performed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				t.Errorf("Failed to analyze %s at %d Hz: %s", currentNote, sampleRate, msg)
+			} else {
+				note := res.Note()
+
+				/*
+				 * Check if note was determined correctly.
+				 */
+				if note != currentNote {
+					t.Errorf("Tuner failed to determine correct note at %d Hz. Expected '%s', got '%s'.", sampleRate, currentNote, note)
+				}
+
+				cents := res.Cents()
+
+				/*
+				 * Check if deviation is large.
+				 */
+				if cents < -5 || cents > 5 {
+					t.Errorf("Tuner exhibits large deviation of %d cents for note '%s' at %d Hz.", cents, currentNote, sampleRate)
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Perform a unit test on the tuner detecting synthetic tones.
+ *
+ * The periods of the tones are no integer number of samples, so multiples
+ * of the period may be sampled closer to their peak than the period itself,
+ * especially at low sample rates.
+ */
+func TestSyntheticTones(t *testing.T) {
+
+	/*
+	 * Sample rates of the tones.
+	 */
+	sampleRates := []uint32{
+		16000,
+		22050,
+		44100,
+		48000,
+		96000,
+	}
+
+	/*
+	 * Notes to detect and their frequencies.
+	 */
+	notes := []string{
+		"D2",
+		"A2",
+		"D3",
+		"G3",
+		"H3",
+		"E4",
+	}
+
+	frequencies := []float64{
+		73.4162,
+		110.0000,
+		146.8324,
+		195.9977,
+		246.9417,
+		329.6276,
+	}
+
+	for _, sampleRate := range sampleRates {
+
+		for i, currentNote := range notes {
+			tn := Create()
+			samples := harmonicTone(frequencies[i], sampleRate, int(sampleRate))
+			tn.Process(samples, sampleRate)
+			res, err := tn.Analyze()
+
+			/*
+			 * Check if analysis could be performed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				t.Errorf("Failed to analyze %s at %d Hz: %s", currentNote, sampleRate, msg)
+			} else {
+				note := res.Note()
+
+				/*
+				 * Check if note was determined correctly.
+				 */
+				if note != currentNote {
+					t.Errorf("Tuner failed to determine correct note at %d Hz. Expected '%s', got '%s'.", sampleRate, currentNote, note)
+				}
+
+				cents := res.Cents()
+
+				/*
+				 * Check if deviation is large.
+				 */
+				if cents < -5 || cents > 5 {
+					t.Errorf("Tuner exhibits large deviation of %d cents for note '%s' at %d Hz.", cents, currentNote, sampleRate)
+				}
+
+			}
+
+		}
+
+	}
+
+}
@@ -0,0 +1,303 @@
+package visualizer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/circular"
+	"github.com/metalblueberry/bard/pkg/cqt"
+	"github.com/metalblueberry/bard/pkg/denoise"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+// DefaultAnalysisRate is the number of analyses per second by default.
+const DefaultAnalysisRate = 60
+
+// Snapshot is the state of the analysis at one instant. Snapshots are never
+// changed once published, so the display reads them without locking while
+// the next one is analyzed.
+type Snapshot struct {
+	// number of the analysis since the notes were last set, zero before
+	// the first
+	Sequence int64
+	// most recent audio, oldest sample first
+	Waveform []float64
+	// magnitude of every transform bin with room noise removed, the noise
+	// floor of every bin and the magnitude a bin must exceed to be played
+	Spectrum   []float64
+	Floor      []float64
+	Thresholds []float64
+	// level in dB notes must peak over the noise floor by, and whether the
+	// floor is being learned
+	Threshold float64
+	Learning  bool
+	// displayed notes with their magnitude
+	Notes Notes
+	// spectra of the recent analyses, oldest first, nil before the first
+	History [][]float64
+	// recent tuner results, oldest first, zero times before the first
+	Pitch []timeline.Entry
+	// smoothed time taken by one analysis
+	Duration time.Duration
+
+	// notes and transform the snapshot was analyzed with
+	mapping *noteMapping
+}
+
+// Playing returns whether the note of a transform bin is played, which is
+// when its magnitude peaks over the noise floor by the threshold.
+func (s *Snapshot) Playing(bin int) bool {
+	return bin < len(s.Spectrum) && s.Spectrum[bin] > s.Thresholds[bin]
+}
+
+// NoteSet returns the set of the notes the snapshot was analyzed with.
+func (s *Snapshot) NoteSet() NoteSet {
+	return s.mapping.set
+}
+
+// noteMapping relates the displayed notes to the bins of the transform
+// which analyzes them. It is never changed once created.
+type noteMapping struct {
+	set       NoteSet
+	notes     Notes
+	transform *cqt.Transform
+	// transform bin of each note
+	noteBins []int
+	// MIDI range of the piano roll and the transform bin of each of its keys
+	rollLow  int
+	rollHigh int
+	rollBins []int
+}
+
+// analyzer analyzes the audio of the tee into snapshots, independently of
+// the rate they are drawn at.
+type analyzer struct {
+	echo *Tee
+	// analyses per second of RunAnalysis, which the floor is tracked at
+	rate float64
+
+	// learned room noise removed from the spectrum, nil disables it
+	noise        *denoise.Profile
+	noiseRemoval denoise.Config
+
+	// everything below is changed by the display as well, so it is only
+	// used with the lock held, which the analysis holds throughout
+	lock        sync.Mutex
+	mapping     *noteMapping
+	floor       *denoise.Floor
+	floorConfig denoise.FloorConfig
+	// analyses the floor is still learned for
+	learning int
+	history  *circular.Buffer[[]float64]
+	sequence int64
+	duration time.Duration
+
+	// latest *Snapshot and the error which stopped RunAnalysis, if any
+	snapshot atomic.Value
+	err      atomic.Value
+}
+
+// analysisError wraps errors so atomic.Value always stores the same type.
+type analysisError struct {
+	err error
+}
+
+func newAnalyzer(tee *Tee, options Options) *analyzer {
+	return &analyzer{
+		echo:         tee,
+		rate:         options.AnalysisRate,
+		noise:        options.Noise,
+		noiseRemoval: options.NoiseRemoval,
+		floorConfig:  options.Floor,
+		history:      circular.CreateBuffer[[]float64](options.History),
+	}
+}
+
+// latest returns the latest published snapshot.
+func (a *analyzer) latest() *Snapshot {
+	return a.snapshot.Load().(*Snapshot)
+}
+
+// failed returns the error which stopped the analysis, if any.
+func (a *analyzer) failed() error {
+	if e, ok := a.err.Load().(analysisError); ok {
+		return e.err
+	}
+	return nil
+}
+
+// configure analyzes a number of samples for the notes of a set, keeping
+// the buffer of the tee if length is zero. The history is cleared, as its
+// notes no longer match. On error the configuration is kept.
+func (a *analyzer) configure(length int, set NoteSet) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	previous := a.echo.BufferLength()
+	if length == 0 {
+		length = previous
+	}
+	mapping, err := newNoteMapping(set, a.echo.SampleRate(), length)
+	if err != nil {
+		return err
+	}
+	floor, err := denoise.CreateFloor(len(mapping.transform.Bins()), a.floorConfig)
+	if err != nil {
+		return err
+	}
+	if length != previous {
+		if err := a.echo.SetBufferLength(length); err != nil {
+			return err
+		}
+	}
+	a.mapping = mapping
+	a.floor = floor
+	a.clearHistoryLocked()
+	return nil
+}
+
+// clearHistory forgets the analysis of the past.
+func (a *analyzer) clearHistory() {
+	a.lock.Lock()
+	a.clearHistoryLocked()
+	a.lock.Unlock()
+}
+
+// clearHistoryLocked forgets the analysis of the past and publishes an empty
+// snapshot, the tuner results are kept.
+func (a *analyzer) clearHistoryLocked() {
+	a.history = circular.CreateBuffer[[]float64](a.history.Length())
+	a.sequence = 0
+	var pitch []timeline.Entry
+	if previous, ok := a.snapshot.Load().(*Snapshot); ok {
+		pitch = previous.Pitch
+	}
+	a.snapshot.Store(&Snapshot{
+		Threshold: a.floorConfig.Threshold,
+		Learning:  a.learning > 0,
+		History:   make([][]float64, a.history.Length()),
+		Pitch:     pitch,
+		Duration:  a.duration,
+		mapping:   a.mapping,
+	})
+}
+
+// noteSet returns the set of the notes analyzed.
+func (a *analyzer) noteSet() NoteSet {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.mapping.set
+}
+
+// analyze analyzes the most recent audio of the tee and publishes the
+// result.
+func (a *analyzer) analyze() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	start := time.Now()
+
+	mapping := a.mapping
+	waveform := a.echo.CopyBuffer(nil)
+	bins := mapping.transform.Bins()
+	spectrum := make([]float64, len(bins))
+	if err := mapping.transform.Process(waveform, spectrum); err != nil {
+		return err
+	}
+	if a.noise != nil {
+		for bin, value := range spectrum {
+			spectrum[bin] = denoise.Subtract(value, a.noiseAt(bins[bin]), a.noiseRemoval)
+		}
+	}
+	a.updateFloor(spectrum)
+
+	floor := make([]float64, len(spectrum))
+	thresholds := make([]float64, len(spectrum))
+	for bin := range spectrum {
+		floor[bin] = a.floor.Level(bin)
+		thresholds[bin] = a.floor.Threshold(bin)
+	}
+	notes := make(Notes, len(mapping.notes))
+	copy(notes, mapping.notes)
+	for i := range notes {
+		notes[i].Value = spectrum[mapping.noteBins[i]]
+	}
+	a.history.Enqueue(spectrum)
+	history := make([][]float64, a.history.Length())
+	a.history.Retrieve(history)
+
+	if recorder := a.echo.Recorder(); recorder != nil {
+		values := make(map[string]float64, len(notes))
+		for _, note := range notes {
+			values[note.Name] = note.Value
+		}
+		recorder.SetNotes(values)
+	}
+
+	a.sequence++
+	measure(&a.duration, start)
+	a.snapshot.Store(&Snapshot{
+		Sequence:   a.sequence,
+		Waveform:   waveform,
+		Spectrum:   spectrum,
+		Floor:      floor,
+		Thresholds: thresholds,
+		Threshold:  a.floorConfig.Threshold,
+		Learning:   a.learning > 0,
+		Notes:      notes,
+		History:    history,
+		Pitch:      a.echo.Pitch(nil),
+		Duration:   a.duration,
+		mapping:    mapping,
+	})
+	return nil
+}
+
+// run analyzes the audio at the rate of the analyzer until the context is
+// cancelled or the analysis fails.
+func (a *analyzer) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / a.rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.analyze(); err != nil {
+				a.err.Store(analysisError{fmt.Errorf("analysis stopped: %w", err)})
+				return
+			}
+		}
+	}
+}
+
+// noiseAt returns the magnitude of the learned noise within a transform bin.
+// The noise profile is measured with FFT frames, so broadband noise is scaled
+// by the ratio of window lengths to match the bandwidth of the bin.
+func (a *analyzer) noiseAt(bin cqt.Bin) float64 {
+	scale := math.Sqrt(float64(a.noise.FrameSize) / float64(bin.Length))
+	return scale * a.noise.Magnitude(bin.Frequency)
+}
+
+// Analyze analyzes the audio streamed so far once, for callers which drive
+// the analysis themselves instead of using RunAnalysis. The snapshot is
+// drawn after the next Update.
+func (g *Game) Analyze() error {
+	return g.analysis.analyze()
+}
+
+// RunAnalysis analyzes the audio at the analysis rate on the calling
+// goroutine until the context is cancelled. If the analysis fails it stops,
+// and Update returns the error.
+func (g *Game) RunAnalysis(ctx context.Context) {
+	g.analysis.run(ctx)
+}
+
+// Snapshot returns the snapshot of the analysis drawn, which is held while
+// the display is frozen.
+func (g *Game) Snapshot() *Snapshot {
+	return g.shown
+}
@@ -0,0 +1,92 @@
+package visualizer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+)
+
+func TestAnalysis(t *testing.T) {
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	options := DefaultOptions()
+	options.History = 4
+	g, err := CreateGame(context.Background(), tee, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := g.Snapshot(); s.Sequence != 0 || len(s.History) != 4 || s.History[3] != nil {
+		t.Fatalf("first snapshot is not empty: sequence %d, %d spectra", s.Sequence, len(s.History))
+	}
+
+	var first *Snapshot
+	for i := 1; i <= 6; i++ {
+		if err := tee.Step(2048); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Analyze(); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		s := g.Snapshot()
+		if s.Sequence != int64(i) {
+			t.Errorf("analysis %d has sequence %d", i, s.Sequence)
+		}
+		if &s.History[len(s.History)-1][0] != &s.Spectrum[0] {
+			t.Errorf("analysis %d is not the newest of the history", i)
+		}
+		if first == nil {
+			first = s
+		}
+	}
+	if first.Sequence != 1 || len(first.History) != 4 || first.History[2] != nil {
+		t.Errorf("published snapshot was changed by later analyses")
+	}
+
+	// A4 is played and shown at the bin of its note
+	s := g.Snapshot()
+	for i, note := range s.Notes {
+		if bin := s.mapping.noteBins[i]; note.Value != s.Spectrum[bin] {
+			t.Errorf("note %s shows %v, its bin %v", note.Name, note.Value, s.Spectrum[bin])
+		}
+		if note.Name == "A4" && !s.Playing(s.mapping.noteBins[i]) {
+			t.Errorf("A4 is not played")
+		}
+	}
+
+	// new notes and FFT sizes start a new history
+	if err := g.SetFFTSize(8192); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if s := g.Snapshot(); s.Sequence != 0 || s.History[3] != nil {
+		t.Errorf("history was kept after the FFT size changed")
+	}
+
+	// RunAnalysis publishes snapshots until it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		g.RunAnalysis(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for g.analysis.latest().Sequence == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if g.analysis.latest().Sequence == 0 {
+		t.Errorf("RunAnalysis published no snapshot")
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+}
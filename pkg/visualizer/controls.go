@@ -0,0 +1,209 @@
+package visualizer
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+)
+
+const (
+	// largest zoom of the waveform in time and amplitude
+	MaxWaveZoom = 64
+	// points of the waveform drawn at most, one for every ten samples of the
+	// default buffer
+	wavePoints = 560
+	// change of the input gain per step and its largest magnitude, in dB
+	GainStep = 3.0
+	MaxGain  = 36.0
+	// range of the number of samples the transform analyzes, and the size
+	// the command line starts at, a power of two like the sizes stepped to
+	MinFFTSize     = 1024
+	MaxFFTSize     = 32768
+	DefaultFFTSize = 4096
+	// weight of the latest frame in the analysis and drawing times shown by
+	// the HUD
+	frameTimeSmoothing = 0.1
+)
+
+var (
+	hudBackground = color.NRGBA{A: 200}
+	frozenColor   = color.NRGBA{R: 90, G: 170, B: 255, A: 255}
+)
+
+// Frozen returns whether the display is frozen.
+func (g *Game) Frozen() bool {
+	return g.frozen
+}
+
+// ToggleFreeze freezes the display on the last analysis, or resumes it. The
+// audio keeps streaming and recording meanwhile.
+func (g *Game) ToggleFreeze() {
+	g.frozen = !g.frozen
+}
+
+// WaveZoom returns the zoom of the waveform in time and amplitude.
+func (g *Game) WaveZoom() (float64, float64) {
+	return g.waveZoom, g.waveGain
+}
+
+// ZoomWave zooms the waveform in time and amplitude by a number of steps,
+// each doubling or halving the zoom, negative steps zoom out.
+func (g *Game) ZoomWave(timeSteps, amplitudeSteps int) {
+	g.waveZoom = zoom(g.waveZoom, timeSteps)
+	g.waveGain = zoom(g.waveGain, amplitudeSteps)
+}
+
+// ResetZoom shows the whole buffer at full scale in the waveform.
+func (g *Game) ResetZoom() {
+	g.waveZoom, g.waveGain = 1, 1
+}
+
+// zoom changes a zoom factor by a number of doublings within its range.
+func zoom(factor float64, steps int) float64 {
+	for ; steps > 0 && factor < MaxWaveZoom; steps-- {
+		factor *= 2
+	}
+	for ; steps < 0 && factor > 1; steps++ {
+		factor /= 2
+	}
+	return factor
+}
+
+// Gain returns the gain of the analyzed audio in dB.
+func (g *Game) Gain() float64 {
+	return g.echo.Gain()
+}
+
+// ChangeGain changes the gain of the analyzed audio within its range.
+func (g *Game) ChangeGain(delta float64) {
+	gain := g.echo.Gain() + delta
+	if gain < -MaxGain {
+		gain = -MaxGain
+	} else if gain > MaxGain {
+		gain = MaxGain
+	}
+	g.echo.SetGain(gain)
+}
+
+// FFTSize returns the number of samples the transform analyzes.
+func (g *Game) FFTSize() int {
+	return g.echo.BufferLength()
+}
+
+// SetFFTSize changes the number of samples the transform analyzes. Longer
+// buffers resolve low notes better but react slower. The transform is
+// rebuilt and the history cleared, on error the size is kept.
+func (g *Game) SetFFTSize(size int) error {
+	if size < MinFFTSize || size > MaxFFTSize {
+		return fmt.Errorf("FFT size must be between %d and %d, got %d", MinFFTSize, MaxFFTSize, size)
+	}
+	return g.analysis.configure(size, g.NoteSet())
+}
+
+// ChangeFFTSize moves the FFT size by a number of powers of two, negative
+// steps make it smaller. A size which is no power of two moves to the
+// nearest one in the direction of the step.
+func (g *Game) ChangeFFTSize(steps int) error {
+	size := g.FFTSize()
+	for ; steps > 0; steps-- {
+		next := 1
+		for next <= size {
+			next *= 2
+		}
+		size = next
+	}
+	for ; steps < 0; steps++ {
+		next := 1
+		for next*2 < size {
+			next *= 2
+		}
+		size = next
+	}
+	if size < MinFFTSize {
+		size = MinFFTSize
+	} else if size > MaxFFTSize {
+		size = MaxFFTSize
+	}
+	return g.SetFFTSize(size)
+}
+
+// HUD returns whether the settings and timings are shown over the display.
+func (g *Game) HUD() bool {
+	return g.hud
+}
+
+// ToggleHUD shows or hides the settings and timings.
+func (g *Game) ToggleHUD() {
+	g.hud = !g.hud
+}
+
+// measure adds the time since start to a smoothed time.
+func measure(frameTime *time.Duration, start time.Time) {
+	*frameTime += time.Duration(frameTimeSmoothing * float64(time.Since(start)-*frameTime))
+}
+
+// drawWaveform draws the most recent part of the buffer, zoomed in time and
+// amplitude.
+func (g *Game) drawWaveform(screen canvas.Canvas) {
+	waveform := g.shown.Waveform
+	samples := int(float64(len(waveform)) / g.waveZoom)
+	data := waveform[len(waveform)-samples:]
+	step := (samples + wavePoints - 1) / wavePoints
+	if step < 1 {
+		step = 1
+	}
+	g.drawWave(screen, data, 1/g.waveGain, step, color.White)
+}
+
+// drawFrozen shows that the display is frozen.
+func (g *Game) drawFrozen(screen canvas.Canvas) {
+	if g.frozen {
+		screen.Text("FROZEN", screen.Bounds().Dx()-80, 64, frozenColor)
+	}
+}
+
+// hudLines returns the settings and timings shown by the HUD.
+func (g *Game) hudLines() []string {
+	rate := g.echo.SampleRate()
+	size := g.FFTSize()
+	window := 1000 * float64(size) / rate
+	latency := fmt.Sprintf("latency %.0f ms window", window)
+	if input := g.echo.Latency(); input > 0 {
+		latency = fmt.Sprintf("latency %.0f ms input + %.0f ms window", float64(input)/float64(time.Millisecond), window)
+	}
+	state := "live"
+	if g.frozen {
+		state = "frozen"
+	}
+	return []string{
+		fmt.Sprintf("%.0f Hz, FFT %d samples, %s", rate, size, state),
+		latency,
+		fmt.Sprintf("analysis %.1f ms %g times a second, drawing %.1f ms", float64(g.shown.Duration)/float64(time.Millisecond), g.analysis.rate, float64(g.drawTime)/float64(time.Millisecond)),
+		fmt.Sprintf("gain %+.0f dB, threshold %.0f dB over noise floor", g.Gain(), g.Threshold()),
+		fmt.Sprintf("wave zoom %gx time, %gx amplitude", g.waveZoom, g.waveGain),
+		fmt.Sprintf("panel %s, key %s", g.panel, g.Key()),
+		fmt.Sprintf("notes %s", g.NoteSet()),
+		fmt.Sprintf("layout %s", g.layout),
+		"P freeze, Z V zoom (shift out), backspace unzoom, G gain (shift -)",
+		fmt.Sprintf("page up/down FFT size, 1-%d %s, H hide", len(Panels()), strings.Join(Panels(), " ")),
+		fmt.Sprintf("F1-F%d toggle %s, drag borders to resize", len(Views()), strings.Join(Views(), " ")),
+	}
+}
+
+// drawHUD shows the settings, the sample rate, the latency and the time
+// spent per frame over the display.
+func (g *Game) drawHUD(screen canvas.Canvas) {
+	if !g.hud {
+		return
+	}
+	lines := g.hudLines()
+	const lineHeight = 16
+	x, y := screen.Bounds().Min.X+100, screen.Bounds().Min.Y+8
+	screen.FillRect(float32(x), float32(y), 420, float32(len(lines)*lineHeight+8), hudBackground)
+	for i, line := range lines {
+		screen.Text(line, x+8, y+(i+1)*lineHeight, color.White)
+	}
+}
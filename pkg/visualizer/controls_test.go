@@ -0,0 +1,93 @@
+package visualizer
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/canvas"
+)
+
+func TestControls(t *testing.T) {
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	g, err := CreateGame(context.Background(), tee, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sizes which are no power of two move to the next one
+	for _, test := range []struct {
+		steps int
+		size  int
+	}{{1, 8192}, {-1, 4096}, {-1, 2048}, {-10, MinFFTSize}, {10, MaxFFTSize}} {
+		if err := g.ChangeFFTSize(test.steps); err != nil {
+			t.Fatal(err)
+		}
+		if g.FFTSize() != test.size {
+			t.Errorf("FFT size %d after %+d steps, expected %d", g.FFTSize(), test.steps, test.size)
+		}
+	}
+	if err := g.SetFFTSize(100); err == nil {
+		t.Errorf("FFT size below %d was accepted", MinFFTSize)
+	}
+
+	for i := 0; i < 20; i++ {
+		g.ChangeGain(GainStep)
+	}
+	if g.Gain() != MaxGain {
+		t.Errorf("gain is %v dB, expected the maximum of %v dB", g.Gain(), MaxGain)
+	}
+
+	g.ZoomWave(3, 10)
+	if zoomTime, zoomAmplitude := g.WaveZoom(); zoomTime != 8 || zoomAmplitude != MaxWaveZoom {
+		t.Errorf("wave zoom is %vx and %vx, expected 8x and %vx", zoomTime, zoomAmplitude, MaxWaveZoom)
+	}
+	g.ZoomWave(-5, 0)
+	if zoomTime, _ := g.WaveZoom(); zoomTime != 1 {
+		t.Errorf("wave zoomed out to %vx, expected 1x", zoomTime)
+	}
+
+	if err := g.SetPanel("meter"); err == nil {
+		t.Errorf("unknown panel was accepted")
+	}
+	if err := g.SetPanel(PanelTuner); err != nil || g.Panel() != PanelTuner {
+		t.Errorf("panel %s was not selected: %v", PanelTuner, err)
+	}
+
+	// a frozen display keeps drawing the same analysis
+	if err := tee.Step(4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	g.ToggleFreeze()
+	last := g.Snapshot()
+	if err := g.Analyze(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if g.Snapshot() != last {
+		t.Errorf("frozen display drew a new analysis")
+	}
+
+	g.ToggleHUD()
+	hud := strings.Join(g.hudLines(), "\n")
+	for _, want := range []string{"44100 Hz", "FFT 32768", "frozen", "gain +36 dB", "panel tuner"} {
+		if !strings.Contains(hud, want) {
+			t.Errorf("HUD does not show %q:\n%s", want, hud)
+		}
+	}
+	screen := canvas.CreateImage(ScreenWidth, ScreenHeight, mplusNormalFont)
+	screen.Fill(color.Black)
+	g.Draw(screen)
+}
@@ -0,0 +1,87 @@
+package visualizer
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+)
+
+const (
+	// change of the threshold per step and its lowest value, in dB
+	ThresholdStep = 3.0
+	minThreshold  = 1.0
+	// time the floor is learned for when asked to without a duration
+	DefaultLearnFloor = 2 * time.Second
+)
+
+var thresholdColor = color.NRGBA{R: 200, G: 60, B: 60, A: 255}
+
+// updateFloor tracks the noise floor over a spectrum, or learns it while
+// the floor is being calibrated. It is called with the lock held.
+func (a *analyzer) updateFloor(spectrum []float64) {
+	if a.learning == 0 {
+		a.floor.Update(spectrum, 1/a.rate)
+		return
+	}
+	a.floor.Learn(spectrum)
+	a.learning--
+	if a.learning == 0 {
+		if err := a.floor.Calibrate(); err != nil {
+			log.Println(err)
+			return
+		}
+		log.Println("noise floor learned")
+	}
+}
+
+// LearnFloor measures the noise floor over the spectra of a duration, while
+// the instrument should be silent, instead of tracking it.
+func (g *Game) LearnFloor(duration time.Duration) {
+	a := g.analysis
+	a.lock.Lock()
+	a.learning = int(math.Ceil(duration.Seconds() * a.rate))
+	if a.learning < 1 {
+		a.learning = 1
+	}
+	a.lock.Unlock()
+	log.Printf("learning the noise floor for %s, keep quiet", duration)
+}
+
+// Threshold returns the level in dB a note must exceed its noise floor by to
+// count as played.
+func (g *Game) Threshold() float64 {
+	a := g.analysis
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.floorConfig.Threshold
+}
+
+// ChangeThreshold changes the level notes must exceed their noise floor by.
+func (g *Game) ChangeThreshold(delta float64) {
+	a := g.analysis
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	threshold := a.floorConfig.Threshold + delta
+	if threshold < minThreshold {
+		threshold = minThreshold
+	}
+	a.floorConfig.Threshold = threshold
+	a.floor.SetThreshold(threshold)
+}
+
+// drawThreshold shows the threshold over the noise floor, or that the floor
+// is being learned.
+func (g *Game) drawThreshold(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	label := fmt.Sprintf("threshold %.0f dB over noise floor", g.shown.Threshold)
+	c := color.Color(color.Gray{Y: 160})
+	if g.shown.Learning {
+		label = "learning noise floor, keep quiet"
+		c = thresholdColor
+	}
+	screen.Text(label, bounds.Min.X+4, bounds.Min.Y+14, c)
+}
@@ -0,0 +1,94 @@
+package visualizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+)
+
+// HeadlessOptions configures rendering frames without a window.
+type HeadlessOptions struct {
+	// frames rendered per second of audio
+	FrameRate float64
+	// length of the audio rendered, zero renders until the source ends
+	Duration time.Duration
+	// size of the frames in pixels
+	Width  int
+	Height int
+}
+
+// DefaultHeadlessOptions returns options rendering the whole source at 30
+// frames per second in the size of the window.
+func DefaultHeadlessOptions() HeadlessOptions {
+	return HeadlessOptions{
+		FrameRate: 30,
+		Width:     ScreenWidth,
+		Height:    ScreenHeight,
+	}
+}
+
+// Render drives the visualizer from the tee at a fixed frame rate instead of
+// the display clock and writes every frame. The audio is read as fast as it
+// can be rendered, so the source should be a file or synthesizer rather than
+// a device. It returns the number of frames written.
+func Render(ctx context.Context, tee *Tee, options Options, headless HeadlessOptions, w canvas.FrameWriter) (int, error) {
+	if !(headless.FrameRate > 0) {
+		return 0, fmt.Errorf("frame rate must be positive, got %v", headless.FrameRate)
+	}
+	if headless.Width <= 0 || headless.Height <= 0 {
+		return 0, fmt.Errorf("invalid frame size %dx%d", headless.Width, headless.Height)
+	}
+	// the audio is analyzed once per frame
+	options.AnalysisRate = headless.FrameRate
+	g, err := CreateGame(ctx, tee, options)
+	if err != nil {
+		return 0, err
+	}
+	screen := canvas.CreateImage(headless.Width, headless.Height, mplusNormalFont)
+
+	// frames of audio per rendered frame, accumulated so that rates which
+	// do not divide the sample rate do not drift
+	framesPerTick := tee.SampleRate() / headless.FrameRate
+	limit := int64(math.Round(headless.Duration.Seconds() * tee.SampleRate()))
+	var consumed int64
+	count := 0
+	for {
+		target := int64(math.Round(float64(count+1) * framesPerTick))
+		if limit > 0 && target > limit {
+			break
+		}
+		err := tee.Step(int(target - consumed))
+		consumed = target
+		ended := errors.Is(err, io.EOF)
+		if err != nil && !ended {
+			return count, err
+		}
+		// the pitch and the notes are analyzed once per frame instead of in
+		// the background, so the frames do not depend on timing
+		if err := tee.AnalyzePitch(); err != nil {
+			return count, err
+		}
+		if err := g.Analyze(); err != nil {
+			return count, err
+		}
+		if err := g.Update(); err != nil {
+			return count, err
+		}
+		screen.Fill(color.Black)
+		g.Draw(screen)
+		if err := w.WriteFrame(screen.RGBA()); err != nil {
+			return count, err
+		}
+		count++
+		if ended {
+			break
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,262 @@
+package visualizer
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/canvas"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden frames in testdata")
+
+// frameRecorder keeps rendered frames in memory.
+type frameRecorder struct {
+	frames []*image.RGBA
+}
+
+func (r *frameRecorder) WriteFrame(img image.Image) error {
+	frame := image.NewRGBA(img.Bounds())
+	copy(frame.Pix, img.(*image.RGBA).Pix)
+	r.frames = append(r.frames, frame)
+	return nil
+}
+
+func (r *frameRecorder) Close() error {
+	return nil
+}
+
+func renderSine(t *testing.T, frequency float64, options Options, headless HeadlessOptions) *frameRecorder {
+	t.Helper()
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, frequency, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	recorder := &frameRecorder{}
+	n, err := Render(context.Background(), tee, options, headless, recorder)
+	if err != nil {
+		t.Fatalf("rendering failed: %s", err)
+	}
+	if n != len(recorder.frames) {
+		t.Fatalf("Render reported %d frames, wrote %d", n, len(recorder.frames))
+	}
+	return recorder
+}
+
+func TestRenderFrameCount(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 64, 48
+	headless.FrameRate = 30
+	headless.Duration = 2 * time.Second
+
+	recorder := renderSine(t, 440, DefaultOptions(), headless)
+	if len(recorder.frames) != 60 {
+		t.Errorf("rendered %d frames, expected %d", len(recorder.frames), 60)
+	}
+	if got := recorder.frames[0].Bounds(); got != image.Rect(0, 0, 64, 48) {
+		t.Errorf("frame bounds %v, expected %v", got, image.Rect(0, 0, 64, 48))
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 640, 480
+	headless.Duration = time.Second
+
+	recorder := renderSine(t, 440, DefaultOptions(), headless)
+	compareGolden(t, "sine440.png", recorder.frames[len(recorder.frames)-1])
+}
+
+func TestRenderWaterfallGolden(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 320, 240
+	headless.Duration = time.Second
+
+	for _, axis := range []string{AxisNotes, AxisLog} {
+		options := DefaultOptions()
+		options.Panel = PanelWaterfall
+		options.Waterfall.Axis = axis
+		options.Waterfall.ColorMap = "inferno"
+		recorder := renderSine(t, 440, options, headless)
+		compareGolden(t, "waterfall-"+axis+".png", recorder.frames[len(recorder.frames)-1])
+	}
+}
+
+func TestRenderTunerGolden(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 640, 480
+	headless.Duration = time.Second
+
+	// 445 Hz is 20 cents sharp of A4
+	options := DefaultOptions()
+	options.Panel = PanelTuner
+	recorder := renderSine(t, 445, options, headless)
+	compareGolden(t, "tuner445.png", recorder.frames[len(recorder.frames)-1])
+}
+
+func TestWaterfallOptions(t *testing.T) {
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+
+	broken := []func(o *Options){
+		func(o *Options) { o.Waterfall.Axis = "linear" },
+		func(o *Options) { o.Waterfall.ColorMap = "rainbow" },
+		func(o *Options) { o.Waterfall.Floor = 0 },
+		func(o *Options) { o.History = 0 },
+		func(o *Options) { o.Panel = "meter" },
+		func(o *Options) { o.AnalysisRate = 0 },
+		func(o *Options) { o.Floor.FallTime = 0 },
+		func(o *Options) { o.Gain = 2 * MaxGain },
+		func(o *Options) { o.FFTSize = 100 },
+	}
+	for i, change := range broken {
+		options := DefaultOptions()
+		change(&options)
+		if _, err := CreateGame(context.Background(), tee, options); err == nil {
+			t.Errorf("invalid options %d were accepted", i)
+		}
+	}
+}
+
+// compareGolden compares a frame with a golden file in testdata, or rewrites
+// the file when the tests run with -update.
+func compareGolden(t *testing.T, name string, frame *image.RGBA) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frame); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(golden)
+	if err != nil {
+		t.Fatalf("%s, run the test with -update to create it", err)
+	}
+	defer file.Close()
+	want, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Bounds() != frame.Bounds() {
+		t.Fatalf("golden frame %s has bounds %v, rendered %v", name, want.Bounds(), frame.Bounds())
+	}
+	differing := 0
+	for y := frame.Bounds().Min.Y; y < frame.Bounds().Max.Y; y++ {
+		for x := frame.Bounds().Min.X; x < frame.Bounds().Max.X; x++ {
+			r0, g0, b0, _ := want.At(x, y).RGBA()
+			r1, g1, b1, _ := frame.At(x, y).RGBA()
+			if r0 != r1 || g0 != g1 || b0 != b1 {
+				differing++
+			}
+		}
+	}
+	if differing > 0 {
+		out := filepath.Join(t.TempDir(), name)
+		os.WriteFile(out, buf.Bytes(), 0644)
+		t.Errorf("%d pixels differ from %s, the rendered frame is in %s", differing, golden, out)
+	}
+}
+
+func TestRenderStaffGolden(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 640, 480
+	headless.Duration = 2 * time.Second
+
+	// A#4 is not in G major, so it is written with a sharp
+	options := DefaultOptions()
+	options.Panel = PanelStaff
+	options.Key = "G"
+	recorder := renderSine(t, 466.16, options, headless)
+	compareGolden(t, "staff-g.png", recorder.frames[len(recorder.frames)-1])
+}
+
+func TestRenderLayoutGolden(t *testing.T) {
+	headless := DefaultHeadlessOptions()
+	headless.Width, headless.Height = 640, 480
+	headless.Duration = time.Second
+
+	layout, err := ParseLayout("roll,tuner:0.5;2*staff,bars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.Layout = layout
+	recorder := renderSine(t, 440, options, headless)
+	compareGolden(t, "layout.png", recorder.frames[len(recorder.frames)-1])
+}
+
+func TestDrawScaled(t *testing.T) {
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	g, err := CreateGame(context.Background(), tee, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		if err := tee.Step(int(tee.SampleRate() / 30)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tee.AnalyzePitch(); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Analyze(); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a display with a scale factor of 2 lays out the same screen in
+	// device independent pixels and draws it at twice the resolution
+	const width, height = 320, 240
+	normal := canvas.CreateImage(width, height, mplusNormalFont)
+	normal.Fill(color.Black)
+	g.Draw(normal)
+	double := canvas.CreateImage(2*width, 2*height, ScaledFont(2))
+	double.Fill(color.Black)
+	scaled := canvas.CreateScaled(double, 2)
+	if got := scaled.Bounds(); got != normal.Bounds() {
+		t.Fatalf("scaled bounds %v, expected %v", got, normal.Bounds())
+	}
+	g.Draw(scaled)
+
+	lit := func(img *image.RGBA, x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r|g|b != 0
+	}
+	matching := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			want := lit(normal.RGBA(), x, y)
+			got := false
+			for i := 0; i < 4; i++ {
+				got = got || lit(double.RGBA(), 2*x+i%2, 2*y+i/2)
+			}
+			if got == want {
+				matching++
+			}
+		}
+	}
+	if ratio := float64(matching) / (width * height); ratio < 0.95 {
+		t.Errorf("%.1f%% of the pixels drawn at a scale of 2 match, expected 95%%", 100*ratio)
+	}
+}
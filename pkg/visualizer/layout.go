@@ -0,0 +1,414 @@
+package visualizer
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Views arranged by a layout besides the panels, which can be placed by
+// name as well.
+const (
+	ViewRoll = "roll"
+	ViewWave = "wave"
+	// the panel selected by NextPanel, TogglePanel and SetPanel
+	ViewPanel = "panel"
+)
+
+const (
+	// distance in pixels from a border within which it can be dragged
+	borderGrab = 4
+	// smallest size in pixels a border is dragged to leave a pane
+	minPaneSize = 24
+)
+
+// Views returns the views a layout can show, in the order they are toggled
+// by the function keys of the window.
+func Views() []string {
+	return append([]string{ViewRoll, ViewWave, ViewPanel}, Panels()...)
+}
+
+func validView(view string) bool {
+	for _, v := range Views() {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
+
+// Pane shows a view in a share of the width of its row. Hidden panes take
+// no space.
+type Pane struct {
+	View   string
+	Weight float64
+	Hidden bool
+}
+
+// Row is a strip of the layout taking a share of its height, divided among
+// its panes from left to right. Rows whose panes are all hidden take no
+// space.
+type Row struct {
+	Weight float64
+	Panes  []Pane
+}
+
+// Layout arranges the views of the visualizer in rows from top to bottom.
+// Shares are relative to the weights of the visible rows and panes, so the
+// arrangement follows the size of the screen.
+type Layout struct {
+	Rows []Row
+}
+
+// DefaultLayout returns the piano roll and the waveform in the upper half
+// and the selected panel in the lower half.
+func DefaultLayout() Layout {
+	return Layout{Rows: []Row{
+		{Weight: 1, Panes: []Pane{{View: ViewRoll, Weight: 1}}},
+		{Weight: 1, Panes: []Pane{{View: ViewWave, Weight: 1}}},
+		{Weight: 2, Panes: []Pane{{View: ViewPanel, Weight: 1}}},
+	}}
+}
+
+// ParseLayout parses a layout written as rows separated by semicolons, top
+// to bottom, of panes separated by commas, left to right. A row may start
+// with its weight and an asterisk, a pane may end with a colon and its
+// weight and starts with a minus if hidden. Weights default to one, the
+// default layout is "roll;wave;2*panel".
+func ParseLayout(s string) (Layout, error) {
+	var layout Layout
+	for _, rowSpec := range strings.Split(s, ";") {
+		row := Row{Weight: 1}
+		rowSpec = strings.TrimSpace(rowSpec)
+		if i := strings.Index(rowSpec, "*"); i >= 0 {
+			weight, err := parseWeight(rowSpec[:i])
+			if err != nil {
+				return Layout{}, fmt.Errorf("layout %q: %w", s, err)
+			}
+			row.Weight = weight
+			rowSpec = rowSpec[i+1:]
+		}
+		for _, paneSpec := range strings.Split(rowSpec, ",") {
+			pane := Pane{View: strings.TrimSpace(paneSpec), Weight: 1}
+			if strings.HasPrefix(pane.View, "-") {
+				pane.Hidden = true
+				pane.View = strings.TrimSpace(pane.View[1:])
+			}
+			if i := strings.Index(pane.View, ":"); i >= 0 {
+				weight, err := parseWeight(pane.View[i+1:])
+				if err != nil {
+					return Layout{}, fmt.Errorf("layout %q: %w", s, err)
+				}
+				pane.Weight = weight
+				pane.View = strings.TrimSpace(pane.View[:i])
+			}
+			row.Panes = append(row.Panes, pane)
+		}
+		layout.Rows = append(layout.Rows, row)
+	}
+	if err := layout.validate(); err != nil {
+		return Layout{}, fmt.Errorf("layout %q: %w", s, err)
+	}
+	return layout, nil
+}
+
+func parseWeight(s string) (float64, error) {
+	weight, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid weight %q", s)
+	}
+	return weight, nil
+}
+
+// validate checks that a layout has rows, that every row has panes and that
+// all views are known and weights positive.
+func (l Layout) validate() error {
+	if len(l.Rows) == 0 {
+		return fmt.Errorf("layout has no rows")
+	}
+	for _, row := range l.Rows {
+		if !(row.Weight > 0) {
+			return fmt.Errorf("row weight must be positive, got %v", row.Weight)
+		}
+		if len(row.Panes) == 0 {
+			return fmt.Errorf("layout has a row without panes")
+		}
+		for _, pane := range row.Panes {
+			if !validView(pane.View) {
+				return fmt.Errorf("unknown view %q, expected one of %s", pane.View, strings.Join(Views(), ", "))
+			}
+			if !(pane.Weight > 0) {
+				return fmt.Errorf("weight of view %s must be positive, got %v", pane.View, pane.Weight)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the layout in the form parsed by ParseLayout.
+func (l Layout) String() string {
+	rows := make([]string, len(l.Rows))
+	for i, row := range l.Rows {
+		panes := make([]string, len(row.Panes))
+		for j, pane := range row.Panes {
+			s := pane.View
+			if pane.Hidden {
+				s = "-" + s
+			}
+			if pane.Weight != 1 {
+				s += ":" + formatWeight(pane.Weight)
+			}
+			panes[j] = s
+		}
+		rows[i] = strings.Join(panes, ",")
+		if row.Weight != 1 {
+			rows[i] = formatWeight(row.Weight) + "*" + rows[i]
+		}
+	}
+	return strings.Join(rows, ";")
+}
+
+// formatWeight writes a weight with up to two decimals, dragged borders
+// would otherwise save long fractions.
+func formatWeight(weight float64) string {
+	return strconv.FormatFloat(math.Round(weight*100)/100, 'f', -1, 64)
+}
+
+// clone returns a copy of the layout which can be changed without changing
+// the original.
+func (l Layout) clone() Layout {
+	rows := make([]Row, len(l.Rows))
+	for i, row := range l.Rows {
+		rows[i] = Row{Weight: row.Weight, Panes: append([]Pane(nil), row.Panes...)}
+	}
+	return Layout{Rows: rows}
+}
+
+// visible returns whether a row has a pane which is not hidden.
+func (r Row) visible() bool {
+	for _, pane := range r.Panes {
+		if !pane.Hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// PaneArea is the area of the screen a pane of the layout is drawn in.
+type PaneArea struct {
+	View   string
+	Bounds image.Rectangle
+	// index of the row and of the pane within it
+	Row, Pane int
+}
+
+// Arrange returns the areas of the visible panes on a screen, top to bottom
+// and left to right. The areas tile the screen without gaps.
+func (l Layout) Arrange(screen image.Rectangle) []PaneArea {
+	var areas []PaneArea
+	rows := make([]float64, len(l.Rows))
+	for i, row := range l.Rows {
+		if row.visible() {
+			rows[i] = row.Weight
+		}
+	}
+	for i, span := range split(screen.Min.Y, screen.Max.Y, rows) {
+		if rows[i] == 0 {
+			continue
+		}
+		panes := make([]float64, len(l.Rows[i].Panes))
+		for j, pane := range l.Rows[i].Panes {
+			if !pane.Hidden {
+				panes[j] = pane.Weight
+			}
+		}
+		for j, column := range split(screen.Min.X, screen.Max.X, panes) {
+			if panes[j] == 0 {
+				continue
+			}
+			areas = append(areas, PaneArea{
+				View:   l.Rows[i].Panes[j].View,
+				Bounds: image.Rect(column[0], span[0], column[1], span[1]),
+				Row:    i,
+				Pane:   j,
+			})
+		}
+	}
+	return areas
+}
+
+// split divides the range from min to max by weights. Boundaries are
+// rounded from the running sum so the parts add up to the whole range.
+func split(min, max int, weights []float64) [][2]int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	parts := make([][2]int, len(weights))
+	sum := 0.0
+	start := min
+	for i, weight := range weights {
+		sum += weight
+		end := start
+		if total > 0 {
+			end = min + int(math.Round(float64(max-min)*sum/total))
+		}
+		parts[i] = [2]int{start, end}
+		start = end
+	}
+	return parts
+}
+
+// Border is a border between two visible rows, or between two visible panes
+// of a row, which can be dragged to resize them.
+type Border struct {
+	// index of the row below the border, or of the row of the panes
+	Row int
+	// index of the pane right of the border, -1 for a border between rows
+	Pane int
+}
+
+// borderAt returns the border of the layout close to a point on a screen.
+func (l Layout) borderAt(screen image.Rectangle, pt image.Point) (Border, bool) {
+	areas := l.Arrange(screen)
+	for i, area := range areas {
+		b := area.Bounds
+		first := i == 0 || areas[i-1].Row != area.Row
+		if first && i > 0 && abs(pt.Y-b.Min.Y) <= borderGrab {
+			return Border{Row: area.Row, Pane: -1}, true
+		}
+		if !first && abs(pt.X-b.Min.X) <= borderGrab && pt.Y >= b.Min.Y && pt.Y < b.Max.Y {
+			return Border{Row: area.Row, Pane: area.Pane}, true
+		}
+	}
+	return Border{}, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// drag moves a border to a point on a screen by shifting weight between the
+// rows or panes on either side of it, which keep at least minPaneSize.
+func (l Layout) drag(screen image.Rectangle, border Border, pt image.Point) {
+	areas := l.Arrange(screen)
+	var before, after *PaneArea
+	for i := range areas {
+		area := &areas[i]
+		if border.Pane < 0 {
+			if area.Row == border.Row && after == nil {
+				after = area
+			} else if area.Row < border.Row {
+				before = area
+			}
+		} else if area.Row == border.Row {
+			if area.Pane == border.Pane {
+				after = area
+			} else if area.Pane < border.Pane {
+				before = area
+			}
+		}
+	}
+	if before == nil || after == nil {
+		return
+	}
+
+	var start, end, position int
+	var weightBefore, weightAfter *float64
+	if border.Pane < 0 {
+		start, end, position = before.Bounds.Min.Y, after.Bounds.Max.Y, pt.Y
+		weightBefore, weightAfter = &l.Rows[before.Row].Weight, &l.Rows[after.Row].Weight
+	} else {
+		start, end, position = before.Bounds.Min.X, after.Bounds.Max.X, pt.X
+		row := l.Rows[border.Row].Panes
+		weightBefore, weightAfter = &row[before.Pane].Weight, &row[after.Pane].Weight
+	}
+	if end-start < 2*minPaneSize {
+		return
+	}
+	if position < start+minPaneSize {
+		position = start + minPaneSize
+	} else if position > end-minPaneSize {
+		position = end - minPaneSize
+	}
+	total := *weightBefore + *weightAfter
+	*weightBefore = total * float64(position-start) / float64(end-start)
+	*weightAfter = total - *weightBefore
+}
+
+// toggle hides the panes showing a view, or shows them if they are all
+// hidden. A view without a pane is added as a new row at the bottom. It
+// returns whether the view is shown.
+func (l *Layout) toggle(view string) bool {
+	shown, found := false, false
+	for _, row := range l.Rows {
+		for _, pane := range row.Panes {
+			if pane.View == view {
+				found = true
+				shown = shown || !pane.Hidden
+			}
+		}
+	}
+	if !found {
+		l.Rows = append(l.Rows, Row{Weight: 1, Panes: []Pane{{View: view, Weight: 1}}})
+		return true
+	}
+	for i := range l.Rows {
+		for j := range l.Rows[i].Panes {
+			if l.Rows[i].Panes[j].View == view {
+				l.Rows[i].Panes[j].Hidden = shown
+			}
+		}
+	}
+	return !shown
+}
+
+// Layout returns the arrangement of the views.
+func (g *Game) Layout() Layout {
+	return g.layout.clone()
+}
+
+// SetLayout changes the arrangement of the views.
+func (g *Game) SetLayout(layout Layout) error {
+	if err := layout.validate(); err != nil {
+		return err
+	}
+	g.layout = layout.clone()
+	return nil
+}
+
+// ToggleView hides or shows a view, adding it below the others if the
+// layout has no pane for it. It returns whether the view is shown.
+func (g *Game) ToggleView(view string) (bool, error) {
+	if !validView(view) {
+		return false, fmt.Errorf("unknown view %q, expected one of %s", view, strings.Join(Views(), ", "))
+	}
+	return g.layout.toggle(view), nil
+}
+
+// ViewArea returns the area of the first visible pane showing a view on a
+// screen.
+func (g *Game) ViewArea(view string, screen image.Rectangle) (image.Rectangle, bool) {
+	for _, area := range g.layout.Arrange(screen) {
+		if area.View == view || (view == g.panel && area.View == ViewPanel) {
+			return area.Bounds, true
+		}
+	}
+	return image.Rectangle{}, false
+}
+
+// BorderAt returns the border between panes close to a point on a screen,
+// if any.
+func (g *Game) BorderAt(screen image.Rectangle, pt image.Point) (Border, bool) {
+	return g.layout.borderAt(screen, pt)
+}
+
+// DragBorder moves a border between panes to a point on a screen.
+func (g *Game) DragBorder(screen image.Rectangle, border Border, pt image.Point) {
+	g.layout.drag(screen, border, pt)
+}
@@ -0,0 +1,103 @@
+package visualizer
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	for _, s := range []string{
+		"roll;wave;2*panel",
+		"roll,tuner:0.5;-wave;1.5*bars,staff",
+		"panel",
+	} {
+		layout, err := ParseLayout(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if got := layout.String(); got != s {
+			t.Errorf("layout %q is written as %q", s, got)
+		}
+	}
+	if got := DefaultLayout().String(); got != "roll;wave;2*panel" {
+		t.Errorf("default layout is written as %q", got)
+	}
+
+	for _, s := range []string{"", "roll;;wave", "meter", "0*roll", "roll:x", "roll:-1"} {
+		if _, err := ParseLayout(s); err == nil {
+			t.Errorf("invalid layout %q was accepted", s)
+		}
+	}
+}
+
+func TestArrange(t *testing.T) {
+	screen := image.Rect(0, 0, 1280, 960)
+	areas := DefaultLayout().Arrange(screen)
+	expected := []PaneArea{
+		{View: ViewRoll, Bounds: image.Rect(0, 0, 1280, 240), Row: 0, Pane: 0},
+		{View: ViewWave, Bounds: image.Rect(0, 240, 1280, 480), Row: 1, Pane: 0},
+		{View: ViewPanel, Bounds: image.Rect(0, 480, 1280, 960), Row: 2, Pane: 0},
+	}
+	if len(areas) != len(expected) {
+		t.Fatalf("arranged %d panes, expected %d", len(areas), len(expected))
+	}
+	for i := range areas {
+		if areas[i] != expected[i] {
+			t.Errorf("pane %d is %+v, expected %+v", i, areas[i], expected[i])
+		}
+	}
+
+	// hidden panes and rows take no space, the others tile the screen
+	layout, err := ParseLayout("roll,tuner:0.5;-wave;2*bars,-staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	areas = layout.Arrange(image.Rect(0, 0, 300, 301))
+	bounds := []image.Rectangle{image.Rect(0, 0, 200, 100), image.Rect(200, 0, 300, 100), image.Rect(0, 100, 300, 301)}
+	if len(areas) != len(bounds) {
+		t.Fatalf("arranged %d panes, expected %d", len(areas), len(bounds))
+	}
+	for i := range areas {
+		if areas[i].Bounds != bounds[i] {
+			t.Errorf("pane %s is at %v, expected %v", areas[i].View, areas[i].Bounds, bounds[i])
+		}
+	}
+}
+
+func TestResizeLayout(t *testing.T) {
+	screen := image.Rect(0, 0, 400, 400)
+	layout, err := ParseLayout("roll,tuner;bars")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := layout.borderAt(screen, image.Pt(100, 100)); ok {
+		t.Errorf("border found inside a pane")
+	}
+	border, ok := layout.borderAt(screen, image.Pt(100, 202))
+	if !ok || border != (Border{Row: 1, Pane: -1}) {
+		t.Fatalf("found border %+v %v between the rows", border, ok)
+	}
+	layout.drag(screen, border, image.Pt(100, 300))
+	if got := layout.String(); got != "1.5*roll,tuner;0.5*bars" {
+		t.Errorf("layout is %s after dragging the rows apart", got)
+	}
+
+	border, ok = layout.borderAt(screen, image.Pt(199, 10))
+	if !ok || border != (Border{Row: 0, Pane: 1}) {
+		t.Fatalf("found border %+v %v between the panes", border, ok)
+	}
+	// panes keep their minimum size
+	layout.drag(screen, border, image.Pt(399, 10))
+	if areas := layout.Arrange(screen); areas[1].Bounds.Dx() != minPaneSize {
+		t.Errorf("pane is %d pixels wide, expected at least %d", areas[1].Bounds.Dx(), minPaneSize)
+	}
+
+	if layout.toggle(ViewRoll) || !layout.toggle(ViewRoll) {
+		t.Errorf("toggling a shown view twice did not hide and show it")
+	}
+	if !layout.toggle(PanelStaff) || len(layout.Rows) != 3 {
+		t.Errorf("toggling a view without a pane did not add a row: %s", layout)
+	}
+}
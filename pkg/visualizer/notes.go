@@ -0,0 +1,337 @@
+package visualizer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/metalblueberry/bard/pkg/cqt"
+)
+
+type Notes []NoteStruct
+
+type NoteStruct struct {
+	Name      string
+	Frequency float64
+	Value     float64
+}
+
+// Scales of a note set.
+const (
+	ScaleChromatic = "chromatic"
+	ScaleMajor     = "major"
+	ScaleCustom    = "custom"
+)
+
+// scales are the semitones of the degrees of each scale above its root, in
+// the order NextScale cycles through them.
+var scales = []struct {
+	name  string
+	steps []int
+}{
+	{ScaleChromatic, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	{ScaleMajor, []int{0, 2, 4, 5, 7, 9, 11}},
+	{"minor", []int{0, 2, 3, 5, 7, 8, 10}},
+	{"harmonic-minor", []int{0, 2, 3, 5, 7, 8, 11}},
+	{"melodic-minor", []int{0, 2, 3, 5, 7, 9, 11}},
+	{"dorian", []int{0, 2, 3, 5, 7, 9, 10}},
+	{"phrygian", []int{0, 1, 3, 5, 7, 8, 10}},
+	{"lydian", []int{0, 2, 4, 6, 7, 9, 11}},
+	{"mixolydian", []int{0, 2, 4, 5, 7, 9, 10}},
+	{"locrian", []int{0, 1, 3, 5, 6, 8, 10}},
+	{"pentatonic", []int{0, 2, 4, 7, 9}},
+	{"minor-pentatonic", []int{0, 3, 5, 7, 10}},
+	{"blues", []int{0, 3, 5, 6, 7, 10}},
+}
+
+// scaleAliases are other names of the modes of the major scale.
+var scaleAliases = map[string]string{
+	"ionian":  ScaleMajor,
+	"aeolian": "minor",
+}
+
+// Scales returns the names of the scales of a note set, custom last.
+func Scales() []string {
+	names := make([]string, 0, len(scales)+1)
+	for _, scale := range scales {
+		names = append(names, scale.name)
+	}
+	return append(names, ScaleCustom)
+}
+
+// NoteSet selects the notes shown by the note bars, the waterfall and the
+// piano roll: the notes of a scale built on a root, or a custom list, from a
+// low to a high note.
+type NoteSet struct {
+	// one of Scales
+	Scale string
+	// pitch of the root without octave, like C, F# or Bb, C if empty
+	Root string
+	// lowest and highest note shown, like C4 and E6
+	Low, High string
+	// notes of the custom scale, pitches without octave are shown in every
+	// octave of the range
+	Custom []string
+}
+
+// DefaultNoteSet returns the C major scale from C4 to E6.
+func DefaultNoteSet() NoteSet {
+	return NoteSet{
+		Scale: ScaleMajor,
+		Root:  "C",
+		Low:   "C4",
+		High:  "E6",
+	}
+}
+
+func (s NoteSet) String() string {
+	if strings.EqualFold(s.Scale, ScaleCustom) {
+		return fmt.Sprintf("custom %s, %s-%s", strings.Join(s.Custom, " "), s.Low, s.High)
+	}
+	if strings.EqualFold(s.Scale, ScaleChromatic) {
+		return fmt.Sprintf("chromatic, %s-%s", s.Low, s.High)
+	}
+	return fmt.Sprintf("%s %s, %s-%s", s.Root, s.Scale, s.Low, s.High)
+}
+
+// pitchClasses maps the letters of note names to their pitch class. The
+// tuner names B as H, both are accepted.
+var pitchClasses = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11, 'H': 11}
+
+// parseNote parses a note name like C, F#4 or Bb2 into its pitch class and
+// its MIDI number, which is only valid if the name has an octave.
+func parseNote(name string) (pitch int, note int, hasOctave bool, err error) {
+	s := strings.TrimSpace(name)
+	if s == "" {
+		return 0, 0, false, fmt.Errorf("empty note name")
+	}
+	pitch, ok := pitchClasses[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return 0, 0, false, fmt.Errorf("invalid note %q", name)
+	}
+	s = s[1:]
+	for len(s) > 0 && (s[0] == '#' || s[0] == 'b') {
+		if s[0] == '#' {
+			pitch++
+		} else {
+			pitch--
+		}
+		s = s[1:]
+	}
+	if s == "" {
+		return (pitch + 12) % 12, 0, false, nil
+	}
+	octave, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid octave in note %q", name)
+	}
+	note = (octave+1)*12 + pitch
+	return (pitch + 12) % 12, note, true, nil
+}
+
+// parseRange parses the low and high note of a note set.
+func (s NoteSet) parseRange() (int, int, error) {
+	_, low, ok, err := parseNote(s.Low)
+	if err == nil && !ok {
+		err = fmt.Errorf("low note %q needs an octave", s.Low)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	_, high, ok, err := parseNote(s.High)
+	if err == nil && !ok {
+		err = fmt.Errorf("high note %q needs an octave", s.High)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if low > high {
+		return 0, 0, fmt.Errorf("low note %s is above high note %s", s.Low, s.High)
+	}
+	return low, high, nil
+}
+
+// Notes returns the notes of the set from low to high.
+func (s NoteSet) Notes() (Notes, error) {
+	low, high, err := s.parseRange()
+	if err != nil {
+		return nil, err
+	}
+
+	var pitches [12]bool
+	explicit := map[int]bool{}
+	if strings.EqualFold(s.Scale, ScaleCustom) {
+		for _, name := range s.Custom {
+			pitch, note, hasOctave, err := parseNote(name)
+			if err != nil {
+				return nil, err
+			}
+			if hasOctave {
+				explicit[note] = true
+			} else {
+				pitches[pitch] = true
+			}
+		}
+	} else {
+		steps, ok := scaleSteps(s.Scale)
+		if !ok {
+			return nil, fmt.Errorf("unknown scale %q, expected one of %s", s.Scale, strings.Join(Scales(), ", "))
+		}
+		root := 0
+		if s.Root != "" {
+			root, _, _, err = parseNote(s.Root)
+			if err != nil {
+				return nil, fmt.Errorf("invalid root: %w", err)
+			}
+		}
+		for _, step := range steps {
+			pitches[(root+step)%12] = true
+		}
+	}
+
+	var notes Notes
+	for note := low; note <= high; note++ {
+		if note >= 0 && (pitches[note%12] || explicit[note]) {
+			notes = append(notes, NoteStruct{Name: midiName(note), Frequency: midiFrequency(note)})
+		}
+	}
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("note set %s has no notes", s)
+	}
+	return notes, nil
+}
+
+// scaleSteps returns the steps of a scale by its name.
+func scaleSteps(name string) ([]int, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := scaleAliases[name]; ok {
+		name = alias
+	}
+	for _, scale := range scales {
+		if scale.name == name {
+			return scale.steps, true
+		}
+	}
+	return nil, false
+}
+
+// nextScale returns the scale following a scale in Scales. Custom is only
+// included if the set has custom notes.
+func (s NoteSet) nextScale() string {
+	names := Scales()
+	if len(s.Custom) == 0 {
+		names = names[:len(names)-1]
+	}
+	current := strings.ToLower(s.Scale)
+	if alias, ok := scaleAliases[current]; ok {
+		current = alias
+	}
+	for i, name := range names {
+		if name == current {
+			return names[(i+1)%len(names)]
+		}
+	}
+	return names[0]
+}
+
+// transpose returns the name of a note moved by a number of semitones,
+// keeping the octave off if the name has none.
+func transpose(name string, semitones int) (string, error) {
+	pitch, note, hasOctave, err := parseNote(name)
+	if err != nil {
+		return "", err
+	}
+	if !hasOctave {
+		return noteNames[((pitch+semitones)%12+12)%12], nil
+	}
+	if note+semitones < 0 {
+		return "", fmt.Errorf("note %s is below C-1", name)
+	}
+	return midiName(note + semitones), nil
+}
+
+// newNoteMapping creates a constant-Q transform with one bin per semitone
+// covering the notes of a set and relates the notes and the keys of the
+// piano roll to its bins. Kernels are limited to the length of the audio
+// buffer so low notes can still be computed from it.
+func newNoteMapping(set NoteSet, sampleRate float64, bufferLength int) (*noteMapping, error) {
+	notes, err := set.Notes()
+	if err != nil {
+		return nil, err
+	}
+	transform, err := cqt.Create(cqt.Config{
+		SampleRate:      sampleRate,
+		MinFrequency:    notes[0].Frequency,
+		MaxFrequency:    notes[len(notes)-1].Frequency,
+		BinsPerSemitone: 1,
+		MaxLength:       bufferLength,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("note set %s: %w", set, err)
+	}
+	m := &noteMapping{
+		set:       set,
+		notes:     notes,
+		transform: transform,
+		noteBins:  make([]int, len(notes)),
+		rollLow:   midiNote(notes[0].Frequency),
+		rollHigh:  midiNote(notes[len(notes)-1].Frequency),
+	}
+	for i := range notes {
+		m.noteBins[i] = transform.Closest(notes[i].Frequency)
+	}
+	m.rollBins = make([]int, m.rollHigh-m.rollLow+1)
+	for i := range m.rollBins {
+		m.rollBins[i] = transform.Closest(midiFrequency(m.rollLow + i))
+	}
+	return m, nil
+}
+
+// SetNotes shows the notes of a set. The transform is rebuilt for the range
+// of the set and the history is cleared, as its notes no longer match. On
+// error the notes shown before are kept.
+func (g *Game) SetNotes(set NoteSet) error {
+	return g.analysis.configure(0, set)
+}
+
+// NoteSet returns the set of the notes shown.
+func (g *Game) NoteSet() NoteSet {
+	return g.analysis.noteSet()
+}
+
+// NextScale shows the notes of the next scale with the same root and range.
+func (g *Game) NextScale() error {
+	set := g.NoteSet()
+	set.Scale = set.nextScale()
+	return g.SetNotes(set)
+}
+
+// TransposeRoot moves the root of the scale by a number of semitones, from
+// C if the set has no root.
+func (g *Game) TransposeRoot(semitones int) error {
+	set := g.NoteSet()
+	if set.Root == "" {
+		set.Root = "C"
+	}
+	root, err := transpose(set.Root, semitones)
+	if err != nil {
+		return err
+	}
+	set.Root = root
+	return g.SetNotes(set)
+}
+
+// ShiftOctaves moves the range of the notes shown by a number of octaves.
+func (g *Game) ShiftOctaves(octaves int) error {
+	set := g.NoteSet()
+	low, err := transpose(set.Low, 12*octaves)
+	if err != nil {
+		return err
+	}
+	high, err := transpose(set.High, 12*octaves)
+	if err != nil {
+		return err
+	}
+	set.Low, set.High = low, high
+	return g.SetNotes(set)
+}
@@ -0,0 +1,111 @@
+package visualizer
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+)
+
+func noteNamesOf(notes Notes) string {
+	names := make([]string, len(notes))
+	for i, note := range notes {
+		names[i] = note.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestNoteSet(t *testing.T) {
+	tests := []struct {
+		set   NoteSet
+		names string
+	}{
+		{DefaultNoteSet(), "C4 D4 E4 F4 G4 A4 H4 C5 D5 E5 F5 G5 A5 H5 C6 D6 E6"},
+		{NoteSet{Scale: ScaleChromatic, Low: "E2", High: "G#2"}, "E2 F2 F#2 G2 G#2"},
+		{NoteSet{Scale: "minor", Root: "A", Low: "A3", High: "A4"}, "A3 H3 C4 D4 E4 F4 G4 A4"},
+		{NoteSet{Scale: "aeolian", Root: "a", Low: "A3", High: "C4"}, "A3 H3 C4"},
+		{NoteSet{Scale: "pentatonic", Root: "G", Low: "G3", High: "G4"}, "G3 A3 H3 D4 E4 G4"},
+		{NoteSet{Scale: "blues", Root: "Bb", Low: "Bb2", High: "Bb3"}, "A#2 C#3 D#3 E3 F3 G#3 A#3"},
+		{NoteSet{Scale: "dorian", Root: "D", Low: "D4", High: "D5"}, "D4 E4 F4 G4 A4 H4 C5 D5"},
+		{NoteSet{Scale: ScaleCustom, Custom: []string{"E", "A2", " D"}, Low: "E2", High: "E3"}, "E2 A2 D3 E3"},
+	}
+	for _, test := range tests {
+		notes, err := test.set.Notes()
+		if err != nil {
+			t.Errorf("%s: %s", test.set, err)
+			continue
+		}
+		if got := noteNamesOf(notes); got != test.names {
+			t.Errorf("%s: got %s, expected %s", test.set, got, test.names)
+		}
+	}
+
+	notes, _ := DefaultNoteSet().Notes()
+	if math.Abs(notes[5].Frequency-440) > 1e-9 {
+		t.Errorf("A4 has frequency %f, expected 440", notes[5].Frequency)
+	}
+}
+
+func TestNoteSetErrors(t *testing.T) {
+	broken := []NoteSet{
+		{Scale: "klezmer", Root: "C", Low: "C4", High: "C5"},
+		{Scale: ScaleMajor, Root: "X", Low: "C4", High: "C5"},
+		{Scale: ScaleMajor, Root: "C", Low: "C", High: "C5"},
+		{Scale: ScaleMajor, Root: "C", Low: "C5", High: "C4"},
+		{Scale: ScaleCustom, Custom: []string{"C#"}, Low: "C4", High: "C4"},
+		{Scale: ScaleCustom, Custom: []string{"Q4"}, Low: "C4", High: "C5"},
+	}
+	for _, set := range broken {
+		if _, err := set.Notes(); err == nil {
+			t.Errorf("invalid note set %s was accepted", set)
+		}
+	}
+}
+
+func TestNoteSetChanges(t *testing.T) {
+	set := DefaultNoteSet()
+	if next := set.nextScale(); next != "minor" {
+		t.Errorf("scale after major is %s, expected minor", next)
+	}
+	set.Scale = "blues"
+	if next := set.nextScale(); next != ScaleChromatic {
+		t.Errorf("scale after blues without custom notes is %s, expected chromatic", next)
+	}
+	set.Custom = []string{"C"}
+	if next := set.nextScale(); next != ScaleCustom {
+		t.Errorf("scale after blues with custom notes is %s, expected custom", next)
+	}
+
+	for _, test := range []struct {
+		name      string
+		semitones int
+		want      string
+	}{
+		{"C", 1, "C#"},
+		{"H", 1, "C"},
+		{"C", -1, "H"},
+		{"Bb", 0, "A#"},
+		{"E6", 12, "E7"},
+		{"C4", -12, "C3"},
+	} {
+		got, err := transpose(test.name, test.semitones)
+		if err != nil || got != test.want {
+			t.Errorf("transposing %s by %d gave %s (%v), expected %s", test.name, test.semitones, got, err, test.want)
+		}
+	}
+
+	// a set without root is built on C, so is transposing it
+	tee := CreateTee(audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1), nil)
+	defer tee.Close()
+	options := DefaultOptions()
+	options.Notes.Root = ""
+	g, err := CreateGame(context.Background(), tee, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.TransposeRoot(2); err != nil || g.NoteSet().Root != "D" {
+		t.Errorf("transposing an empty root by 2 gave %q (%v), expected D", g.NoteSet().Root, err)
+	}
+}
@@ -0,0 +1,160 @@
+package visualizer
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+const (
+	// seconds of tuner results shown by the piano roll
+	pianoRollSeconds = 5.0
+	// width of the keyboard left of the piano roll
+	keyboardWidth = 48
+	// deviation in cents drawn in the full colour of flat or sharp notes
+	maxCentsColor = 50
+)
+
+var (
+	noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "H"}
+
+	inTuneColor = color.NRGBA{R: 70, G: 200, B: 90, A: 255}
+	sharpColor  = color.NRGBA{R: 230, G: 60, B: 50, A: 255}
+	flatColor   = color.NRGBA{R: 60, G: 120, B: 230, A: 255}
+)
+
+// midiNote returns the MIDI number of the note closest to a frequency.
+func midiNote(frequency float64) int {
+	return int(math.Round(69 + 12*math.Log2(frequency/440)))
+}
+
+// midiFrequency returns the frequency of a MIDI note.
+func midiFrequency(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+// midiName returns the name of a MIDI note in the notation of the tuner.
+func midiName(note int) string {
+	return fmt.Sprintf("%s%d", noteNames[note%12], note/12-1)
+}
+
+// isBlackKey returns whether a MIDI note is a black key on the keyboard.
+func isBlackKey(note int) bool {
+	switch note % 12 {
+	case 1, 3, 6, 8, 10:
+		return true
+	}
+	return false
+}
+
+// centsColor returns the colour of a detected note, green when in tune,
+// turning blue when flat and red when sharp.
+func centsColor(cents int) color.NRGBA {
+	t := float64(cents) / maxCentsColor
+	to := sharpColor
+	if t < 0 {
+		t = -t
+		to = flatColor
+	}
+	if t > 1 {
+		t = 1
+	}
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + t*(float64(b)-float64(a))))
+	}
+	return color.NRGBA{
+		R: mix(inTuneColor.R, to.R),
+		G: mix(inTuneColor.G, to.G),
+		B: mix(inTuneColor.B, to.B),
+		A: 255,
+	}
+}
+
+// drawPianoRoll draws the notes detected by the tuner over the last seconds,
+// with a row per key of the keyboard and the present at the right edge. The
+// keys of the keyboard light up while their notes peak in the transform.
+func (g *Game) drawPianoRoll(screen canvas.Canvas) {
+	s := g.shown
+	m := s.mapping
+	bounds := screen.Bounds()
+	rows := m.rollHigh - m.rollLow + 1
+	rowHeight := float32(bounds.Dy()) / float32(rows)
+	rowY := func(note int) float32 {
+		return float32(bounds.Max.Y) - float32(note-m.rollLow+1)*rowHeight
+	}
+	left := float32(bounds.Min.X + keyboardWidth)
+	width := float32(bounds.Max.X) - left
+
+	// keys light up while their notes peak over the noise floor, the one
+	// peaking the most is highlighted like the strongest note bar
+	sounding := func(note int) bool {
+		return s.Playing(m.rollBins[note-m.rollLow])
+	}
+	loudest := -1
+	loudestRatio := math.Inf(-1)
+	for note := m.rollLow; note <= m.rollHigh; note++ {
+		if !sounding(note) {
+			continue
+		}
+		bin := m.rollBins[note-m.rollLow]
+		if ratio := s.Spectrum[bin] / s.Floor[bin]; ratio > loudestRatio {
+			loudest, loudestRatio = note, ratio
+		}
+	}
+
+	for note := m.rollLow; note <= m.rollHigh; note++ {
+		y := rowY(note)
+		lane := color.Gray{Y: 30}
+		key := color.Color(color.Gray{Y: 220})
+		if isBlackKey(note) {
+			lane = color.Gray{Y: 18}
+			key = color.Gray{Y: 40}
+		}
+		if sounding(note) {
+			key = color.NRGBA{R: 255, G: 170, B: 40, A: 255}
+		}
+		if note == loudest {
+			key = color.NRGBA{R: 255, A: 255}
+		}
+		screen.FillRect(left, y, width, rowHeight, lane)
+		screen.FillRect(float32(bounds.Min.X), y, keyboardWidth-1, rowHeight-1, key)
+	}
+	// octaves are labelled once all lanes are drawn, as labels are taller
+	// than a lane
+	for note := m.rollLow; note <= m.rollHigh; note++ {
+		if note%12 == 0 {
+			screen.Text(midiName(note), bounds.Min.X+keyboardWidth+4, int(rowY(note)+rowHeight), color.Gray{Y: 160})
+		}
+	}
+
+	latest := g.latestPitch()
+	if latest == nil {
+		return
+	}
+
+	hopWidth := float32(timeline.DEFAULT_HOP.Seconds() / pianoRollSeconds * float64(width))
+	barHeight := rowHeight - 2
+	if barHeight < 1 {
+		barHeight = rowHeight
+	}
+	for _, entry := range s.Pitch {
+		age := latest.Time - entry.Time
+		if !entry.Voiced || entry.Time <= 0 || age >= pianoRollSeconds {
+			continue
+		}
+		note := midiNote(entry.Frequency)
+		if note < m.rollLow || note > m.rollHigh {
+			continue
+		}
+		x := float32(bounds.Max.X) - float32(age/pianoRollSeconds)*width - hopWidth
+		screen.FillRect(x, rowY(note)+(rowHeight-barHeight)/2, hopWidth, barHeight, centsColor(entry.Cents))
+	}
+
+	if latest.Voiced {
+		label := fmt.Sprintf("%s %+dc", latest.Note, latest.Cents)
+		screen.Text(label, bounds.Max.X-70, bounds.Min.Y+14, centsColor(latest.Cents))
+	}
+}
@@ -0,0 +1,42 @@
+package visualizer
+
+import "testing"
+
+func TestMidiNotes(t *testing.T) {
+	cases := []struct {
+		frequency float64
+		note      int
+		name      string
+		black     bool
+	}{
+		{261.6256, 60, "C4", false},
+		{277.1826, 61, "C#4", true},
+		{440, 69, "A4", false},
+		{493.8833, 71, "H4", false},
+		{1318.5102, 88, "E6", false},
+	}
+	for _, c := range cases {
+		note := midiNote(c.frequency)
+		if note != c.note || midiName(note) != c.name || isBlackKey(note) != c.black {
+			t.Errorf("%.1f Hz is note %d %s (black %t), expected %d %s (black %t)", c.frequency, note, midiName(note), isBlackKey(note), c.note, c.name, c.black)
+		}
+		if got := midiFrequency(c.note); got < c.frequency*0.999 || got > c.frequency*1.001 {
+			t.Errorf("note %d has %.2f Hz, expected %.2f Hz", c.note, got, c.frequency)
+		}
+	}
+}
+
+func TestCentsColor(t *testing.T) {
+	if centsColor(0) != inTuneColor {
+		t.Errorf("in tune colour is %v, expected %v", centsColor(0), inTuneColor)
+	}
+	if centsColor(maxCentsColor) != sharpColor || centsColor(90) != sharpColor {
+		t.Errorf("sharp colour is %v, expected %v", centsColor(maxCentsColor), sharpColor)
+	}
+	if centsColor(-maxCentsColor) != flatColor {
+		t.Errorf("flat colour is %v, expected %v", centsColor(-maxCentsColor), flatColor)
+	}
+	if c := centsColor(25); c.R <= inTuneColor.R || c.R >= sharpColor.R {
+		t.Errorf("colour of +25 cents %v is not between in tune and sharp", c)
+	}
+}
@@ -0,0 +1,135 @@
+package visualizer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/circular"
+	"github.com/metalblueberry/bard/pkg/timeline"
+	"github.com/metalblueberry/bard/pkg/tuner"
+)
+
+// pitchHistoryLength is the number of tuner results kept, ten seconds at
+// the default hop.
+const pitchHistoryLength = int(10 * time.Second / timeline.DEFAULT_HOP)
+
+// pitchTracker streams the audio of the tee into the tuner and keeps the
+// recent results of its analysis. Streaming is cheap and happens on the
+// audio path, the analysis runs separately so it never delays the audio.
+type pitchTracker struct {
+	tuner      *tuner.Tuner
+	sampleRate float64
+	// called with every result on the analyzing goroutine, may be nil
+	listener func(entry timeline.Entry)
+
+	lock    sync.Mutex
+	frames  int64
+	history *circular.Buffer[timeline.Entry]
+	// power of the samples streamed since the last analysis, for its level
+	sumSquares float64
+	samples    int
+}
+
+func newPitchTracker(sampleRate float64) *pitchTracker {
+	config := timeline.DefaultConfig()
+	t := tuner.Create()
+	t.SetPreprocessing(config.Preprocess)
+	t.SetAnalysisRate(config.AnalysisRate)
+	return &pitchTracker{
+		tuner:      t,
+		sampleRate: sampleRate,
+		history:    circular.CreateBuffer[timeline.Entry](pitchHistoryLength),
+	}
+}
+
+// process streams mono samples into the tuner and returns the time of the
+// audio streamed so far, in seconds.
+func (p *pitchTracker) process(mono []float64) float64 {
+	p.tuner.Process(mono, uint32(p.sampleRate))
+	sumSquares := 0.0
+	for _, x := range mono {
+		sumSquares += x * x
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.frames += int64(len(mono))
+	p.sumSquares += sumSquares
+	p.samples += len(mono)
+	return float64(p.frames) / p.sampleRate
+}
+
+// analyze runs the tuner over the streamed audio and keeps the result,
+// stamped with the time of the audio streamed so far.
+func (p *pitchTracker) analyze() error {
+	p.lock.Lock()
+	entry := timeline.Entry{Time: float64(p.frames) / p.sampleRate}
+	if p.samples > 0 {
+		entry.Level = audio.Decibels(math.Sqrt(p.sumSquares / float64(p.samples)))
+	}
+	p.sumSquares, p.samples = 0, 0
+	p.lock.Unlock()
+
+	// nothing was streamed yet
+	if entry.Time == 0 {
+		return nil
+	}
+	if p.tuner.Voiced() {
+		result, err := p.tuner.Analyze()
+		if err != nil {
+			return fmt.Errorf("analyzing pitch at %.3f s: %w", entry.Time, err)
+		}
+		entry.Voiced = true
+		entry.Frequency = result.Frequency()
+		entry.Note = result.Note()
+		entry.Cents = int(result.Cents())
+		entry.Confidence = result.Confidence()
+	}
+
+	p.lock.Lock()
+	p.history.Enqueue(entry)
+	p.lock.Unlock()
+	if p.listener != nil {
+		p.listener(entry)
+	}
+	return nil
+}
+
+// run analyzes the audio once per hop until the context is cancelled.
+func (p *pitchTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(timeline.DEFAULT_HOP)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.analyze(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// results copies the kept results into out, oldest first. Results not
+// produced yet have a zero time.
+func (p *pitchTracker) results(out []timeline.Entry) []timeline.Entry {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(out) != p.history.Length() {
+		out = make([]timeline.Entry, p.history.Length())
+	}
+	p.history.Retrieve(out)
+	return out
+}
+
+// reset forgets the kept results, the tuner keeps its buffered audio.
+func (p *pitchTracker) reset() {
+	p.lock.Lock()
+	p.history = circular.CreateBuffer[timeline.Entry](p.history.Length())
+	p.lock.Unlock()
+}
@@ -0,0 +1,487 @@
+package visualizer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/practice"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+const (
+	// height of the status bar and of the progress strip below it
+	statusHeight   = 44
+	progressHeight = 8
+	// height of the keyboard below the hit line
+	practiceKeyboardHeight = 64
+	// semitones shown around the range of the melody
+	practiceMargin = 2
+	// fewest lanes shown, so short melodies do not get huge lanes
+	practiceMinLanes = 13
+	// rows of the results table
+	resultRowHeight   = 16
+	resultColumnWidth = 250
+)
+
+var (
+	pendingColor = color.NRGBA{R: 150, G: 160, B: 190, A: 255}
+	targetColor  = color.NRGBA{R: 255, G: 210, B: 60, A: 255}
+	missedColor  = color.NRGBA{R: 110, G: 40, B: 40, A: 255}
+	hitLineColor = color.NRGBA{R: 255, G: 255, B: 255, A: 180}
+	sectionColor = color.NRGBA{R: 90, G: 170, B: 255, A: 90}
+)
+
+// PracticeOptions configures the practice game.
+type PracticeOptions struct {
+	Title string
+	// time of the melody shown above the hit line
+	Lookahead time.Duration
+	// scroll smoothly by the clock between tuner results, which arrive once
+	// per hop, instead of moving once per result as rendered frames do
+	Interpolate bool
+}
+
+// DefaultPracticeOptions returns the options of the practice game showing
+// four seconds of the melody ahead.
+func DefaultPracticeOptions() PracticeOptions {
+	return PracticeOptions{
+		Title:     "bard practice",
+		Lookahead: 4 * time.Second,
+	}
+}
+
+// Practice is the falling-notes practice game. The notes of a melody scroll
+// down toward a hit line above a keyboard, and the pitch the tuner detects
+// in the audio passing through the tee is scored on timing and intonation.
+type Practice struct {
+	ctx     context.Context
+	echo    *Tee
+	session *practice.Session
+	options PracticeOptions
+
+	// tuner results fetched for this frame, the time of the newest one
+	// processed and the latest voiced one, nil during silence
+	pitch     []timeline.Entry
+	processed float64
+	detected  *timeline.Entry
+	// wall time the newest result was processed at, for interpolation
+	updated time.Time
+
+	// MIDI range of the lanes
+	low  int
+	high int
+	// results of the last pass shown instead of the notes
+	showResults bool
+	finished    bool
+}
+
+// CreatePractice creates the practice game of a session for the audio
+// passing through the tee. The tuner must analyze the tee, either with
+// RunPitch or AnalyzePitch before every Update.
+func CreatePractice(ctx context.Context, tee *Tee, session *practice.Session, options PracticeOptions) (*Practice, error) {
+	if options.Lookahead <= 0 {
+		return nil, fmt.Errorf("lookahead must be positive, got %s", options.Lookahead)
+	}
+	low, high := session.Melody().Range()
+	low -= practiceMargin
+	high += practiceMargin
+	for high-low+1 < practiceMinLanes {
+		low--
+		high++
+	}
+	return &Practice{
+		ctx:     ctx,
+		echo:    tee,
+		session: session,
+		options: options,
+		low:     low,
+		high:    high,
+	}, nil
+}
+
+// Session returns the practice session scored by the game.
+func (p *Practice) Session() *practice.Session {
+	return p.session
+}
+
+// Update scores the tuner results produced since the last update. Results
+// produced before the first update only start the clock. The results are
+// shown when a pass over the section completes without looping.
+func (p *Practice) Update() error {
+	p.pitch = p.echo.Pitch(p.pitch)
+	for _, entry := range p.pitch {
+		if entry.Time <= p.processed {
+			continue
+		}
+		if p.processed == 0 {
+			// older results were produced before the game started
+			entry = p.pitch[len(p.pitch)-1]
+		}
+		p.processed = entry.Time
+		p.updated = time.Now()
+		p.session.Process(entry)
+		p.detected = nil
+		if entry.Voiced {
+			detected := entry
+			p.detected = &detected
+		}
+	}
+	if finished := p.session.Finished(); finished != p.finished {
+		p.finished = finished
+		p.showResults = finished
+	}
+	return p.ctx.Err()
+}
+
+// TogglePause pauses or resumes the melody.
+func (p *Practice) TogglePause() {
+	p.session.SetPaused(!p.session.Paused())
+}
+
+// Restart starts practicing the section from its first pass.
+func (p *Practice) Restart() {
+	p.session.Restart()
+	p.showResults = false
+	p.finished = false
+}
+
+// ToggleWait switches between waiting for every note to be played and
+// keeping time.
+func (p *Practice) ToggleWait() {
+	p.session.SetWait(!p.session.Config().Wait)
+}
+
+// ToggleLoop repeats the section or stops after the current pass.
+func (p *Practice) ToggleLoop() {
+	p.session.SetLoop(!p.session.Config().Loop)
+	if p.session.Config().Loop {
+		p.showResults = false
+	}
+}
+
+// SetLoopStart starts the section at the current position and restarts.
+func (p *Practice) SetLoopStart() error {
+	from := p.session.Position()
+	if from < 0 {
+		from = 0
+	}
+	_, to := p.session.Section()
+	if to != 0 && to <= from {
+		to = 0
+	}
+	return p.session.SetSection(from, to)
+}
+
+// SetLoopEnd ends the section at the current position, loops it and
+// restarts.
+func (p *Practice) SetLoopEnd() error {
+	from, _ := p.session.Section()
+	if err := p.session.SetSection(from, p.session.Position()); err != nil {
+		return err
+	}
+	p.session.SetLoop(true)
+	return nil
+}
+
+// ClearSection practices the whole melody again.
+func (p *Practice) ClearSection() error {
+	return p.session.SetSection(0, 0)
+}
+
+// ChangeSpeed changes the speed of the melody within the supported range.
+func (p *Practice) ChangeSpeed(delta float64) {
+	p.session.SetSpeed(p.session.Config().Speed + delta)
+}
+
+// ToggleResults shows the results of the last completed pass, or the notes.
+func (p *Practice) ToggleResults() {
+	if last, _ := p.session.Last(); last != nil {
+		p.showResults = !p.showResults
+	}
+}
+
+// Results returns whether the results of the last pass are shown.
+func (p *Practice) Results() bool {
+	return p.showResults
+}
+
+// position returns the position of the melody drawn, moved on by the clock
+// since the last result if the game interpolates.
+func (p *Practice) position() time.Duration {
+	s := p.session
+	position := s.Position()
+	if !p.options.Interpolate || p.updated.IsZero() || s.Paused() || s.Waiting() || s.Finished() {
+		return position
+	}
+	since := time.Since(p.updated)
+	if since > timeline.DEFAULT_HOP {
+		since = timeline.DEFAULT_HOP
+	}
+	return position + time.Duration(float64(since)*s.Config().Speed)
+}
+
+// Draw draws the falling notes, or the results of the last pass.
+func (p *Practice) Draw(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	p.drawStatus(screen.Sub(image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+statusHeight)))
+	p.drawProgress(screen.Sub(image.Rect(bounds.Min.X, bounds.Min.Y+statusHeight, bounds.Max.X, bounds.Min.Y+statusHeight+progressHeight)))
+	area := image.Rect(bounds.Min.X, bounds.Min.Y+statusHeight+progressHeight, bounds.Max.X, bounds.Max.Y)
+	if p.showResults {
+		p.drawResults(screen.Sub(area))
+	} else {
+		p.drawNotes(screen.Sub(area))
+	}
+}
+
+// drawStatus shows the score of the pass, the modes and the detected note.
+func (p *Practice) drawStatus(screen canvas.Canvas) {
+	s := p.session
+	config := s.Config()
+	bounds := screen.Bounds()
+	summary := s.Summary()
+	screen.FillRect(float32(bounds.Min.X), float32(bounds.Min.Y), float32(bounds.Dx()), float32(bounds.Dy()), color.Gray{Y: 25})
+
+	status := fmt.Sprintf("%s  pass %d  score %d  hits %d/%d", s.Melody().Title, summary.Pass, summary.Score, summary.Hits, summary.Judged)
+	if summary.Judged > 0 {
+		status += fmt.Sprintf("  accuracy %.0f%%", 100*summary.Accuracy)
+	}
+	if best := s.Best(); best.Pass > 0 {
+		status += fmt.Sprintf("  best %d", best.Score)
+	}
+	screen.Text(status, bounds.Min.X+8, bounds.Min.Y+16, color.White)
+
+	modes := fmt.Sprintf("%.2fx", config.Speed)
+	if config.Wait {
+		modes += "  wait"
+	}
+	if config.Loop {
+		modes += "  loop"
+	}
+	if from, to := s.Section(); from != 0 || to != 0 {
+		modes += fmt.Sprintf("  section %s-%s", formatPosition(from), formatPosition(to))
+	}
+	switch {
+	case s.Paused():
+		modes += "  PAUSED"
+	case s.Waiting():
+		modes += "  waiting for " + midiName(p.target().Note.Pitch)
+	}
+	modes += "   space pause, enter restart, W wait, L loop, [ ] section, backspace whole, up/down speed, tab results"
+	screen.Text(modes, bounds.Min.X+8, bounds.Min.Y+36, color.Gray{Y: 160})
+
+	if p.detected != nil {
+		note := midiNote(p.detected.Frequency)
+		cents := int(math.Round(practice.Cents(p.detected.Frequency, note)))
+		screen.Text(fmt.Sprintf("%s %+dc", midiName(note), cents), bounds.Max.X-80, bounds.Min.Y+16, centsColor(cents))
+	}
+}
+
+// formatPosition formats a position in the melody, zero as the end of the
+// section is the end of the melody.
+func formatPosition(position time.Duration) string {
+	if position == 0 {
+		return "end"
+	}
+	return fmt.Sprintf("%.1fs", position.Seconds())
+}
+
+// drawProgress shows the position in the melody and the section practiced.
+func (p *Practice) drawProgress(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	duration := p.session.Melody().Duration()
+	screen.FillRect(float32(bounds.Min.X), float32(bounds.Min.Y), float32(bounds.Dx()), float32(bounds.Dy()), color.Gray{Y: 50})
+	if duration <= 0 {
+		return
+	}
+	x := func(position time.Duration) float32 {
+		ratio := float64(position) / float64(duration)
+		ratio = math.Max(0, math.Min(1, ratio))
+		return float32(bounds.Min.X) + float32(ratio*float64(bounds.Dx()))
+	}
+	from, to := p.session.Section()
+	if to == 0 {
+		to = duration
+	}
+	screen.FillRect(x(from), float32(bounds.Min.Y), x(to)-x(from), float32(bounds.Dy()), sectionColor)
+	screen.FillRect(float32(bounds.Min.X), float32(bounds.Min.Y+2), x(p.position())-float32(bounds.Min.X), float32(bounds.Dy()-4), color.Gray{Y: 200})
+}
+
+// target returns the result of the next note to play, the first which was
+// not judged, or the last note once all were.
+func (p *Practice) target() *practice.Result {
+	results := p.session.Results()
+	for i := range results {
+		if results[i].State == practice.STATE_PENDING {
+			return &results[i]
+		}
+	}
+	return &results[len(results)-1]
+}
+
+// noteColor returns the colour of a note of the pass: pending notes are
+// grey and the next one to play is highlighted, hit notes show how well
+// they were tuned and missed notes are dark red.
+func noteColor(result *practice.Result, target bool) color.NRGBA {
+	switch {
+	case result.State == practice.STATE_HIT:
+		return centsColor(int(math.Round(result.Cents)))
+	case result.State == practice.STATE_MISSED:
+		return missedColor
+	case target:
+		return targetColor
+	}
+	return pendingColor
+}
+
+// drawNotes draws the lanes of the notes falling toward the hit line, the
+// keyboard below it and the pitch detected.
+func (p *Practice) drawNotes(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	lanes := p.high - p.low + 1
+	laneWidth := float32(bounds.Dx()) / float32(lanes)
+	laneX := func(note int) float32 {
+		return float32(bounds.Min.X) + float32(note-p.low)*laneWidth
+	}
+	hitY := float32(bounds.Max.Y - practiceKeyboardHeight)
+	top := float32(bounds.Min.Y)
+	position := p.position()
+	y := func(at time.Duration) float32 {
+		return hitY - float32(float64(at-position)/float64(p.options.Lookahead))*(hitY-top)
+	}
+
+	for note := p.low; note <= p.high; note++ {
+		lane := color.Gray{Y: 30}
+		if isBlackKey(note) {
+			lane = color.Gray{Y: 18}
+		}
+		screen.FillRect(laneX(note), top, laneWidth, hitY-top, lane)
+		if note%12 == 0 {
+			screen.FillRect(laneX(note), top, 1, hitY-top, color.Gray{Y: 60})
+		}
+	}
+
+	// notes are clipped at the hit line, which they reach when they are to
+	// be played
+	notes := screen.Sub(image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, int(hitY)))
+	target := p.target()
+	results := p.session.Results()
+	for i := range results {
+		result := &results[i]
+		note := result.Note
+		if note.Pitch < p.low || note.Pitch > p.high {
+			continue
+		}
+		bottom, upper := y(note.Start), y(note.End())
+		if bottom < top || upper > hitY {
+			continue
+		}
+		x := laneX(note.Pitch) + 2
+		c := noteColor(result, result == target)
+		notes.FillRect(x, upper+1, laneWidth-4, bottom-upper-2, c)
+		if bottom-upper > 16 && laneWidth > 20 {
+			notes.Text(midiName(note.Pitch), int(x)+2, int(bottom)-4, color.Black)
+		}
+	}
+	screen.FillRect(float32(bounds.Min.X), hitY-1, float32(bounds.Dx()), 2, hitLineColor)
+
+	// the keyboard shows the next note to play and the one detected
+	detected := -1
+	if p.detected != nil {
+		detected = midiNote(p.detected.Frequency)
+	}
+	for note := p.low; note <= p.high; note++ {
+		key := color.Color(color.Gray{Y: 220})
+		if isBlackKey(note) {
+			key = color.Gray{Y: 40}
+		}
+		if note == target.Note.Pitch && target.State == practice.STATE_PENDING {
+			key = targetColor
+		}
+		if note == detected {
+			key = centsColor(int(math.Round(practice.Cents(p.detected.Frequency, note))))
+		}
+		screen.FillRect(laneX(note)+1, hitY+2, laneWidth-2, practiceKeyboardHeight-3, key)
+		if note%12 == 0 {
+			screen.Text(midiName(note), int(laneX(note))+2, bounds.Max.Y-4, color.Gray{Y: 100})
+		}
+	}
+
+	// the detected pitch is marked at its exact position between the
+	// lanes, so the deviation from the note can be seen
+	if p.detected != nil {
+		pitch := 69 + 12*math.Log2(p.detected.Frequency/440)
+		x := laneX(p.low) + float32(pitch-float64(p.low)+0.5)*laneWidth
+		if x >= float32(bounds.Min.X) && x < float32(bounds.Max.X) {
+			cents := int(math.Round(practice.Cents(p.detected.Frequency, detected)))
+			screen.FillRect(x-2, hitY-12, 4, 24, centsColor(cents))
+		}
+	}
+}
+
+// drawResults shows the accuracy of every note of the last completed pass
+// as a chart and as a table of their timing and intonation.
+func (p *Practice) drawResults(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	results, summary := p.session.Last()
+	if results == nil {
+		return
+	}
+	x0, y0 := bounds.Min.X+16, bounds.Min.Y+24
+	screen.Text(fmt.Sprintf("results of pass %d: score %d, %d of %d notes hit, accuracy %.0f%%", summary.Pass, summary.Score, summary.Hits, summary.Notes, 100*summary.Accuracy), x0, y0, color.White)
+	if best := p.session.Best(); best.Pass != summary.Pass {
+		screen.Text(fmt.Sprintf("best pass %d: score %d, accuracy %.0f%%", best.Pass, best.Score, 100*best.Accuracy), x0, y0+20, color.Gray{Y: 160})
+	}
+
+	// a bar per note, as high as it was accurate
+	chart := image.Rect(x0, y0+36, bounds.Max.X-16, y0+136)
+	screen.FillRect(float32(chart.Min.X), float32(chart.Min.Y), float32(chart.Dx()), float32(chart.Dy()), color.Gray{Y: 25})
+	barWidth := float32(chart.Dx()) / float32(len(results))
+	for i := range results {
+		result := &results[i]
+		height := float32(result.Accuracy) * float32(chart.Dy())
+		if result.State == practice.STATE_MISSED {
+			height = 3
+		}
+		x := float32(chart.Min.X) + float32(i)*barWidth
+		gap := float32(1)
+		if barWidth < 3 {
+			gap = 0
+		}
+		screen.FillRect(x, float32(chart.Max.Y)-height, barWidth-gap, height, noteColor(result, false))
+	}
+
+	// the table fills columns from top to bottom
+	tableTop := chart.Max.Y + 32
+	rows := (bounds.Max.Y - tableTop - resultRowHeight) / resultRowHeight
+	columns := (bounds.Max.X - x0) / resultColumnWidth
+	if rows < 1 || columns < 1 {
+		return
+	}
+	header := "  #  note   timing   cents  accuracy"
+	for column := 0; column < columns && column*rows < len(results); column++ {
+		screen.Text(header, x0+column*resultColumnWidth, tableTop, color.Gray{Y: 160})
+	}
+	for i := range results {
+		column, row := i/rows, i%rows
+		if column >= columns {
+			more := len(results) - i
+			screen.Text(fmt.Sprintf("and %d more notes", more), x0, bounds.Max.Y-4, color.Gray{Y: 160})
+			break
+		}
+		screen.Text(resultLine(i, &results[i]), x0+column*resultColumnWidth, tableTop+(row+1)*resultRowHeight, noteColor(&results[i], false))
+	}
+}
+
+// resultLine formats a note of the results table.
+func resultLine(i int, result *practice.Result) string {
+	name := midiName(result.Note.Pitch)
+	if result.State != practice.STATE_HIT {
+		return fmt.Sprintf("%3d  %-4s   missed", i+1, name)
+	}
+	return fmt.Sprintf("%3d  %-4s %+5dms %+5.0fc  %6.0f%%", i+1, name, result.Offset.Milliseconds(), result.Cents, 100*result.Accuracy)
+}
@@ -0,0 +1,83 @@
+package visualizer
+
+import (
+	"context"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/melody"
+	"github.com/metalblueberry/bard/pkg/practice"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+func TestPractice(t *testing.T) {
+	// A4 is played throughout, so the first note is hit and the others are
+	// missed
+	m := &melody.Melody{
+		Title: "Exercise",
+		Notes: []melody.Note{
+			{Pitch: 69, Start: 0, Duration: time.Second},
+			{Pitch: 71, Start: time.Second, Duration: 500 * time.Millisecond},
+			{Pitch: 72, Start: 1500 * time.Millisecond, Duration: time.Second},
+		},
+	}
+	config := practice.DefaultConfig()
+	config.LeadIn = 500 * time.Millisecond
+	session, err := practice.Create(m, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	p, err := CreatePractice(context.Background(), tee, session, DefaultPracticeOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hop := int(tee.SampleRate() * timeline.DEFAULT_HOP.Seconds())
+	screen := canvas.CreateImage(640, 480, mplusNormalFont)
+	step := func(hops int) {
+		t.Helper()
+		for i := 0; i < hops; i++ {
+			if err := tee.Step(hop); err != nil {
+				t.Fatal(err)
+			}
+			if err := tee.AnalyzePitch(); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Update(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		screen.Fill(color.Black)
+		p.Draw(screen)
+	}
+
+	step(30)
+	results := session.Results()
+	if results[0].State != practice.STATE_HIT {
+		t.Errorf("A4 was judged %+v, expected a hit", results[0])
+	}
+	compareGolden(t, "practice.png", screen.RGBA())
+
+	step(50)
+	if !session.Finished() || !p.Results() {
+		t.Fatalf("finished %v showing results %v, expected the results at the end", session.Finished(), p.Results())
+	}
+	if _, summary := session.Last(); summary.Hits != 1 || summary.Notes != 3 {
+		t.Errorf("summary %+v, expected 1 of 3 notes hit", summary)
+	}
+	compareGolden(t, "practice_results.png", screen.RGBA())
+
+	p.Restart()
+	if p.Results() || session.Finished() || session.Summary().Judged != 0 {
+		t.Errorf("restart kept the results of the last pass")
+	}
+	if err := p.SetLoopEnd(); err == nil {
+		t.Errorf("section ending before the melody starts was accepted")
+	}
+}
@@ -0,0 +1,446 @@
+package visualizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+const (
+	// seconds of detected notes shown on the staff
+	staffSeconds = 8.0
+	// notes shorter than this in seconds are left out of the history
+	minStaffNote = 0.1
+	// lowest and highest staff positions shown, four ledger lines below the
+	// bass staff and above the treble staff
+	staffLow  = 10
+	staffHigh = 46
+	// staff positions of the bottom line of the bass staff, middle C and the
+	// bottom line of the treble staff
+	bassBottom   = 18
+	middleC      = 28
+	trebleBottom = 30
+	// font size the height of symbols is measured at
+	glyphMeasureSize = 100
+
+	DefaultKey = "C"
+)
+
+// Accidentals drawn on the staff.
+const (
+	sharp   = "♯"
+	flat    = "♭"
+	natural = "♮"
+)
+
+// keys are the major keys by their number of fifths from C, negative for
+// flats.
+var keys = []struct {
+	name   string
+	fifths int
+}{
+	{"Cb", -7}, {"Gb", -6}, {"Db", -5}, {"Ab", -4}, {"Eb", -3}, {"Bb", -2}, {"F", -1},
+	{"C", 0},
+	{"G", 1}, {"D", 2}, {"A", 3}, {"E", 4}, {"B", 5}, {"F#", 6}, {"C#", 7},
+}
+
+var (
+	// pitch class of the natural note of each letter, from C to B
+	letterPitches = [7]int{0, 2, 4, 5, 7, 9, 11}
+	// letters in the order sharps and flats are added to a key signature
+	sharpOrder = [7]int{3, 0, 4, 1, 5, 2, 6}
+	flatOrder  = [7]int{6, 2, 5, 1, 4, 0, 3}
+	// staff positions of the sharps and flats of a key signature on the
+	// treble staff, the bass staff shows them two octaves lower
+	sharpPositions = [7]int{38, 35, 39, 36, 33, 37, 34}
+	flatPositions  = [7]int{34, 37, 33, 36, 32, 35, 31}
+)
+
+// Keys returns the names of the major keys the staff can be written in, in
+// the order NextKey cycles through them.
+func Keys() []string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.name
+	}
+	return names
+}
+
+// findKey returns the index of a key by its name. H is accepted for B, as
+// the tuner names notes.
+func findKey(name string) (int, bool) {
+	if strings.HasPrefix(name, "H") || strings.HasPrefix(name, "h") {
+		name = "B" + name[1:]
+	}
+	for i, key := range keys {
+		if strings.EqualFold(key.name, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// keySignature returns the alteration in semitones the key signature applies
+// to each letter, from C to B.
+func keySignature(fifths int) [7]int {
+	var signature [7]int
+	for i := 0; i < fifths; i++ {
+		signature[sharpOrder[i]] = 1
+	}
+	for i := 0; i < -fifths; i++ {
+		signature[flatOrder[i]] = -1
+	}
+	return signature
+}
+
+// spelledNote is a MIDI note written on the staff.
+type spelledNote struct {
+	// staff position counted in letters from C0
+	position int
+	// alteration in semitones and whether it needs an accidental as the key
+	// signature does not apply it
+	alter      int
+	accidental bool
+}
+
+// spellNote writes a MIDI note in a key. Notes of the scale are written as
+// the key signature alters them, other notes prefer a natural, then sharps
+// in sharp keys and flats in flat keys.
+func spellNote(note int, fifths int) spelledNote {
+	signature := keySignature(fifths)
+	pitch := note % 12
+	alterOf := func(letter int) int {
+		alter := ((pitch-letterPitches[letter])%12 + 12) % 12
+		if alter > 6 {
+			alter -= 12
+		}
+		return alter
+	}
+
+	letter := -1
+	for l := 0; l < 7 && letter < 0; l++ {
+		if alterOf(l) == signature[l] {
+			letter = l
+		}
+	}
+	for l := 0; l < 7 && letter < 0; l++ {
+		if alterOf(l) == 0 {
+			letter = l
+		}
+	}
+	prefer := 1
+	if fifths < 0 {
+		prefer = -1
+	}
+	for l := 0; l < 7 && letter < 0; l++ {
+		if alterOf(l) == prefer {
+			letter = l
+		}
+	}
+
+	alter := alterOf(letter)
+	octave := (note-alter)/12 - 1
+	return spelledNote{
+		position:   octave*7 + letter,
+		alter:      alter,
+		accidental: alter != signature[letter],
+	}
+}
+
+// staffNote is a note detected over consecutive tuner results.
+type staffNote struct {
+	note       int
+	start, end float64
+	cents      int
+}
+
+// staffNotes groups the voiced tuner results fetched for this frame into
+// notes, oldest first.
+func (g *Game) staffNotes() []staffNote {
+	var notes []staffNote
+	var previous float64
+	for _, entry := range g.shown.Pitch {
+		if entry.Time <= 0 {
+			continue
+		}
+		if !entry.Voiced {
+			previous = 0
+			continue
+		}
+		note := midiNote(entry.Frequency)
+		if n := len(notes); n > 0 && previous > 0 && notes[n-1].note == note {
+			notes[n-1].end = entry.Time
+			notes[n-1].cents = entry.Cents
+		} else {
+			notes = append(notes, staffNote{note: note, start: entry.Time, end: entry.Time, cents: entry.Cents})
+		}
+		previous = entry.Time
+	}
+	return notes
+}
+
+// NextKey writes the staff in the key with one more sharp or one flat less.
+func (g *Game) NextKey() {
+	g.key = (g.key + 1) % len(keys)
+}
+
+// Key returns the name of the key the staff is written in.
+func (g *Game) Key() string {
+	return keys[g.key].name
+}
+
+// glyphs renders symbols of the font at the sizes the staff needs.
+type glyphs struct {
+	faces map[int]font.Face
+	// area of each symbol at glyphMeasureSize
+	bounds map[string]image.Rectangle
+}
+
+func (gl *glyphs) face(size int) font.Face {
+	if gl.faces == nil {
+		gl.faces = map[int]font.Face{}
+	}
+	face, ok := gl.faces[size]
+	if !ok {
+		var err error
+		face, err = opentype.NewFace(mplusFont, &opentype.FaceOptions{Size: float64(size), DPI: 72, Hinting: font.HintingNone})
+		if err != nil {
+			// the font was parsed already, so this cannot fail for a
+			// positive size
+			panic(err)
+		}
+		gl.faces[size] = face
+	}
+	return face
+}
+
+// measure returns the area of a symbol at glyphMeasureSize.
+func (gl *glyphs) measure(s string) image.Rectangle {
+	if gl.bounds == nil {
+		gl.bounds = map[string]image.Rectangle{}
+	}
+	r, ok := gl.bounds[s]
+	if !ok {
+		b, _ := font.BoundString(gl.face(glyphMeasureSize), s)
+		r = image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil())
+		gl.bounds[s] = r
+	}
+	return r
+}
+
+// width returns the width of a symbol scaled to a height.
+func (gl *glyphs) width(s string, height float64) float64 {
+	r := gl.measure(s)
+	if r.Dy() == 0 {
+		return 0
+	}
+	return float64(r.Dx()) * height / float64(r.Dy())
+}
+
+// draw draws a symbol scaled to a height, its top left corner at a point.
+func (gl *glyphs) draw(screen canvas.Canvas, s string, x, top, height float64, c color.Color) {
+	r := gl.measure(s)
+	size := int(math.Round(height * glyphMeasureSize / float64(r.Dy())))
+	if r.Dy() == 0 || size < 1 {
+		return
+	}
+	img, _ := textImage(gl.face(size), s, c)
+	x0, y0 := int(math.Round(x)), int(math.Round(top))
+	screen.DrawImage(img, image.Rect(x0, y0, x0+int(math.Round(gl.width(s, height))), y0+int(math.Round(height))))
+}
+
+// smoothCurve returns a Catmull-Rom spline through control points given in
+// staff spaces, with y upwards, as screen points around an origin.
+func smoothCurve(control [][2]float64, ox, oy, spacing float64) []float32 {
+	const steps = 8
+	at := func(i int) [2]float64 {
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(control) {
+			i = len(control) - 1
+		}
+		return control[i]
+	}
+	var points []float32
+	for i := 0; i < len(control)-1; i++ {
+		p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+		for step := 0; step < steps; step++ {
+			t := float64(step) / steps
+			var p [2]float64
+			for k := range p {
+				p[k] = 0.5 * (2*p1[k] + (-p0[k]+p2[k])*t +
+					(2*p0[k]-5*p1[k]+4*p2[k]-p3[k])*t*t +
+					(-p0[k]+3*p1[k]-3*p2[k]+p3[k])*t*t*t)
+			}
+			points = append(points, float32(ox+p[0]*spacing), float32(oy-p[1]*spacing))
+		}
+	}
+	last := control[len(control)-1]
+	return append(points, float32(ox+last[0]*spacing), float32(oy-last[1]*spacing))
+}
+
+// dot draws a filled circle.
+func dot(screen canvas.Canvas, cx, cy, radius float64, c color.Color) {
+	ellipse(screen, cx, cy, radius, radius, c)
+}
+
+// ellipse draws a filled ellipse row by row.
+func ellipse(screen canvas.Canvas, cx, cy, a, b float64, c color.Color) {
+	for dy := -b; dy <= b; dy++ {
+		half := a * math.Sqrt(1-(dy/b)*(dy/b))
+		screen.FillRect(float32(cx-half), float32(cy+dy), float32(2*half), 1, c)
+	}
+}
+
+// The clefs are drawn as curves, as the font has no glyphs for them. Their
+// control points are in staff spaces from the line they mark, the G line
+// for the treble clef and the F line for the bass clef.
+var (
+	trebleClefCurve = [][2]float64{
+		{0.15, -2.3}, {-0.2, -2.5}, {-0.45, -2.2}, {-0.2, -1.9}, {0.15, -2.1}, {0.35, -2.6},
+		{0.3, -1}, {0.1, 1}, {-0.1, 2.5}, {0.1, 3.6}, {0.45, 4.1}, {0.55, 3.5}, {0.3, 2.8},
+		{-0.3, 2}, {-0.75, 1.2}, {-0.9, 0.3}, {-0.7, -0.5}, {-0.2, -0.9}, {0.4, -0.9},
+		{0.8, -0.5}, {0.85, 0}, {0.6, 0.45}, {0.15, 0.5}, {-0.2, 0.2}, {-0.15, -0.2},
+	}
+	bassClefCurve = [][2]float64{
+		{-0.55, 0}, {-0.5, 0.6}, {0, 1}, {0.6, 0.9}, {0.95, 0.3}, {0.9, -0.6},
+		{0.5, -1.4}, {-0.2, -2.1}, {-0.7, -2.4},
+	}
+)
+
+// drawClefs draws the treble and bass clef with their centres at x.
+func drawClefs(screen canvas.Canvas, x float64, y func(int) float64, spacing float64, c color.Color) {
+	width := float32(math.Max(1.5, spacing/7))
+	screen.Polyline(smoothCurve(trebleClefCurve, x, y(trebleBottom+2), spacing), width, c)
+	screen.Polyline(smoothCurve(bassClefCurve, x, y(bassBottom+6), spacing), width, c)
+	dot(screen, x-0.4*spacing, y(bassBottom+6), 0.25*spacing, c)
+	dot(screen, x+1.3*spacing, y(bassBottom+6)-spacing/2, 0.13*spacing, c)
+	dot(screen, x+1.3*spacing, y(bassBottom+6)+spacing/2, 0.13*spacing, c)
+}
+
+// drawStaff draws the notes detected over the last seconds on a grand staff
+// in the chosen key, the present at the right edge. The sounding note is
+// coloured by its deviation in cents like in the piano roll.
+func (g *Game) drawStaff(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	// distance between staff lines, a position is half of it
+	spacing := float64(bounds.Dy()) / (float64(staffHigh-staffLow)/2 + 2)
+	y := func(position int) float64 {
+		return float64(bounds.Max.Y) - spacing - float64(position-staffLow)*spacing/2
+	}
+	ink := color.Gray{Y: 220}
+	left := float64(bounds.Min.X) + spacing
+	right := float64(bounds.Max.X) - spacing
+
+	for i := 0; i < 5; i++ {
+		for _, bottom := range []int{bassBottom, trebleBottom} {
+			screen.FillRect(float32(left), float32(y(bottom+2*i)), float32(right-left), 1, ink)
+		}
+	}
+	screen.FillRect(float32(left), float32(y(trebleBottom+8)), 2, float32(y(bassBottom)-y(trebleBottom+8))+1, ink)
+
+	drawClefs(screen, left+1.5*spacing, y, spacing, ink)
+	x := left + 3.5*spacing
+
+	fifths := keys[g.key].fifths
+	for i := 0; i < fifths || i < -fifths; i++ {
+		for _, offset := range []int{0, -14} {
+			if fifths > 0 {
+				g.drawAccidental(screen, 1, x, y(sharpPositions[i]+offset), spacing, ink)
+			} else {
+				g.drawAccidental(screen, -1, x, y(flatPositions[i]+offset), spacing, ink)
+			}
+		}
+		x += spacing
+	}
+	screen.Text(fmt.Sprintf("%s major", keys[g.key].name), int(left), bounds.Min.Y+14, color.Gray{Y: 160})
+
+	notes := g.staffNotes()
+	latest := g.latestPitch()
+	if latest == nil {
+		return
+	}
+	history := x + spacing
+	width := right - history - 2*spacing
+	for i, note := range notes {
+		current := i == len(notes)-1 && latest.Voiced && note.end == latest.Time
+		age := latest.Time - note.start
+		if age >= staffSeconds || (!current && note.end-note.start < minStaffNote) {
+			continue
+		}
+		spelled := spellNote(note.note, fifths)
+		if spelled.position < staffLow || spelled.position > staffHigh {
+			continue
+		}
+		c := color.Color(ink)
+		if current {
+			c = centsColor(note.cents)
+		}
+		head := history + 1.5*spacing + (1-age/staffSeconds)*width
+		g.drawNote(screen, spelled, head, y, spacing, c)
+
+		// a faint line follows the note while it sounds
+		end := head + (note.end-note.start)/staffSeconds*width
+		faint := color.NRGBAModel.Convert(c).(color.NRGBA)
+		faint.A = 96
+		screen.FillRect(float32(head+spacing/2), float32(y(spelled.position))-1, float32(end-head-spacing/2), 2, faint)
+	}
+}
+
+// drawNote draws a note head with its accidental and the ledger lines it
+// needs outside of the staves.
+func (g *Game) drawNote(screen canvas.Canvas, note spelledNote, x float64, y func(int) float64, spacing float64, c color.Color) {
+	var ledgers []int
+	for p := trebleBottom + 10; p <= note.position; p += 2 {
+		ledgers = append(ledgers, p)
+	}
+	for p := bassBottom - 2; p >= note.position; p -= 2 {
+		ledgers = append(ledgers, p)
+	}
+	if note.position == middleC {
+		ledgers = append(ledgers, middleC)
+	}
+	for _, p := range ledgers {
+		screen.FillRect(float32(x-spacing), float32(y(p)), float32(2*spacing), 1, color.Gray{Y: 220})
+	}
+
+	cy := y(note.position)
+	a := 0.65 * spacing
+	ellipse(screen, x, cy, a, 0.45*spacing, c)
+
+	if note.accidental {
+		g.drawAccidental(screen, note.alter, x-a-0.3*spacing-g.accidentalWidth(note.alter, spacing), cy, spacing, c)
+	}
+}
+
+// accidental returns the symbol of an alteration, the height it is drawn
+// at and how far above the note its top is, in staff spaces. The bowl of a
+// flat sits on the note, sharps and naturals are centred on it.
+func accidental(alter int) (string, float64, float64) {
+	switch {
+	case alter > 0:
+		return sharp, 2.8, 1.4
+	case alter < 0:
+		return flat, 2.3, 1.8
+	}
+	return natural, 2.8, 1.4
+}
+
+// accidentalWidth returns the width of the accidental of an alteration.
+func (g *Game) accidentalWidth(alter int, spacing float64) float64 {
+	symbol, height, _ := accidental(alter)
+	return g.glyphs.width(symbol, height*spacing)
+}
+
+// drawAccidental draws a sharp, flat or natural for a note at a height.
+func (g *Game) drawAccidental(screen canvas.Canvas, alter int, x, cy, spacing float64, c color.Color) {
+	symbol, height, above := accidental(alter)
+	g.glyphs.draw(screen, symbol, x, cy-above*spacing, height*spacing, c)
+}
@@ -0,0 +1,51 @@
+package visualizer
+
+import "testing"
+
+func TestSpellNote(t *testing.T) {
+	tests := []struct {
+		note       int
+		key        string
+		position   int
+		alter      int
+		accidental bool
+	}{
+		// middle C and the A above it
+		{60, "C", 28, 0, false},
+		{69, "C", 33, 0, false},
+		// black keys take sharps in C and sharp keys, flats in flat keys
+		{70, "C", 33, 1, true},
+		{70, "F", 34, -1, false},
+		{66, "G", 31, 1, false},
+		{66, "Bb", 32, -1, true},
+		// naturals cancel the key signature
+		{65, "G", 31, 0, true},
+		{71, "F", 34, 0, true},
+		// notes of the scale crossing the octave keep their letter
+		{65, "F#", 30, 1, false},
+		{59, "Cb", 28, -1, false},
+		{60, "C#", 27, 1, false},
+	}
+	for _, test := range tests {
+		key, ok := findKey(test.key)
+		if !ok {
+			t.Fatalf("key %s not found", test.key)
+		}
+		got := spellNote(test.note, keys[key].fifths)
+		want := spelledNote{position: test.position, alter: test.alter, accidental: test.accidental}
+		if got != want {
+			t.Errorf("note %d in %s: got %+v, expected %+v", test.note, test.key, got, want)
+		}
+	}
+}
+
+func TestFindKey(t *testing.T) {
+	for _, name := range []string{"H", "b", "Bb", "f#"} {
+		if _, ok := findKey(name); !ok {
+			t.Errorf("key %s not found", name)
+		}
+	}
+	if _, ok := findKey("X"); ok {
+		t.Error("unknown key X was found")
+	}
+}
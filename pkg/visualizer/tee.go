@@ -0,0 +1,282 @@
+package visualizer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/circular"
+	"github.com/metalblueberry/bard/pkg/denoise"
+	"github.com/metalblueberry/bard/pkg/session"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+// DefaultBufferLength is the number of samples kept for analysis by default,
+// an eighth of a second at 44.1 kHz.
+const DefaultBufferLength = 44100 / 8
+
+// Tee feeds the audio of a source into the analysis buffer while passing it
+// on to an optional sink.
+type Tee struct {
+	source audio.Source
+	// optional monitor output, nil when audio is only analyzed
+	sink audio.Sink
+
+	circularBuffer *circular.Buffer[float64]
+	learner        *denoise.Learner
+	lock           sync.Mutex
+	mono           []float64
+	stepBuff       []float32
+	// gain of the analyzed audio in dB and the factor it amplifies by, the
+	// monitor output and recordings are not affected
+	gain   float64
+	factor float64
+
+	// session being recorded, nil when not recording. It has its own lock
+	// so disk writes never block the display. The frames of the session are
+	// the results of the pitch tracker, moved by the time of the stream the
+	// recording started at, which is negative until audio was recorded.
+	recorder    *session.Recorder
+	recordStart float64
+	recordLock  sync.Mutex
+
+	pitch *pitchTracker
+}
+
+// CreateTee creates a tee reading from source, sink may be nil.
+func CreateTee(source audio.Source, sink audio.Sink) *Tee {
+	e := &Tee{
+		source:         source,
+		sink:           sink,
+		circularBuffer: circular.CreateBuffer[float64](DefaultBufferLength),
+		factor:         1,
+		pitch:          newPitchTracker(source.Format().SampleRate),
+	}
+	e.pitch.listener = e.recordFrame
+	return e
+}
+
+// Run copies audio from the source into the buffer until the source ends.
+func (e *Tee) Run(ctx context.Context) error {
+	return audio.Pump(ctx, e.source, e.sink, 0, e.processAudio)
+}
+
+// Step reads a number of frames from the source and passes them on like
+// Run does. It returns io.EOF once the source has ended.
+func (e *Tee) Step(frames int) error {
+	samples := e.source.Format().Samples(frames)
+	if cap(e.stepBuff) < samples {
+		e.stepBuff = make([]float32, samples)
+	}
+	block := e.stepBuff[:samples]
+	n, err := audio.ReadFull(e.source, block)
+	if n > 0 {
+		e.processAudio(block[:n])
+		if e.sink != nil {
+			if _, err := e.sink.Write(block[:n]); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// SampleRate returns the sample rate of the source.
+func (e *Tee) SampleRate() float64 {
+	return e.source.Format().SampleRate
+}
+
+func (e *Tee) processAudio(block []float32) {
+	e.mono = audio.Downmix(block, e.source.Format().Channels, e.mono[:0])
+
+	e.lock.Lock()
+	for i := range e.mono {
+		e.mono[i] *= e.factor
+		e.circularBuffer.Enqueue(e.mono[i])
+	}
+	if e.learner != nil {
+		e.learner.Process(e.mono)
+	}
+	e.lock.Unlock()
+
+	end := e.pitch.process(e.mono)
+
+	// only the audio is written here, the frames follow from the analysis
+	e.recordLock.Lock()
+	if e.recorder != nil {
+		if e.recordStart < 0 {
+			e.recordStart = end - float64(len(e.mono))/e.SampleRate()
+		}
+		if err := e.recorder.Write(block); err != nil {
+			log.Printf("recording stopped: %s", err)
+			e.recorder.Close()
+			e.recorder = nil
+		}
+	}
+	e.recordLock.Unlock()
+}
+
+// recordFrame writes a result of the pitch tracker to the session being
+// recorded, if the audio it analyzed was recorded.
+func (e *Tee) recordFrame(entry timeline.Entry) {
+	e.recordLock.Lock()
+	defer e.recordLock.Unlock()
+	if e.recorder == nil || e.recordStart < 0 || entry.Time <= e.recordStart {
+		return
+	}
+	entry.Time -= e.recordStart
+	if err := e.recorder.WriteFrame(entry); err != nil {
+		log.Printf("recording stopped: %s", err)
+		e.recorder.Close()
+		e.recorder = nil
+	}
+}
+
+// RunPitch analyzes the pitch of the audio with the tuner once per hop on
+// the calling goroutine until the context is cancelled.
+func (e *Tee) RunPitch(ctx context.Context) {
+	e.pitch.run(ctx)
+}
+
+// AnalyzePitch analyzes the pitch of the audio streamed so far once, for
+// callers which drive the analysis themselves instead of using RunPitch.
+func (e *Tee) AnalyzePitch() error {
+	return e.pitch.analyze()
+}
+
+// Pitch copies the recent results of the tuner into out, oldest first.
+// Results not produced yet have a zero time.
+func (e *Tee) Pitch(out []timeline.Entry) []timeline.Entry {
+	return e.pitch.results(out)
+}
+
+// StartRecording starts recording a session into dir, the audio goes to a
+// wave file and the tuner frames to a JSON lines file next to it.
+func (e *Tee) StartRecording(dir string) (*session.Recorder, error) {
+	e.recordLock.Lock()
+	defer e.recordLock.Unlock()
+	if e.recorder != nil {
+		return e.recorder, nil
+	}
+	recorder, err := session.CreateRecorder(session.Path(dir, time.Now()), e.source.Format())
+	if err != nil {
+		return nil, err
+	}
+	e.recorder = recorder
+	e.recordStart = -1
+	return recorder, nil
+}
+
+// StopRecording completes the session being recorded, if any.
+func (e *Tee) StopRecording() error {
+	e.recordLock.Lock()
+	defer e.recordLock.Unlock()
+	if e.recorder == nil {
+		return nil
+	}
+	err := e.recorder.Close()
+	e.recorder = nil
+	return err
+}
+
+// Recorder returns the session being recorded, or nil.
+func (e *Tee) Recorder() *session.Recorder {
+	e.recordLock.Lock()
+	defer e.recordLock.Unlock()
+	return e.recorder
+}
+
+// Calibrate learns the spectrum of the input while it is kept quiet.
+func (e *Tee) Calibrate(duration time.Duration) (*denoise.Profile, error) {
+	learner, err := denoise.CreateLearner(denoise.DEFAULT_FRAME_SIZE, e.SampleRate())
+	if err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	e.learner = learner
+	e.lock.Unlock()
+
+	time.Sleep(duration)
+
+	e.lock.Lock()
+	e.learner = nil
+	e.lock.Unlock()
+	return learner.Profile()
+}
+
+// Reset clears the buffered audio, for example after seeking.
+func (e *Tee) Reset() {
+	e.lock.Lock()
+	e.circularBuffer = circular.CreateBuffer[float64](e.circularBuffer.Length())
+	e.lock.Unlock()
+
+	e.pitch.reset()
+}
+
+// CopyBuffer copies the most recent audio into out, resizing it if needed.
+func (e *Tee) CopyBuffer(out []float64) []float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(out) != e.circularBuffer.Length() {
+		out = make([]float64, e.circularBuffer.Length())
+	}
+	e.circularBuffer.Retrieve(out)
+	return out
+}
+
+// BufferLength returns the number of samples kept for analysis.
+func (e *Tee) BufferLength() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.circularBuffer.Length()
+}
+
+// SetBufferLength changes the number of samples kept for analysis. The
+// buffered audio is cleared.
+func (e *Tee) SetBufferLength(length int) error {
+	if length <= 0 {
+		return fmt.Errorf("buffer length must be positive, got %d", length)
+	}
+	e.lock.Lock()
+	e.circularBuffer = circular.CreateBuffer[float64](length)
+	e.lock.Unlock()
+	return nil
+}
+
+// Gain returns the gain of the analyzed audio in dB.
+func (e *Tee) Gain() float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.gain
+}
+
+// SetGain amplifies the analyzed audio by a gain in dB. The monitor output
+// and recordings keep the level of the source.
+func (e *Tee) SetGain(gain float64) {
+	e.lock.Lock()
+	e.gain = gain
+	e.factor = math.Pow(10, gain/20)
+	e.lock.Unlock()
+}
+
+// Latency returns the input latency reported by the driver of the source,
+// zero if it reports none.
+func (e *Tee) Latency() time.Duration {
+	if source, ok := e.source.(interface{ Latency() time.Duration }); ok {
+		return source.Latency()
+	}
+	return 0
+}
+
+// Close completes any recording and closes the source.
+func (e *Tee) Close() error {
+	errRecord := e.StopRecording()
+	if err := e.source.Close(); err != nil {
+		return err
+	}
+	return errRecord
+}
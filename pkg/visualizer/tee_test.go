@@ -0,0 +1,57 @@
+package visualizer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/session"
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+func TestRecording(t *testing.T) {
+	sine := audio.CreateSine(audio.Format{SampleRate: 44100, Channels: 1}, 0, 440, 0.1)
+	tee := CreateTee(sine, nil)
+	defer tee.Close()
+	hop := int(tee.SampleRate() * timeline.DEFAULT_HOP.Seconds())
+	step := func(hops int) {
+		t.Helper()
+		for i := 0; i < hops; i++ {
+			if err := tee.Step(hop); err != nil {
+				t.Fatal(err)
+			}
+			if err := tee.AnalyzePitch(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the recording starts after a second of audio, its frames are the
+	// results of the pitch tracker timed from the start of the recording
+	step(20)
+	recorder, err := tee.StartRecording(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	step(10)
+	if err := tee.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := session.ReadFrames(recorder.FramesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 10 {
+		t.Fatalf("recorded %d frames, expected 10", len(frames))
+	}
+	for i, frame := range frames {
+		want := float64(i+1) * timeline.DEFAULT_HOP.Seconds()
+		if math.Abs(frame.Time-want) > 1e-6 || !frame.Voiced || frame.Note != "A4" {
+			t.Errorf("frame %d is %+v, expected A4 at %.2f s", i, frame.Entry, want)
+		}
+	}
+	if math.Abs(recorder.Seconds()-0.5) > 1e-6 {
+		t.Errorf("recorded %.3f s, expected 0.5 s", recorder.Seconds())
+	}
+}
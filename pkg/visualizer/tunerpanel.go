@@ -0,0 +1,219 @@
+package visualizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/timeline"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// deviation in cents at the ends of the needle scale
+	needleRange = 50
+	// deviation in cents considered in tune
+	inTuneCents = 5
+	// angle of the needle at the ends of the scale, from upright
+	needleAngle = math.Pi / 3
+	// seconds a voiced result is still shown after the signal stops
+	tunerHold = 0.5
+)
+
+// latestPitch returns the newest tuner result of the snapshot drawn, nil if
+// there is none yet.
+func (g *Game) latestPitch() *timeline.Entry {
+	pitch := g.shown.Pitch
+	for i := len(pitch) - 1; i >= 0; i-- {
+		if pitch[i].Time > 0 {
+			return &pitch[i]
+		}
+	}
+	return nil
+}
+
+// splitNote splits a note name of the tuner such as C#4 into the name and
+// the octave.
+func splitNote(note string) (string, string) {
+	i := strings.IndexAny(note, "0123456789-")
+	if i < 0 {
+		return note, ""
+	}
+	return note[:i], note[i:]
+}
+
+// textImage renders text into an image just large enough for it, so it can
+// be drawn or scaled up with DrawImage. The returned rectangle is the area of
+// the text relative to its origin on the baseline.
+func textImage(face font.Face, s string, c color.Color) (*image.RGBA, image.Rectangle) {
+	bounds, _ := font.BoundString(face, s)
+	r := image.Rect(bounds.Min.X.Floor(), bounds.Min.Y.Floor(), bounds.Max.X.Ceil(), bounds.Max.Y.Ceil())
+	img := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(-r.Min.X, -r.Min.Y),
+	}
+	d.DrawString(s)
+	return img, r
+}
+
+// drawLargeText draws text scaled by a whole factor to fit into an area,
+// aligned to its bottom left corner, and returns the area covered.
+func drawLargeText(screen canvas.Canvas, s string, area image.Rectangle, c color.Color) image.Rectangle {
+	img, _ := textImage(Font(), s, c)
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return image.Rectangle{Min: area.Min, Max: area.Min}
+	}
+	scale := area.Dx() / size.X
+	if s := area.Dy() / size.Y; s < scale {
+		scale = s
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	dst := image.Rect(area.Min.X, area.Max.Y-size.Y*scale, area.Min.X+size.X*scale, area.Max.Y)
+	screen.DrawImage(img, dst)
+	return dst
+}
+
+// drawTuner draws the newest tuner result: the note with its octave and
+// frequency, a needle showing its deviation in cents over a scale with the
+// in tune zone, and the confidence of the detection.
+func (g *Game) drawTuner(screen canvas.Canvas) {
+	bounds := screen.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	noteArea := image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+width/4, bounds.Max.Y)
+	gaugeArea := image.Rect(noteArea.Max.X, bounds.Min.Y, bounds.Max.X-width/8, bounds.Max.Y)
+	confidenceArea := image.Rect(gaugeArea.Max.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+
+	// the last voiced result is held briefly so short gaps do not flicker,
+	// the needle rests in the centre without one
+	var entry *timeline.Entry
+	if latest := g.latestPitch(); latest != nil {
+		for i := len(g.shown.Pitch) - 1; i >= 0 && g.shown.Pitch[i].Time > 0; i-- {
+			if latest.Time-g.shown.Pitch[i].Time > tunerHold {
+				break
+			}
+			if g.shown.Pitch[i].Voiced {
+				entry = &g.shown.Pitch[i]
+				break
+			}
+		}
+	}
+
+	g.drawTunerNote(screen, noteArea, entry)
+	g.drawNeedle(screen, gaugeArea, entry)
+
+	confidence := 0.0
+	if entry != nil {
+		confidence = entry.Confidence
+	}
+	margin := height / 8
+	bar := image.Rect(confidenceArea.Min.X+confidenceArea.Dx()/3, confidenceArea.Min.Y+margin,
+		confidenceArea.Max.X-confidenceArea.Dx()/3, confidenceArea.Max.Y-margin)
+	screen.FillRect(float32(bar.Min.X), float32(bar.Min.Y), float32(bar.Dx()), float32(bar.Dy()), color.Gray{Y: 40})
+	filled := float32(confidence) * float32(bar.Dy())
+	screen.FillRect(float32(bar.Min.X), float32(bar.Max.Y)-filled, float32(bar.Dx()), filled, confidenceColor(confidence))
+	screen.Text(fmt.Sprintf("%.0f%%", confidence*100), bar.Min.X, bar.Min.Y-4, color.Gray{Y: 160})
+	screen.Text("confidence", confidenceArea.Min.X+4, bar.Max.Y+14, color.Gray{Y: 160})
+}
+
+// confidenceColor returns the colour of the confidence bar, red for noise
+// turning green for a clearly periodic signal.
+func confidenceColor(confidence float64) color.NRGBA {
+	if confidence < 0 {
+		confidence = 0
+	}
+	if confidence > 1 {
+		confidence = 1
+	}
+	return color.NRGBA{
+		R: uint8(math.Round(230 - 160*confidence)),
+		G: uint8(math.Round(60 + 140*confidence)),
+		B: uint8(math.Round(50 + 40*confidence)),
+		A: 255,
+	}
+}
+
+// drawTunerNote draws the name, octave and frequency of a tuner result, or a
+// dash without one.
+func (g *Game) drawTunerNote(screen canvas.Canvas, area image.Rectangle, entry *timeline.Entry) {
+	margin := area.Dy() / 8
+	textArea := image.Rect(area.Min.X+margin, area.Min.Y+margin, area.Max.X-margin, area.Max.Y-2*margin)
+	if entry == nil {
+		drawLargeText(screen, "-", textArea, color.Gray{Y: 100})
+		return
+	}
+	c := centsColor(entry.Cents)
+	name, octave := splitNote(entry.Note)
+	nameArea := textArea
+	nameArea.Max.X = textArea.Min.X + textArea.Dx()*2/3
+	drawn := drawLargeText(screen, name, nameArea, c)
+	octaveArea := image.Rect(drawn.Max.X+2, drawn.Min.Y+drawn.Dy()/2, textArea.Max.X, drawn.Max.Y)
+	drawLargeText(screen, octave, octaveArea, c)
+
+	label := fmt.Sprintf("%.2f Hz  %+dc", entry.Frequency, entry.Cents)
+	screen.Text(label, textArea.Min.X, area.Max.Y-margin, color.White)
+}
+
+// drawNeedle draws the scale of the tuner from flat to sharp with the in tune
+// zone in the middle and a needle pointing at the deviation of a result.
+func (g *Game) drawNeedle(screen canvas.Canvas, area image.Rectangle, entry *timeline.Entry) {
+	cx := float64(area.Min.X) + float64(area.Dx())/2
+	cy := float64(area.Max.Y) - float64(area.Dy())/8
+	radius := float64(area.Dy()) * 0.75
+	if r := float64(area.Dx()) / 2 / math.Sin(needleAngle) * 0.9; r < radius {
+		radius = r
+	}
+	// point returns the position at a deviation in cents and a distance from
+	// the pivot of the needle
+	point := func(cents float64, r float64) (float32, float32) {
+		angle := cents / needleRange * needleAngle
+		return float32(cx + r*math.Sin(angle)), float32(cy - r*math.Cos(angle))
+	}
+	arc := func(from, to float64, r float64) []float32 {
+		var points []float32
+		for cents := from; cents <= to+1e-9; cents += 1 {
+			x, y := point(cents, r)
+			points = append(points, x, y)
+		}
+		return points
+	}
+
+	screen.Polyline(arc(-needleRange, needleRange, radius), 2, color.Gray{Y: 120})
+	screen.Polyline(arc(-inTuneCents, inTuneCents, radius), 8, inTuneColor)
+	for cents := -needleRange; cents <= needleRange; cents += 10 {
+		length := radius * 0.06
+		if cents%50 == 0 {
+			length *= 2
+		}
+		x0, y0 := point(float64(cents), radius-length)
+		x1, y1 := point(float64(cents), radius)
+		screen.Polyline([]float32{x0, y0, x1, y1}, 2, color.Gray{Y: 120})
+	}
+	for _, cents := range []int{-needleRange, 0, needleRange} {
+		x, y := point(float64(cents), radius*1.08)
+		label := fmt.Sprintf("%+d", cents)
+		if cents == 0 {
+			label = "0"
+		}
+		screen.Text(label, int(x)-8, int(y), color.Gray{Y: 160})
+	}
+
+	needle := color.Color(color.Gray{Y: 90})
+	cents := 0.0
+	if entry != nil {
+		cents = math.Max(-needleRange, math.Min(needleRange, float64(entry.Cents)))
+		needle = centsColor(entry.Cents)
+	}
+	x, y := point(cents, radius*0.95)
+	screen.Polyline([]float32{float32(cx), float32(cy), x, y}, 3, needle)
+	screen.FillRect(float32(cx)-4, float32(cy)-4, 8, 8, color.Gray{Y: 200})
+}
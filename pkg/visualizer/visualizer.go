@@ -0,0 +1,487 @@
+package visualizer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/denoise"
+	"github.com/metalblueberry/bard/pkg/session"
+	"github.com/metalblueberry/bard/pkg/stretch"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+const (
+	ScreenWidth  = 640 * 2
+	ScreenHeight = 480 * 2
+
+	// magnitude displayed at full height in the note bars
+	noteBarScale = 0.05
+	// change of the replay speed per step
+	SpeedStep = 0.25
+	// analysis frames kept by default, one second at the default analysis rate
+	DefaultHistory = 60
+)
+
+// Panels shown by the panel view of the layout, or placed in it by name.
+const (
+	PanelBars      = "bars"
+	PanelWaterfall = "waterfall"
+	PanelTuner     = "tuner"
+	PanelStaff     = "staff"
+)
+
+// Panels returns the panels of the panel view in the order NextPanel cycles
+// through them.
+func Panels() []string {
+	return []string{PanelBars, PanelWaterfall, PanelTuner, PanelStaff}
+}
+
+// Game holds the state of the visualizer. The audio passing through the tee
+// is analyzed into snapshots independently of the display, on a goroutine of
+// its own or once per frame, and drawn on any canvas, so the same frames can
+// be shown in a window or rendered headless.
+type Game struct {
+	ctx      context.Context
+	echo     *Tee
+	analysis *analyzer
+	// snapshot drawn, replaced by the latest one on every update unless the
+	// display is frozen
+	shown *Snapshot
+
+	waterfall *waterfall
+	// arrangement of the views and the panel shown by its panel view
+	layout Layout
+	panel  string
+	// index of the key of the staff and the symbols drawn on it
+	key    int
+	glyphs glyphs
+
+	points []float32
+	// zoom of the waveform in time and amplitude
+	waveZoom float64
+	waveGain float64
+
+	// display frozen on the last analysis
+	frozen bool
+	// settings and smoothed drawing time shown over the display
+	hud      bool
+	drawTime time.Duration
+
+	// directory sessions are recorded into by ToggleRecording
+	recordDir string
+	markers   int
+
+	// transport of a replayed file and its recorded frames, nil when live
+	player *audio.Player
+	frames []session.Frame
+}
+
+// Update takes the latest snapshot of the analysis to draw, unless the
+// display is frozen. It returns the error which stopped the analysis, if
+// any.
+func (g *Game) Update() error {
+	if err := g.analysis.failed(); err != nil {
+		return err
+	}
+	if !g.frozen {
+		g.shown = g.analysis.latest()
+	}
+	return g.ctx.Err()
+}
+
+// Tee returns the tee the visualizer analyzes.
+func (g *Game) Tee() *Tee {
+	return g.echo
+}
+
+// ToggleRecording starts recording a session, or stops the one being
+// recorded.
+func (g *Game) ToggleRecording() error {
+	if g.echo.Recorder() != nil {
+		if err := g.echo.StopRecording(); err != nil {
+			return err
+		}
+		log.Println("recording stopped")
+		return nil
+	}
+	recorder, err := g.echo.StartRecording(g.recordDir)
+	if err != nil {
+		return err
+	}
+	log.Printf("recording to %s", recorder.AudioPath())
+	return nil
+}
+
+// Mark adds a numbered marker to the session being recorded, if any.
+func (g *Game) Mark() {
+	if recorder := g.echo.Recorder(); recorder != nil {
+		g.markers++
+		recorder.Mark(fmt.Sprintf("marker %d", g.markers))
+	}
+}
+
+// Player returns the transport of a replayed file, nil for live input.
+func (g *Game) Player() *audio.Player {
+	return g.player
+}
+
+// ChangeSpeed changes the replay speed within the supported range.
+func (g *Game) ChangeSpeed(delta float64) {
+	if g.player == nil {
+		return
+	}
+	speed := g.player.Speed() + delta
+	if speed < stretch.MIN_SPEED {
+		speed = stretch.MIN_SPEED
+	} else if speed > stretch.MAX_SPEED {
+		speed = stretch.MAX_SPEED
+	}
+	g.player.SetSpeed(speed)
+}
+
+// Seek moves the replay and clears what was shown of the old position.
+func (g *Game) Seek(seconds float64) error {
+	if g.player == nil {
+		return nil
+	}
+	if err := g.player.Seek(seconds); err != nil {
+		return err
+	}
+	g.echo.Reset()
+	g.analysis.clearHistory()
+	return nil
+}
+
+// ProgressBar returns the area of the replay progress bar on a screen.
+func ProgressBar(screen image.Rectangle) image.Rectangle {
+	return image.Rect(screen.Min.X+10, screen.Max.Y-20, screen.Max.X-10, screen.Max.Y-10)
+}
+
+// drawTransport shows the replay position, speed and the recorded tuner
+// frame at the position.
+func (g *Game) drawTransport(screen canvas.Canvas) {
+	if g.player == nil {
+		return
+	}
+	position := g.player.Position()
+	duration := g.player.Duration()
+
+	bar := ProgressBar(screen.Bounds())
+	screen.FillRect(float32(bar.Min.X), float32(bar.Min.Y), float32(bar.Dx()), float32(bar.Dy()), color.Gray{Y: 60})
+	if duration > 0 {
+		done := float32(float64(bar.Dx()) * position / duration)
+		screen.FillRect(float32(bar.Min.X), float32(bar.Min.Y), done, float32(bar.Dy()), color.Gray{Y: 200})
+	}
+
+	state := "playing"
+	if g.player.Paused() {
+		state = "paused"
+	}
+	label := fmt.Sprintf("%s %.1f / %.1fs  %.2fx", state, position, duration, g.player.Speed())
+	if frame := session.FrameAt(g.frames, position); frame != nil && frame.Voiced {
+		label += fmt.Sprintf("  recorded %s %+dc (%.0f%%)", frame.Note, frame.Cents, 100*frame.Confidence)
+	}
+	screen.Text(label, bar.Min.X, bar.Min.Y-6, color.White)
+}
+
+// drawRecording shows the length of the session being recorded.
+func (g *Game) drawRecording(screen canvas.Canvas) {
+	recorder := g.echo.Recorder()
+	if recorder == nil {
+		return
+	}
+	label := fmt.Sprintf("REC %.1fs", recorder.Seconds())
+	screen.Text(label, screen.Bounds().Dx()-80, 48, color.NRGBA{R: 255, A: 255})
+}
+
+// Draw draws the current state of the visualizer on the screen, the views
+// arranged by the layout: by default the piano roll and the waveform in the
+// upper half, the note bars, the waterfall, the tuner or the staff in the
+// lower half. While frozen the same snapshot is drawn again.
+func (g *Game) Draw(screen canvas.Canvas) {
+	start := time.Now()
+	for _, area := range g.layout.Arrange(screen.Bounds()) {
+		g.drawView(area.View, screen.Sub(area.Bounds))
+	}
+	g.drawRecording(screen)
+	g.drawFrozen(screen)
+	g.drawTransport(screen)
+	measure(&g.drawTime, start)
+	g.drawHUD(screen)
+}
+
+// drawView draws a view of the layout in its pane.
+func (g *Game) drawView(view string, screen canvas.Canvas) {
+	if view == ViewPanel {
+		view = g.panel
+	}
+	switch view {
+	case ViewRoll:
+		g.drawPianoRoll(screen)
+	case ViewWave:
+		g.drawWaveform(screen)
+		g.drawThreshold(screen)
+	case PanelWaterfall:
+		g.drawWaterfall(screen)
+	case PanelTuner:
+		g.drawTuner(screen)
+	case PanelStaff:
+		g.drawStaff(screen)
+	default:
+		g.drawBars(screen)
+	}
+}
+
+// drawBars draws the level of each note over the threshold it must exceed
+// to be played.
+func (g *Game) drawBars(screen canvas.Canvas) {
+	s := g.shown
+	notes := make([]float64, 0, len(s.Notes))
+	thresholds := make([]float64, 0, len(s.Notes))
+	for i, note := range s.Notes {
+		notes = append(notes, note.Value)
+		thresholds = append(thresholds, s.Thresholds[s.mapping.noteBins[i]])
+	}
+	g.drawWave(screen, thresholds, noteBarScale, 1, thresholdColor)
+	g.drawWave(screen, notes, noteBarScale, 1, color.White)
+	bounds := screen.Bounds()
+	screen.Text(s.NoteSet().String(), bounds.Min.X+4, bounds.Min.Y+14, color.Gray{Y: 160})
+}
+
+// fontSize is the size of the text of the visualizer in points.
+const fontSize = 12
+
+var (
+	mplusFont       *opentype.Font
+	mplusNormalFont font.Face
+	// faces of the text at the scales of displays drawn on so far
+	scaledFonts = map[float64]font.Face{}
+)
+
+func init() {
+	var err error
+	mplusFont, err = opentype.Parse(fonts.MPlus1pRegular_ttf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mplusNormalFont = scaledFont(1)
+}
+
+// scaledFont creates the face of the text at a scale.
+func scaledFont(scale float64) font.Face {
+	face, err := opentype.NewFace(mplusFont, &opentype.FaceOptions{
+		Size:    fontSize,
+		DPI:     72 * scale,
+		Hinting: font.HintingVertical,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// Font returns the font face text of the visualizer is drawn with.
+func Font() font.Face {
+	return mplusNormalFont
+}
+
+// ScaledFont returns the font face of the text scaled for a display with
+// more pixels per point, to draw on a canvas.Scaled of the same scale. It
+// must be called from the goroutine drawing.
+func ScaledFont(scale float64) font.Face {
+	if scale == 1 {
+		return mplusNormalFont
+	}
+	face, ok := scaledFonts[scale]
+	if !ok {
+		face = scaledFont(scale)
+		scaledFonts[scale] = face
+	}
+	return face
+}
+
+// draws a wave in the given section of the image
+func (g *Game) drawWave(screen canvas.Canvas, data []float64, size float64, step int, c color.Color) {
+	left := screen.Bounds().Min.X
+	mid := screen.Bounds().Min.Y + screen.Bounds().Dy()/2
+	width := screen.Bounds().Dx()
+
+	points := append(g.points[:0], float32(left), float32(mid))
+
+	// size is drawn at the edge of the section
+	scale := float64(screen.Bounds().Dy()/2) / size
+	for i := 0; i < len(data); i = i + step {
+		y := float32((-data[i] * float64(scale)) + float64(mid))
+		points = append(points, float32(left)+float32(i*width)/float32(len(data)), y)
+	}
+
+	screen.Polyline(points, 1, c)
+	g.points = points
+}
+
+// Options configures the visualizer.
+type Options struct {
+	Title string
+	// learned room noise removed from the note bars, nil disables it
+	Noise        *denoise.Profile
+	NoiseRemoval denoise.Config
+	// noise floor notes must peak over to be played and how long it is
+	// learned for at the start, if at all
+	Floor      denoise.FloorConfig
+	LearnFloor time.Duration
+	// analyses per second of RunAnalysis, which the floor is tracked at
+	AnalysisRate float64
+	// directory sessions are recorded into, Record starts recording at once
+	RecordDir string
+	Record    bool
+	// transport of a replayed file, nil for live input, and the tuner frames
+	// recorded with it, if any
+	Player *audio.Player
+	Frames []session.Frame
+	// notes shown by the note bars, the waterfall and the piano roll
+	Notes NoteSet
+	// arrangement of the views and the panel shown by its panel view, one
+	// of Panels
+	Layout Layout
+	Panel  string
+	// called with the layout when the window is closed after it was
+	// changed, nil does not store it
+	SaveLayout func(Layout) error
+	// key the staff is written in, one of Keys
+	Key string
+	// number of analysis frames kept for the waterfall
+	History   int
+	Waterfall WaterfallOptions
+	// gain of the analyzed audio in dB and the number of samples the
+	// transform analyzes, zero keeps the buffer of the tee
+	Gain    float64
+	FFTSize int
+	// show the settings and frame times over the display
+	HUD bool
+}
+
+// DefaultOptions returns the options of the visualizer without noise removal,
+// recording into the current directory when R is pressed.
+func DefaultOptions() Options {
+	return Options{
+		Title:        "bard",
+		NoiseRemoval: denoise.DefaultConfig(),
+		Floor:        denoise.DefaultFloorConfig(),
+		AnalysisRate: DefaultAnalysisRate,
+		RecordDir:    ".",
+		Notes:        DefaultNoteSet(),
+		Layout:       DefaultLayout(),
+		Panel:        PanelBars,
+		Key:          DefaultKey,
+		History:      DefaultHistory,
+		Waterfall:    DefaultWaterfallOptions(),
+	}
+}
+
+// CreateGame creates the visualizer for the audio passing through the tee.
+// Update stops with the error of the context once it is cancelled.
+func CreateGame(ctx context.Context, tee *Tee, options Options) (*Game, error) {
+	if !(options.AnalysisRate > 0) {
+		return nil, fmt.Errorf("analysis rate must be positive, got %v", options.AnalysisRate)
+	}
+	if options.History <= 0 {
+		return nil, fmt.Errorf("history must be positive, got %d", options.History)
+	}
+	if err := options.Layout.validate(); err != nil {
+		return nil, err
+	}
+	if !validPanel(options.Panel) {
+		return nil, fmt.Errorf("unknown panel %q, expected one of %s", options.Panel, strings.Join(Panels(), ", "))
+	}
+	key, ok := findKey(options.Key)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q, expected one of %s", options.Key, strings.Join(Keys(), ", "))
+	}
+	if options.Gain < -MaxGain || options.Gain > MaxGain {
+		return nil, fmt.Errorf("gain must be between %+.0f and %+.0f dB, got %v", -MaxGain, MaxGain, options.Gain)
+	}
+	if options.FFTSize != 0 && (options.FFTSize < MinFFTSize || options.FFTSize > MaxFFTSize) {
+		return nil, fmt.Errorf("FFT size must be between %d and %d, got %d", MinFFTSize, MaxFFTSize, options.FFTSize)
+	}
+	waterfall, err := newWaterfall(options.Waterfall)
+	if err != nil {
+		return nil, err
+	}
+	g := &Game{
+		ctx:       ctx,
+		echo:      tee,
+		analysis:  newAnalyzer(tee, options),
+		waterfall: waterfall,
+		layout:    options.Layout.clone(),
+		panel:     options.Panel,
+		key:       key,
+		waveZoom:  1,
+		waveGain:  1,
+		hud:       options.HUD,
+		recordDir: options.RecordDir,
+		player:    options.Player,
+		frames:    options.Frames,
+	}
+	tee.SetGain(options.Gain)
+	if err := g.analysis.configure(options.FFTSize, options.Notes); err != nil {
+		return nil, err
+	}
+	if options.LearnFloor > 0 {
+		g.LearnFloor(options.LearnFloor)
+	}
+	g.shown = g.analysis.latest()
+	return g, nil
+}
+
+func validPanel(panel string) bool {
+	for _, p := range Panels() {
+		if p == panel {
+			return true
+		}
+	}
+	return false
+}
+
+// Panel returns the panel shown by the panel view.
+func (g *Game) Panel() string {
+	return g.panel
+}
+
+// NextPanel shows the next panel in the panel view.
+func (g *Game) NextPanel() {
+	panels := Panels()
+	for i, p := range panels {
+		if p == g.panel {
+			g.panel = panels[(i+1)%len(panels)]
+			return
+		}
+	}
+}
+
+// SetPanel shows a panel in the panel view.
+func (g *Game) SetPanel(panel string) error {
+	if !validPanel(panel) {
+		return fmt.Errorf("unknown panel %q, expected one of %s", panel, strings.Join(Panels(), ", "))
+	}
+	g.panel = panel
+	return nil
+}
+
+// TogglePanel shows a panel in the panel view, or the note bars if it is
+// already shown.
+func (g *Game) TogglePanel(panel string) {
+	if g.panel == panel {
+		g.panel = PanelBars
+	} else {
+		g.panel = panel
+	}
+}
@@ -0,0 +1,185 @@
+package visualizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/colormap"
+)
+
+// Frequency axes of the waterfall.
+const (
+	// one row per displayed note
+	AxisNotes = "notes"
+	// logarithmic frequency across every bin of the transform
+	AxisLog = "log"
+
+	// rows interpolated between two bins on the logarithmic axis
+	logRowsPerBin = 4
+	// width of the note labels left of the waterfall
+	waterfallLabelWidth = 36
+	// minimum vertical distance between note labels
+	waterfallLabelSpacing = 12
+)
+
+// WaterfallOptions configures the waterfall, which shows the history of the
+// analysis as a colour-mapped time-frequency image with the newest frame on
+// the right.
+type WaterfallOptions struct {
+	// AxisNotes or AxisLog
+	Axis string
+	// name of the colour map, see colormap.Names
+	ColorMap string
+	// magnitude shown at 0 dB and the level in dB shown as the lowest colour
+	Reference float64
+	Floor     float64
+}
+
+// DefaultWaterfallOptions returns a waterfall with a note axis, the
+// default colour map and the scale of the note bars as reference.
+func DefaultWaterfallOptions() WaterfallOptions {
+	return WaterfallOptions{
+		Axis:      AxisNotes,
+		ColorMap:  colormap.DEFAULT,
+		Reference: noteBarScale,
+		Floor:     -48,
+	}
+}
+
+// waterfall holds the image of the waterfall between frames.
+type waterfall struct {
+	options WaterfallOptions
+	cmap    *colormap.Map
+	img     *image.RGBA
+}
+
+func newWaterfall(options WaterfallOptions) (*waterfall, error) {
+	if options.Axis != AxisNotes && options.Axis != AxisLog {
+		return nil, fmt.Errorf("unknown waterfall axis %q, expected %q or %q", options.Axis, AxisNotes, AxisLog)
+	}
+	if !(options.Reference > 0) || !(options.Floor < 0) {
+		return nil, fmt.Errorf("waterfall needs a positive reference and a negative floor, got %v and %v dB", options.Reference, options.Floor)
+	}
+	cmap, err := colormap.Get(options.ColorMap)
+	if err != nil {
+		return nil, err
+	}
+	return &waterfall{options: options, cmap: cmap}, nil
+}
+
+// intensity maps a magnitude on the dB scale to the range of the colour map.
+func (w *waterfall) intensity(value float64) float64 {
+	if !(value > 0) {
+		return 0
+	}
+	db := 20 * math.Log10(value/w.options.Reference)
+	return (db - w.options.Floor) / -w.options.Floor
+}
+
+// waterfallRows returns the number of rows of the frequency axis.
+func (g *Game) waterfallRows() int {
+	m := g.shown.mapping
+	if g.waterfall.options.Axis == AxisLog {
+		return (len(m.transform.Bins())-1)*logRowsPerBin + 1
+	}
+	return len(m.noteBins)
+}
+
+// waterfallValue returns the magnitude of a row of the frequency axis, row
+// zero being the lowest frequency.
+func (g *Game) waterfallValue(spectrum []float64, row int) float64 {
+	if g.waterfall.options.Axis == AxisLog {
+		bin := row / logRowsPerBin
+		frac := float64(row%logRowsPerBin) / logRowsPerBin
+		if bin+1 >= len(spectrum) {
+			return spectrum[len(spectrum)-1]
+		}
+		return (1-frac)*spectrum[bin] + frac*spectrum[bin+1]
+	}
+	return spectrum[g.shown.mapping.noteBins[row]]
+}
+
+// waterfallRow returns the row of the frequency axis a note is shown in.
+func (g *Game) waterfallRow(note int) float64 {
+	if g.waterfall.options.Axis == AxisLog {
+		return float64(g.shown.mapping.noteBins[note] * logRowsPerBin)
+	}
+	return float64(note)
+}
+
+// drawWaterfall draws the history of the analysis with time scrolling to the
+// left and the frequency rising upwards.
+func (g *Game) drawWaterfall(screen canvas.Canvas) {
+	w := g.waterfall
+	history := g.shown.History
+	columns := len(history)
+	rows := g.waterfallRows()
+	if w.img == nil || w.img.Bounds().Dx() != columns || w.img.Bounds().Dy() != rows {
+		w.img = image.NewRGBA(image.Rect(0, 0, columns, rows))
+	}
+
+	for x := 0; x < columns; x++ {
+		spectrum := history[x]
+		for row := 0; row < rows; row++ {
+			value := 0.0
+			if len(spectrum) > 0 {
+				value = g.waterfallValue(spectrum, row)
+			}
+			w.img.SetRGBA(x, rows-1-row, w.cmap.At(w.intensity(value)))
+		}
+	}
+
+	bounds := screen.Bounds()
+	area := image.Rect(bounds.Min.X+waterfallLabelWidth, bounds.Min.Y+4, bounds.Max.X, bounds.Max.Y-4)
+	screen.DrawImage(w.img, area)
+
+	// label the notes at the centre of their rows, skipping labels which
+	// would overlap the one below
+	rowHeight := float64(area.Dy()) / float64(rows)
+	lastY := math.Inf(1)
+	for i, note := range g.shown.mapping.notes {
+		y := float64(area.Max.Y) - (g.waterfallRow(i)+0.5)*rowHeight
+		if lastY-y < waterfallLabelSpacing {
+			continue
+		}
+		screen.Text(note.Name, bounds.Min.X+4, int(y)+4, color.White)
+		lastY = y
+	}
+
+	legend := fmt.Sprintf("%s, %.0f to 0 dB", w.cmap.Name(), w.options.Floor)
+	screen.Text(legend, area.Max.X-160, area.Min.Y+14, color.White)
+}
+
+// ToggleWaterfall switches between the waterfall and the note bars.
+func (g *Game) ToggleWaterfall() {
+	g.TogglePanel(PanelWaterfall)
+}
+
+// ToggleAxis switches the frequency axis of the waterfall between notes and
+// a logarithmic scale.
+func (g *Game) ToggleAxis() {
+	if g.waterfall.options.Axis == AxisLog {
+		g.waterfall.options.Axis = AxisNotes
+	} else {
+		g.waterfall.options.Axis = AxisLog
+	}
+}
+
+// NextColorMap switches the waterfall to the next colour map.
+func (g *Game) NextColorMap() {
+	names := colormap.Names()
+	next := names[0]
+	for i, name := range names {
+		if name == g.waterfall.cmap.Name() && i+1 < len(names) {
+			next = names[i+1]
+		}
+	}
+	cmap, err := colormap.Get(next)
+	if err == nil {
+		g.waterfall.cmap = cmap
+		g.waterfall.options.ColorMap = next
+	}
+}
@@ -0,0 +1,164 @@
+package window
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/visualizer"
+	"golang.org/x/image/font"
+)
+
+var (
+	whiteImage = ebiten.NewImage(3, 3)
+
+	// whiteSubImage is an internal sub image of whiteImage.
+	// Use whiteSubImage at DrawTriangles instead of whiteImage in order to avoid bleeding edges.
+	whiteSubImage = whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+)
+
+func init() {
+	whiteImage.Fill(color.White)
+}
+
+// drawBuffers are reused between frames to avoid allocations.
+type drawBuffers struct {
+	vertices []ebiten.Vertex
+	indices  []uint16
+	// GPU images of DrawImage by size, their pixels are replaced every time
+	images map[image.Point]*ebiten.Image
+}
+
+// screenCanvas draws on an ebiten image.
+type screenCanvas struct {
+	img     *ebiten.Image
+	face    font.Face
+	buffers *drawBuffers
+}
+
+// newScreenCanvas draws on the screen with text of a face, which must have
+// the scale the screen is drawn at.
+func newScreenCanvas(img *ebiten.Image, face font.Face) *screenCanvas {
+	return &screenCanvas{img: img, face: face, buffers: &drawBuffers{images: map[image.Point]*ebiten.Image{}}}
+}
+
+func (c *screenCanvas) Bounds() image.Rectangle {
+	return c.img.Bounds()
+}
+
+func (c *screenCanvas) Sub(r image.Rectangle) canvas.Canvas {
+	return &screenCanvas{
+		img:     c.img.SubImage(r).(*ebiten.Image),
+		face:    c.face,
+		buffers: c.buffers,
+	}
+}
+
+func (c *screenCanvas) Fill(clr color.Color) {
+	c.img.Fill(clr)
+}
+
+func (c *screenCanvas) FillRect(x, y, width, height float32, clr color.Color) {
+	vector.DrawFilledRect(c.img, x, y, width, height, clr, false)
+}
+
+func (c *screenCanvas) Polyline(points []float32, width float32, clr color.Color) {
+	if len(points) < 4 {
+		return
+	}
+	var path vector.Path
+	path.MoveTo(points[0], points[1])
+	for i := 2; i+1 < len(points); i += 2 {
+		path.LineTo(points[i], points[i+1])
+	}
+
+	op := &vector.StrokeOptions{}
+	op.Width = width
+	vs, is := path.AppendVerticesAndIndicesForStroke(c.buffers.vertices[:0], c.buffers.indices[:0], op)
+	// vertex colors are straight alpha by default
+	n := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	for i := range vs {
+		vs[i].SrcX = 1
+		vs[i].SrcY = 1
+		vs[i].ColorR = float32(n.R) / 0xff
+		vs[i].ColorG = float32(n.G) / 0xff
+		vs[i].ColorB = float32(n.B) / 0xff
+		vs[i].ColorA = float32(n.A) / 0xff
+	}
+	c.img.DrawTriangles(vs, is, whiteSubImage, &ebiten.DrawTrianglesOptions{
+		AntiAlias: false,
+	})
+	c.buffers.vertices, c.buffers.indices = vs, is
+}
+
+func (c *screenCanvas) DrawImage(img *image.RGBA, dst image.Rectangle) {
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return
+	}
+	gpu, ok := c.buffers.images[size]
+	if !ok {
+		gpu = ebiten.NewImage(size.X, size.Y)
+		c.buffers.images[size] = gpu
+	}
+	pix := img.Pix
+	if img.Stride != 4*size.X || img.Rect.Min != (image.Point{}) {
+		// WritePixels needs tightly packed pixels
+		packed := image.NewRGBA(image.Rectangle{Max: size})
+		draw.Draw(packed, packed.Bounds(), img, img.Bounds().Min, draw.Src)
+		pix = packed.Pix
+	}
+	gpu.WritePixels(pix)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(dst.Dx())/float64(size.X), float64(dst.Dy())/float64(size.Y))
+	op.GeoM.Translate(float64(dst.Min.X), float64(dst.Min.Y))
+	c.img.DrawImage(gpu, op)
+}
+
+func (c *screenCanvas) Text(s string, x, y int, clr color.Color) {
+	text.Draw(c.img, s, c.face, x, y, clr)
+}
+
+// scaledScreen is a screen drawn on at the scale of the display, whose
+// coordinates are in device independent pixels.
+type scaledScreen struct {
+	screen *screenCanvas
+	canvas canvas.Canvas
+	scale  float64
+}
+
+// canvasFor returns the canvas drawing on a screen at a scale, reusing the
+// canvas of the last frame if it matches.
+func (s *scaledScreen) canvasFor(img *ebiten.Image, scale float64) canvas.Canvas {
+	if s.screen != nil && s.screen.img == img && s.scale == scale {
+		return s.canvas
+	}
+	s.screen = newScreenCanvas(img, visualizer.ScaledFont(scale))
+	s.scale = scale
+	s.canvas = s.screen
+	if scale != 1 {
+		s.canvas = canvas.CreateScaled(s.screen, scale)
+	}
+	return s.canvas
+}
+
+// physicalSize returns the size in physical pixels of a screen of a size in
+// device independent pixels on a display of a scale.
+func physicalSize(width, height int, scale float64) (int, int) {
+	return int(math.Ceil(float64(width) * scale)), int(math.Ceil(float64(height) * scale))
+}
+
+// logicalPoint returns the point in device independent pixels of a point in
+// physical pixels on a display of a scale.
+func logicalPoint(pt image.Point, scale float64) image.Point {
+	if scale <= 0 {
+		return pt
+	}
+	return image.Pt(int(float64(pt.X)/scale), int(float64(pt.Y)/scale))
+}
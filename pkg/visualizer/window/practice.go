@@ -0,0 +1,106 @@
+package window
+
+import (
+	"context"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/metalblueberry/bard/pkg/visualizer"
+)
+
+// practiceGame adapts the practice game to the ebiten game loop.
+type practiceGame struct {
+	view   *visualizer.Practice
+	screen scaledScreen
+	// pass whose results were logged last
+	logged int
+}
+
+func (g *practiceGame) Update() error {
+	if err := g.view.Update(); err != nil {
+		return err
+	}
+	g.logPass()
+	g.handleKeys()
+	return nil
+}
+
+// logPass logs the results of every pass as it completes.
+func (g *practiceGame) logPass() {
+	_, summary := g.view.Session().Last()
+	if summary.Pass == g.logged {
+		return
+	}
+	// restarting the session starts counting the passes over
+	g.logged = summary.Pass
+	if summary.Pass == 0 {
+		return
+	}
+	log.Printf("pass %d: score %d, %d of %d notes hit, accuracy %.0f%%",
+		summary.Pass, summary.Score, summary.Hits, summary.Notes, 100*summary.Accuracy)
+}
+
+// handleKeys controls the game: space pauses, enter restarts, W toggles
+// waiting for every note, L toggles looping, the brackets start and end the
+// section at the current position, backspace practices the whole melody,
+// the up and down arrows change the speed and tab shows the results.
+// Sections which cannot be practiced are logged and the section is kept.
+func (g *practiceGame) handleKeys() {
+	var err error
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		g.view.TogglePause()
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		g.view.Restart()
+	case inpututil.IsKeyJustPressed(ebiten.KeyW):
+		g.view.ToggleWait()
+	case inpututil.IsKeyJustPressed(ebiten.KeyL):
+		g.view.ToggleLoop()
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft):
+		err = g.view.SetLoopStart()
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight):
+		err = g.view.SetLoopEnd()
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		err = g.view.ClearSection()
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		g.view.ChangeSpeed(visualizer.SpeedStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		g.view.ChangeSpeed(-visualizer.SpeedStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		g.view.ToggleResults()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (g *practiceGame) Draw(screen *ebiten.Image) {
+	g.view.Draw(g.screen.canvasFor(screen, ebiten.DeviceScaleFactor()))
+}
+
+// Layout makes the screen as large as the window in physical pixels, like
+// the visualizer.
+func (g *practiceGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return physicalSize(outsideWidth, outsideHeight, ebiten.DeviceScaleFactor())
+}
+
+// RunPractice opens the practice game scoring the audio passing through the
+// tee and blocks until the window is closed or the context is cancelled.
+func RunPractice(ctx context.Context, tee *visualizer.Tee, p *visualizer.Practice, options visualizer.PracticeOptions) error {
+	ebiten.SetWindowSize(visualizer.ScreenWidth, visualizer.ScreenHeight)
+	ebiten.SetWindowSizeLimits(minWidth, minHeight, -1, -1)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle(options.Title)
+
+	// the tuner analyzes in the background so it never stalls a frame
+	pitchCtx, stopPitch := context.WithCancel(ctx)
+	defer stopPitch()
+	go tee.RunPitch(pitchCtx)
+
+	err := ebiten.RunGame(&practiceGame{view: p})
+	if err == context.Canceled {
+		err = nil
+	}
+	return err
+}
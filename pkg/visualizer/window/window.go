@@ -0,0 +1,352 @@
+// Package window shows the visualizer in an ebiten window and handles its
+// keyboard and mouse input.
+package window
+
+import (
+	"context"
+	"image"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/metalblueberry/bard/pkg/visualizer"
+)
+
+// smallest size of the window
+const (
+	minWidth  = 320
+	minHeight = 240
+)
+
+var (
+	// panelKeys select the panels in the order of visualizer.Panels
+	panelKeys = []ebiten.Key{ebiten.Key1, ebiten.Key2, ebiten.Key3, ebiten.Key4, ebiten.Key5}
+	// viewKeys toggle the views in the order of visualizer.Views
+	viewKeys = []ebiten.Key{ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9}
+)
+
+// game adapts the visualizer to the ebiten game loop.
+type game struct {
+	view   *visualizer.Game
+	screen scaledScreen
+	// size of the screen in device independent pixels, which follows the
+	// window, and the scale of the display
+	width, height int
+	scale         float64
+	// border between panes being dragged, if any
+	dragging *visualizer.Border
+}
+
+// bounds returns the area of the screen.
+func (g *game) bounds() image.Rectangle {
+	return image.Rect(0, 0, g.width, g.height)
+}
+
+// Update stops the game only when the visualizer stops, because its context
+// was cancelled or its analysis failed. Errors of the controls, like a
+// recording directory which cannot be written, are logged and the window
+// stays open.
+func (g *game) Update() error {
+	if err := g.view.Update(); err != nil {
+		return err
+	}
+	if err := g.handleRecording(); err != nil {
+		log.Println(err)
+	}
+	g.handlePanels()
+	g.handleNotes()
+	g.handleFloor()
+	g.handleLayout()
+	if err := g.handleDisplay(); err != nil {
+		log.Println(err)
+	}
+	if err := g.handleTransport(); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// handlePanels cycles the panels of the panel view with tab, toggles the
+// tuner with T, the staff with S and cycles its keys with K, and toggles the
+// waterfall with W, its logarithmic axis with L and its colour maps with C.
+func (g *game) handlePanels() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		g.view.NextPanel()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.view.TogglePanel(visualizer.PanelTuner)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		g.view.TogglePanel(visualizer.PanelStaff)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.view.NextKey()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		g.view.ToggleWaterfall()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		g.view.ToggleAxis()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.view.NextColorMap()
+	}
+}
+
+// handleNotes changes the notes shown: N cycles the scales, the brackets move
+// the root by a semitone and comma and period shift the range by an octave.
+// Note sets which cannot be shown are logged and the notes are kept.
+func (g *game) handleNotes() {
+	var err error
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyN):
+		err = g.view.NextScale()
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft):
+		err = g.view.TransposeRoot(-1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight):
+		err = g.view.TransposeRoot(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyComma):
+		err = g.view.ShiftOctaves(-1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyPeriod):
+		err = g.view.ShiftOctaves(1)
+	default:
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("showing %s", g.view.NoteSet())
+}
+
+// handleFloor learns the noise floor with F and changes the threshold notes
+// must peak over it by with minus and equals.
+func (g *game) handleFloor() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyF):
+		g.view.LearnFloor(visualizer.DefaultLearnFloor)
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus):
+		g.view.ChangeThreshold(-visualizer.ThresholdStep)
+		log.Printf("threshold %.0f dB over the noise floor", g.view.Threshold())
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual):
+		g.view.ChangeThreshold(visualizer.ThresholdStep)
+		log.Printf("threshold %.0f dB over the noise floor", g.view.Threshold())
+	}
+}
+
+// handleDisplay controls the display: P freezes it, as does space for live
+// input, Z and V zoom the waveform in time and amplitude, or out with shift,
+// as does the mouse wheel over the waveform, backspace resets the zoom, G
+// raises the input gain, or lowers it with shift, page up and down change
+// the FFT size, the number keys select a panel, right clicking cycles them,
+// and H shows or hides the HUD.
+func (g *game) handleDisplay() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) ||
+		(g.view.Player() == nil && inpututil.IsKeyJustPressed(ebiten.KeySpace)) {
+		g.view.ToggleFreeze()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		g.view.ToggleHUD()
+	}
+
+	out := ebiten.IsKeyPressed(ebiten.KeyShift)
+	step := 1
+	if out {
+		step = -1
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+		g.view.ZoomWave(step, 0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyV):
+		g.view.ZoomWave(0, step)
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		g.view.ResetZoom()
+	case inpututil.IsKeyJustPressed(ebiten.KeyG):
+		g.view.ChangeGain(float64(step) * visualizer.GainStep)
+		log.Printf("input gain %+.0f dB", g.view.Gain())
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageUp):
+		return g.changeFFTSize(1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageDown):
+		return g.changeFFTSize(-1)
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
+		g.view.NextPanel()
+	}
+
+	// the wheel zooms the waveform in time, or in amplitude with shift
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		if wave, ok := g.view.ViewArea(visualizer.ViewWave, g.bounds()); ok && g.cursor().In(wave) {
+			steps := 1
+			if dy < 0 {
+				steps = -1
+			}
+			if out {
+				g.view.ZoomWave(0, steps)
+			} else {
+				g.view.ZoomWave(steps, 0)
+			}
+		}
+	}
+
+	panels := visualizer.Panels()
+	for i, key := range panelKeys {
+		if i < len(panels) && inpututil.IsKeyJustPressed(key) {
+			return g.view.SetPanel(panels[i])
+		}
+	}
+	return nil
+}
+
+// changeFFTSize moves the FFT size by a number of powers of two and logs it.
+func (g *game) changeFFTSize(steps int) error {
+	if err := g.view.ChangeFFTSize(steps); err != nil {
+		return err
+	}
+	log.Printf("FFT size %d samples", g.view.FFTSize())
+	return nil
+}
+
+// handleLayout toggles the views with the function keys and resizes the
+// panes by dragging the borders between them with the mouse.
+func (g *game) handleLayout() {
+	views := visualizer.Views()
+	for i, key := range viewKeys {
+		if i < len(views) && inpututil.IsKeyJustPressed(key) {
+			if _, err := g.view.ToggleView(views[i]); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	pt := g.cursor()
+	border, hover := g.view.BorderAt(g.bounds(), pt)
+	switch {
+	case g.dragging != nil && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft):
+		g.dragging = nil
+	case g.dragging != nil:
+		g.view.DragBorder(g.bounds(), *g.dragging, pt)
+		border, hover = *g.dragging, true
+	case hover && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		g.dragging = &border
+	}
+
+	shape := ebiten.CursorShapeDefault
+	if hover && border.Pane < 0 {
+		shape = ebiten.CursorShapeNSResize
+	} else if hover {
+		shape = ebiten.CursorShapeEWResize
+	}
+	ebiten.SetCursorShape(shape)
+}
+
+// handleRecording toggles recording with R and adds a marker with M.
+func (g *game) handleRecording() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		if err := g.view.ToggleRecording(); err != nil {
+			return err
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.view.Mark()
+	}
+	return nil
+}
+
+// handleTransport controls a replayed file: space pauses, the arrow keys
+// seek by five seconds (one with shift) or change the speed, home returns
+// to the start and clicking the progress bar seeks to the position.
+func (g *game) handleTransport() error {
+	player := g.view.Player()
+	if player == nil {
+		return nil
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if err := player.SetPaused(!player.Paused()); err != nil {
+			return err
+		}
+	}
+
+	step := 5.0
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		step = 1
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+		return g.view.Seek(player.Position() - step)
+	case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+		return g.view.Seek(player.Position() + step)
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		return g.view.Seek(0)
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+		g.view.ChangeSpeed(visualizer.SpeedStep)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+		g.view.ChangeSpeed(-visualizer.SpeedStep)
+	case g.dragging == nil && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		pt := g.cursor()
+		bar := visualizer.ProgressBar(g.bounds())
+		if pt.In(bar) && player.Duration() > 0 {
+			ratio := float64(pt.X-bar.Min.X) / float64(bar.Dx())
+			return g.view.Seek(ratio * player.Duration())
+		}
+	}
+	return nil
+}
+
+func (g *game) Draw(screen *ebiten.Image) {
+	g.view.Draw(g.screen.canvasFor(screen, g.scale))
+}
+
+// Layout makes the screen as large as the window in physical pixels, so it
+// is sharp on high resolution displays. The game lays out its panes in
+// device independent pixels, which are scaled when drawn.
+func (g *game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	g.width, g.height = outsideWidth, outsideHeight
+	g.scale = ebiten.DeviceScaleFactor()
+	return physicalSize(outsideWidth, outsideHeight, g.scale)
+}
+
+// cursor returns the position of the cursor in device independent pixels.
+func (g *game) cursor() image.Point {
+	return logicalPoint(image.Pt(ebiten.CursorPosition()), g.scale)
+}
+
+// Run opens the visualizer window for the audio passing through the tee and
+// blocks until the window is closed or the context is cancelled.
+func Run(ctx context.Context, tee *visualizer.Tee, options visualizer.Options) error {
+	ebiten.SetWindowSize(visualizer.ScreenWidth, visualizer.ScreenHeight)
+	ebiten.SetWindowSizeLimits(minWidth, minHeight, -1, -1)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle(options.Title)
+
+	view, err := visualizer.CreateGame(ctx, tee, options)
+	if err != nil {
+		return err
+	}
+	// the tuner analyzes in the background so it never stalls a frame
+	pitchCtx, stopPitch := context.WithCancel(ctx)
+	defer stopPitch()
+	go tee.RunPitch(pitchCtx)
+	// and so does the transform, the window draws its latest snapshot
+	analysisCtx, stopAnalysis := context.WithCancel(ctx)
+	defer stopAnalysis()
+	go view.RunAnalysis(analysisCtx)
+
+	if options.Record {
+		recorder, err := tee.StartRecording(options.RecordDir)
+		if err != nil {
+			return err
+		}
+		log.Printf("recording to %s", recorder.AudioPath())
+	}
+	defer tee.StopRecording()
+
+	err = ebiten.RunGame(&game{view: view, width: visualizer.ScreenWidth, height: visualizer.ScreenHeight, scale: 1})
+	if err == context.Canceled {
+		err = nil
+	}
+	if layout := view.Layout(); options.SaveLayout != nil && layout.String() != options.Layout.String() {
+		if errSave := options.SaveLayout(layout); err == nil {
+			err = errSave
+		}
+	}
+	return err
+}
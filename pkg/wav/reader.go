@@ -0,0 +1,555 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+/*
+ * Data structure representing a streaming reader of wave files.
+ *
+ * Only the headers are read when the reader is created, samples are read
+ * on demand. If the underlying stream supports seeking, chunks following
+ * the sample data, like cue points, are read as well.
+ */
+type Reader struct {
+	reader    io.Reader
+	seeker    io.Seeker
+	closer    io.Closer
+	format    Format
+	dataStart int64
+	dataSize  int64
+	remaining int64
+	cues      []Cue
+	labels    map[uint32]string
+	buf       []byte
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *Reader) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames in the file. Streams which leave the size of
+ * the samples open report the largest size possible.
+ */
+func (this *Reader) Frames() int64 {
+	return this.dataSize / int64(this.format.BlockAlign())
+}
+
+/*
+ * Returns the cue points of the file.
+ */
+func (this *Reader) Cues() []Cue {
+	return this.cues
+}
+
+/*
+ * Reads interleaved samples and returns the number of samples read, which
+ * is always a multiple of the channel count.
+ *
+ * Returns io.EOF once all samples were read. Truncated files end at the
+ * last complete frame.
+ */
+func (this *Reader) Read(buf []float32) (int, error) {
+	blockAlign := int64(this.format.BlockAlign())
+
+	/*
+	 * Signal the end of the data, ignoring an incomplete last frame.
+	 */
+	if this.remaining < blockAlign {
+		this.remaining = 0
+		return 0, io.EOF
+	}
+
+	numChannels := int(this.format.Channels)
+	numFrames := int64(len(buf) / numChannels)
+	numBytes := numFrames * blockAlign
+
+	/*
+	 * At least one frame must fit into the buffer.
+	 */
+	if numFrames == 0 {
+		return 0, io.ErrShortBuffer
+	}
+
+	/*
+	 * Never read beyond the data chunk.
+	 */
+	if numBytes > this.remaining {
+		numBytes = this.remaining - (this.remaining % blockAlign)
+	}
+
+	/*
+	 * Grow the byte buffer as needed.
+	 */
+	if int64(len(this.buf)) < numBytes {
+		this.buf = make([]byte, numBytes)
+	}
+
+	data := this.buf[0:numBytes]
+	n, err := io.ReadFull(this.reader, data)
+
+	/*
+	 * Treat a truncated file as its end.
+	 */
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		this.remaining = 0
+		err = nil
+	} else if err != nil {
+		msg := err.Error()
+		return 0, fmt.Errorf("Failed to read samples: %s", msg)
+	} else {
+		this.remaining -= int64(n)
+	}
+
+	numComplete := int64(n) - (int64(n) % blockAlign)
+	decode(data[0:numComplete], this.format.Encoding, buf)
+	numSamples := int(numComplete) / this.format.BytesPerSample()
+
+	/*
+	 * Report the end only when nothing was read.
+	 */
+	if numSamples == 0 && this.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	return numSamples, err
+}
+
+/*
+ * Moves the read position to a certain frame. The underlying stream must
+ * support seeking.
+ */
+func (this *Reader) SeekFrame(frame int64) error {
+
+	/*
+	 * Check if seeking is possible.
+	 */
+	if this.seeker == nil {
+		return fmt.Errorf("%s", "Stream does not support seeking.")
+	} else if frame < 0 || frame > this.Frames() {
+		return fmt.Errorf("Frame %d out of range, file has %d frames.", frame, this.Frames())
+	}
+
+	offset := frame * int64(this.format.BlockAlign())
+	_, err := this.seeker.Seek(this.dataStart+offset, io.SeekStart)
+
+	/*
+	 * Check if position could be changed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek: %s", msg)
+	}
+
+	this.remaining = this.dataSize - offset
+	return nil
+}
+
+/*
+ * Closes the underlying file, if the reader was opened from a path.
+ */
+func (this *Reader) Close() error {
+
+	if this.closer != nil {
+		return this.closer.Close()
+	}
+
+	return nil
+}
+
+/*
+ * Parses the format chunk.
+ */
+func parseFormat(body []byte) (Format, error) {
+	format := Format{}
+
+	/*
+	 * The basic format chunk has 16 bytes.
+	 */
+	if len(body) < 16 {
+		return format, fmt.Errorf("Format chunk has %d bytes, expected at least %d.", len(body), 16)
+	}
+
+	tag := binary.LittleEndian.Uint16(body[0:])
+	format.Channels = binary.LittleEndian.Uint16(body[2:])
+	format.SampleRate = binary.LittleEndian.Uint32(body[4:])
+	format.BitDepth = binary.LittleEndian.Uint16(body[14:])
+	format.SampleFormat = tag
+
+	/*
+	 * Extensible formats store the actual format in a GUID.
+	 */
+	if tag == FORMAT_EXTENSIBLE {
+
+		if len(body) < 40 {
+			return format, fmt.Errorf("Extensible format chunk has %d bytes, expected %d.", len(body), 40)
+		}
+
+		/*
+		 * Samples with fewer valid bits are stored in containers of the
+		 * full bit depth and scaled accordingly, so only the container
+		 * size matters here.
+		 */
+		format.ChannelMask = binary.LittleEndian.Uint32(body[20:])
+		format.SampleFormat = binary.LittleEndian.Uint16(body[24:])
+		format.Extensible = true
+
+		if !bytes.Equal(body[26:40], GUID_TAIL) {
+			return format, fmt.Errorf("%s", "Unknown sub-format GUID.")
+		}
+
+	}
+
+	err := format.Validate()
+	return format, err
+}
+
+/*
+ * Parses a cue chunk.
+ */
+func (this *Reader) parseCues(body []byte) {
+	numCues := 0
+
+	/*
+	 * The chunk starts with the number of cue points.
+	 */
+	if len(body) >= 4 {
+		numCues = int(binary.LittleEndian.Uint32(body[0:]))
+	}
+
+	for i := 0; i < numCues; i++ {
+		offset := 4 + 24*i
+
+		/*
+		 * Ignore truncated entries.
+		 */
+		if offset+24 > len(body) {
+			break
+		}
+
+		entry := body[offset : offset+24]
+
+		cue := Cue{
+			ID:       binary.LittleEndian.Uint32(entry[0:]),
+			Position: binary.LittleEndian.Uint32(entry[20:]),
+		}
+
+		this.cues = append(this.cues, cue)
+	}
+
+}
+
+/*
+ * Parses the labels of an associated data list.
+ */
+func (this *Reader) parseLabels(body []byte) {
+
+	/*
+	 * Only associated data lists carry labels.
+	 */
+	if len(body) < 4 || string(body[0:4]) != ID_ADTL {
+		return
+	}
+
+	offset := 4
+
+	for offset+8 <= len(body) {
+		id := string(body[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(body[offset+4:]))
+		start := offset + 8
+		end := start + size
+
+		/*
+		 * Ignore truncated sub-chunks.
+		 */
+		if end > len(body) {
+			break
+		}
+
+		if id == ID_LABEL && size >= 4 {
+			cueID := binary.LittleEndian.Uint32(body[start:])
+			text := body[start+4 : end]
+			text = bytes.TrimRight(text, "\x00")
+			this.labels[cueID] = string(text)
+		}
+
+		offset = end + (size & 1)
+	}
+
+}
+
+/*
+ * Skips the body of a chunk including its pad byte.
+ */
+func (this *Reader) skip(size int64) error {
+	size += size & 1
+
+	/*
+	 * Prefer seeking over reading.
+	 */
+	if this.seeker != nil {
+		_, err := this.seeker.Seek(size, io.SeekCurrent)
+		return err
+	}
+
+	_, err := io.CopyN(io.Discard, this.reader, size)
+	return err
+}
+
+/*
+ * Reads the body of a chunk including its pad byte.
+ */
+func (this *Reader) readBody(size int64) ([]byte, error) {
+	body := make([]byte, size+(size&1))
+	n, err := io.ReadFull(this.reader, body)
+
+	/*
+	 * A missing pad byte at the end of the file is tolerated.
+	 */
+	if err == io.ErrUnexpectedEOF && int64(n) >= size {
+		err = nil
+	}
+
+	return body[0:size], err
+}
+
+/*
+ * Reads the chunks of a file up to the sample data, and beyond it if the
+ * stream supports seeking.
+ */
+func (this *Reader) parse() error {
+	header := make([]byte, 12)
+	_, err := io.ReadFull(this.reader, header)
+
+	/*
+	 * Check the RIFF header.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to read RIFF header: %s", msg)
+	} else if string(header[0:4]) != ID_RIFF || string(header[8:12]) != ID_WAVE {
+		return fmt.Errorf("%s", "Stream is not a RIFF wave file.")
+	}
+
+	hasFormat := false
+	hasData := false
+	open := false
+	position := int64(12)
+	chunkHeader := make([]byte, 8)
+
+	for {
+		_, err := io.ReadFull(this.reader, chunkHeader)
+
+		/*
+		 * The file ends after the last chunk.
+		 */
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to read chunk header: %s", msg)
+		}
+
+		id := string(chunkHeader[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunkHeader[4:]))
+		position += 8
+
+		switch id {
+		case ID_FORMAT:
+			body, err := this.readBody(size)
+
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to read format chunk: %s", msg)
+			}
+
+			this.format, err = parseFormat(body)
+
+			if err != nil {
+				return err
+			}
+
+			hasFormat = true
+		case ID_CUE, ID_LIST:
+			body, err := this.readBody(size)
+
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to read %s chunk: %s", id, msg)
+			}
+
+			if id == ID_CUE {
+				this.parseCues(body)
+			} else {
+				this.parseLabels(body)
+			}
+
+		case ID_DATA:
+
+			/*
+			 * Samples can only be interpreted with a known format.
+			 */
+			if !hasFormat {
+				return fmt.Errorf("%s", "Data chunk precedes format chunk.")
+			}
+
+			hasData = true
+			this.dataStart = position
+			this.dataSize = size
+			this.remaining = size
+
+			/*
+			 * Files which were never finished, or were written to a
+			 * stream, leave the size open, the samples extend to the end
+			 * of the file then. A size of zero is an empty file, which
+			 * may still have chunks after the data.
+			 */
+			if size == MAX_RIFF_SIZE {
+				open = true
+				this.dataSize = MAX_RIFF_SIZE
+				this.remaining = MAX_RIFF_SIZE
+			}
+
+			/*
+			 * Without seeking, or with an open size, the samples must
+			 * be read next.
+			 */
+			if this.seeker == nil || open {
+				break
+			}
+
+			err := this.skip(size)
+
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to skip data chunk: %s", msg)
+			}
+
+		default:
+			err := this.skip(size)
+
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to skip %s chunk: %s", id, msg)
+			}
+
+		}
+
+		/*
+		 * Nothing follows samples extending to the end of the file.
+		 */
+		if open || (hasData && this.seeker == nil) {
+			break
+		}
+
+		position += size + (size & 1)
+	}
+
+	/*
+	 * Check that samples were found.
+	 */
+	if !hasData {
+		return fmt.Errorf("%s", "Wave file has no data chunk.")
+	} else if this.seeker == nil {
+		return nil
+	}
+
+	_, err = this.seeker.Seek(this.dataStart, io.SeekStart)
+
+	/*
+	 * Return to the start of the samples.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek to data chunk: %s", msg)
+	}
+
+	end, _ := this.seeker.Seek(0, io.SeekEnd)
+	this.seeker.Seek(this.dataStart, io.SeekStart)
+
+	/*
+	 * Files written by crashed recorders may claim more data than present,
+	 * and open sizes are limited to the file.
+	 */
+	if available := end - this.dataStart; available < this.dataSize {
+		this.dataSize = available
+		this.remaining = available
+	}
+
+	return nil
+}
+
+/*
+ * Creates a reader for a wave file in a stream.
+ */
+func CreateReader(r io.Reader) (*Reader, error) {
+
+	/*
+	 * Create data structure for a reader.
+	 */
+	reader := &Reader{
+		reader: r,
+		labels: map[uint32]string{},
+	}
+
+	seeker, ok := r.(io.Seeker)
+
+	if ok {
+		reader.seeker = seeker
+	}
+
+	err := reader.parse()
+
+	/*
+	 * Check if headers could be parsed.
+	 */
+	if err != nil {
+		return nil, err
+	}
+
+	/*
+	 * Attach labels to cue points.
+	 */
+	for i, cue := range reader.cues {
+		reader.cues[i].Label = reader.labels[cue.ID]
+	}
+
+	return reader, nil
+}
+
+/*
+ * Opens a wave file for reading.
+ */
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+
+	/*
+	 * Check if file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to open wave file '%s': %s", path, msg)
+	}
+
+	reader, err := CreateReader(file)
+
+	/*
+	 * Check if file could be parsed.
+	 */
+	if err != nil {
+		file.Close()
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to parse wave file '%s': %s", path, msg)
+	}
+
+	reader.closer = file
+	return reader, nil
+}
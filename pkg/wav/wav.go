@@ -0,0 +1,305 @@
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+ * Global constants.
+ */
+const (
+	FORMAT_PCM        = 0x0001
+	FORMAT_IEEE_FLOAT = 0x0003
+	FORMAT_EXTENSIBLE = 0xfffe
+	ID_RIFF           = "RIFF"
+	ID_WAVE           = "WAVE"
+	ID_FORMAT         = "fmt "
+	ID_DATA           = "data"
+	ID_CUE            = "cue "
+	ID_LIST           = "LIST"
+	ID_ADTL           = "adtl"
+	ID_LABEL          = "labl"
+	ID_FACT           = "fact"
+	MAX_RIFF_SIZE     = 0xffffffff
+)
+
+/*
+ * The tail shared by all sub-format GUIDs of WAVE_FORMAT_EXTENSIBLE, which
+ * follows the two-byte format tag.
+ */
+var GUID_TAIL = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00,
+	0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71,
+}
+
+/*
+ * Data structure describing how samples are stored.
+ */
+type Encoding struct {
+	SampleFormat uint16
+	BitDepth     uint16
+}
+
+/*
+ * Encodings supported by this package.
+ */
+var (
+	PCM_8    = Encoding{SampleFormat: FORMAT_PCM, BitDepth: 8}
+	PCM_16   = Encoding{SampleFormat: FORMAT_PCM, BitDepth: 16}
+	PCM_24   = Encoding{SampleFormat: FORMAT_PCM, BitDepth: 24}
+	PCM_32   = Encoding{SampleFormat: FORMAT_PCM, BitDepth: 32}
+	FLOAT_32 = Encoding{SampleFormat: FORMAT_IEEE_FLOAT, BitDepth: 32}
+	FLOAT_64 = Encoding{SampleFormat: FORMAT_IEEE_FLOAT, BitDepth: 64}
+)
+
+/*
+ * Data structure describing the layout of a wave file.
+ *
+ * ChannelMask assigns channels to speaker positions. It is only stored in
+ * WAVE_FORMAT_EXTENSIBLE files, which are written whenever a file has more
+ * than two channels, more than 16 bits per sample or Extensible is set.
+ */
+type Format struct {
+	Encoding
+	Channels    uint16
+	SampleRate  uint32
+	ChannelMask uint32
+	Extensible  bool
+}
+
+/*
+ * Data structure representing a cue point.
+ *
+ * Position is the frame the cue point refers to.
+ */
+type Cue struct {
+	ID       uint32
+	Position uint32
+	Label    string
+}
+
+/*
+ * Returns the number of bytes of a single sample.
+ */
+func (this Encoding) BytesPerSample() int {
+	return int(this.BitDepth) / 8
+}
+
+/*
+ * Checks whether samples of this encoding can be read and written.
+ */
+func (this Encoding) Validate() error {
+
+	switch this {
+	case PCM_8, PCM_16, PCM_24, PCM_32, FLOAT_32, FLOAT_64:
+		return nil
+	default:
+		return fmt.Errorf("Unsupported encoding: format %#04x with %d bits per sample.", this.SampleFormat, this.BitDepth)
+	}
+
+}
+
+/*
+ * Returns a textual representation of the encoding.
+ */
+func (this Encoding) String() string {
+
+	/*
+	 * Name the sample format.
+	 */
+	if this.SampleFormat == FORMAT_IEEE_FLOAT {
+		return fmt.Sprintf("%d-bit float", this.BitDepth)
+	} else {
+		return fmt.Sprintf("%d-bit PCM", this.BitDepth)
+	}
+
+}
+
+/*
+ * Returns the number of bytes of a single frame.
+ */
+func (this Format) BlockAlign() int {
+	return int(this.Channels) * this.BytesPerSample()
+}
+
+/*
+ * Checks whether a format can be read and written.
+ */
+func (this Format) Validate() error {
+
+	/*
+	 * Check channels and sample rate before the encoding.
+	 */
+	if this.Channels == 0 {
+		return fmt.Errorf("%s", "Wave file must have at least one channel.")
+	} else if this.SampleRate == 0 {
+		return fmt.Errorf("%s", "Wave file must have a positive sample rate.")
+	} else {
+		return this.Encoding.Validate()
+	}
+
+}
+
+/*
+ * Returns whether the format must be stored as WAVE_FORMAT_EXTENSIBLE.
+ */
+func (this Format) needsExtensible() bool {
+	return this.Extensible || this.Channels > 2 || (this.SampleFormat == FORMAT_PCM && this.BitDepth > 16)
+}
+
+/*
+ * Returns the channel mask stored for a format, choosing the usual speaker
+ * layout if none is set.
+ */
+func (this Format) channelMask() uint32 {
+
+	/*
+	 * Explicit masks take precedence.
+	 */
+	if this.ChannelMask != 0 {
+		return this.ChannelMask
+	}
+
+	switch this.Channels {
+	case 1:
+		return 0x4
+	case 2:
+		return 0x3
+	default:
+		return uint32((uint64(1) << this.Channels) - 1)
+	}
+
+}
+
+/*
+ * Decodes interleaved samples from raw bytes.
+ *
+ * Integer samples are scaled to the range from -1 to 1.
+ */
+func decode(data []byte, encoding Encoding, out []float32) {
+	size := encoding.BytesPerSample()
+	n := len(data) / size
+
+	switch encoding {
+	case PCM_8:
+
+		/*
+		 * 8-bit samples are unsigned.
+		 */
+		for i := 0; i < n; i++ {
+			out[i] = float32(int(data[i])-128) / 128.0
+		}
+
+	case PCM_16:
+
+		for i := 0; i < n; i++ {
+			value := int16(binary.LittleEndian.Uint16(data[2*i:]))
+			out[i] = float32(value) / 32768.0
+		}
+
+	case PCM_24:
+
+		/*
+		 * Sign-extend three bytes into the upper bits of a 32-bit integer.
+		 */
+		for i := 0; i < n; i++ {
+			b := data[3*i : 3*i+3]
+			value := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			out[i] = float32(float64(value) / 8388608.0)
+		}
+
+	case PCM_32:
+
+		for i := 0; i < n; i++ {
+			value := int32(binary.LittleEndian.Uint32(data[4*i:]))
+			out[i] = float32(float64(value) / 2147483648.0)
+		}
+
+	case FLOAT_32:
+
+		for i := 0; i < n; i++ {
+			bits := binary.LittleEndian.Uint32(data[4*i:])
+			out[i] = math.Float32frombits(bits)
+		}
+
+	case FLOAT_64:
+
+		for i := 0; i < n; i++ {
+			bits := binary.LittleEndian.Uint64(data[8*i:])
+			out[i] = float32(math.Float64frombits(bits))
+		}
+
+	}
+
+}
+
+/*
+ * Scales a sample to a signed integer of a certain number of bits,
+ * clipping values outside of the range from -1 to 1.
+ */
+func quantize(x float32, bits uint) int64 {
+	scale := float64(int64(1) << (bits - 1))
+	value := math.Round(float64(x) * scale)
+
+	/*
+	 * Clip to the representable range.
+	 */
+	if value > scale-1.0 {
+		value = scale - 1.0
+	} else if value < -scale {
+		value = -scale
+	}
+
+	return int64(value)
+}
+
+/*
+ * Encodes interleaved samples into raw bytes.
+ */
+func encode(samples []float32, encoding Encoding, out []byte) {
+
+	switch encoding {
+	case PCM_8:
+
+		for i, x := range samples {
+			out[i] = byte(quantize(x, 8) + 128)
+		}
+
+	case PCM_16:
+
+		for i, x := range samples {
+			binary.LittleEndian.PutUint16(out[2*i:], uint16(quantize(x, 16)))
+		}
+
+	case PCM_24:
+
+		for i, x := range samples {
+			value := uint32(quantize(x, 24))
+			out[3*i] = byte(value)
+			out[3*i+1] = byte(value >> 8)
+			out[3*i+2] = byte(value >> 16)
+		}
+
+	case PCM_32:
+
+		for i, x := range samples {
+			binary.LittleEndian.PutUint32(out[4*i:], uint32(quantize(x, 32)))
+		}
+
+	case FLOAT_32:
+
+		for i, x := range samples {
+			binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(x))
+		}
+
+	case FLOAT_64:
+
+		for i, x := range samples {
+			binary.LittleEndian.PutUint64(out[8*i:], math.Float64bits(float64(x)))
+		}
+
+	}
+
+}
@@ -0,0 +1,543 @@
+package wav
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrepxx/go-dsp-guitar/wave"
+)
+
+/*
+ * Data structure representing an in-memory stream supporting seeking.
+ */
+type memoryFile struct {
+	data     []byte
+	position int64
+}
+
+/*
+ * Writes to the stream, growing it as needed.
+ */
+func (this *memoryFile) Write(buf []byte) (int, error) {
+	end := this.position + int64(len(buf))
+
+	/*
+	 * Grow the stream.
+	 */
+	for int64(len(this.data)) < end {
+		this.data = append(this.data, 0)
+	}
+
+	copy(this.data[this.position:], buf)
+	this.position = end
+	return len(buf), nil
+}
+
+/*
+ * Moves the position in the stream.
+ */
+func (this *memoryFile) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+	case io.SeekStart:
+		this.position = offset
+	case io.SeekCurrent:
+		this.position += offset
+	case io.SeekEnd:
+		this.position = int64(len(this.data)) + offset
+	}
+
+	return this.position, nil
+}
+
+/*
+ * Generates interleaved test samples in the range from -1 to 1.
+ */
+func testSamples(numFrames int, numChannels int) []float32 {
+	samples := make([]float32, numFrames*numChannels)
+
+	for i := range samples {
+		channel := i % numChannels
+		frame := i / numChannels
+		phase := 2.0 * math.Pi * float64(frame) * float64(channel+1) / 50.0
+		samples[i] = float32(0.9 * math.Sin(phase))
+	}
+
+	return samples
+}
+
+/*
+ * Writes samples into an in-memory wave file.
+ */
+func writeMemory(t *testing.T, format Format, samples []float32, cues []Cue) []byte {
+	file := &memoryFile{}
+	w, err := CreateWriter(file, format)
+
+	/*
+	 * Check if writer could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create writer: %s", msg)
+	}
+
+	err = w.Write(samples)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write samples: %s", msg)
+	}
+
+	for _, cue := range cues {
+		w.AddCue(cue.Position, cue.Label)
+	}
+
+	err = w.Close()
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to close writer: %s", msg)
+	}
+
+	return file.data
+}
+
+/*
+ * Reads all samples from a reader, using a small buffer to exercise
+ * streaming.
+ */
+func readAll(t *testing.T, r *Reader) []float32 {
+	result := []float32{}
+	buf := make([]float32, 7*int(r.Format().Channels))
+
+	for {
+		n, err := r.Read(buf)
+
+		/*
+		 * Reads must be aligned to frames.
+		 */
+		if n%int(r.Format().Channels) != 0 {
+			t.Fatalf("Read %d samples, expected a multiple of %d.", n, r.Format().Channels)
+		}
+
+		result = append(result, buf[0:n]...)
+
+		if err == io.EOF {
+			return result
+		} else if err != nil {
+			msg := err.Error()
+			t.Fatalf("Failed to read samples: %s", msg)
+		}
+
+	}
+
+}
+
+/*
+ * Check that all encodings survive a round trip.
+ */
+func TestRoundTrip(t *testing.T) {
+	encodings := []Encoding{PCM_8, PCM_16, PCM_24, PCM_32, FLOAT_32, FLOAT_64}
+
+	/*
+	 * Quantization errors of each encoding.
+	 */
+	tolerances := []float64{1.0 / 64.0, 1.0 / 16384.0, 1e-6, 1e-7, 0.0, 0.0}
+
+	for i, encoding := range encodings {
+
+		for _, numChannels := range []uint16{1, 2, 6} {
+			format := Format{
+				Encoding:   encoding,
+				Channels:   numChannels,
+				SampleRate: 44100,
+			}
+
+			samples := testSamples(101, int(numChannels))
+			data := writeMemory(t, format, samples, nil)
+			r, err := CreateReader(bytes.NewReader(data))
+
+			/*
+			 * Check if file could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				t.Fatalf("Failed to read %s file with %d channels: %s", encoding, numChannels, msg)
+			}
+
+			got := r.Format()
+
+			/*
+			 * Check the format read back.
+			 */
+			if got.Encoding != encoding || got.Channels != numChannels || got.SampleRate != 44100 {
+				t.Errorf("Read format %s, %d channels, %d Hz, expected %s, %d channels, %d Hz.", got.Encoding, got.Channels, got.SampleRate, encoding, numChannels, 44100)
+			}
+
+			if got.Extensible != format.needsExtensible() {
+				t.Errorf("File with %s and %d channels is extensible: %t, expected %t.", encoding, numChannels, got.Extensible, format.needsExtensible())
+			}
+
+			if r.Frames() != 101 {
+				t.Errorf("File has %d frames, expected %d.", r.Frames(), 101)
+			}
+
+			result := readAll(t, r)
+
+			if len(result) != len(samples) {
+				t.Fatalf("Read %d samples, expected %d.", len(result), len(samples))
+			}
+
+			for j, x := range result {
+				diff := math.Abs(float64(x - samples[j]))
+
+				if diff > tolerances[i] {
+					t.Fatalf("Sample %d of %s file is %f, expected %f.", j, encoding, x, samples[j])
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Check that samples outside of the valid range are clipped.
+ */
+func TestClipping(t *testing.T) {
+	format := Format{Encoding: PCM_16, Channels: 1, SampleRate: 8000}
+	data := writeMemory(t, format, []float32{2.0, -2.0, 1.0, -1.0}, nil)
+	r, err := CreateReader(bytes.NewReader(data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	result := readAll(t, r)
+	expected := []float32{32767.0 / 32768.0, -1.0, 32767.0 / 32768.0, -1.0}
+
+	for i, x := range result {
+
+		if x != expected[i] {
+			t.Errorf("Sample %d is %f, expected %f.", i, x, expected[i])
+		}
+
+	}
+
+}
+
+/*
+ * Check that cue points and their labels are stored.
+ */
+func TestCues(t *testing.T) {
+	format := Format{Encoding: PCM_24, Channels: 1, SampleRate: 48000}
+
+	cues := []Cue{
+		Cue{Position: 0, Label: "start"},
+		Cue{Position: 17, Label: ""},
+		Cue{Position: 33, Label: "chorus"},
+	}
+
+	data := writeMemory(t, format, testSamples(51, 1), cues)
+
+	/*
+	 * Chunks after the data are found when the stream can seek.
+	 */
+	r, err := CreateReader(bytes.NewReader(data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	got := r.Cues()
+
+	if len(got) != len(cues) {
+		t.Fatalf("File has %d cue points, expected %d.", len(got), len(cues))
+	}
+
+	for i, cue := range got {
+
+		if cue.ID != uint32(i+1) || cue.Position != cues[i].Position || cue.Label != cues[i].Label {
+			t.Errorf("Cue point %d is %+v, expected position %d and label '%s'.", i, cue, cues[i].Position, cues[i].Label)
+		}
+
+	}
+
+	/*
+	 * The samples must be readable after the trailing chunks were parsed.
+	 */
+	if n := len(readAll(t, r)); n != 51 {
+		t.Errorf("Read %d samples, expected %d.", n, 51)
+	}
+
+}
+
+/*
+ * Check that chunks after the data of an empty file are not read as
+ * samples.
+ */
+func TestEmpty(t *testing.T) {
+	format := Format{Encoding: PCM_16, Channels: 2, SampleRate: 44100}
+	data := writeMemory(t, format, []float32{}, []Cue{Cue{Position: 0, Label: "start"}})
+	r, err := CreateReader(bytes.NewReader(data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	if r.Frames() != 0 {
+		t.Errorf("Empty file has %d frames, expected %d.", r.Frames(), 0)
+	}
+
+	if cues := r.Cues(); len(cues) != 1 || cues[0].Label != "start" {
+		t.Errorf("Empty file has cue points %+v, expected one labelled 'start'.", cues)
+	}
+
+	if n := len(readAll(t, r)); n != 0 {
+		t.Errorf("Read %d samples from empty file, expected %d.", n, 0)
+	}
+
+	/*
+	 * Without seeking, the cue points cannot be found before the data,
+	 * but they must not be read as samples either.
+	 */
+	r, err = CreateReader(io.MultiReader(bytes.NewReader(data)))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to stream file: %s", msg)
+	}
+
+	if n := len(readAll(t, r)); n != 0 {
+		t.Errorf("Streamed %d samples from empty file, expected %d.", n, 0)
+	}
+
+}
+
+/*
+ * Check that files can be read from streams which cannot seek.
+ */
+func TestStreaming(t *testing.T) {
+	format := Format{Encoding: FLOAT_32, Channels: 2, SampleRate: 22050}
+	samples := testSamples(1000, 2)
+	data := writeMemory(t, format, samples, []Cue{Cue{Position: 10}})
+	stream := io.MultiReader(bytes.NewReader(data))
+	r, err := CreateReader(stream)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	result := readAll(t, r)
+
+	if len(result) != len(samples) {
+		t.Fatalf("Read %d samples, expected %d.", len(result), len(samples))
+	}
+
+	err = r.SeekFrame(0)
+
+	/*
+	 * Seeking requires a seekable stream.
+	 */
+	if err == nil {
+		t.Errorf("%s", "Seeking in a stream succeeded, expected an error.")
+	}
+
+}
+
+/*
+ * Check that seeking and truncated files are handled.
+ */
+func TestSeekAndTruncate(t *testing.T) {
+	format := Format{Encoding: PCM_16, Channels: 2, SampleRate: 44100}
+	samples := testSamples(100, 2)
+	data := writeMemory(t, format, samples, nil)
+	r, err := CreateReader(bytes.NewReader(data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	err = r.SeekFrame(90)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to seek: %s", msg)
+	}
+
+	if n := len(readAll(t, r)); n != 20 {
+		t.Errorf("Read %d samples after seeking, expected %d.", n, 20)
+	}
+
+	/*
+	 * Cut the file in the middle of a frame.
+	 */
+	truncated := data[0 : len(data)-2*4*10-1]
+	r, err = CreateReader(bytes.NewReader(truncated))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read truncated file: %s", msg)
+	}
+
+	if n := len(readAll(t, r)); n != 158 {
+		t.Errorf("Read %d samples from truncated file, expected %d.", n, 158)
+	}
+
+}
+
+/*
+ * Check that files are compatible with the wave package of go-dsp-guitar.
+ */
+func TestCompatibility(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compat.wav")
+	format := Format{Encoding: PCM_16, Channels: 2, SampleRate: 44100}
+	samples := testSamples(64, 2)
+	w, err := Create(path, format)
+
+	/*
+	 * Check if file could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create file: %s", msg)
+	}
+
+	w.Write(samples)
+	w.Close()
+	buf, err := os.ReadFile(path)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file: %s", msg)
+	}
+
+	file, err := wave.FromBuffer(buf)
+
+	/*
+	 * Check that the other implementation accepts our file.
+	 */
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to parse file with go-dsp-guitar: %s", msg)
+	}
+
+	if file.SampleRate() != 44100 || file.ChannelCount() != 2 {
+		t.Errorf("File has %d Hz and %d channels, expected %d Hz and %d channels.", file.SampleRate(), file.ChannelCount(), 44100, 2)
+	}
+
+	for c := uint16(0); c < 2; c++ {
+		channel, _ := file.Channel(c)
+		values := channel.Floats()
+
+		for i, x := range values {
+			expected := float64(samples[2*i+int(c)])
+
+			if math.Abs(x-expected) > 1.0/16384.0 {
+				t.Fatalf("Sample %d of channel %d is %f, expected %f.", i, c, x, expected)
+			}
+
+		}
+
+	}
+
+	/*
+	 * Check that we accept files of the other implementation.
+	 */
+	other, _ := wave.CreateEmpty(8000, wave.AUDIO_PCM, 16, 1)
+	channel, _ := other.Channel(0)
+	channel.WriteFloats([]float64{0.0, 0.5, -0.5})
+	otherBuf, _ := other.Bytes()
+	r, err := CreateReader(bytes.NewReader(otherBuf))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read file of go-dsp-guitar: %s", msg)
+	}
+
+	result := readAll(t, r)
+
+	if len(result) != 3 || math.Abs(float64(result[1])-0.5) > 1e-3 {
+		t.Errorf("Read %v, expected %v.", result, []float64{0.0, 0.5, -0.5})
+	}
+
+}
+
+/*
+ * Check that files which were never closed can be read.
+ */
+func TestUnfinished(t *testing.T) {
+	format := Format{Encoding: PCM_16, Channels: 1, SampleRate: 1000}
+	file := &memoryFile{}
+	w, err := CreateWriter(file, format)
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to create writer: %s", msg)
+	}
+
+	/*
+	 * Before the first update, the size of the samples is left open.
+	 */
+	err = w.Write(testSamples(300, 1))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write samples: %s", msg)
+	}
+
+	r, err := CreateReader(bytes.NewReader(file.data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read unfinished file: %s", msg)
+	}
+
+	if n := len(readAll(t, r)); n != 300 {
+		t.Errorf("Read %d samples from unfinished file, expected %d.", n, 300)
+	}
+
+	r, err = CreateReader(io.MultiReader(bytes.NewReader(file.data)))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to stream unfinished file: %s", msg)
+	}
+
+	if n := len(readAll(t, r)); n != 300 {
+		t.Errorf("Streamed %d samples from unfinished file, expected %d.", n, 300)
+	}
+
+	/*
+	 * After a second of audio the sizes are updated.
+	 */
+	err = w.Write(testSamples(900, 1))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to write samples: %s", msg)
+	}
+
+	r, err = CreateReader(bytes.NewReader(file.data))
+
+	if err != nil {
+		msg := err.Error()
+		t.Fatalf("Failed to read unfinished file: %s", msg)
+	}
+
+	if r.Frames() != 1200 {
+		t.Errorf("Unfinished file has %d frames, expected %d.", r.Frames(), 1200)
+	}
+
+}
@@ -0,0 +1,450 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+/*
+ * Data structure representing a streaming writer of wave files.
+ *
+ * Samples are written as they arrive. The chunk sizes in the header are
+ * updated about once per second of audio and when the writer is flushed,
+ * so a file which is never closed can still be read up to the last update.
+ * The final sizes are filled in when the writer is closed.
+ */
+type Writer struct {
+	writer     io.WriteSeeker
+	closer     io.Closer
+	format     Format
+	dataStart  int64
+	dataSize   int64
+	flushed    int64
+	cues       []Cue
+	buf        []byte
+	closed     bool
+	nextCueID  uint32
+	frameCount int64
+}
+
+/*
+ * Returns the format of the file.
+ */
+func (this *Writer) Format() Format {
+	return this.format
+}
+
+/*
+ * Returns the number of frames written so far.
+ */
+func (this *Writer) Frames() int64 {
+	return this.frameCount
+}
+
+/*
+ * Writes interleaved samples. The number of samples must be a multiple of
+ * the channel count.
+ */
+func (this *Writer) Write(samples []float32) error {
+	numChannels := int(this.format.Channels)
+
+	/*
+	 * Check that the writer is usable and frames are complete.
+	 */
+	if this.closed {
+		return fmt.Errorf("%s", "Writer is closed.")
+	} else if len(samples)%numChannels != 0 {
+		return fmt.Errorf("Got %d samples, expected a multiple of %d channels.", len(samples), numChannels)
+	}
+
+	numBytes := len(samples) * this.format.BytesPerSample()
+
+	/*
+	 * Make sure the file stays within the limits of RIFF.
+	 */
+	if this.dataStart+this.dataSize+int64(numBytes) > MAX_RIFF_SIZE {
+		return fmt.Errorf("%s", "Wave file would exceed 4 GiB.")
+	}
+
+	/*
+	 * Grow the byte buffer as needed.
+	 */
+	if len(this.buf) < numBytes {
+		this.buf = make([]byte, numBytes)
+	}
+
+	data := this.buf[0:numBytes]
+	encode(samples, this.format.Encoding, data)
+	n, err := this.writer.Write(data)
+	this.dataSize += int64(n)
+	this.frameCount = this.dataSize / int64(this.format.BlockAlign())
+
+	/*
+	 * Check if samples could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write samples: %s", msg)
+	}
+
+	interval := int64(this.format.SampleRate) * int64(this.format.BlockAlign())
+
+	/*
+	 * Keep the header up to date in case the file is never closed.
+	 */
+	if this.dataSize-this.flushed >= interval {
+		return this.Flush()
+	}
+
+	return nil
+}
+
+/*
+ * Updates the chunk sizes in the header to cover the samples written so
+ * far, so the file can be read even if it is never closed.
+ */
+func (this *Writer) Flush() error {
+
+	/*
+	 * A closed file already has its final sizes.
+	 */
+	if this.closed {
+		return fmt.Errorf("%s", "Writer is closed.")
+	}
+
+	end := this.dataStart + this.dataSize
+	err := this.patchSizes(end, end)
+
+	if err != nil {
+		return err
+	}
+
+	this.flushed = this.dataSize
+	return nil
+}
+
+/*
+ * Adds a cue point at a frame with an optional label and returns its ID.
+ */
+func (this *Writer) AddCue(position uint32, label string) uint32 {
+	this.nextCueID++
+
+	cue := Cue{
+		ID:       this.nextCueID,
+		Position: position,
+		Label:    label,
+	}
+
+	this.cues = append(this.cues, cue)
+	return cue.ID
+}
+
+/*
+ * Appends a chunk to a buffer, adding a pad byte if needed.
+ */
+func appendChunk(buf *bytes.Buffer, id string, body []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(body)))
+	buf.Write(body)
+
+	/*
+	 * Chunks always start at even offsets.
+	 */
+	if len(body)%2 != 0 {
+		buf.WriteByte(0)
+	}
+
+}
+
+/*
+ * Creates the body of the format chunk.
+ */
+func formatChunk(format Format) []byte {
+	buf := &bytes.Buffer{}
+	tag := format.SampleFormat
+	extensible := format.needsExtensible()
+
+	/*
+	 * Extensible files store the actual format in the sub-format GUID.
+	 */
+	if extensible {
+		tag = FORMAT_EXTENSIBLE
+	}
+
+	blockAlign := uint16(format.BlockAlign())
+	byteRate := format.SampleRate * uint32(blockAlign)
+	binary.Write(buf, binary.LittleEndian, tag)
+	binary.Write(buf, binary.LittleEndian, format.Channels)
+	binary.Write(buf, binary.LittleEndian, format.SampleRate)
+	binary.Write(buf, binary.LittleEndian, byteRate)
+	binary.Write(buf, binary.LittleEndian, blockAlign)
+	binary.Write(buf, binary.LittleEndian, format.BitDepth)
+
+	/*
+	 * Plain PCM has no extension, other formats carry its size.
+	 */
+	if extensible {
+		binary.Write(buf, binary.LittleEndian, uint16(22))
+		binary.Write(buf, binary.LittleEndian, format.BitDepth)
+		binary.Write(buf, binary.LittleEndian, format.channelMask())
+		binary.Write(buf, binary.LittleEndian, format.SampleFormat)
+		buf.Write(GUID_TAIL)
+	} else if format.SampleFormat != FORMAT_PCM {
+		binary.Write(buf, binary.LittleEndian, uint16(0))
+	}
+
+	return buf.Bytes()
+}
+
+/*
+ * Creates the cue chunk and the associated data list holding the labels.
+ */
+func (this *Writer) cueChunks() []byte {
+	buf := &bytes.Buffer{}
+
+	/*
+	 * Omit both chunks without cue points.
+	 */
+	if len(this.cues) == 0 {
+		return nil
+	}
+
+	cue := &bytes.Buffer{}
+	binary.Write(cue, binary.LittleEndian, uint32(len(this.cues)))
+	labels := &bytes.Buffer{}
+	labels.WriteString(ID_ADTL)
+
+	for _, c := range this.cues {
+		binary.Write(cue, binary.LittleEndian, c.ID)
+		binary.Write(cue, binary.LittleEndian, c.Position)
+		cue.WriteString(ID_DATA)
+		binary.Write(cue, binary.LittleEndian, uint32(0))
+		binary.Write(cue, binary.LittleEndian, uint32(0))
+		binary.Write(cue, binary.LittleEndian, c.Position)
+
+		/*
+		 * Labels are stored as null-terminated strings.
+		 */
+		if c.Label != "" {
+			body := make([]byte, 4, 4+len(c.Label)+1)
+			binary.LittleEndian.PutUint32(body, c.ID)
+			body = append(body, c.Label...)
+			body = append(body, 0)
+			appendChunk(labels, ID_LABEL, body)
+		}
+
+	}
+
+	appendChunk(buf, ID_CUE, cue.Bytes())
+
+	/*
+	 * Only write the list if any cue point has a label.
+	 */
+	if labels.Len() > 4 {
+		appendChunk(buf, ID_LIST, labels.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+/*
+ * Finishes the file by writing trailing chunks and the final chunk sizes.
+ *
+ * The underlying file is closed if the writer was created from a path.
+ */
+func (this *Writer) Close() error {
+
+	/*
+	 * Closing twice has no effect.
+	 */
+	if this.closed {
+		return nil
+	}
+
+	this.closed = true
+	err := this.finish()
+
+	/*
+	 * Close the file even if the file could not be finished.
+	 */
+	if this.closer != nil {
+		errClose := this.closer.Close()
+
+		if err == nil && errClose != nil {
+			msg := errClose.Error()
+			err = fmt.Errorf("Failed to close wave file: %s", msg)
+		}
+
+	}
+
+	return err
+}
+
+/*
+ * Writes trailing chunks and patches the chunk sizes.
+ */
+func (this *Writer) finish() error {
+	trailer := &bytes.Buffer{}
+
+	/*
+	 * The data chunk must be padded to an even size.
+	 */
+	if this.dataSize%2 != 0 {
+		trailer.WriteByte(0)
+	}
+
+	trailer.Write(this.cueChunks())
+	end := this.dataStart + this.dataSize + int64(trailer.Len())
+
+	/*
+	 * Check that the sizes fit into the header.
+	 */
+	if end-8 > MAX_RIFF_SIZE {
+		return fmt.Errorf("%s", "Wave file exceeds 4 GiB.")
+	}
+
+	_, err := this.writer.Write(trailer.Bytes())
+
+	/*
+	 * Check if trailing chunks could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write trailing chunks: %s", msg)
+	}
+
+	return this.patchSizes(end, end)
+}
+
+/*
+ * Writes the sizes of the RIFF and data chunks for a file ending at a
+ * certain offset and moves the stream to another offset.
+ */
+func (this *Writer) patchSizes(end int64, position int64) error {
+	sizes := []struct {
+		offset int64
+		value  uint32
+	}{
+		{4, uint32(end - 8)},
+		{this.dataStart - 4, uint32(this.dataSize)},
+	}
+
+	/*
+	 * Patch the size of the RIFF and data chunks.
+	 */
+	for _, size := range sizes {
+		_, err := this.writer.Seek(size.offset, io.SeekStart)
+
+		if err == nil {
+			err = binary.Write(this.writer, binary.LittleEndian, size.value)
+		}
+
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to update chunk sizes: %s", msg)
+		}
+
+	}
+
+	_, err := this.writer.Seek(position, io.SeekStart)
+
+	/*
+	 * Continue writing where the stream was left.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to seek to end of file: %s", msg)
+	}
+
+	return nil
+}
+
+/*
+ * Creates a writer producing a wave file in a stream.
+ */
+func CreateWriter(w io.WriteSeeker, format Format) (*Writer, error) {
+	err := format.Validate()
+
+	/*
+	 * Check if the format can be written.
+	 */
+	if err != nil {
+		return nil, err
+	}
+
+	/*
+	 * The sizes are left open until they are first updated, so readers
+	 * take the samples up to the end of the file.
+	 */
+	buf := &bytes.Buffer{}
+	buf.WriteString(ID_RIFF)
+	binary.Write(buf, binary.LittleEndian, uint32(MAX_RIFF_SIZE))
+	buf.WriteString(ID_WAVE)
+	appendChunk(buf, ID_FORMAT, formatChunk(format))
+	buf.WriteString(ID_DATA)
+	binary.Write(buf, binary.LittleEndian, uint32(MAX_RIFF_SIZE))
+	start, err := w.Seek(0, io.SeekCurrent)
+
+	/*
+	 * Sizes are patched relative to the start of the stream.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to determine stream position: %s", msg)
+	} else if start != 0 {
+		return nil, fmt.Errorf("%s", "Wave file must start at the beginning of the stream.")
+	}
+
+	_, err = w.Write(buf.Bytes())
+
+	/*
+	 * Check if header could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to write header: %s", msg)
+	}
+
+	/*
+	 * Create data structure for a writer.
+	 */
+	writer := &Writer{
+		writer:    w,
+		format:    format,
+		dataStart: int64(buf.Len()),
+	}
+
+	return writer, nil
+}
+
+/*
+ * Creates a wave file for writing.
+ */
+func Create(path string, format Format) (*Writer, error) {
+	file, err := os.Create(path)
+
+	/*
+	 * Check if file could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create wave file '%s': %s", path, msg)
+	}
+
+	writer, err := CreateWriter(file, format)
+
+	/*
+	 * Check if header could be written.
+	 */
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	writer.closer = file
+	return writer, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/melody"
+	"github.com/metalblueberry/bard/pkg/practice"
+	"github.com/metalblueberry/bard/pkg/visualizer"
+	"github.com/metalblueberry/bard/pkg/visualizer/window"
+)
+
+func runPractice(args []string) error {
+	fs := newFlagSet("practice")
+	shared := addSharedFlags(fs)
+	defaults := practice.DefaultConfig()
+	part := fs.Int("part", 0, "part of the melody to practice, counting the tracks or parts with notes from 0")
+	wait := fs.Bool("wait", false, "stop at every note until it is played instead of keeping time, W toggles it in the window")
+	loop := fs.Bool("loop", false, "repeat the section until the window is closed, L toggles it in the window")
+	from := fs.Duration("from", 0, "start of the section practiced, [ sets it in the window")
+	to := fs.Duration("to", 0, "end of the section practiced, 0 practices to the end, ] sets it in the window")
+	speed := fs.Float64("speed", defaults.Speed, "speed of the melody between 0.25 and 2, the up and down arrows change it in the window")
+	timingWindow := fs.Duration("window", defaults.TimingWindow, "how early or late a note may be played to hit it")
+	tolerance := fs.Float64("tolerance", defaults.Tolerance, "cents a note may be out of tune to hit it")
+	latency := fs.Duration("latency", 0, "delay of the sound card and the pitch detection, subtracted from the time notes are played at")
+	leadIn := fs.Duration("lead-in", defaults.LeadIn, "time before the section each pass starts at")
+	lookahead := fs.Duration("lookahead", visualizer.DefaultPracticeOptions().Lookahead, "time of the melody shown ahead of the hit line")
+	inputFile := fs.String("file", "", "play along with an audio file (WAV, FLAC, Ogg Vorbis or MP3) instead of the sound card")
+	synth := fs.Float64("synth", 0, "play a sine wave of this frequency instead of the sound card")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+	m, err := melody.Load(fs.Arg(0), *part)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d notes in %s", m.Title, len(m.Notes), m.Duration().Round(100*time.Millisecond))
+
+	config := practice.Config{
+		TimingWindow: *timingWindow,
+		Tolerance:    *tolerance,
+		Latency:      *latency,
+		LeadIn:       *leadIn,
+		Speed:        *speed,
+		Wait:         *wait,
+		From:         *from,
+		To:           *to,
+		Loop:         *loop,
+	}
+	s, err := practice.Create(m, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	play := func(tee *visualizer.Tee) error {
+		defer tee.Close()
+		go func() {
+			if err := tee.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Println(err)
+			}
+		}()
+
+		options := visualizer.DefaultPracticeOptions()
+		options.Title = "bard practice: " + m.Title
+		options.Lookahead = *lookahead
+		options.Interpolate = true
+		game, err := visualizer.CreatePractice(ctx, tee, s, options)
+		if err != nil {
+			return err
+		}
+		err = window.RunPractice(ctx, tee, game, options)
+		if best := s.Best(); best.Pass > 0 {
+			log.Printf("best pass %d: score %d, %d of %d notes hit, accuracy %.0f%%",
+				best.Pass, best.Score, best.Hits, best.Notes, 100*best.Accuracy)
+		}
+		return err
+	}
+
+	switch {
+	case *inputFile != "":
+		file, err := openFile(*inputFile)
+		if err != nil {
+			return err
+		}
+		return play(visualizer.CreateTee(audio.CreateThrottle(file), nil))
+	case *synth > 0:
+		sine := audio.CreateSine(synthFormat(settings), 0, *synth, 0.1)
+		return play(visualizer.CreateTee(audio.CreateThrottle(sine), nil))
+	default:
+		return withPortAudio(func() error {
+			stream, err := openStream(settings, streamOptions{input: true})
+			if err != nil {
+				return err
+			}
+			return play(visualizer.CreateTee(stream, nil))
+		})
+	}
+}
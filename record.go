@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+)
+
+func runRecord(args []string) error {
+	fs := newFlagSet("record")
+	shared := addSharedFlags(fs)
+	channels := fs.Int("channels", 1, "number of channels to record")
+	duration := fs.Duration("duration", 0, "stop after this long, 0 records until interrupted")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	return withPortAudio(func() error {
+		stream, err := openStream(settings, streamOptions{input: true, channels: *channels})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		sink, err := audio.CreateFile(fs.Arg(0), stream.Format())
+		if err != nil {
+			return err
+		}
+		log.Printf("recording %s to %s", stream.Format(), fs.Arg(0))
+		start := time.Now()
+		err = audio.Pump(ctx, stream, sink, 0, nil)
+		if ctx.Err() != nil {
+			err = nil
+		}
+		log.Printf("recorded %s", time.Since(start).Round(time.Millisecond))
+		if errClose := sink.Close(); err == nil {
+			err = errClose
+		}
+		return err
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/timeline"
+)
+
+// noteEvent is a note held for some time.
+type noteEvent struct {
+	note  string
+	start float64
+	end   float64
+}
+
+func runTranscribe(args []string) error {
+	fs := newFlagSet("transcribe")
+	settings := addTimelineFlags(fs)
+	minDuration := fs.Duration("min-duration", 100*time.Millisecond, "drop notes shorter than this")
+	if err := parseArgs(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	config := settings.config()
+	var current *noteEvent
+	flush := func() {
+		if current != nil && current.end-current.start >= minDuration.Seconds() {
+			fmt.Printf("%8.3f %8.3f %s\n", current.start, current.end-current.start, current.note)
+		}
+		current = nil
+	}
+
+	fmt.Printf("%8s %8s %s\n", "START", "LENGTH", "NOTE")
+	err := analyzeFile(fs.Arg(0), config, func(entry timeline.Entry) error {
+		if current != nil && current.note == entry.Note {
+			current.end = entry.Time
+			return nil
+		}
+		flush()
+		if entry.Voiced {
+			current = &noteEvent{note: entry.Note, start: entry.Time - config.Hop.Seconds(), end: entry.Time}
+		}
+		return nil
+	})
+	flush()
+	return err
+}
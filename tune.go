@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/denoise"
+	"github.com/metalblueberry/bard/pkg/tuner"
+)
+
+// liveTuner feeds a stream into a tuner and analyzes it periodically, so the
+// expensive analysis never holds up the audio.
+type liveTuner struct {
+	*tuner.Tuner
+
+	// the denoiser must see every sample, so it runs on each block
+	denoiser *denoise.Denoiser
+	lock     sync.Mutex
+	mono     []float64
+}
+
+func newLiveTuner(analysisRate uint, denoiser *denoise.Denoiser) *liveTuner {
+	t := tuner.Create()
+	t.SetPreprocessing(tuner.DefaultPreprocessConfig())
+	t.SetAnalysisRate(uint32(analysisRate))
+	return &liveTuner{
+		Tuner:    t,
+		denoiser: denoiser,
+	}
+}
+
+// loadProfile loads a noise profile, an empty path disables noise removal.
+func loadProfile(path string) (*denoise.Profile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return denoise.LoadProfile(path)
+}
+
+// createDenoiser removes the noise of a profile from a stream, nil when
+// there is no profile. The profile must have the rate of the stream.
+func createDenoiser(profile *denoise.Profile, format audio.Format) (*denoise.Denoiser, error) {
+	if profile == nil {
+		return nil, nil
+	}
+	return denoise.CreateDenoiser(profile, format.SampleRate, denoise.DefaultConfig())
+}
+
+// Process streams a block of interleaved samples into the tuner.
+func (l *liveTuner) Process(block []float32, format audio.Format) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.mono = audio.Downmix(block, format.Channels, l.mono[:0])
+	if l.denoiser != nil {
+		l.denoiser.Process(l.mono)
+	}
+	l.Tuner.Process(l.mono, uint32(format.SampleRate))
+}
+
+// Run analyzes the stream at every interval and reports each new note until
+// the context is cancelled. Silence is reported as a nil result.
+func (l *liveTuner) Run(ctx context.Context, interval time.Duration, report func(result *tuner.Result)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := ""
+	for {
+		select {
+		case <-ticker.C:
+			if !l.Voiced() {
+				if previous != "" {
+					report(nil)
+					previous = ""
+				}
+				continue
+			}
+			result, err := l.Analyze()
+			if err != nil {
+				return err
+			}
+			if result.Note() != previous {
+				report(result)
+				previous = result.Note()
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func printResult(result *tuner.Result) {
+	if result == nil {
+		fmt.Println("-")
+		return
+	}
+	fmt.Printf("%-4s %8.2f Hz %+4d cents\n", result.Note(), result.Frequency(), result.Cents())
+}
+
+func runTune(args []string) error {
+	fs := newFlagSet("tune")
+	shared := addSharedFlags(fs)
+	noisePath := fs.String("noise", "", "noise profile removed before pitch detection, see visualize -calibrate")
+	analysisRate := fs.Uint("analysis-rate", 0, "sample rate the tuner analyzes at, 0 uses the device rate")
+	interval := fs.Duration("interval", 100*time.Millisecond, "time between analyses")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+	profile, err := loadProfile(*noisePath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	return withPortAudio(func() error {
+		stream, err := openStream(settings, streamOptions{input: true})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		denoiser, err := createDenoiser(profile, stream.Format())
+		if err != nil {
+			return err
+		}
+		live := newLiveTuner(*analysisRate, denoiser)
+		tap := func(block []float32) {
+			live.Process(block, stream.Format())
+		}
+		go func() {
+			if err := audio.Pump(ctx, stream, nil, 0, tap); err != nil && ctx.Err() == nil {
+				log.Println(err)
+			}
+		}()
+		return live.Run(ctx, *interval, printResult)
+	})
+}
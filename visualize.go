@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/metalblueberry/bard/pkg/audio"
+	"github.com/metalblueberry/bard/pkg/canvas"
+	"github.com/metalblueberry/bard/pkg/colormap"
+	"github.com/metalblueberry/bard/pkg/config"
+	"github.com/metalblueberry/bard/pkg/denoise"
+	"github.com/metalblueberry/bard/pkg/session"
+	"github.com/metalblueberry/bard/pkg/visualizer"
+	"github.com/metalblueberry/bard/pkg/visualizer/window"
+)
+
+func runVisualize(args []string) error {
+	fs := newFlagSet("visualize")
+	shared := addSharedFlags(fs)
+	noisePath := fs.String("noise", "", "noise profile to remove from the display, learned with -calibrate")
+	calibrate := fs.Duration("calibrate", 0, "record room noise for this long and store it as the -noise profile")
+	inputFile := fs.String("file", "", "replay an audio file (WAV, FLAC, Ogg Vorbis or MP3) instead of the sound card")
+	framesPath := fs.String("frames", "", "tuner frames recorded with -file, defaults to the session sidecar next to it")
+	speed := fs.Float64("speed", 1, "replay speed of -file between 0.5 and 2, the pitch is preserved")
+	play := fs.Bool("play", false, "play -file on the output device while replaying it")
+	synth := fs.Float64("synth", 0, "analyze a sine wave of this frequency instead of the sound card")
+	record := fs.Bool("record", false, "start recording the session at once, R toggles recording in the window")
+	recordDir := fs.String("record-dir", ".", "directory sessions are recorded into, as a wave file and a JSON lines file of tuner frames")
+	panel := fs.String("panel", visualizer.PanelBars, "panel shown by the panel view of the layout, one of "+strings.Join(visualizer.Panels(), ", ")+", tab cycles them in the window")
+	scale := fs.String("scale", "", "scale of the notes shown, one of "+strings.Join(visualizer.Scales(), ", ")+", N cycles them in the window")
+	root := fs.String("root", "", "root of the scale, like C, F# or Bb, the brackets move it in the window")
+	lowNote := fs.String("low", "", "lowest note shown, like C4, comma and period shift the range in the window")
+	highNote := fs.String("high", "", "highest note shown, like E6")
+	customNotes := fs.String("notes", "", "comma-separated notes of the custom scale, pitches without octave are shown in every octave")
+	threshold := fs.Float64("threshold", denoise.DefaultFloorConfig().Threshold, "level in dB a note must peak over the noise floor by to count as played, minus and equals change it in the window")
+	learnFloor := fs.Duration("learn-floor", 0, "learn the noise floor for this long at the start instead of tracking it, keep quiet meanwhile, F learns it in the window")
+	gain := fs.Float64("gain", 0, "gain of the analyzed input in dB, the display is amplified but not the monitor output or recordings, G changes it in the window")
+	fftSize := fs.Int("fft-size", visualizer.DefaultFFTSize, "samples analyzed per frame, a power of two, longer resolves low notes better but reacts slower, page up and down change it in the window")
+	hud := fs.Bool("hud", false, "show the settings, latency and frame times over the display, H toggles it in the window")
+	layout := fs.String("layout", "", "arrangement of the views, rows top to bottom separated by semicolons of views separated by commas, like roll;wave;2*panel, changes made in the window are stored in the configuration")
+	key := fs.String("key", visualizer.DefaultKey, "major key the staff is written in, one of "+strings.Join(visualizer.Keys(), ", ")+", K cycles them in the window")
+	waterfall := fs.Bool("waterfall", false, "show the waterfall panel, same as -panel waterfall, W toggles it in the window")
+	axis := fs.String("axis", visualizer.AxisNotes, "frequency axis of the waterfall, notes or log, L toggles it in the window")
+	colorMap := fs.String("colormap", colormap.DEFAULT, "colour map of the waterfall, one of "+strings.Join(colormap.Names(), ", ")+", C cycles them in the window")
+	floor := fs.Float64("floor", visualizer.DefaultWaterfallOptions().Floor, "level in dB shown as the lowest colour of the waterfall")
+	history := fs.Int("history", visualizer.DefaultHistory, "analysis frames shown by the waterfall")
+	analysisFPS := fs.Float64("analysis-fps", visualizer.DefaultAnalysisRate, "analyses per second of the window, independent of its frame rate, -render analyzes once per frame")
+	renderPath := fs.String("render", "", "render frames of -file or -synth without a window, into an animated GIF if the path ends in .gif, otherwise into a directory of PNG files")
+	frameRate := fs.Float64("fps", 30, "frames per second of audio rendered by -render")
+	duration := fs.Duration("duration", 0, "length of the audio rendered by -render, 0 renders the whole file")
+	if err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	settings, err := shared.load()
+	if err != nil {
+		return err
+	}
+	settings.Override(config.Config{Scale: *scale, Root: *root, LowNote: *lowNote, HighNote: *highNote, Notes: *customNotes, Layout: *layout})
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	displayOptions := func() (visualizer.Options, error) {
+		options := visualizer.DefaultOptions()
+		if settings.Layout != "" {
+			layout, err := visualizer.ParseLayout(settings.Layout)
+			if err != nil {
+				return options, err
+			}
+			options.Layout = layout
+		}
+		options.History = *history
+		options.AnalysisRate = *analysisFPS
+		options.Notes = noteSet(settings)
+		options.Floor.Threshold = *threshold
+		options.LearnFloor = *learnFloor
+		options.Gain = *gain
+		options.FFTSize = *fftSize
+		options.HUD = *hud
+		options.Panel = *panel
+		options.Key = *key
+		if *waterfall {
+			options.Panel = visualizer.PanelWaterfall
+		}
+		options.Waterfall.Axis = *axis
+		options.Waterfall.ColorMap = *colorMap
+		options.Waterfall.Floor = *floor
+		return options, nil
+	}
+
+	if *renderPath != "" {
+		if *calibrate > 0 {
+			return errors.New("-calibrate needs live input and cannot be used with -render")
+		}
+		options, err := displayOptions()
+		if err != nil {
+			return err
+		}
+		if *noisePath != "" {
+			profile, err := denoise.LoadProfile(*noisePath)
+			if err != nil {
+				return err
+			}
+			options.Noise = profile
+		}
+		headless := visualizer.DefaultHeadlessOptions()
+		headless.FrameRate = *frameRate
+		headless.Duration = *duration
+
+		var src audio.Source
+		switch {
+		case *inputFile != "":
+			file, err := openFile(*inputFile)
+			if err != nil {
+				return err
+			}
+			src = file
+		case *synth > 0:
+			if *duration <= 0 {
+				return errors.New("-render of -synth needs a -duration")
+			}
+			src = audio.CreateSine(synthFormat(settings), 0, *synth, 0.1)
+		default:
+			return errors.New("-render needs -file or -synth")
+		}
+		return renderFrames(ctx, visualizer.CreateTee(src, nil), options, headless, *renderPath)
+	}
+
+	var player *audio.Player
+	var frames []session.Frame
+
+	show := func(tee *visualizer.Tee) error {
+		defer tee.Close()
+		go func() {
+			if err := tee.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Println(err)
+			}
+		}()
+
+		options, err := displayOptions()
+		if err != nil {
+			return err
+		}
+		options.SaveLayout = func(layout visualizer.Layout) error {
+			path, err := shared.save(func(c *config.Config) {
+				c.Layout = layout.String()
+			})
+			if err == nil {
+				log.Printf("layout %s stored in %s", layout, path)
+			}
+			return err
+		}
+		options.Record = *record
+		options.RecordDir = *recordDir
+		options.Player = player
+		options.Frames = frames
+		if *calibrate > 0 {
+			log.Printf("recording room noise for %s, keep quiet", *calibrate)
+			profile, err := tee.Calibrate(*calibrate)
+			if err != nil {
+				return err
+			}
+			if *noisePath != "" {
+				if err := profile.Save(*noisePath); err != nil {
+					return err
+				}
+			}
+			options.Noise = profile
+		} else if *noisePath != "" {
+			profile, err := denoise.LoadProfile(*noisePath)
+			if err != nil {
+				return err
+			}
+			options.Noise = profile
+		}
+		return window.Run(ctx, tee, options)
+	}
+
+	switch {
+	case *inputFile != "":
+		file, err := openFile(*inputFile)
+		if err != nil {
+			return err
+		}
+		player, err = audio.CreatePlayer(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if err := player.SetSpeed(*speed); err != nil {
+			player.Close()
+			return err
+		}
+		frames, err = loadFrames(*inputFile, *framesPath)
+		if err != nil {
+			player.Close()
+			return err
+		}
+		if !*play {
+			return show(visualizer.CreateTee(audio.CreateThrottle(player), nil))
+		}
+		return withPortAudio(func() error {
+			// the device paces the replay at the rate of the file
+			playback := *settings
+			playback.SampleRate = player.Format().SampleRate
+			stream, err := openStream(&playback, streamOptions{output: true, channels: player.Format().Channels})
+			if err != nil {
+				player.Close()
+				return err
+			}
+			defer stream.Close()
+			return show(visualizer.CreateTee(player, stream))
+		})
+	case *synth > 0:
+		sine := audio.CreateSine(synthFormat(settings), 0, *synth, 0.1)
+		return show(visualizer.CreateTee(audio.CreateThrottle(sine), nil))
+	default:
+		return withPortAudio(func() error {
+			// monitor the input on the output
+			stream, err := openStream(settings, streamOptions{input: true, output: true})
+			if err != nil {
+				return err
+			}
+			return show(visualizer.CreateTee(stream, stream))
+		})
+	}
+}
+
+// loadFrames reads the tuner frames recorded with a replayed file. Without an
+// explicit path the session sidecar is used if there is one.
+func loadFrames(audioPath, path string) ([]session.Frame, error) {
+	if path == "" {
+		path = session.FramesPath(audioPath)
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+	frames, err := session.ReadFrames(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("loaded %d recorded frames from %s", len(frames), path)
+	return frames, nil
+}
+
+// synthFormat is the format of the -synth sine wave, at the configured
+// sample rate if there is one.
+func synthFormat(settings *config.Config) audio.Format {
+	format := audio.Format{SampleRate: 44100, Channels: 1}
+	if settings.SampleRate > 0 {
+		format.SampleRate = settings.SampleRate
+	}
+	return format
+}
+
+// renderFrames renders the visualizer headless into a GIF or PNG sequence.
+func renderFrames(ctx context.Context, tee *visualizer.Tee, options visualizer.Options, headless visualizer.HeadlessOptions, path string) error {
+	defer tee.Close()
+	w, err := canvas.CreateFrameWriter(path, headless.FrameRate)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	n, err := visualizer.Render(ctx, tee, options, headless, w)
+	if errClose := w.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("rendered %d frames to %s in %s", n, path, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// noteSet returns the notes shown by the visualizer as configured, falling
+// back to its defaults. Custom notes select the custom scale unless another
+// scale is given.
+func noteSet(settings *config.Config) visualizer.NoteSet {
+	set := visualizer.DefaultNoteSet()
+	if settings.Notes != "" {
+		set.Scale = visualizer.ScaleCustom
+		set.Custom = strings.Split(settings.Notes, ",")
+	}
+	if settings.Scale != "" {
+		set.Scale = settings.Scale
+	}
+	if settings.Root != "" {
+		set.Root = settings.Root
+	}
+	if settings.LowNote != "" {
+		set.Low = settings.LowNote
+	}
+	if settings.HighNote != "" {
+		set.High = settings.HighNote
+	}
+	return set
+}